@@ -17,6 +17,7 @@ import (
 	"github.com/aiflowy/aiflowy-go/internal/middleware"
 	"github.com/aiflowy/aiflowy-go/internal/repository"
 	"github.com/aiflowy/aiflowy-go/internal/router"
+	"github.com/aiflowy/aiflowy-go/internal/service"
 	"github.com/aiflowy/aiflowy-go/internal/service/tool/builtin"
 	"github.com/aiflowy/aiflowy-go/pkg/logger"
 	"github.com/aiflowy/aiflowy-go/pkg/metrics"
@@ -98,6 +99,11 @@ func main() {
 		zap.Int("count", len(builtin.GetBuiltinTools())),
 	)
 
+	// Recover workflow executions left running by the previous process
+	if err := service.GetChainExecutor().RecoverExecutions(context.Background()); err != nil {
+		logger.Error("Failed to recover workflow executions", zap.Error(err))
+	}
+
 	// Create Echo instance
 	e := echo.New()
 	e.HideBanner = true
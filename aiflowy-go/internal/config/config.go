@@ -18,6 +18,10 @@ type Config struct {
 	Snowflake SnowflakeConfig `mapstructure:"snowflake"`
 	Storage   StorageConfig   `mapstructure:"storage"`
 	Security  SecurityConfig  `mapstructure:"security"`
+	Quota     QuotaConfig     `mapstructure:"quota"`
+
+	// Datasources 工作流 SQL 节点可访问的外部数据源，按名称引用
+	Datasources map[string]DatasourceConfig `mapstructure:"datasources"`
 }
 
 type ServerConfig struct {
@@ -39,6 +43,12 @@ type DatabaseConfig struct {
 	ConnMaxLifetime int    `mapstructure:"conn_max_lifetime"`
 }
 
+// DatasourceConfig 工作流 SQL 节点的外部数据源，AllowWrite 为 true 时节点才能关闭只读
+type DatasourceConfig struct {
+	DatabaseConfig `mapstructure:",squash"`
+	AllowWrite     bool `mapstructure:"allow_write"`
+}
+
 type RedisConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
@@ -75,6 +85,20 @@ type SecurityConfig struct {
 	ApiKeyMasterKey string `mapstructure:"api_key_master_key"` // Bot API Key 加密主密钥 (32字节)
 }
 
+// QuotaConfig 大模型用量配额，超出后拒绝对话
+type QuotaConfig struct {
+	User   QuotaLimit `mapstructure:"user"`   // 每个用户
+	Tenant QuotaLimit `mapstructure:"tenant"` // 每个租户
+}
+
+// QuotaLimit 每日和每月的 token 数及费用上限，0 表示不限制
+type QuotaLimit struct {
+	DailyTokens   int64   `mapstructure:"daily_tokens"`
+	MonthlyTokens int64   `mapstructure:"monthly_tokens"`
+	DailyCost     float64 `mapstructure:"daily_cost"`
+	MonthlyCost   float64 `mapstructure:"monthly_cost"`
+}
+
 // DSN returns the database connection string
 func (c *DatabaseConfig) DSN() string {
 	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=%s&parseTime=True&loc=Local",
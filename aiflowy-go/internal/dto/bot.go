@@ -109,3 +109,21 @@ type ModelInfo struct {
 	ModelName    string `json:"modelName"`
 	ProviderType string `json:"providerType"`
 }
+
+// Token usage summary dimensions
+const (
+	TokenUsageGroupUser   = "user"
+	TokenUsageGroupBot    = "bot"
+	TokenUsageGroupModel  = "model"
+	TokenUsageGroupTenant = "tenant"
+)
+
+// TokenUsageSummaryRequest represents request to roll up token usage
+type TokenUsageSummaryRequest struct {
+	GroupBy   string `query:"groupBy" json:"groupBy"` // user, bot, model or tenant, defaults to model
+	BotID     int64  `query:"botId" json:"botId,string"`
+	AccountID int64  `query:"accountId" json:"accountId,string"`
+	ModelID   int64  `query:"modelId" json:"modelId,string"`
+	StartTime string `query:"startTime" json:"startTime"` // e.g. 2024-05-01 or 2024-05-01 08:00:00
+	EndTime   string `query:"endTime" json:"endTime"`     // A date only includes the whole day
+}
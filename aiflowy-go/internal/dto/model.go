@@ -44,6 +44,10 @@ type ModelSaveRequest struct {
 	SupportVideo        bool   `json:"supportVideo"`
 	SupportAudio        bool   `json:"supportAudio"`
 	SupportFree         bool   `json:"supportFree"`
+
+	InputPrice    float64 `json:"inputPrice"`
+	OutputPrice   float64 `json:"outputPrice"`
+	ContextLength int     `json:"contextLength"`
 }
 
 // ModelListRequest represents request to list models
@@ -1,5 +1,7 @@
 package dto
 
+import "time"
+
 // WorkflowSaveRequest 保存工作流请求
 type WorkflowSaveRequest struct {
 	ID          string `json:"id,omitempty"`
@@ -31,6 +33,7 @@ type BotWorkflowUpdateRequest struct {
 type WorkflowRunRequest struct {
 	ID        string                 `json:"id" validate:"required"`
 	Variables map[string]interface{} `json:"variables,omitempty"`
+	Draft     bool                   `json:"draft,omitempty"` // 运行草稿 (设计器调试)，默认运行发布版本
 }
 
 // WorkflowSingleRunRequest 单节点运行请求
@@ -46,22 +49,183 @@ type WorkflowResumeRequest struct {
 	ConfirmParams map[string]interface{} `json:"confirmParams,omitempty"`
 }
 
+// WorkflowCancelRequest 取消工作流执行请求
+type WorkflowCancelRequest struct {
+	ExecuteID string `json:"executeId" validate:"required"`
+}
+
 // ChainStatusRequest 获取工作流状态请求
 type ChainStatusRequest struct {
 	ExecuteID string      `json:"executeId" validate:"required"`
 	Nodes     []*NodeInfo `json:"nodes,omitempty"`
 }
 
+// WorkflowPublishRequest 发布工作流请求
+type WorkflowPublishRequest struct {
+	ID      string `json:"id" validate:"required"`
+	Comment string `json:"comment,omitempty"` // 发布说明
+}
+
+// WorkflowRollbackRequest 回滚工作流请求
+type WorkflowRollbackRequest struct {
+	ID      string `json:"id" validate:"required"`
+	Version int    `json:"version" validate:"required"` // 回滚到的版本号
+}
+
+// ========================== 执行记录 ==========================
+
+// WorkflowExecListRequest 执行记录分页查询请求，时间范围按执行开始时间过滤
+type WorkflowExecListRequest struct {
+	PageRequest
+	WorkflowID      int64  `query:"workflowId" json:"workflowId,string"`
+	Status          *int   `query:"status" json:"status"`
+	CreatedKey      string `query:"createdKey" json:"createdKey"`           // 执行人标识 (用户 ID)
+	StartTime       string `query:"startTime" json:"startTime"`             // 如 2024-05-01 或 2024-05-01 08:00:00
+	EndTime         string `query:"endTime" json:"endTime"`                 // 只有日期时包含当天
+	IncludeChildren bool   `query:"includeChildren" json:"includeChildren"` // 包含子工作流的执行记录
+}
+
+// WorkflowRerunRequest 重新执行请求：stepId 为空时使用相同输入重新执行，
+// 否则恢复该步骤开始时的变量，从该步骤的节点继续执行
+type WorkflowRerunRequest struct {
+	ExecuteID string `json:"executeId" validate:"required"`
+	StepID    int64  `json:"stepId,string,omitempty"`
+}
+
+// WorkflowExecDetail 执行记录详情与步骤时间线
+type WorkflowExecDetail struct {
+	ID              int64                   `json:"id,string"`
+	ExecKey         string                  `json:"execKey"`
+	WorkflowID      int64                   `json:"workflowId,string"`
+	Title           string                  `json:"title,omitempty"`
+	WorkflowVersion int                     `json:"workflowVersion"` // 0 为草稿
+	Status          int                     `json:"status"`
+	Input           interface{}             `json:"input,omitempty"`
+	Output          interface{}             `json:"output,omitempty"`
+	ErrorInfo       string                  `json:"errorInfo,omitempty"`
+	StartTime       time.Time               `json:"startTime"`
+	EndTime         *time.Time              `json:"endTime,omitempty"`
+	Duration        int64                   `json:"duration"` // 毫秒，未结束时为 0
+	Tokens          int64                   `json:"tokens,omitempty"`
+	CreatedKey      string                  `json:"createdKey,omitempty"`
+	CreatedBy       string                  `json:"createdBy,omitempty"`
+	ParentExecKey   string                  `json:"parentExecKey,omitempty"`
+	Steps           []*WorkflowExecStepInfo `json:"steps"`
+}
+
+// WorkflowExecStepInfo 时间线中的一个步骤 (节点的一次尝试)
+type WorkflowExecStepInfo struct {
+	ID           int64       `json:"id,string"`
+	NodeID       string      `json:"nodeId"`
+	NodeName     string      `json:"nodeName"`
+	NodeType     string      `json:"nodeType,omitempty"`
+	Attempt      int         `json:"attempt"`
+	Status       int         `json:"status"`
+	Input        interface{} `json:"input,omitempty"` // 节点开始时的变量
+	Output       interface{} `json:"output,omitempty"`
+	ErrorInfo    string      `json:"errorInfo,omitempty"`
+	Logs         string      `json:"logs,omitempty"`
+	StartTime    time.Time   `json:"startTime"`
+	EndTime      *time.Time  `json:"endTime,omitempty"`
+	Duration     int64       `json:"duration"`               // 毫秒，未结束时为 0
+	ChildExecKey string      `json:"childExecKey,omitempty"` // 子工作流节点发起的执行
+	Rerunnable   bool        `json:"rerunnable"`             // 可从该步骤重新执行
+}
+
+// ========================== 工作流版本 ==========================
+
+// 版本差异类型
+const (
+	DiffAdded    = "added"
+	DiffRemoved  = "removed"
+	DiffModified = "modified"
+)
+
+// WorkflowVersionDiff 两个版本之间的差异 (版本号 0 表示草稿)
+type WorkflowVersionDiff struct {
+	From     int                 `json:"from"`
+	To       int                 `json:"to"`
+	Nodes    []*WorkflowNodeDiff `json:"nodes"`
+	Edges    []*WorkflowEdgeDiff `json:"edges"`
+	Settings []string            `json:"settings,omitempty"` // 变化的工作流级配置，如 timeout
+}
+
+// WorkflowNodeDiff 节点差异
+type WorkflowNodeDiff struct {
+	NodeID string        `json:"nodeId"`
+	Name   string        `json:"name,omitempty"`
+	Type   string        `json:"type"`
+	Change string        `json:"change"`           // added, removed, modified
+	Fields []string      `json:"fields,omitempty"` // 修改的字段，如 name、data.prompt
+	Before *WorkflowNode `json:"before,omitempty"`
+	After  *WorkflowNode `json:"after,omitempty"`
+}
+
+// WorkflowEdgeDiff 连线差异，连线按 source、sourcePort、target 识别
+type WorkflowEdgeDiff struct {
+	Change string        `json:"change"` // added, removed, modified
+	Before *WorkflowEdge `json:"before,omitempty"`
+	After  *WorkflowEdge `json:"after,omitempty"`
+}
+
+// ========================== 工作流校验 ==========================
+
+// WorkflowValidateRequest 校验工作流请求：content 为空时校验工作流的草稿
+type WorkflowValidateRequest struct {
+	ID      string `json:"id,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// 校验问题级别：error 会导致工作流无法发布与执行，warning 仅作提示 (unresolved_reference 同样拒绝发布)
+const (
+	ValidationLevelError   = "error"
+	ValidationLevelWarning = "warning"
+)
+
+// 校验问题类型
+const (
+	ValidationInvalidJSON         = "invalid_json"         // 内容不是合法的工作流 JSON
+	ValidationInvalidStructure    = "invalid_structure"    // 缺少开始/结束节点、节点 ID 无效等
+	ValidationDanglingEdge        = "dangling_edge"        // 连线的节点不存在或跨越循环体
+	ValidationUnknownNodeType     = "unknown_node_type"    // 未知的节点类型
+	ValidationMissingConfig       = "missing_config"       // 缺少必填的节点配置
+	ValidationInvalidExpression   = "invalid_expression"   // 条件表达式语法错误
+	ValidationInvalidScript       = "invalid_script"       // 代码节点脚本语法错误
+	ValidationCycle               = "cycle"                // 循环节点之外的环路
+	ValidationUnreachable         = "unreachable"          // 开始节点无法到达的节点
+	ValidationUnresolvedReference = "unresolved_reference" // 引用的变量没有上游节点产生或有多个节点产生
+	ValidationLegacyReference     = "legacy_reference"     // 使用旧的 ${field} 引用节点输出
+	ValidationTypeMismatch        = "type_mismatch"        // 参数类型不匹配
+)
+
+// WorkflowValidationIssue 校验发现的问题
+type WorkflowValidationIssue struct {
+	Level   string `json:"level"` // error, warning
+	Code    string `json:"code"`
+	NodeID  string `json:"nodeId,omitempty"`
+	Source  string `json:"source,omitempty"` // 连线的问题：源节点 ID
+	Target  string `json:"target,omitempty"` // 连线的问题：目标节点 ID
+	Field   string `json:"field,omitempty"`  // 相关的节点配置字段
+	Message string `json:"message"`
+}
+
+// WorkflowValidationResult 工作流校验结果，存在 error 级别的问题时 valid 为 false
+type WorkflowValidationResult struct {
+	Valid  bool                       `json:"valid"`
+	Issues []*WorkflowValidationIssue `json:"issues"`
+}
+
 // ========================== 工作流 DSL 相关 ==========================
 
 // WorkflowDefinition 工作流定义 (DSL)
 type WorkflowDefinition struct {
-	ID          string            `json:"id,omitempty"`
-	Name        string            `json:"name,omitempty"`
-	Description string            `json:"description,omitempty"`
-	Nodes       []*WorkflowNode   `json:"nodes,omitempty"`
-	Edges       []*WorkflowEdge   `json:"edges,omitempty"`
-	Variables   map[string]string `json:"variables,omitempty"`
+	ID          string                 `json:"id,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Nodes       []*WorkflowNode        `json:"nodes,omitempty"`
+	Edges       []*WorkflowEdge        `json:"edges,omitempty"`
+	Variables   map[string]interface{} `json:"variables,omitempty"` // 全局变量及初始值，通过 ${name} 引用
+	Timeout     int                    `json:"timeout,omitempty"`   // 执行超时 (秒)，暂停等待期间不计入
 }
 
 // WorkflowNode 工作流节点
@@ -72,6 +236,7 @@ type WorkflowNode struct {
 	Data       map[string]interface{} `json:"data,omitempty"`     // 节点配置数据
 	Position   *NodePosition          `json:"position,omitempty"` // 可视化位置
 	Parameters []*WorkflowParameter   `json:"parameters,omitempty"`
+	ParentID   string                 `json:"parentId,omitempty"` // 所属循环节点 ID (循环体内的节点)
 }
 
 // WorkflowEdge 工作流边 (连接)
@@ -104,7 +269,7 @@ type WorkflowParameter struct {
 // ChainInfo 工作流执行信息
 type ChainInfo struct {
 	ExecuteID string                 `json:"executeId"`
-	Status    int                    `json:"status"` // 0: pending, 1: running, 2: completed, 3: failed, 4: suspended
+	Status    int                    `json:"status"` // 0: pending, 1: running, 2: completed, 3: failed, 4: suspended, 6: cancelled
 	Message   string                 `json:"message,omitempty"`
 	Result    map[string]interface{} `json:"result,omitempty"`
 	Nodes     map[string]*NodeInfo   `json:"nodes,omitempty"`
@@ -114,7 +279,7 @@ type ChainInfo struct {
 type NodeInfo struct {
 	NodeID               string                 `json:"nodeId"`
 	NodeName             string                 `json:"nodeName,omitempty"`
-	Status               int                    `json:"status"` // 0: pending, 1: running, 2: completed, 3: failed, 4: suspended
+	Status               int                    `json:"status"` // 0: pending, 1: running, 2: completed, 3: failed, 4: suspended, 5: skipped, 6: cancelled
 	Message              string                 `json:"message,omitempty"`
 	Result               map[string]interface{} `json:"result,omitempty"`
 	SuspendForParameters []*WorkflowParameter   `json:"suspendForParameters,omitempty"`
@@ -127,6 +292,8 @@ const (
 	ChainStatusCompleted = 2 // 已完成
 	ChainStatusFailed    = 3 // 执行失败
 	ChainStatusSuspended = 4 // 已挂起 (等待人工确认)
+	ChainStatusSkipped   = 5 // 已跳过 (分支未命中)
+	ChainStatusCancelled = 6 // 已取消
 )
 
 // 节点类型常量
@@ -142,6 +309,46 @@ const (
 	NodeTypePlugin       = "plugin"        // 插件节点
 	NodeTypeDoc          = "doc"           // 文档节点
 	NodeTypeSQL          = "sql"           // SQL 节点
+	NodeTypeLoop         = "loop"          // 循环节点
+	NodeTypeHTTP         = "http"          // HTTP 请求节点
+	NodeTypeKnowledge    = "knowledge"     // 知识库检索节点
+	NodeTypeClassifier   = "classifier"    // 意图分类节点
+	NodeTypeExtractor    = "extractor"     // 参数提取节点
+)
+
+// 节点重试的错误类别 (节点 data.retry.retryOn)
+const (
+	RetryOnTimeout   = "timeout"    // 超时
+	RetryOnNetwork   = "network"    // 网络错误
+	RetryOnRateLimit = "rate_limit" // 限流 (429)
+	RetryOnServer    = "server"     // 服务端错误 (5xx)
+	RetryOnAny       = "any"        // 任意错误
+)
+
+// EdgePortError 错误分支端口：源节点执行失败时走 sourcePort 为 error 的边
+const EdgePortError = "error"
+
+// EdgePortDefault 默认分支：HTTP 节点的状态码没有匹配任何状态码分支 (如 2xx、404)，
+// 或意图分类节点无法归入任何类别时走该端口的边
+const EdgePortDefault = "default"
+
+// 参数提取节点的分支 (输出 condition)
+const (
+	ExtractorBranchComplete = "complete" // 必填参数齐全
+	ExtractorBranchMissing  = "missing"  // 缺少必填参数
+)
+
+// 循环节点模式 (节点 data.mode)
+const (
+	LoopModeArray = "array" // 遍历数组 (data.items)
+	LoopModeWhile = "while" // 条件成立时重复执行 (data.condition)
+)
+
+// 汇聚节点触发模式 (节点 data.joinMode)
+const (
+	JoinModeAll    = "all"     // 等待所有上游节点结束 (默认)
+	JoinModeAny    = "any"     // 任一上游节点完成即触发
+	JoinModeFirstN = "first_n" // 前 N 个上游节点完成即触发 (data.joinCount)
 )
 
 // RunningParametersResponse 运行参数响应
@@ -45,4 +45,6 @@ type BotOptions struct {
 	HistoryCount     int    `json:"historyCount,omitempty"`
 	WelcomeMessage   string `json:"welcomeMessage,omitempty"`
 	SuggestedQuestions []string `json:"suggestedQuestions,omitempty"`
+	// BuiltinTools enables or disables builtin tools by name; tools not listed are enabled
+	BuiltinTools map[string]bool `json:"builtinTools,omitempty"`
 }
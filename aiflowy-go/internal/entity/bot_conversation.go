@@ -13,6 +13,10 @@ type BotConversation struct {
 	Modified   time.Time `json:"modified"`
 	ModifiedBy int64     `json:"modifiedBy,string"`
 
+	// Rolling summary of the turns older than SummaryMessageID
+	Summary          string `json:"summary,omitempty"`
+	SummaryMessageID int64  `json:"summaryMessageId,string,omitempty"`
+
 	// Relations
 	Bot      *Bot          `json:"bot,omitempty"`
 	Messages []BotMessage  `json:"messages,omitempty"`
@@ -36,6 +36,9 @@ type BotMessageOptions struct {
 	ModelName     string      `json:"modelName,omitempty"`
 	FinishReason  string      `json:"finishReason,omitempty"`
 	ThinkingContent string    `json:"thinkingContent,omitempty"`
+
+	// Cost is priced with the model's per-million-token prices
+	Cost float64 `json:"cost,omitempty"`
 }
 
 // TokenUsage represents token usage statistics
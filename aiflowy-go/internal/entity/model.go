@@ -26,6 +26,13 @@ type Model struct {
 	SupportAudio        bool   `json:"supportAudio" db:"support_audio"`
 	SupportFree         bool   `json:"supportFree" db:"support_free"`
 
+	// Prices per million tokens, used to cost chat usage
+	InputPrice  float64 `json:"inputPrice" db:"input_price"`
+	OutputPrice float64 `json:"outputPrice" db:"output_price"`
+
+	// Context window in tokens; 0 falls back to the default chat history budget
+	ContextLength int `json:"contextLength" db:"context_length"`
+
 	// Non-database fields
 	ModelProvider *ModelProvider `json:"modelProvider,omitempty" db:"-"`
 }
@@ -0,0 +1,38 @@
+package entity
+
+import "time"
+
+// TokenUsageRecord is the token usage of one chat turn, summed over its
+// tool-loop iterations
+type TokenUsageRecord struct {
+	ID               int64     `json:"id,string"`
+	TenantID         int64     `json:"tenantId,string"`
+	AccountID        int64     `json:"accountId,string"`
+	BotID            int64     `json:"botId,string"`
+	ModelID          int64     `json:"modelId,string"`
+	ModelName        string    `json:"modelName"`
+	ConversationID   int64     `json:"conversationId,string"`
+	MessageID        int64     `json:"messageId,string"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	Cost             float64   `json:"cost"`
+	Created          time.Time `json:"created"`
+}
+
+// TokenUsageStat is token usage rolled up by one dimension
+type TokenUsageStat struct {
+	ID               int64   `json:"id,string"` // User, bot, model or tenant ID
+	ModelName        string  `json:"modelName,omitempty"`
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// TokenUsageTotal is the usage of a quota scope over a period
+type TokenUsageTotal struct {
+	Tokens int64
+	Cost   float64
+}
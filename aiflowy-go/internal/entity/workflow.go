@@ -11,7 +11,7 @@ type Workflow struct {
 	Title       string    `db:"title" json:"title"`
 	Description string    `db:"description" json:"description,omitempty"`
 	Icon        string    `db:"icon" json:"icon,omitempty"`
-	Content     string    `db:"content" json:"content,omitempty"` // 工作流设计的 JSON 内容
+	Content     string    `db:"content" json:"content,omitempty"` // 工作流设计的 JSON 内容 (草稿)
 	Created     time.Time `db:"created" json:"created"`
 	CreatedBy   int64     `db:"created_by" json:"createdBy,string"`
 	Modified    time.Time `db:"modified" json:"modified"`
@@ -19,9 +19,12 @@ type Workflow struct {
 	EnglishName string    `db:"english_name" json:"englishName,omitempty"`
 	Status      int       `db:"status" json:"status"`
 	CategoryID  int64     `db:"category_id" json:"categoryId,string,omitempty"`
+	// 当前发布版本 ID，执行与工具调用使用该版本
+	PublishedVersionID int64 `db:"published_version_id" json:"publishedVersionId,string,omitempty"`
 
 	// 非数据库字段
-	CategoryName string `db:"-" json:"categoryName,omitempty"`
+	CategoryName     string `db:"-" json:"categoryName,omitempty"`
+	PublishedVersion int    `db:"-" json:"publishedVersion,omitempty"` // 当前发布版本号
 }
 
 // TableName 返回表名
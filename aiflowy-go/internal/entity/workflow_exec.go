@@ -11,6 +11,8 @@ const (
 	ExecStatusCompleted WorkflowExecStatus = 2 // 已完成
 	ExecStatusFailed    WorkflowExecStatus = 3 // 执行失败
 	ExecStatusSuspended WorkflowExecStatus = 4 // 已暂停 (等待人工确认)
+	ExecStatusSkipped   WorkflowExecStatus = 5 // 已跳过 (分支未命中)
+	ExecStatusCancelled WorkflowExecStatus = 6 // 已取消
 )
 
 // WorkflowExecResult 工作流执行记录实体
@@ -30,6 +32,9 @@ type WorkflowExecResult struct {
 	CreatedKey   string             `db:"created_key" json:"createdKey,omitempty"` // 执行人标识
 	CreatedBy    string             `db:"created_by" json:"createdBy,omitempty"`   // 执行人
 	ErrorInfo    string             `db:"error_info" json:"errorInfo,omitempty"`
+	ParentExecKey string            `db:"parent_exec_key" json:"parentExecKey,omitempty"` // 子工作流：父执行标识
+	ParentStepID  int64             `db:"parent_step_id" json:"parentStepId,string,omitempty"` // 子工作流：父执行中调用它的步骤 ID
+	WorkflowVersion int             `db:"workflow_version" json:"workflowVersion"`             // 执行的工作流版本号 (0 为草稿)
 }
 
 // WorkflowExecStep 工作流执行步骤实体
@@ -47,4 +52,6 @@ type WorkflowExecStep struct {
 	Tokens    int64              `db:"tokens" json:"tokens,omitempty"`
 	Status    WorkflowExecStatus `db:"status" json:"status"`
 	ErrorInfo string             `db:"error_info" json:"errorInfo,omitempty"`
+	Attempt   int                `db:"attempt" json:"attempt"` // 第几次尝试 (从 1 开始)
+	Logs      string             `db:"logs" json:"logs,omitempty"` // 节点运行日志 (如代码节点的 print 输出)
 }
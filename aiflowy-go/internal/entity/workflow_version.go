@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// WorkflowVersion 工作流发布版本实体 (发布后不可修改)
+type WorkflowVersion struct {
+	ID         int64     `db:"id" json:"id,string"`
+	WorkflowID int64     `db:"workflow_id" json:"workflowId,string"`
+	Version    int       `db:"version" json:"version"` // 版本号，按工作流从 1 递增
+	Content    string    `db:"content" json:"content,omitempty"`
+	Comment    string    `db:"comment" json:"comment,omitempty"` // 发布说明
+	Created    time.Time `db:"created" json:"created"`
+	CreatedBy  int64     `db:"created_by" json:"createdBy,string"`
+
+	// 非数据库字段
+	Published bool `db:"-" json:"published"` // 是否为当前发布版本
+}
+
+// TableName 返回表名
+func (WorkflowVersion) TableName() string {
+	return "tb_workflow_version"
+}
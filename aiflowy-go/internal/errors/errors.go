@@ -50,6 +50,7 @@ const (
 	CodeDataExists       = 1003
 	CodeOperationFailed  = 1004
 	CodePermissionDenied = 1005
+	CodeQuotaExceeded    = 1006
 
 	// Auth error codes (2000+)
 	CodeTokenExpired     = 2001
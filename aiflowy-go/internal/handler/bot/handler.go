@@ -22,6 +22,7 @@ import (
 type Handler struct {
 	botSvc     *service.BotService
 	botChatSvc *service.BotChatService
+	usageSvc   *service.TokenUsageService
 }
 
 // NewHandler creates a new bot handler
@@ -29,6 +30,7 @@ func NewHandler() *Handler {
 	return &Handler{
 		botSvc:     service.NewBotService(),
 		botChatSvc: service.NewBotChatService(),
+		usageSvc:   service.NewTokenUsageService(),
 	}
 }
 
@@ -197,7 +199,8 @@ type BotChatRequest struct {
 	BotID          int64                   `json:"botId,string"`
 	ConversationID int64                   `json:"conversationId,string"`
 	Message        string                  `json:"message"`
-	Image          string                  `json:"image,omitempty"`
+	Image          string                    `json:"image,omitempty"`
+	Attachments    []*service.ChatAttachment `json:"attachments,omitempty"`
 	Stream         bool                    `json:"stream"`
 	Options        *service.BotChatOptions `json:"options,omitempty"`
 }
@@ -212,7 +215,7 @@ func (h *Handler) Chat(c echo.Context) error {
 	if req.BotID == 0 {
 		return apierrors.BadRequest("缺少机器人ID")
 	}
-	if req.Message == "" {
+	if req.Message == "" && req.Image == "" && len(req.Attachments) == 0 {
 		return apierrors.BadRequest("消息内容不能为空")
 	}
 
@@ -223,6 +226,7 @@ func (h *Handler) Chat(c echo.Context) error {
 		ConversationID: req.ConversationID,
 		Message:        req.Message,
 		Image:          req.Image,
+		Attachments:    req.Attachments,
 		Stream:         req.Stream,
 		Options:        req.Options,
 	}
@@ -268,6 +272,23 @@ func (h *Handler) Chat(c echo.Context) error {
 	return nil
 }
 
+// UsageSummary handles GET /api/v1/bot/usage/summary - token usage of the
+// current tenant rolled up by user, bot, model or tenant
+func (h *Handler) UsageSummary(c echo.Context) error {
+	var req dto.TokenUsageSummaryRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("参数解析失败")
+	}
+
+	_, tenantID, _ := getUserContext(c)
+	stats, err := h.usageSvc.Summarize(c.Request().Context(), &req, tenantID)
+	if err != nil {
+		return err
+	}
+
+	return response.Success(c, stats)
+}
+
 // ========== Category Endpoints ==========
 
 // CategoryList handles GET /api/v1/botCategory/list
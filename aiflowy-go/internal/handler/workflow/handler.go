@@ -1,15 +1,20 @@
 package workflow
 
 import (
+	"errors"
+	"fmt"
 	"io"
 	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
 	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
 	"github.com/aiflowy/aiflowy-go/internal/handler/auth"
 	"github.com/aiflowy/aiflowy-go/internal/service"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
 	"github.com/aiflowy/aiflowy-go/pkg/response"
 )
 
@@ -47,18 +52,37 @@ func (h *Handler) RegisterRoutes(g *echo.Group) {
 	workflow.GET("/list", h.ListWorkflows)
 	workflow.GET("/getDetail", h.GetWorkflow)
 	workflow.POST("/save", h.SaveWorkflow)
+	workflow.POST("/validate", h.ValidateWorkflow)
 	workflow.POST("/remove", h.DeleteWorkflow)
 	workflow.GET("/copy", h.CopyWorkflow)
 	workflow.GET("/getRunningParameters", h.GetRunningParameters)
 	workflow.POST("/importWorkFlow", h.ImportWorkflow)
 	workflow.GET("/exportWorkFlow", h.ExportWorkflow)
 
+	// 工作流版本
+	workflow.POST("/publish", h.PublishWorkflow)
+	workflow.POST("/rollback", h.RollbackWorkflow)
+	workflow.GET("/listVersions", h.ListVersions)
+	workflow.GET("/getVersion", h.GetVersion)
+	workflow.GET("/diffVersions", h.DiffVersions)
+
 	// 工作流执行 (Stage 11 将实现完整功能)
 	workflow.POST("/runAsync", h.RunAsync)
+	workflow.POST("/runStream", h.RunStream)
+	workflow.GET("/attachStream", h.AttachStream)
 	workflow.POST("/getChainStatus", h.GetChainStatus)
 	workflow.POST("/resume", h.Resume)
+	workflow.POST("/cancel", h.Cancel)
 	workflow.POST("/singleRun", h.SingleRun)
 
+	// 执行记录
+	execResult := g.Group("/workflowExecResult")
+	execResult.GET("/page", h.PageExecResults)
+	execResult.GET("/detail", h.GetExecDetail)
+	execResult.POST("/rerun", h.Rerun)
+	execStep := g.Group("/workflowExecStep")
+	execStep.GET("/list", h.ListExecSteps)
+
 	// 工作流分类
 	workflowCategory := g.Group("/workflowCategory")
 	workflowCategory.GET("/list", h.ListWorkflowCategories)
@@ -111,11 +135,33 @@ func (h *Handler) SaveWorkflow(c echo.Context) error {
 		return apierrors.BadRequest("无效的请求参数")
 	}
 
-	workflow, err := h.service.SaveWorkflow(ctx, &req, tenantID, userID, deptID)
+	workflow, validation, err := h.service.SaveWorkflow(ctx, &req, tenantID, userID, deptID)
 	if err != nil {
 		return err
 	}
-	return response.Success(c, workflow)
+	return response.Success(c, &saveWorkflowResponse{Workflow: workflow, Validation: validation})
+}
+
+// saveWorkflowResponse 保存结果：工作流字段与草稿的校验结果
+type saveWorkflowResponse struct {
+	*entity.Workflow
+	Validation *dto.WorkflowValidationResult `json:"validation,omitempty"`
+}
+
+// ValidateWorkflow 校验工作流，返回全部问题
+func (h *Handler) ValidateWorkflow(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dto.WorkflowValidateRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+
+	result, err := h.service.ValidateWorkflow(ctx, &req)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, result)
 }
 
 // DeleteWorkflow 删除工作流
@@ -192,11 +238,11 @@ func (h *Handler) ImportWorkflow(c echo.Context) error {
 		Content:     string(content),
 	}
 
-	workflow, err := h.service.SaveWorkflow(ctx, req, tenantID, userID, deptID)
+	workflow, validation, err := h.service.SaveWorkflow(ctx, req, tenantID, userID, deptID)
 	if err != nil {
 		return err
 	}
-	return response.Success(c, workflow)
+	return response.Success(c, &saveWorkflowResponse{Workflow: workflow, Validation: validation})
 }
 
 // ExportWorkflow 导出工作流
@@ -213,6 +259,110 @@ func (h *Handler) ExportWorkflow(c echo.Context) error {
 	return response.Success(c, workflow.Content)
 }
 
+// ========================== 工作流版本 ==========================
+
+// PublishWorkflow 将草稿发布为新版本
+func (h *Handler) PublishWorkflow(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID, _, _ := getUserContext(c)
+
+	var req dto.WorkflowPublishRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+	if req.ID == "" {
+		return apierrors.BadRequest("工作流 ID 不能为空")
+	}
+
+	version, err := h.service.PublishWorkflow(ctx, &req, userID)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, version)
+}
+
+// RollbackWorkflow 将发布版本回滚到指定版本
+func (h *Handler) RollbackWorkflow(c echo.Context) error {
+	ctx := c.Request().Context()
+
+	var req dto.WorkflowRollbackRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+	if req.ID == "" {
+		return apierrors.BadRequest("工作流 ID 不能为空")
+	}
+
+	version, err := h.service.RollbackWorkflow(ctx, &req)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, version)
+}
+
+// ListVersions 获取工作流版本列表
+func (h *Handler) ListVersions(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.QueryParam("id")
+
+	versions, err := h.service.ListVersions(ctx, id)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, versions)
+}
+
+// GetVersion 获取指定版本的内容 (version 为 0 或为空时返回草稿)
+func (h *Handler) GetVersion(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.QueryParam("id")
+
+	version, err := queryVersion(c, "version")
+	if err != nil {
+		return err
+	}
+
+	result, err := h.service.GetVersion(ctx, id, version)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, result)
+}
+
+// DiffVersions 按节点比较两个版本 (版本号 0 或为空表示草稿)
+func (h *Handler) DiffVersions(c echo.Context) error {
+	ctx := c.Request().Context()
+	id := c.QueryParam("id")
+
+	from, err := queryVersion(c, "from")
+	if err != nil {
+		return err
+	}
+	to, err := queryVersion(c, "to")
+	if err != nil {
+		return err
+	}
+
+	diff, err := h.service.DiffVersions(ctx, id, from, to)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, diff)
+}
+
+// queryVersion 解析版本号参数，为空表示草稿
+func queryVersion(c echo.Context, name string) (int, error) {
+	value := c.QueryParam(name)
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 0 {
+		return 0, apierrors.BadRequest("无效的版本号: " + value)
+	}
+	return version, nil
+}
+
 // ========================== 工作流执行 ==========================
 
 // RunAsync 异步运行工作流
@@ -237,17 +387,112 @@ func (h *Handler) RunAsync(c echo.Context) error {
 	}
 
 	// 执行工作流
-	executeID, err := h.executor.ExecuteAsync(ctx, req.ID, req.Variables, strconv.FormatInt(userID, 10), createdBy)
+	executeID, err := h.executor.ExecuteAsync(ctx, req.ID, req.Draft, req.Variables, strconv.FormatInt(userID, 10), createdBy)
 	if err != nil {
-		return apierrors.InternalError(err.Error())
+		return executionError(c, err)
 	}
 
 	return response.Success(c, executeID)
 }
 
+// RunStream 运行工作流并通过 SSE 推送执行事件
+// 客户端断开不会中断执行，可通过 attachStream 重新附加
+func (h *Handler) RunStream(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID, _, _ := getUserContext(c)
+
+	var req dto.WorkflowRunRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+
+	if req.ID == "" {
+		return apierrors.BadRequest("工作流 ID 不能为空")
+	}
+
+	claims := auth.GetClaims(c)
+	createdBy := ""
+	if claims != nil {
+		createdBy = claims.Nickname
+	}
+
+	sub, err := h.executor.ExecuteStream(ctx, req.ID, req.Draft, req.Variables, strconv.FormatInt(userID, 10), createdBy)
+	if err != nil {
+		return executionError(c, err)
+	}
+
+	return streamEvents(c, sub)
+}
+
+// AttachStream 附加到已有执行，先推送当前状态再推送实时事件
+func (h *Handler) AttachStream(c echo.Context) error {
+	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
+
+	executeID := c.QueryParam("executeId")
+	if executeID == "" {
+		return apierrors.BadRequest("执行 ID 不能为空")
+	}
+
+	sub, err := h.executor.Subscribe(ctx, executeID, tenantID)
+	if err != nil {
+		return executionError(c, err)
+	}
+
+	return streamEvents(c, sub)
+}
+
+// streamEvents 以 SSE 输出订阅的执行事件，直到执行结束或客户端断开
+func streamEvents(c echo.Context, sub *service.WorkflowSubscription) error {
+	defer sub.Close()
+
+	c.Response().Header().Set("Content-Type", "text/event-stream")
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Connection", "keep-alive")
+	c.Response().Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+	c.Response().WriteHeader(200)
+	c.Response().Flush()
+
+	// 暂停等待等长时间没有事件时发送心跳，避免连接被代理断开
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request().Context().Done():
+			return nil
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Response(), ": ping\n\n"); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		case envelope, ok := <-sub.Events():
+			if !ok {
+				if sub.Lagged() {
+					builder := protocol.NewBuilder(sub.ExecuteID, "")
+					errEnv := builder.SystemError("EVENT_LAGGED", "事件推送积压，请重新附加执行", true)
+					sseData, _ := errEnv.ToSSE()
+					fmt.Fprint(c.Response(), sseData)
+					c.Response().Flush()
+				}
+				return nil
+			}
+			sseData, err := envelope.ToSSE()
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprint(c.Response(), sseData); err != nil {
+				return nil
+			}
+			c.Response().Flush()
+		}
+	}
+}
+
 // GetChainStatus 获取工作流执行状态
 func (h *Handler) GetChainStatus(c echo.Context) error {
 	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
 
 	var req dto.ChainStatusRequest
 	if err := c.Bind(&req); err != nil {
@@ -258,9 +503,9 @@ func (h *Handler) GetChainStatus(c echo.Context) error {
 		return apierrors.BadRequest("执行 ID 不能为空")
 	}
 
-	chainInfo, err := h.executor.GetChainStatus(ctx, req.ExecuteID, req.Nodes)
+	chainInfo, err := h.executor.GetChainStatus(ctx, req.ExecuteID, tenantID, req.Nodes)
 	if err != nil {
-		return apierrors.InternalError(err.Error())
+		return executionError(c, err)
 	}
 
 	return response.Success(c, chainInfo)
@@ -269,6 +514,7 @@ func (h *Handler) GetChainStatus(c echo.Context) error {
 // Resume 恢复工作流执行
 func (h *Handler) Resume(c echo.Context) error {
 	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
 
 	var req dto.WorkflowResumeRequest
 	if err := c.Bind(&req); err != nil {
@@ -279,8 +525,42 @@ func (h *Handler) Resume(c echo.Context) error {
 		return apierrors.BadRequest("执行 ID 不能为空")
 	}
 
-	if err := h.executor.Resume(ctx, req.ExecuteID, req.ConfirmParams); err != nil {
-		return apierrors.InternalError(err.Error())
+	if err := h.executor.Resume(ctx, req.ExecuteID, tenantID, req.ConfirmParams); err != nil {
+		return executionError(c, err)
+	}
+
+	return response.Success(c, nil)
+}
+
+// executionError 参数不符合声明时返回 422 字段级错误，其它错误作为内部错误
+func executionError(c echo.Context, err error) error {
+	var paramsErr *service.WorkflowParamsError
+	if errors.As(err, &paramsErr) {
+		return response.UnprocessableEntity(c, paramsErr.Errors)
+	}
+	var bizErr *apierrors.BusinessError
+	if errors.As(err, &bizErr) {
+		return bizErr
+	}
+	return apierrors.InternalError(err.Error())
+}
+
+// Cancel 取消工作流执行
+func (h *Handler) Cancel(c echo.Context) error {
+	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
+
+	var req dto.WorkflowCancelRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+
+	if req.ExecuteID == "" {
+		return apierrors.BadRequest("执行 ID 不能为空")
+	}
+
+	if err := h.executor.Cancel(ctx, req.ExecuteID, tenantID); err != nil {
+		return executionError(c, err)
 	}
 
 	return response.Success(c, nil)
@@ -310,6 +590,93 @@ func (h *Handler) SingleRun(c echo.Context) error {
 	return response.Success(c, result)
 }
 
+// ========================== 执行记录 ==========================
+
+// PageExecResults 分页查询执行记录，可按工作流、状态、执行人与开始时间范围过滤
+func (h *Handler) PageExecResults(c echo.Context) error {
+	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
+
+	var req dto.WorkflowExecListRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+
+	results, total, err := h.service.PageExecResults(ctx, &req, tenantID)
+	if err != nil {
+		return err
+	}
+	return response.PageSuccess(c, results, total, req.GetPage(), req.GetPageSize())
+}
+
+// GetExecDetail 获取执行记录详情与步骤时间线
+func (h *Handler) GetExecDetail(c echo.Context) error {
+	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
+
+	executeID := c.QueryParam("executeId")
+	if executeID == "" {
+		return apierrors.BadRequest("执行 ID 不能为空")
+	}
+
+	detail, err := h.service.GetExecDetail(ctx, executeID, tenantID)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, detail)
+}
+
+// ListExecSteps 获取执行的步骤时间线
+func (h *Handler) ListExecSteps(c echo.Context) error {
+	ctx := c.Request().Context()
+	_, tenantID, _ := getUserContext(c)
+
+	executeID := c.QueryParam("executeId")
+	if executeID == "" {
+		return apierrors.BadRequest("执行 ID 不能为空")
+	}
+
+	steps, err := h.service.ListExecSteps(ctx, executeID, tenantID)
+	if err != nil {
+		return err
+	}
+	return response.Success(c, steps)
+}
+
+// Rerun 重新执行：使用相同输入重新执行，或从指定步骤恢复变量后继续执行，返回新的执行 ID
+func (h *Handler) Rerun(c echo.Context) error {
+	ctx := c.Request().Context()
+	userID, tenantID, _ := getUserContext(c)
+
+	var req dto.WorkflowRerunRequest
+	if err := c.Bind(&req); err != nil {
+		return apierrors.BadRequest("无效的请求参数")
+	}
+
+	if req.ExecuteID == "" {
+		return apierrors.BadRequest("执行 ID 不能为空")
+	}
+
+	claims := auth.GetClaims(c)
+	createdBy := ""
+	if claims != nil {
+		createdBy = claims.Nickname
+	}
+
+	var executeID string
+	var err error
+	if req.StepID != 0 {
+		executeID, err = h.executor.RerunFromStep(ctx, req.ExecuteID, tenantID, req.StepID, strconv.FormatInt(userID, 10), createdBy)
+	} else {
+		executeID, err = h.executor.Rerun(ctx, req.ExecuteID, tenantID, strconv.FormatInt(userID, 10), createdBy)
+	}
+	if err != nil {
+		return executionError(c, err)
+	}
+
+	return response.Success(c, executeID)
+}
+
 // ========================== WorkflowCategory ==========================
 
 // ListWorkflowCategories 获取工作流分类列表
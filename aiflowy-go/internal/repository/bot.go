@@ -339,12 +339,14 @@ func (r *BotRepository) CountBotsByCategory(ctx context.Context, categoryID int6
 
 // GetConversationByID retrieves a conversation by ID
 func (r *BotRepository) GetConversationByID(ctx context.Context, id int64) (*entity.BotConversation, error) {
-	query := `SELECT id, title, COALESCE(bot_id,0), COALESCE(account_id,0), created, COALESCE(created_by,0), modified, COALESCE(modified_by,0)
+	query := `SELECT id, title, COALESCE(bot_id,0), COALESCE(account_id,0), created, COALESCE(created_by,0), modified, COALESCE(modified_by,0),
+		COALESCE(summary,''), COALESCE(summary_message_id,0)
 		FROM tb_bot_conversation WHERE id = ?`
 
 	var c entity.BotConversation
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&c.ID, &c.Title, &c.BotID, &c.AccountID, &c.Created, &c.CreatedBy, &c.Modified, &c.ModifiedBy,
+		&c.Summary, &c.SummaryMessageID,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -473,6 +475,15 @@ func (r *BotRepository) UpdateConversation(ctx context.Context, c *entity.BotCon
 	return err
 }
 
+// UpdateConversationSummary saves the rolling summary of a conversation and
+// the last message it covers
+func (r *BotRepository) UpdateConversationSummary(ctx context.Context, id int64, summary string, summaryMessageID int64) error {
+	query := `UPDATE tb_bot_conversation SET summary = ?, summary_message_id = ? WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, summary, summaryMessageID, id)
+	return err
+}
+
 // DeleteConversation deletes a conversation
 func (r *BotRepository) DeleteConversation(ctx context.Context, id int64) error {
 	query := "DELETE FROM tb_bot_conversation WHERE id = ?"
@@ -666,12 +677,18 @@ func (r *BotRepository) DeleteMessagesByBotID(ctx context.Context, botID int64)
 
 // GetRecentMessages gets recent messages for a conversation (for context)
 func (r *BotRepository) GetRecentMessages(ctx context.Context, conversationID int64, limit int) ([]*entity.BotMessage, error) {
+	return r.GetRecentMessagesAfter(ctx, conversationID, 0, limit)
+}
+
+// GetRecentMessagesAfter gets the most recent messages of a conversation newer
+// than afterID, oldest first
+func (r *BotRepository) GetRecentMessagesAfter(ctx context.Context, conversationID, afterID int64, limit int) ([]*entity.BotMessage, error) {
 	query := `SELECT id, COALESCE(bot_id,0), COALESCE(account_id,0), COALESCE(conversation_id,0), COALESCE(role,''),
 		COALESCE(content,''), COALESCE(image,''), COALESCE(options,''), created, modified
-		FROM tb_bot_message WHERE conversation_id = ?
-		ORDER BY created DESC LIMIT ?`
+		FROM tb_bot_message WHERE conversation_id = ? AND id > ?
+		ORDER BY created DESC, id DESC LIMIT ?`
 
-	rows, err := r.db.QueryContext(ctx, query, conversationID, limit)
+	rows, err := r.db.QueryContext(ctx, query, conversationID, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
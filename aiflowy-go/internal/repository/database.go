@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -15,6 +16,11 @@ import (
 
 var db *sql.DB
 
+var (
+	dataSourcesMu sync.Mutex
+	dataSources   = make(map[string]*sql.DB)
+)
+
 // InitDB initializes the database connection
 func InitDB(cfg *config.DatabaseConfig) error {
 	var err error
@@ -52,8 +58,62 @@ func GetDB() *sql.DB {
 	return db
 }
 
+// GetDataSource returns a named datasource from config.datasources, opened on first use.
+// The main database connection is never returned.
+func GetDataSource(name string) (*sql.DB, error) {
+	if name == "" {
+		return nil, fmt.Errorf("datasource name is required")
+	}
+
+	dataSourcesMu.Lock()
+	defer dataSourcesMu.Unlock()
+
+	if ds, ok := dataSources[name]; ok {
+		return ds, nil
+	}
+
+	appCfg := config.Get()
+	if appCfg == nil {
+		return nil, fmt.Errorf("datasource not configured: %s", name)
+	}
+	cfg, ok := appCfg.Datasources[name]
+	if !ok {
+		return nil, fmt.Errorf("datasource not configured: %s", name)
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "mysql"
+	}
+	if cfg.Charset == "" {
+		cfg.Charset = "utf8mb4"
+	}
+
+	ds, err := sql.Open(cfg.Driver, cfg.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open datasource %s: %w", name, err)
+	}
+	if cfg.MaxIdleConns > 0 {
+		ds.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.MaxOpenConns > 0 {
+		ds.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		ds.SetConnMaxLifetime(time.Duration(cfg.ConnMaxLifetime) * time.Second)
+	}
+
+	dataSources[name] = ds
+	return ds, nil
+}
+
 // CloseDB closes the database connection
 func CloseDB() error {
+	dataSourcesMu.Lock()
+	for name, ds := range dataSources {
+		ds.Close()
+		delete(dataSources, name)
+	}
+	dataSourcesMu.Unlock()
+
 	if db != nil {
 		return db.Close()
 	}
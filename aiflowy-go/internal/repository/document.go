@@ -358,6 +358,51 @@ func (r *DocumentRepository) GetChunkIDs(ctx context.Context, documentID int64)
 	return ids, nil
 }
 
+// ChunkSource 分块所属的文档信息
+type ChunkSource struct {
+	ChunkID      int64
+	DocumentID   int64
+	Title        string
+	DocumentType string
+	DocumentPath string
+	Sorting      int
+}
+
+// ListChunkSources 批量获取分块所属的文档 (分块 ID -> 文档信息)
+func (r *DocumentRepository) ListChunkSources(ctx context.Context, chunkIDs []int64) (map[int64]*ChunkSource, error) {
+	sources := make(map[int64]*ChunkSource, len(chunkIDs))
+	if len(chunkIDs) == 0 {
+		return sources, nil
+	}
+
+	inClause, args := buildInClause(chunkIDs)
+	query := `
+		SELECT c.id, c.document_id, c.sorting, d.title, d.document_type, d.document_path
+		FROM tb_document_chunk c
+		LEFT JOIN tb_document d ON d.id = c.document_id
+		WHERE c.id IN (` + inClause + `)
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source ChunkSource
+		var title, documentType, documentPath sql.NullString
+		if err := rows.Scan(&source.ChunkID, &source.DocumentID, &source.Sorting, &title, &documentType, &documentPath); err != nil {
+			return nil, err
+		}
+		source.Title = title.String
+		source.DocumentType = documentType.String
+		source.DocumentPath = documentPath.String
+		sources[source.ChunkID] = &source
+	}
+	return sources, rows.Err()
+}
+
 // ========================== DocumentHistory ==========================
 
 // CreateHistory 创建文档历史记录
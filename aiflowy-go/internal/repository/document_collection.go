@@ -284,6 +284,7 @@ func (r *DocumentCollectionRepository) ListByBotID(ctx context.Context, botID in
 		FROM tb_bot_document_collection bdc
 		LEFT JOIN tb_document_collection dc ON bdc.document_collection_id = dc.id
 		WHERE bdc.bot_id = ?
+		ORDER BY bdc.id
 	`
 
 	rows, err := r.db.QueryContext(ctx, query, botID)
@@ -178,7 +178,8 @@ func (r *ModelRepository) GetModelByID(ctx context.Context, id int64) (*entity.M
 	query := `SELECT id, dept_id, tenant_id, provider_id, COALESCE(title,''), COALESCE(icon,''), COALESCE(description,''), COALESCE(endpoint,''),
 		COALESCE(request_path,''), COALESCE(model_name,''), COALESCE(api_key,''), COALESCE(extra_config,''), COALESCE(options,''), COALESCE(group_name,''), COALESCE(model_type,''),
 		COALESCE(with_used,false), COALESCE(support_thinking,false), COALESCE(support_tool,false), COALESCE(support_image,false), COALESCE(support_image_b64_only,false),
-		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false)
+		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false),
+		COALESCE(input_price,0), COALESCE(output_price,0), COALESCE(context_length,0)
 		FROM tb_model WHERE id = ?`
 
 	var m entity.Model
@@ -187,7 +188,7 @@ func (r *ModelRepository) GetModelByID(ctx context.Context, id int64) (*entity.M
 		&m.ID, &m.DeptID, &m.TenantID, &providerID, &m.Title, &m.Icon, &m.Description, &m.Endpoint,
 		&m.RequestPath, &m.ModelName, &m.APIKey, &m.ExtraConfig, &m.Options, &m.GroupName, &m.ModelType,
 		&m.WithUsed, &m.SupportThinking, &m.SupportTool, &m.SupportImage, &m.SupportImageB64Only,
-		&m.SupportVideo, &m.SupportAudio, &m.SupportFree,
+		&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.InputPrice, &m.OutputPrice, &m.ContextLength,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -207,6 +208,7 @@ func (r *ModelRepository) GetModelWithProvider(ctx context.Context, id int64) (*
 		COALESCE(m.request_path,''), COALESCE(m.model_name,''), COALESCE(m.api_key,''), COALESCE(m.extra_config,''), COALESCE(m.options,''), COALESCE(m.group_name,''), COALESCE(m.model_type,''),
 		COALESCE(m.with_used,false), COALESCE(m.support_thinking,false), COALESCE(m.support_tool,false), COALESCE(m.support_image,false), COALESCE(m.support_image_b64_only,false),
 		COALESCE(m.support_video,false), COALESCE(m.support_audio,false), COALESCE(m.support_free,false),
+		COALESCE(m.input_price,0), COALESCE(m.output_price,0), COALESCE(m.context_length,0),
 		COALESCE(p.provider_name, ''), COALESCE(p.provider_type, '')
 		FROM tb_model m
 		LEFT JOIN tb_model_provider p ON m.provider_id = p.id
@@ -218,7 +220,7 @@ func (r *ModelRepository) GetModelWithProvider(ctx context.Context, id int64) (*
 		&m.ID, &m.DeptID, &m.TenantID, &providerID, &m.Title, &m.Icon, &m.Description, &m.Endpoint,
 		&m.RequestPath, &m.ModelName, &m.APIKey, &m.ExtraConfig, &m.Options, &m.GroupName, &m.ModelType,
 		&m.WithUsed, &m.SupportThinking, &m.SupportTool, &m.SupportImage, &m.SupportImageB64Only,
-		&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.ProviderName, &m.ProviderType,
+		&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.InputPrice, &m.OutputPrice, &m.ContextLength, &m.ProviderName, &m.ProviderType,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -237,7 +239,8 @@ func (r *ModelRepository) ListModels(ctx context.Context, req *dto.ModelListRequ
 	query := `SELECT id, dept_id, tenant_id, provider_id, COALESCE(title,''), COALESCE(icon,''), COALESCE(description,''), COALESCE(endpoint,''),
 		COALESCE(request_path,''), COALESCE(model_name,''), COALESCE(api_key,''), COALESCE(extra_config,''), COALESCE(options,''), COALESCE(group_name,''), COALESCE(model_type,''),
 		COALESCE(with_used,false), COALESCE(support_thinking,false), COALESCE(support_tool,false), COALESCE(support_image,false), COALESCE(support_image_b64_only,false),
-		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false)
+		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false),
+		COALESCE(input_price,0), COALESCE(output_price,0), COALESCE(context_length,0)
 		FROM tb_model WHERE 1=1`
 	var args []interface{}
 
@@ -280,7 +283,7 @@ func (r *ModelRepository) ListModels(ctx context.Context, req *dto.ModelListRequ
 			&m.ID, &m.DeptID, &m.TenantID, &providerID, &m.Title, &m.Icon, &m.Description, &m.Endpoint,
 			&m.RequestPath, &m.ModelName, &m.APIKey, &m.ExtraConfig, &m.Options, &m.GroupName, &m.ModelType,
 			&m.WithUsed, &m.SupportThinking, &m.SupportTool, &m.SupportImage, &m.SupportImageB64Only,
-			&m.SupportVideo, &m.SupportAudio, &m.SupportFree,
+			&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.InputPrice, &m.OutputPrice, &m.ContextLength,
 		)
 		if err != nil {
 			return nil, err
@@ -299,7 +302,8 @@ func (r *ModelRepository) PageModels(ctx context.Context, req *dto.ModelListRequ
 	query := `SELECT id, dept_id, tenant_id, provider_id, COALESCE(title,''), COALESCE(icon,''), COALESCE(description,''), COALESCE(endpoint,''),
 		COALESCE(request_path,''), COALESCE(model_name,''), COALESCE(api_key,''), COALESCE(extra_config,''), COALESCE(options,''), COALESCE(group_name,''), COALESCE(model_type,''),
 		COALESCE(with_used,false), COALESCE(support_thinking,false), COALESCE(support_tool,false), COALESCE(support_image,false), COALESCE(support_image_b64_only,false),
-		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false)
+		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false),
+		COALESCE(input_price,0), COALESCE(output_price,0), COALESCE(context_length,0)
 		FROM tb_model WHERE 1=1`
 	var args []interface{}
 
@@ -356,7 +360,7 @@ func (r *ModelRepository) PageModels(ctx context.Context, req *dto.ModelListRequ
 			&m.ID, &m.DeptID, &m.TenantID, &providerID, &m.Title, &m.Icon, &m.Description, &m.Endpoint,
 			&m.RequestPath, &m.ModelName, &m.APIKey, &m.ExtraConfig, &m.Options, &m.GroupName, &m.ModelType,
 			&m.WithUsed, &m.SupportThinking, &m.SupportTool, &m.SupportImage, &m.SupportImageB64Only,
-			&m.SupportVideo, &m.SupportAudio, &m.SupportFree,
+			&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.InputPrice, &m.OutputPrice, &m.ContextLength,
 		)
 		if err != nil {
 			return nil, 0, err
@@ -374,7 +378,8 @@ func (r *ModelRepository) GetModelsGroupedByType(ctx context.Context, req *dto.M
 	query := `SELECT id, dept_id, tenant_id, provider_id, COALESCE(title,''), COALESCE(icon,''), COALESCE(description,''), COALESCE(endpoint,''),
 		COALESCE(request_path,''), COALESCE(model_name,''), COALESCE(api_key,''), COALESCE(extra_config,''), COALESCE(options,''), COALESCE(group_name,''), COALESCE(model_type,''),
 		COALESCE(with_used,false), COALESCE(support_thinking,false), COALESCE(support_tool,false), COALESCE(support_image,false), COALESCE(support_image_b64_only,false),
-		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false)
+		COALESCE(support_video,false), COALESCE(support_audio,false), COALESCE(support_free,false),
+		COALESCE(input_price,0), COALESCE(output_price,0), COALESCE(context_length,0)
 		FROM tb_model WHERE 1=1`
 	var args []interface{}
 
@@ -411,7 +416,7 @@ func (r *ModelRepository) GetModelsGroupedByType(ctx context.Context, req *dto.M
 			&m.ID, &m.DeptID, &m.TenantID, &providerID, &m.Title, &m.Icon, &m.Description, &m.Endpoint,
 			&m.RequestPath, &m.ModelName, &m.APIKey, &m.ExtraConfig, &m.Options, &m.GroupName, &m.ModelType,
 			&m.WithUsed, &m.SupportThinking, &m.SupportTool, &m.SupportImage, &m.SupportImageB64Only,
-			&m.SupportVideo, &m.SupportAudio, &m.SupportFree,
+			&m.SupportVideo, &m.SupportAudio, &m.SupportFree, &m.InputPrice, &m.OutputPrice, &m.ContextLength,
 		)
 		if err != nil {
 			return nil, err
@@ -442,8 +447,8 @@ func (r *ModelRepository) CreateModel(ctx context.Context, m *entity.Model) erro
 		(id, dept_id, tenant_id, provider_id, title, icon, description, endpoint, request_path,
 		model_name, api_key, extra_config, options, group_name, model_type, with_used,
 		support_thinking, support_tool, support_image, support_image_b64_only,
-		support_video, support_audio, support_free)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		support_video, support_audio, support_free, input_price, output_price, context_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	var providerID interface{} = nil
 	if m.ProviderID > 0 {
@@ -454,7 +459,7 @@ func (r *ModelRepository) CreateModel(ctx context.Context, m *entity.Model) erro
 		m.ID, m.DeptID, m.TenantID, providerID, m.Title, m.Icon, m.Description, m.Endpoint, m.RequestPath,
 		m.ModelName, m.APIKey, m.ExtraConfig, m.Options, m.GroupName, m.ModelType, m.WithUsed,
 		m.SupportThinking, m.SupportTool, m.SupportImage, m.SupportImageB64Only,
-		m.SupportVideo, m.SupportAudio, m.SupportFree,
+		m.SupportVideo, m.SupportAudio, m.SupportFree, m.InputPrice, m.OutputPrice, m.ContextLength,
 	)
 	return err
 }
@@ -466,7 +471,8 @@ func (r *ModelRepository) UpdateModel(ctx context.Context, m *entity.Model) erro
 		endpoint = ?, request_path = ?, model_name = ?, api_key = ?, extra_config = ?,
 		options = ?, group_name = ?, model_type = ?, with_used = ?, support_thinking = ?,
 		support_tool = ?, support_image = ?, support_image_b64_only = ?,
-		support_video = ?, support_audio = ?, support_free = ?
+		support_video = ?, support_audio = ?, support_free = ?,
+		input_price = ?, output_price = ?, context_length = ?
 		WHERE id = ?`
 
 	var providerID interface{} = nil
@@ -479,7 +485,8 @@ func (r *ModelRepository) UpdateModel(ctx context.Context, m *entity.Model) erro
 		m.Endpoint, m.RequestPath, m.ModelName, m.APIKey, m.ExtraConfig,
 		m.Options, m.GroupName, m.ModelType, m.WithUsed, m.SupportThinking,
 		m.SupportTool, m.SupportImage, m.SupportImageB64Only,
-		m.SupportVideo, m.SupportAudio, m.SupportFree, m.ID,
+		m.SupportVideo, m.SupportAudio, m.SupportFree,
+		m.InputPrice, m.OutputPrice, m.ContextLength, m.ID,
 	)
 	return err
 }
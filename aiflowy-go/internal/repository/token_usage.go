@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/pkg/snowflake"
+)
+
+// TokenUsage 配额统计的范围
+const (
+	TokenUsageScopeAccount = "account_id"
+	TokenUsageScopeTenant  = "tenant_id"
+)
+
+// tokenUsageGroupColumns 汇总维度对应的列
+var tokenUsageGroupColumns = map[string]string{
+	dto.TokenUsageGroupUser:   "account_id",
+	dto.TokenUsageGroupBot:    "bot_id",
+	dto.TokenUsageGroupModel:  "model_id",
+	dto.TokenUsageGroupTenant: "tenant_id",
+}
+
+// TokenUsageRepository token 用量数据访问层
+type TokenUsageRepository struct {
+	db *sql.DB
+}
+
+// NewTokenUsageRepository 创建 TokenUsageRepository
+func NewTokenUsageRepository() *TokenUsageRepository {
+	return &TokenUsageRepository{
+		db: GetDB(),
+	}
+}
+
+// Create 记录一次用量
+func (r *TokenUsageRepository) Create(ctx context.Context, record *entity.TokenUsageRecord) error {
+	if record.ID == 0 {
+		record.ID, _ = snowflake.GenerateID()
+	}
+
+	query := `
+		INSERT INTO tb_token_usage
+		(id, tenant_id, account_id, bot_id, model_id, model_name, conversation_id, message_id,
+		 prompt_tokens, completion_tokens, total_tokens, cost, created)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		record.ID, record.TenantID, record.AccountID, record.BotID, record.ModelID, record.ModelName,
+		nullInt64(record.ConversationID), nullInt64(record.MessageID),
+		record.PromptTokens, record.CompletionTokens, record.TotalTokens, record.Cost, record.Created,
+	)
+	return err
+}
+
+// SumByScope 统计范围内自 monthStart 起和自 dayStart 起的用量，scope 为 TokenUsageScope 常量
+func (r *TokenUsageRepository) SumByScope(ctx context.Context, scope string, id int64, monthStart, dayStart time.Time) (month, day entity.TokenUsageTotal, err error) {
+	query := `
+		SELECT COALESCE(SUM(total_tokens), 0), COALESCE(SUM(cost), 0),
+		       COALESCE(SUM(CASE WHEN created >= ? THEN total_tokens ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN created >= ? THEN cost ELSE 0 END), 0)
+		FROM tb_token_usage
+		WHERE ` + scope + ` = ? AND created >= ?
+	`
+	err = r.db.QueryRowContext(ctx, query, dayStart, dayStart, id, monthStart).Scan(
+		&month.Tokens, &month.Cost, &day.Tokens, &day.Cost,
+	)
+	return month, day, err
+}
+
+// Summarize 按维度汇总租户在时间范围内的用量，按总 tokens 倒序
+func (r *TokenUsageRepository) Summarize(ctx context.Context, req *dto.TokenUsageSummaryRequest, tenantID int64, from, to time.Time) ([]*entity.TokenUsageStat, error) {
+	column, ok := tokenUsageGroupColumns[req.GroupBy]
+	if !ok {
+		column = tokenUsageGroupColumns[dto.TokenUsageGroupModel]
+	}
+
+	where := " FROM tb_token_usage WHERE tenant_id = ?"
+	args := []interface{}{tenantID}
+
+	if req.BotID > 0 {
+		where += " AND bot_id = ?"
+		args = append(args, req.BotID)
+	}
+	if req.AccountID > 0 {
+		where += " AND account_id = ?"
+		args = append(args, req.AccountID)
+	}
+	if req.ModelID > 0 {
+		where += " AND model_id = ?"
+		args = append(args, req.ModelID)
+	}
+	if !from.IsZero() {
+		where += " AND created >= ?"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		where += " AND created < ?"
+		args = append(args, to)
+	}
+
+	query := `SELECT ` + column + `, COALESCE(MAX(model_name), ''), COUNT(*),
+		SUM(prompt_tokens), SUM(completion_tokens), SUM(total_tokens), SUM(cost)` + where +
+		" GROUP BY " + column + " ORDER BY SUM(total_tokens) DESC"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make([]*entity.TokenUsageStat, 0)
+	for rows.Next() {
+		var stat entity.TokenUsageStat
+		if err := rows.Scan(
+			&stat.ID, &stat.ModelName, &stat.Calls,
+			&stat.PromptTokens, &stat.CompletionTokens, &stat.TotalTokens, &stat.Cost,
+		); err != nil {
+			return nil, err
+		}
+		if column != "model_id" {
+			stat.ModelName = ""
+		}
+		stats = append(stats, &stat)
+	}
+	return stats, rows.Err()
+}
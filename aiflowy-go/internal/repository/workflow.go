@@ -25,15 +25,18 @@ func NewWorkflowRepository() *WorkflowRepository {
 
 // GetWorkflowByID 根据 ID 获取工作流
 func (r *WorkflowRepository) GetWorkflowByID(ctx context.Context, id int64) (*entity.Workflow, error) {
-	query := `SELECT id, alias, dept_id, tenant_id, title, description, icon, content,
-		created, created_by, modified, modified_by, english_name, status, category_id
-		FROM tb_workflow WHERE id = ?`
+	query := `SELECT w.id, w.alias, w.dept_id, w.tenant_id, w.title, w.description, w.icon, w.content,
+		w.created, w.created_by, w.modified, w.modified_by, w.english_name, w.status, w.category_id,
+		w.published_version_id, v.version
+		FROM tb_workflow w
+		LEFT JOIN tb_workflow_version v ON w.published_version_id = v.id
+		WHERE w.id = ?`
 
 	row := r.db.QueryRowContext(ctx, query, id)
 	workflow := &entity.Workflow{}
 
 	var alias, description, icon, content, englishName sql.NullString
-	var categoryID sql.NullInt64
+	var categoryID, publishedVersionID, publishedVersion sql.NullInt64
 	var modified sql.NullTime
 	var modifiedBy sql.NullInt64
 
@@ -42,6 +45,7 @@ func (r *WorkflowRepository) GetWorkflowByID(ctx context.Context, id int64) (*en
 		&workflow.Title, &description, &icon, &content,
 		&workflow.Created, &workflow.CreatedBy, &modified, &modifiedBy,
 		&englishName, &workflow.Status, &categoryID,
+		&publishedVersionID, &publishedVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -64,21 +68,26 @@ func (r *WorkflowRepository) GetWorkflowByID(ctx context.Context, id int64) (*en
 	if modifiedBy.Valid {
 		workflow.ModifiedBy = modifiedBy.Int64
 	}
+	workflow.PublishedVersionID = publishedVersionID.Int64
+	workflow.PublishedVersion = int(publishedVersion.Int64)
 
 	return workflow, nil
 }
 
 // GetWorkflowByAlias 根据别名获取工作流
 func (r *WorkflowRepository) GetWorkflowByAlias(ctx context.Context, alias string) (*entity.Workflow, error) {
-	query := `SELECT id, alias, dept_id, tenant_id, title, description, icon, content,
-		created, created_by, modified, modified_by, english_name, status, category_id
-		FROM tb_workflow WHERE alias = ?`
+	query := `SELECT w.id, w.alias, w.dept_id, w.tenant_id, w.title, w.description, w.icon, w.content,
+		w.created, w.created_by, w.modified, w.modified_by, w.english_name, w.status, w.category_id,
+		w.published_version_id, v.version
+		FROM tb_workflow w
+		LEFT JOIN tb_workflow_version v ON w.published_version_id = v.id
+		WHERE w.alias = ?`
 
 	row := r.db.QueryRowContext(ctx, query, alias)
 	workflow := &entity.Workflow{}
 
 	var aliasVal, description, icon, content, englishName sql.NullString
-	var categoryID sql.NullInt64
+	var categoryID, publishedVersionID, publishedVersion sql.NullInt64
 	var modified sql.NullTime
 	var modifiedBy sql.NullInt64
 
@@ -87,6 +96,7 @@ func (r *WorkflowRepository) GetWorkflowByAlias(ctx context.Context, alias strin
 		&workflow.Title, &description, &icon, &content,
 		&workflow.Created, &workflow.CreatedBy, &modified, &modifiedBy,
 		&englishName, &workflow.Status, &categoryID,
+		&publishedVersionID, &publishedVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -109,6 +119,8 @@ func (r *WorkflowRepository) GetWorkflowByAlias(ctx context.Context, alias strin
 	if modifiedBy.Valid {
 		workflow.ModifiedBy = modifiedBy.Int64
 	}
+	workflow.PublishedVersionID = publishedVersionID.Int64
+	workflow.PublishedVersion = int(publishedVersion.Int64)
 
 	return workflow, nil
 }
@@ -117,9 +129,10 @@ func (r *WorkflowRepository) GetWorkflowByAlias(ctx context.Context, alias strin
 func (r *WorkflowRepository) ListWorkflows(ctx context.Context, tenantID int64) ([]*entity.Workflow, error) {
 	query := `SELECT w.id, w.alias, w.dept_id, w.tenant_id, w.title, w.description, w.icon,
 		w.created, w.created_by, w.modified, w.modified_by, w.english_name, w.status, w.category_id,
-		c.category_name
+		c.category_name, w.published_version_id, v.version
 		FROM tb_workflow w
 		LEFT JOIN tb_workflow_category c ON w.category_id = c.id
+		LEFT JOIN tb_workflow_version v ON w.published_version_id = v.id
 		WHERE w.tenant_id = ? AND w.status >= 0
 		ORDER BY w.created DESC`
 
@@ -133,7 +146,7 @@ func (r *WorkflowRepository) ListWorkflows(ctx context.Context, tenantID int64)
 	for rows.Next() {
 		workflow := &entity.Workflow{}
 		var alias, description, icon, englishName, categoryName sql.NullString
-		var categoryID sql.NullInt64
+		var categoryID, publishedVersionID, publishedVersion sql.NullInt64
 		var modified sql.NullTime
 		var modifiedBy sql.NullInt64
 
@@ -142,6 +155,7 @@ func (r *WorkflowRepository) ListWorkflows(ctx context.Context, tenantID int64)
 			&workflow.Title, &description, &icon,
 			&workflow.Created, &workflow.CreatedBy, &modified, &modifiedBy,
 			&englishName, &workflow.Status, &categoryID, &categoryName,
+			&publishedVersionID, &publishedVersion,
 		)
 		if err != nil {
 			return nil, err
@@ -161,6 +175,8 @@ func (r *WorkflowRepository) ListWorkflows(ctx context.Context, tenantID int64)
 		if modifiedBy.Valid {
 			workflow.ModifiedBy = modifiedBy.Int64
 		}
+		workflow.PublishedVersionID = publishedVersionID.Int64
+		workflow.PublishedVersion = int(publishedVersion.Int64)
 
 		workflows = append(workflows, workflow)
 	}
@@ -337,7 +353,8 @@ func (r *WorkflowRepository) ListBotWorkflows(ctx context.Context, botID int64)
 		w.id, w.alias, w.title, w.description, w.icon, w.english_name, w.status
 		FROM tb_bot_workflow bw
 		LEFT JOIN tb_workflow w ON bw.workflow_id = w.id
-		WHERE bw.bot_id = ? AND w.status >= 0`
+		WHERE bw.bot_id = ? AND w.status >= 0
+		ORDER BY w.id`
 
 	rows, err := r.db.QueryContext(ctx, query, botID)
 	if err != nil {
@@ -378,7 +395,64 @@ func (r *WorkflowRepository) ListWorkflowsByBotID(ctx context.Context, botID int
 		w.created, w.created_by, w.modified, w.modified_by, w.english_name, w.status, w.category_id
 		FROM tb_workflow w
 		INNER JOIN tb_bot_workflow bw ON w.id = bw.workflow_id
-		WHERE bw.bot_id = ? AND w.status >= 0`
+		WHERE bw.bot_id = ? AND w.status >= 0
+		ORDER BY w.id`
+
+	rows, err := r.db.QueryContext(ctx, query, botID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workflows []*entity.Workflow
+	for rows.Next() {
+		workflow := &entity.Workflow{}
+		var alias, description, icon, content, englishName sql.NullString
+		var categoryID sql.NullInt64
+		var modified sql.NullTime
+		var modifiedBy sql.NullInt64
+
+		err := rows.Scan(
+			&workflow.ID, &alias, &workflow.DeptID, &workflow.TenantID,
+			&workflow.Title, &description, &icon, &content,
+			&workflow.Created, &workflow.CreatedBy, &modified, &modifiedBy,
+			&englishName, &workflow.Status, &categoryID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		workflow.Alias = alias.String
+		workflow.Description = description.String
+		workflow.Icon = icon.String
+		workflow.Content = content.String
+		workflow.EnglishName = englishName.String
+		if categoryID.Valid {
+			workflow.CategoryID = categoryID.Int64
+		}
+		if modified.Valid {
+			workflow.Modified = modified.Time
+		}
+		if modifiedBy.Valid {
+			workflow.ModifiedBy = modifiedBy.Int64
+		}
+
+		workflows = append(workflows, workflow)
+	}
+
+	return workflows, nil
+}
+
+// ListPublishedWorkflowsByBotID 获取 Bot 关联且已发布的工作流，Content 为发布版本的内容
+func (r *WorkflowRepository) ListPublishedWorkflowsByBotID(ctx context.Context, botID int64) ([]*entity.Workflow, error) {
+	query := `SELECT w.id, w.alias, w.dept_id, w.tenant_id, w.title, w.description, w.icon, v.content,
+		w.created, w.created_by, w.modified, w.modified_by, w.english_name, w.status, w.category_id,
+		w.published_version_id, v.version
+		FROM tb_workflow w
+		INNER JOIN tb_bot_workflow bw ON w.id = bw.workflow_id
+		INNER JOIN tb_workflow_version v ON w.published_version_id = v.id
+		WHERE bw.bot_id = ? AND w.status >= 0
+		ORDER BY w.id`
 
 	rows, err := r.db.QueryContext(ctx, query, botID)
 	if err != nil {
@@ -399,6 +473,7 @@ func (r *WorkflowRepository) ListWorkflowsByBotID(ctx context.Context, botID int
 			&workflow.Title, &description, &icon, &content,
 			&workflow.Created, &workflow.CreatedBy, &modified, &modifiedBy,
 			&englishName, &workflow.Status, &categoryID,
+			&workflow.PublishedVersionID, &workflow.PublishedVersion,
 		)
 		if err != nil {
 			return nil, err
@@ -478,6 +553,130 @@ func (r *WorkflowRepository) DeleteBotWorkflow(ctx context.Context, botID, workf
 	return err
 }
 
+// ========================== WorkflowVersion ==========================
+
+// PublishWorkflowVersion 创建新版本并设为发布版本，版本号在事务内按工作流递增
+func (r *WorkflowRepository) PublishWorkflowVersion(ctx context.Context, version *entity.WorkflowVersion) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 锁定工作流，避免并发发布得到相同的版本号
+	var workflowID int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM tb_workflow WHERE id = ? FOR UPDATE`, version.WorkflowID).Scan(&workflowID)
+	if err != nil {
+		return err
+	}
+
+	var maxVersion int
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(MAX(version), 0) FROM tb_workflow_version WHERE workflow_id = ?`,
+		version.WorkflowID,
+	).Scan(&maxVersion)
+	if err != nil {
+		return err
+	}
+
+	if version.ID == 0 {
+		version.ID = snowflake.MustGenerateID()
+	}
+	version.Version = maxVersion + 1
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO tb_workflow_version (id, workflow_id, version, content, comment, created, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		version.ID, version.WorkflowID, version.Version, version.Content,
+		nullString(version.Comment), version.Created, version.CreatedBy,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE tb_workflow SET published_version_id = ? WHERE id = ?`,
+		version.ID, version.WorkflowID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SetPublishedVersion 设置工作流的发布版本 (用于回滚)
+func (r *WorkflowRepository) SetPublishedVersion(ctx context.Context, workflowID, versionID int64) error {
+	query := `UPDATE tb_workflow SET published_version_id = ? WHERE id = ?`
+	_, err := r.db.ExecContext(ctx, query, versionID, workflowID)
+	return err
+}
+
+// GetWorkflowVersion 根据版本号获取工作流版本
+func (r *WorkflowRepository) GetWorkflowVersion(ctx context.Context, workflowID int64, version int) (*entity.WorkflowVersion, error) {
+	query := `SELECT id, workflow_id, version, content, comment, created, created_by
+		FROM tb_workflow_version WHERE workflow_id = ? AND version = ?`
+	return r.scanWorkflowVersion(r.db.QueryRowContext(ctx, query, workflowID, version))
+}
+
+// GetWorkflowVersionByID 根据 ID 获取工作流版本
+func (r *WorkflowRepository) GetWorkflowVersionByID(ctx context.Context, id int64) (*entity.WorkflowVersion, error) {
+	query := `SELECT id, workflow_id, version, content, comment, created, created_by
+		FROM tb_workflow_version WHERE id = ?`
+	return r.scanWorkflowVersion(r.db.QueryRowContext(ctx, query, id))
+}
+
+func (r *WorkflowRepository) scanWorkflowVersion(row *sql.Row) (*entity.WorkflowVersion, error) {
+	version := &entity.WorkflowVersion{}
+	var comment sql.NullString
+
+	err := row.Scan(
+		&version.ID, &version.WorkflowID, &version.Version, &version.Content,
+		&comment, &version.Created, &version.CreatedBy,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	version.Comment = comment.String
+	return version, nil
+}
+
+// ListWorkflowVersions 获取工作流的版本列表 (不含内容)，按版本号倒序
+func (r *WorkflowRepository) ListWorkflowVersions(ctx context.Context, workflowID int64) ([]*entity.WorkflowVersion, error) {
+	query := `SELECT id, workflow_id, version, comment, created, created_by
+		FROM tb_workflow_version WHERE workflow_id = ?
+		ORDER BY version DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*entity.WorkflowVersion
+	for rows.Next() {
+		version := &entity.WorkflowVersion{}
+		var comment sql.NullString
+
+		err := rows.Scan(
+			&version.ID, &version.WorkflowID, &version.Version,
+			&comment, &version.Created, &version.CreatedBy,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		version.Comment = comment.String
+		versions = append(versions, version)
+	}
+
+	return versions, nil
+}
+
 // helper functions
 func nullString(s string) sql.NullString {
 	if s == "" {
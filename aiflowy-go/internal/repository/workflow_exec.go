@@ -4,8 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"sync"
 	"time"
 
+	"github.com/aiflowy/aiflowy-go/internal/dto"
 	"github.com/aiflowy/aiflowy-go/internal/entity"
 	"github.com/aiflowy/aiflowy-go/pkg/snowflake"
 )
@@ -33,14 +36,16 @@ func (r *WorkflowExecRepository) CreateExecResult(ctx context.Context, result *e
 	query := `
 		INSERT INTO tb_workflow_exec_result
 		(id, exec_key, workflow_id, title, description, input, output, workflow_json,
-		 start_time, end_time, tokens, status, created_key, created_by, error_info)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 start_time, end_time, tokens, status, created_key, created_by, error_info,
+		 parent_exec_key, parent_step_id, workflow_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		result.ID, result.ExecKey, result.WorkflowID, result.Title, result.Description,
 		result.Input, result.Output, result.WorkflowJSON, result.StartTime, result.EndTime,
 		result.Tokens, result.Status, result.CreatedKey, result.CreatedBy, result.ErrorInfo,
+		nullString(result.ParentExecKey), nullInt64(result.ParentStepID), result.WorkflowVersion,
 	)
 	return err
 }
@@ -58,23 +63,48 @@ func (r *WorkflowExecRepository) UpdateExecResult(ctx context.Context, result *e
 	return err
 }
 
+// ResumeExecResult 将暂停中的执行记录更新为运行中，记录已不是暂停状态 (如已取消) 时不修改并返回 false
+func (r *WorkflowExecRepository) ResumeExecResult(ctx context.Context, id int64) (bool, error) {
+	query := `UPDATE tb_workflow_exec_result SET status = ? WHERE id = ? AND status = ?`
+	res, err := r.db.ExecContext(ctx, query, entity.ExecStatusRunning, id, entity.ExecStatusSuspended)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 // GetExecResultByExecKey 根据执行 Key 获取执行记录
 func (r *WorkflowExecRepository) GetExecResultByExecKey(ctx context.Context, execKey string) (*entity.WorkflowExecResult, error) {
+	return r.getExecResult(ctx, "FROM tb_workflow_exec_result r WHERE r.exec_key = ?", execKey)
+}
+
+// GetTenantExecResultByExecKey 根据执行 Key 获取租户的执行记录，执行的工作流不属于该租户时返回 nil
+func (r *WorkflowExecRepository) GetTenantExecResultByExecKey(ctx context.Context, execKey string, tenantID int64) (*entity.WorkflowExecResult, error) {
+	return r.getExecResult(ctx,
+		"FROM tb_workflow_exec_result r JOIN tb_workflow w ON w.id = r.workflow_id WHERE r.exec_key = ? AND w.tenant_id = ?",
+		execKey, tenantID,
+	)
+}
+
+// getExecResult 按条件获取一条执行记录，from 中执行记录表的别名为 r
+func (r *WorkflowExecRepository) getExecResult(ctx context.Context, from string, args ...interface{}) (*entity.WorkflowExecResult, error) {
 	query := `
-		SELECT id, exec_key, workflow_id, title, description, input, output, workflow_json,
-		       start_time, end_time, tokens, status, created_key, created_by, error_info
-		FROM tb_workflow_exec_result
-		WHERE exec_key = ?
-	`
+		SELECT r.id, r.exec_key, r.workflow_id, r.title, r.description, r.input, r.output, r.workflow_json,
+		       r.start_time, r.end_time, r.tokens, r.status, r.created_key, r.created_by, r.error_info,
+		       r.parent_exec_key, r.parent_step_id, r.workflow_version
+		` + from
 
 	var result entity.WorkflowExecResult
 	var endTime sql.NullTime
-	var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo sql.NullString
+	var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo, parentExecKey sql.NullString
+	var parentStepID sql.NullInt64
 
-	err := r.db.QueryRowContext(ctx, query, execKey).Scan(
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
 		&result.ID, &result.ExecKey, &result.WorkflowID, &title, &description,
 		&input, &output, &workflowJSON, &result.StartTime, &endTime,
 		&result.Tokens, &result.Status, &createdKey, &createdBy, &errorInfo,
+		&parentExecKey, &parentStepID, &result.WorkflowVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -91,6 +121,8 @@ func (r *WorkflowExecRepository) GetExecResultByExecKey(ctx context.Context, exe
 	result.CreatedKey = createdKey.String
 	result.CreatedBy = createdBy.String
 	result.ErrorInfo = errorInfo.String
+	result.ParentExecKey = parentExecKey.String
+	result.ParentStepID = parentStepID.Int64
 	if endTime.Valid {
 		result.EndTime = &endTime.Time
 	}
@@ -102,19 +134,22 @@ func (r *WorkflowExecRepository) GetExecResultByExecKey(ctx context.Context, exe
 func (r *WorkflowExecRepository) GetExecResultByID(ctx context.Context, id int64) (*entity.WorkflowExecResult, error) {
 	query := `
 		SELECT id, exec_key, workflow_id, title, description, input, output, workflow_json,
-		       start_time, end_time, tokens, status, created_key, created_by, error_info
+		       start_time, end_time, tokens, status, created_key, created_by, error_info,
+		       parent_exec_key, parent_step_id, workflow_version
 		FROM tb_workflow_exec_result
 		WHERE id = ?
 	`
 
 	var result entity.WorkflowExecResult
 	var endTime sql.NullTime
-	var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo sql.NullString
+	var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo, parentExecKey sql.NullString
+	var parentStepID sql.NullInt64
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&result.ID, &result.ExecKey, &result.WorkflowID, &title, &description,
 		&input, &output, &workflowJSON, &result.StartTime, &endTime,
 		&result.Tokens, &result.Status, &createdKey, &createdBy, &errorInfo,
+		&parentExecKey, &parentStepID, &result.WorkflowVersion,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -131,6 +166,8 @@ func (r *WorkflowExecRepository) GetExecResultByID(ctx context.Context, id int64
 	result.CreatedKey = createdKey.String
 	result.CreatedBy = createdBy.String
 	result.ErrorInfo = errorInfo.String
+	result.ParentExecKey = parentExecKey.String
+	result.ParentStepID = parentStepID.Int64
 	if endTime.Valid {
 		result.EndTime = &endTime.Time
 	}
@@ -142,7 +179,8 @@ func (r *WorkflowExecRepository) GetExecResultByID(ctx context.Context, id int64
 func (r *WorkflowExecRepository) ListExecResultsByWorkflowID(ctx context.Context, workflowID int64) ([]*entity.WorkflowExecResult, error) {
 	query := `
 		SELECT id, exec_key, workflow_id, title, description, input, output, workflow_json,
-		       start_time, end_time, tokens, status, created_key, created_by, error_info
+		       start_time, end_time, tokens, status, created_key, created_by, error_info,
+		       parent_exec_key, parent_step_id, workflow_version
 		FROM tb_workflow_exec_result
 		WHERE workflow_id = ?
 		ORDER BY start_time DESC
@@ -159,12 +197,14 @@ func (r *WorkflowExecRepository) ListExecResultsByWorkflowID(ctx context.Context
 	for rows.Next() {
 		var result entity.WorkflowExecResult
 		var endTime sql.NullTime
-		var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo sql.NullString
+		var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo, parentExecKey sql.NullString
+		var parentStepID sql.NullInt64
 
 		err := rows.Scan(
 			&result.ID, &result.ExecKey, &result.WorkflowID, &title, &description,
 			&input, &output, &workflowJSON, &result.StartTime, &endTime,
 			&result.Tokens, &result.Status, &createdKey, &createdBy, &errorInfo,
+			&parentExecKey, &parentStepID, &result.WorkflowVersion,
 		)
 		if err != nil {
 			return nil, err
@@ -178,6 +218,8 @@ func (r *WorkflowExecRepository) ListExecResultsByWorkflowID(ctx context.Context
 		result.CreatedKey = createdKey.String
 		result.CreatedBy = createdBy.String
 		result.ErrorInfo = errorInfo.String
+		result.ParentExecKey = parentExecKey.String
+		result.ParentStepID = parentStepID.Int64
 		if endTime.Valid {
 			result.EndTime = &endTime.Time
 		}
@@ -188,6 +230,166 @@ func (r *WorkflowExecRepository) ListExecResultsByWorkflowID(ctx context.Context
 	return results, nil
 }
 
+// ListExecResultsByStatus 获取指定状态的执行记录 (用于启动时恢复执行)
+func (r *WorkflowExecRepository) ListExecResultsByStatus(ctx context.Context, status entity.WorkflowExecStatus) ([]*entity.WorkflowExecResult, error) {
+	query := `
+		SELECT id, exec_key, workflow_id, title, description, input, output, workflow_json,
+		       start_time, end_time, tokens, status, created_key, created_by, error_info,
+		       parent_exec_key, parent_step_id, workflow_version
+		FROM tb_workflow_exec_result
+		WHERE status = ?
+		ORDER BY start_time ASC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*entity.WorkflowExecResult
+	for rows.Next() {
+		var result entity.WorkflowExecResult
+		var endTime sql.NullTime
+		var title, description, input, output, workflowJSON, createdKey, createdBy, errorInfo, parentExecKey sql.NullString
+		var parentStepID sql.NullInt64
+
+		err := rows.Scan(
+			&result.ID, &result.ExecKey, &result.WorkflowID, &title, &description,
+			&input, &output, &workflowJSON, &result.StartTime, &endTime,
+			&result.Tokens, &result.Status, &createdKey, &createdBy, &errorInfo,
+			&parentExecKey, &parentStepID, &result.WorkflowVersion,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Title = title.String
+		result.Description = description.String
+		result.Input = input.String
+		result.Output = output.String
+		result.WorkflowJSON = workflowJSON.String
+		result.CreatedKey = createdKey.String
+		result.CreatedBy = createdBy.String
+		result.ErrorInfo = errorInfo.String
+		result.ParentExecKey = parentExecKey.String
+		result.ParentStepID = parentStepID.Int64
+		if endTime.Valid {
+			result.EndTime = &endTime.Time
+		}
+
+		results = append(results, &result)
+	}
+
+	return results, nil
+}
+
+// PageExecResults 分页查询租户工作流的执行记录，from / to 为零值时不限制开始时间
+// 列表不返回工作流配置快照 (workflow_json)
+func (r *WorkflowExecRepository) PageExecResults(ctx context.Context, req *dto.WorkflowExecListRequest, tenantID int64, from, to time.Time) ([]*entity.WorkflowExecResult, int64, error) {
+	where := " FROM tb_workflow_exec_result r JOIN tb_workflow w ON w.id = r.workflow_id WHERE w.tenant_id = ?"
+	args := []interface{}{tenantID}
+
+	if req.WorkflowID > 0 {
+		where += " AND r.workflow_id = ?"
+		args = append(args, req.WorkflowID)
+	}
+	if req.Status != nil {
+		where += " AND r.status = ?"
+		args = append(args, *req.Status)
+	}
+	if req.CreatedKey != "" {
+		where += " AND r.created_key = ?"
+		args = append(args, req.CreatedKey)
+	}
+	if !from.IsZero() {
+		where += " AND r.start_time >= ?"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		where += " AND r.start_time < ?"
+		args = append(args, to)
+	}
+	if !req.IncludeChildren {
+		where += " AND (r.parent_exec_key IS NULL OR r.parent_exec_key = '')"
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT r.id, r.exec_key, r.workflow_id, r.title, r.description, r.input, r.output,
+		       r.start_time, r.end_time, r.tokens, r.status, r.created_key, r.created_by, r.error_info,
+		       r.parent_exec_key, r.parent_step_id, r.workflow_version` + where +
+		" ORDER BY r.start_time DESC LIMIT ? OFFSET ?"
+	args = append(args, req.GetPageSize(), req.GetOffset())
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var results []*entity.WorkflowExecResult
+	for rows.Next() {
+		var result entity.WorkflowExecResult
+		var endTime sql.NullTime
+		var title, description, input, output, createdKey, createdBy, errorInfo, parentExecKey sql.NullString
+		var parentStepID sql.NullInt64
+
+		err := rows.Scan(
+			&result.ID, &result.ExecKey, &result.WorkflowID, &title, &description,
+			&input, &output, &result.StartTime, &endTime,
+			&result.Tokens, &result.Status, &createdKey, &createdBy, &errorInfo,
+			&parentExecKey, &parentStepID, &result.WorkflowVersion,
+		)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		result.Title = title.String
+		result.Description = description.String
+		result.Input = input.String
+		result.Output = output.String
+		result.CreatedKey = createdKey.String
+		result.CreatedBy = createdBy.String
+		result.ErrorInfo = errorInfo.String
+		result.ParentExecKey = parentExecKey.String
+		result.ParentStepID = parentStepID.Int64
+		if endTime.Valid {
+			result.EndTime = &endTime.Time
+		}
+
+		results = append(results, &result)
+	}
+
+	return results, total, nil
+}
+
+// ListChildExecKeys 获取子工作流的执行标识，按父执行中调用它的步骤 ID 索引
+func (r *WorkflowExecRepository) ListChildExecKeys(ctx context.Context, parentExecKey string) (map[int64]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT parent_step_id, exec_key FROM tb_workflow_exec_result WHERE parent_exec_key = ?", parentExecKey)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make(map[int64]string)
+	for rows.Next() {
+		var stepID sql.NullInt64
+		var execKey string
+		if err := rows.Scan(&stepID, &execKey); err != nil {
+			return nil, err
+		}
+		if stepID.Valid {
+			keys[stepID.Int64] = execKey
+		}
+	}
+	return keys, nil
+}
+
 // ========================== WorkflowExecStep ==========================
 
 // CreateExecStep 创建执行步骤记录
@@ -195,18 +397,21 @@ func (r *WorkflowExecRepository) CreateExecStep(ctx context.Context, step *entit
 	if step.ID == 0 {
 		step.ID, _ = snowflake.GenerateID()
 	}
+	if step.Attempt == 0 {
+		step.Attempt = 1
+	}
 
 	query := `
 		INSERT INTO tb_workflow_exec_step
 		(id, record_id, exec_key, node_id, node_name, input, output, node_data,
-		 start_time, end_time, tokens, status, error_info)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 start_time, end_time, tokens, status, error_info, attempt, logs)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
 		step.ID, step.RecordID, step.ExecKey, step.NodeID, step.NodeName,
 		step.Input, step.Output, step.NodeData, step.StartTime, step.EndTime,
-		step.Tokens, step.Status, step.ErrorInfo,
+		step.Tokens, step.Status, step.ErrorInfo, step.Attempt, nullString(step.Logs),
 	)
 	return err
 }
@@ -215,11 +420,11 @@ func (r *WorkflowExecRepository) CreateExecStep(ctx context.Context, step *entit
 func (r *WorkflowExecRepository) UpdateExecStep(ctx context.Context, step *entity.WorkflowExecStep) error {
 	query := `
 		UPDATE tb_workflow_exec_step
-		SET output = ?, end_time = ?, tokens = ?, status = ?, error_info = ?
+		SET output = ?, end_time = ?, tokens = ?, status = ?, error_info = ?, logs = ?
 		WHERE id = ?
 	`
 	_, err := r.db.ExecContext(ctx, query,
-		step.Output, step.EndTime, step.Tokens, step.Status, step.ErrorInfo, step.ID,
+		step.Output, step.EndTime, step.Tokens, step.Status, step.ErrorInfo, nullString(step.Logs), step.ID,
 	)
 	return err
 }
@@ -228,7 +433,7 @@ func (r *WorkflowExecRepository) UpdateExecStep(ctx context.Context, step *entit
 func (r *WorkflowExecRepository) GetExecStepsByRecordID(ctx context.Context, recordID int64) ([]*entity.WorkflowExecStep, error) {
 	query := `
 		SELECT id, record_id, exec_key, node_id, node_name, input, output, node_data,
-		       start_time, end_time, tokens, status, error_info
+		       start_time, end_time, tokens, status, error_info, attempt, logs
 		FROM tb_workflow_exec_step
 		WHERE record_id = ?
 		ORDER BY start_time ASC
@@ -244,12 +449,12 @@ func (r *WorkflowExecRepository) GetExecStepsByRecordID(ctx context.Context, rec
 	for rows.Next() {
 		var step entity.WorkflowExecStep
 		var endTime sql.NullTime
-		var input, output, nodeData, errorInfo sql.NullString
+		var input, output, nodeData, errorInfo, logs sql.NullString
 
 		err := rows.Scan(
 			&step.ID, &step.RecordID, &step.ExecKey, &step.NodeID, &step.NodeName,
 			&input, &output, &nodeData, &step.StartTime, &endTime,
-			&step.Tokens, &step.Status, &errorInfo,
+			&step.Tokens, &step.Status, &errorInfo, &step.Attempt, &logs,
 		)
 		if err != nil {
 			return nil, err
@@ -259,6 +464,7 @@ func (r *WorkflowExecRepository) GetExecStepsByRecordID(ctx context.Context, rec
 		step.Output = output.String
 		step.NodeData = nodeData.String
 		step.ErrorInfo = errorInfo.String
+		step.Logs = logs.String
 		if endTime.Valid {
 			step.EndTime = &endTime.Time
 		}
@@ -273,7 +479,7 @@ func (r *WorkflowExecRepository) GetExecStepsByRecordID(ctx context.Context, rec
 func (r *WorkflowExecRepository) GetExecStepsByExecKey(ctx context.Context, execKey string) ([]*entity.WorkflowExecStep, error) {
 	query := `
 		SELECT id, record_id, exec_key, node_id, node_name, input, output, node_data,
-		       start_time, end_time, tokens, status, error_info
+		       start_time, end_time, tokens, status, error_info, attempt, logs
 		FROM tb_workflow_exec_step
 		WHERE exec_key = ?
 		ORDER BY start_time ASC
@@ -289,12 +495,12 @@ func (r *WorkflowExecRepository) GetExecStepsByExecKey(ctx context.Context, exec
 	for rows.Next() {
 		var step entity.WorkflowExecStep
 		var endTime sql.NullTime
-		var input, output, nodeData, errorInfo sql.NullString
+		var input, output, nodeData, errorInfo, logs sql.NullString
 
 		err := rows.Scan(
 			&step.ID, &step.RecordID, &step.ExecKey, &step.NodeID, &step.NodeName,
 			&input, &output, &nodeData, &step.StartTime, &endTime,
-			&step.Tokens, &step.Status, &errorInfo,
+			&step.Tokens, &step.Status, &errorInfo, &step.Attempt, &logs,
 		)
 		if err != nil {
 			return nil, err
@@ -304,6 +510,7 @@ func (r *WorkflowExecRepository) GetExecStepsByExecKey(ctx context.Context, exec
 		step.Output = output.String
 		step.NodeData = nodeData.String
 		step.ErrorInfo = errorInfo.String
+		step.Logs = logs.String
 		if endTime.Valid {
 			step.EndTime = &endTime.Time
 		}
@@ -332,14 +539,16 @@ func (r *WorkflowExecRepository) CreateExecResultWithSteps(ctx context.Context,
 	query := `
 		INSERT INTO tb_workflow_exec_result
 		(id, exec_key, workflow_id, title, description, input, output, workflow_json,
-		 start_time, end_time, tokens, status, created_key, created_by, error_info)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 start_time, end_time, tokens, status, created_key, created_by, error_info,
+		 parent_exec_key, parent_step_id, workflow_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = tx.ExecContext(ctx, query,
 		result.ID, result.ExecKey, result.WorkflowID, result.Title, result.Description,
 		result.Input, result.Output, result.WorkflowJSON, result.StartTime, result.EndTime,
 		result.Tokens, result.Status, result.CreatedKey, result.CreatedBy, result.ErrorInfo,
+		nullString(result.ParentExecKey), nullInt64(result.ParentStepID), result.WorkflowVersion,
 	)
 	if err != nil {
 		return err
@@ -371,19 +580,31 @@ func unmarshalJSON(s string, v interface{}) error {
 // ========================== 执行状态缓存 (内存) ==========================
 
 // ChainState 工作流执行状态 (内存中保持)
+// 并行分支会在多个 goroutine 中同时读写，访问变量和节点状态需通过下方的加锁方法
 type ChainState struct {
-	ExecuteID         string
-	WorkflowID        int64
-	RecordID          int64
-	Status            entity.WorkflowExecStatus
-	Variables         map[string]interface{}
-	NodeStates        map[string]*NodeState
-	Result            map[string]interface{}
-	Error             error
-	SuspendedNodeID   string                   // 当前暂停的节点 ID
-	SuspendedParams   []*SuspendedParam        // 暂停时等待的参数
-	CreatedAt         time.Time
-	UpdatedAt         time.Time
+	ExecuteID       string
+	WorkflowID      int64
+	RecordID        int64
+	Status          entity.WorkflowExecStatus
+	Variables       map[string]interface{}
+	NodeStates      map[string]*NodeState
+	Result          map[string]interface{}
+	Error           error
+	SuspendedNodeID string            // 当前暂停的节点 ID
+	SuspendedParams []*SuspendedParam // 暂停时等待的参数
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+
+	// VariableSources 节点写入的变量 -> 写入它的节点 ID：以节点 ID 保存的输出指向自身，
+	// 兼容旧引用的平铺字段指向产生它的节点，多个节点产生同名字段时为空 (该字段不再平铺)；
+	// 不在其中的变量为开始参数、全局变量与循环变量，节点输出不会覆盖它们
+	VariableSources map[string]string
+
+	// Scoped 作用域状态 (如循环的单次迭代)：不单独更新执行记录与检查点，由所属节点合并到父状态
+	Scoped bool
+
+	mu     sync.RWMutex
+	saveMu sync.Mutex // 串行化检查点写入
 }
 
 // NodeState 节点执行状态
@@ -401,8 +622,456 @@ type NodeState struct {
 
 // SuspendedParam 暂停时等待的参数
 type SuspendedParam struct {
-	Name        string `json:"name"`
-	Type        string `json:"type"`
-	Description string `json:"description,omitempty"`
-	Required    bool   `json:"required,omitempty"`
+	Name         string      `json:"name"`
+	Type         string      `json:"type"`
+	Description  string      `json:"description,omitempty"`
+	Required     bool        `json:"required,omitempty"`
+	DefaultValue interface{} `json:"defaultValue,omitempty"`
+}
+
+// GetStatus 获取执行状态
+func (s *ChainState) GetStatus() entity.WorkflowExecStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Status
+}
+
+// SetStatus 设置执行状态
+func (s *ChainState) SetStatus(status entity.WorkflowExecStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = status
+	s.UpdatedAt = time.Now()
+}
+
+// Complete 标记执行完成
+func (s *ChainState) Complete(result map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = entity.ExecStatusCompleted
+	s.Result = result
+	s.UpdatedAt = time.Now()
+}
+
+// Fail 标记执行失败，已失败或已取消时保留原状态
+func (s *ChainState) Fail(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Status == entity.ExecStatusFailed || s.Status == entity.ExecStatusCancelled {
+		return false
+	}
+	s.Status = entity.ExecStatusFailed
+	s.Error = err
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// Cancel 标记执行取消，仅运行中或暂停中的执行可以取消
+func (s *ChainState) Cancel(err error) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Status != entity.ExecStatusRunning && s.Status != entity.ExecStatusSuspended {
+		return false
+	}
+	s.Status = entity.ExecStatusCancelled
+	s.Error = err
+	s.SuspendedNodeID = ""
+	s.SuspendedParams = nil
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// Suspend 标记执行暂停
+func (s *ChainState) Suspend(nodeID string, params []*SuspendedParam) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Status = entity.ExecStatusSuspended
+	s.SuspendedNodeID = nodeID
+	s.SuspendedParams = params
+	s.UpdatedAt = time.Now()
+}
+
+// TryResume 执行仍暂停在 nodeID 时清除暂停信息并恢复为运行状态，返回是否恢复成功
+// 检查与修改在同一把锁内完成，并发的恢复请求只有一个成功，已取消的执行不会被恢复
+func (s *ChainState) TryResume(nodeID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Status != entity.ExecStatusSuspended || s.SuspendedNodeID != nodeID {
+		return false
+	}
+	s.Status = entity.ExecStatusRunning
+	s.SuspendedNodeID = ""
+	s.SuspendedParams = nil
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// GetVariable 获取单个变量
+func (s *ChainState) GetVariable(name string) (interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.Variables[name]
+	return v, ok
+}
+
+// SnapshotVariables 获取变量的浅拷贝，供节点执行器只读使用
+func (s *ChainState) SnapshotVariables() map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vars := make(map[string]interface{}, len(s.Variables))
+	for k, v := range s.Variables {
+		vars[k] = v
+	}
+	return vars
+}
+
+// MergeVariables 合并变量
+func (s *ChainState) MergeVariables(vars map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Variables == nil {
+		s.Variables = make(map[string]interface{})
+	}
+	for k, v := range vars {
+		s.Variables[k] = v
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// SetNodeOutput 记录节点输出：以节点 ID 为作用域保存，供 ${nodeId.field} 引用；
+// 为兼容旧工作流的 ${field} 引用，字段同时平铺到变量中，但只在字段名唯一时保留：
+// 不覆盖开始参数、全局变量与其它节点的 ID，多个节点产生同名字段时移除该平铺字段
+func (s *ChainState) SetNodeOutput(nodeID string, output map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Variables == nil {
+		s.Variables = make(map[string]interface{})
+	}
+	if s.VariableSources == nil {
+		s.VariableSources = make(map[string]string)
+	}
+
+	// 节点 ID 与开始参数或全局变量重名时保留变量，输出只能通过平铺字段引用
+	if _, declared := s.declared(nodeID); !declared {
+		s.Variables[nodeID] = output
+		s.VariableSources[nodeID] = nodeID
+	}
+	for k, v := range output {
+		source, tracked := s.VariableSources[k]
+		switch {
+		case !tracked:
+			if _, declared := s.declared(k); declared {
+				continue
+			}
+		case source == k:
+			// 其它节点的 ID
+			continue
+		case source != nodeID:
+			// 多个节点产生同名字段，引用有歧义
+			s.VariableSources[k] = ""
+			delete(s.Variables, k)
+			continue
+		}
+		s.Variables[k] = v
+		s.VariableSources[k] = nodeID
+	}
+	s.UpdatedAt = time.Now()
+}
+
+// declared 变量是否为开始参数、全局变量或循环变量 (不是由节点输出写入)，调用方需持有锁
+func (s *ChainState) declared(name string) (interface{}, bool) {
+	if _, tracked := s.VariableSources[name]; tracked {
+		return nil, false
+	}
+	v, ok := s.Variables[name]
+	return v, ok
+}
+
+// NewScope 创建作用域状态 (如循环的单次迭代)：复制当前变量与变量来源，
+// vars 中的变量 (如循环项与序号) 作为声明的变量覆盖同名变量
+func (s *ChainState) NewScope(vars map[string]interface{}) *ChainState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	child := &ChainState{
+		ExecuteID:       s.ExecuteID,
+		WorkflowID:      s.WorkflowID,
+		RecordID:        s.RecordID,
+		Status:          entity.ExecStatusRunning,
+		Variables:       make(map[string]interface{}, len(s.Variables)+len(vars)),
+		VariableSources: make(map[string]string, len(s.VariableSources)),
+		NodeStates:      make(map[string]*NodeState),
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Scoped:          true,
+	}
+	for k, v := range s.Variables {
+		child.Variables[k] = v
+	}
+	for k, source := range s.VariableSources {
+		child.VariableSources[k] = source
+	}
+	for k, v := range vars {
+		child.Variables[k] = v
+		delete(child.VariableSources, k)
+	}
+	return child
+}
+
+// GetNodeState 获取节点状态的拷贝
+func (s *ChainState) GetNodeState(nodeID string) (*NodeState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ns, ok := s.NodeStates[nodeID]
+	if !ok {
+		return nil, false
+	}
+	cp := *ns
+	return &cp, true
+}
+
+// SetNodeState 设置节点状态
+func (s *ChainState) SetNodeState(ns *NodeState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.NodeStates == nil {
+		s.NodeStates = make(map[string]*NodeState)
+	}
+	s.NodeStates[ns.NodeID] = ns
+	s.UpdatedAt = time.Now()
+}
+
+// ClaimNodeState 节点尚无状态时写入，返回是否写入成功 (用于保证汇聚节点只触发一次)
+func (s *ChainState) ClaimNodeState(ns *NodeState) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.NodeStates == nil {
+		s.NodeStates = make(map[string]*NodeState)
+	}
+	if _, exists := s.NodeStates[ns.NodeID]; exists {
+		return false
+	}
+	s.NodeStates[ns.NodeID] = ns
+	s.UpdatedAt = time.Now()
+	return true
+}
+
+// UpdateNodeState 在锁内修改节点状态
+func (s *ChainState) UpdateNodeState(nodeID string, fn func(ns *NodeState)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ns, ok := s.NodeStates[nodeID]; ok {
+		fn(ns)
+		s.UpdatedAt = time.Now()
+	}
+}
+
+// DeleteNodeState 删除节点状态
+func (s *ChainState) DeleteNodeState(nodeID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.NodeStates, nodeID)
+}
+
+// SnapshotNodeStates 获取所有节点状态的拷贝
+func (s *ChainState) SnapshotNodeStates() map[string]*NodeState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	states := make(map[string]*NodeState, len(s.NodeStates))
+	for id, ns := range s.NodeStates {
+		cp := *ns
+		states[id] = &cp
+	}
+	return states
+}
+
+// Snapshot 获取执行状态的一致性拷贝 (变量与节点状态均为浅拷贝)
+func (s *ChainState) Snapshot() *ChainState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp := &ChainState{
+		ExecuteID:       s.ExecuteID,
+		WorkflowID:      s.WorkflowID,
+		RecordID:        s.RecordID,
+		Status:          s.Status,
+		Variables:       make(map[string]interface{}, len(s.Variables)),
+		VariableSources: make(map[string]string, len(s.VariableSources)),
+		NodeStates:      make(map[string]*NodeState, len(s.NodeStates)),
+		Result:          s.Result,
+		Error:           s.Error,
+		SuspendedNodeID: s.SuspendedNodeID,
+		SuspendedParams: s.SuspendedParams,
+		CreatedAt:       s.CreatedAt,
+		UpdatedAt:       s.UpdatedAt,
+	}
+	for k, v := range s.Variables {
+		cp.Variables[k] = v
+	}
+	for k, source := range s.VariableSources {
+		cp.VariableSources[k] = source
+	}
+	for id, ns := range s.NodeStates {
+		nsCopy := *ns
+		cp.NodeStates[id] = &nsCopy
+	}
+	return cp
+}
+
+// ========================== 执行状态持久化 ==========================
+
+// chainCheckpoint ChainState 的持久化结构 (error 以字符串保存)
+type chainCheckpoint struct {
+	ExecuteID       string                     `json:"executeId"`
+	WorkflowID      int64                      `json:"workflowId,string"`
+	RecordID        int64                      `json:"recordId,string"`
+	Status          entity.WorkflowExecStatus  `json:"status"`
+	Variables       map[string]interface{}     `json:"variables,omitempty"`
+	VariableSources map[string]string          `json:"variableSources,omitempty"`
+	NodeStates      map[string]*nodeCheckpoint `json:"nodeStates,omitempty"`
+	Result          map[string]interface{}     `json:"result,omitempty"`
+	Error           string                     `json:"error,omitempty"`
+	SuspendedNodeID string                     `json:"suspendedNodeId,omitempty"`
+	SuspendedParams []*SuspendedParam          `json:"suspendedParams,omitempty"`
+	CreatedAt       time.Time                  `json:"createdAt"`
+	UpdatedAt       time.Time                  `json:"updatedAt"`
+}
+
+// nodeCheckpoint NodeState 的持久化结构
+type nodeCheckpoint struct {
+	NodeID    string                    `json:"nodeId"`
+	NodeName  string                    `json:"nodeName"`
+	Status    entity.WorkflowExecStatus `json:"status"`
+	Input     map[string]interface{}    `json:"input,omitempty"`
+	Output    map[string]interface{}    `json:"output,omitempty"`
+	Error     string                    `json:"error,omitempty"`
+	StartTime time.Time                 `json:"startTime"`
+	EndTime   *time.Time                `json:"endTime,omitempty"`
+	Tokens    int64                     `json:"tokens,omitempty"`
+}
+
+// MarshalCheckpoint 将执行状态序列化为检查点 JSON
+func (s *ChainState) MarshalCheckpoint() (string, error) {
+	snapshot := s.Snapshot()
+
+	cp := &chainCheckpoint{
+		ExecuteID:       snapshot.ExecuteID,
+		WorkflowID:      snapshot.WorkflowID,
+		RecordID:        snapshot.RecordID,
+		Status:          snapshot.Status,
+		Variables:       snapshot.Variables,
+		VariableSources: snapshot.VariableSources,
+		NodeStates:      make(map[string]*nodeCheckpoint, len(snapshot.NodeStates)),
+		Result:          snapshot.Result,
+		SuspendedNodeID: snapshot.SuspendedNodeID,
+		SuspendedParams: snapshot.SuspendedParams,
+		CreatedAt:       snapshot.CreatedAt,
+		UpdatedAt:       snapshot.UpdatedAt,
+	}
+	if snapshot.Error != nil {
+		cp.Error = snapshot.Error.Error()
+	}
+	for id, ns := range snapshot.NodeStates {
+		nodeCp := &nodeCheckpoint{
+			NodeID:    ns.NodeID,
+			NodeName:  ns.NodeName,
+			Status:    ns.Status,
+			Input:     ns.Input,
+			Output:    ns.Output,
+			StartTime: ns.StartTime,
+			EndTime:   ns.EndTime,
+			Tokens:    ns.Tokens,
+		}
+		if ns.Error != nil {
+			nodeCp.Error = ns.Error.Error()
+		}
+		cp.NodeStates[id] = nodeCp
+	}
+
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// UnmarshalCheckpoint 从检查点 JSON 还原执行状态
+func UnmarshalCheckpoint(data string) (*ChainState, error) {
+	var cp chainCheckpoint
+	if err := json.Unmarshal([]byte(data), &cp); err != nil {
+		return nil, err
+	}
+
+	state := &ChainState{
+		ExecuteID:       cp.ExecuteID,
+		WorkflowID:      cp.WorkflowID,
+		RecordID:        cp.RecordID,
+		Status:          cp.Status,
+		Variables:       cp.Variables,
+		VariableSources: cp.VariableSources,
+		NodeStates:      make(map[string]*NodeState, len(cp.NodeStates)),
+		Result:          cp.Result,
+		SuspendedNodeID: cp.SuspendedNodeID,
+		SuspendedParams: cp.SuspendedParams,
+		CreatedAt:       cp.CreatedAt,
+		UpdatedAt:       cp.UpdatedAt,
+	}
+	if state.Variables == nil {
+		state.Variables = make(map[string]interface{})
+	}
+	if cp.Error != "" {
+		state.Error = errors.New(cp.Error)
+	}
+	for id, nodeCp := range cp.NodeStates {
+		ns := &NodeState{
+			NodeID:    nodeCp.NodeID,
+			NodeName:  nodeCp.NodeName,
+			Status:    nodeCp.Status,
+			Input:     nodeCp.Input,
+			Output:    nodeCp.Output,
+			StartTime: nodeCp.StartTime,
+			EndTime:   nodeCp.EndTime,
+			Tokens:    nodeCp.Tokens,
+		}
+		if nodeCp.Error != "" {
+			ns.Error = errors.New(nodeCp.Error)
+		}
+		state.NodeStates[id] = ns
+	}
+
+	return state, nil
+}
+
+// SaveChainState 保存执行状态检查点
+// 同一执行的检查点串行写入，保证后写入的快照不会被较早的快照覆盖
+func (r *WorkflowExecRepository) SaveChainState(ctx context.Context, state *ChainState) error {
+	state.saveMu.Lock()
+	defer state.saveMu.Unlock()
+
+	data, err := state.MarshalCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE tb_workflow_exec_result SET chain_state = ? WHERE exec_key = ?`
+	_, err = r.db.ExecContext(ctx, query, data, state.ExecuteID)
+	return err
+}
+
+// GetChainState 加载执行状态检查点，不存在时返回 nil
+func (r *WorkflowExecRepository) GetChainState(ctx context.Context, execKey string) (*ChainState, error) {
+	query := `SELECT chain_state FROM tb_workflow_exec_result WHERE exec_key = ?`
+
+	var data sql.NullString
+	err := r.db.QueryRowContext(ctx, query, execKey).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+
+	return UnmarshalCheckpoint(data.String)
 }
@@ -180,6 +180,7 @@ func SetupRoutes(e *echo.Echo) {
 	botGroup.POST("/chat", botHandler.Chat) // Bot streaming chat API
 	botGroup.POST("/voiceInput", botHandler.VoiceInput)
 	botGroup.POST("/prompt/chore/chat", botHandler.PromptChoreChat)
+	botGroup.GET("/usage/summary", botHandler.UsageSummary)
 
 	// Bot API Key management
 	botApiKeyHandler := bot.NewBotApiKeyHandler()
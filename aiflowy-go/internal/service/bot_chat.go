@@ -17,6 +17,8 @@ import (
 	"github.com/aiflowy/aiflowy-go/internal/repository"
 	"github.com/aiflowy/aiflowy-go/internal/service/llm"
 	aitool "github.com/aiflowy/aiflowy-go/internal/service/tool"
+	"github.com/aiflowy/aiflowy-go/internal/service/tool/builtin"
+	"github.com/aiflowy/aiflowy-go/pkg/metrics"
 	"github.com/aiflowy/aiflowy-go/pkg/protocol"
 	"github.com/aiflowy/aiflowy-go/pkg/snowflake"
 )
@@ -26,6 +28,9 @@ type BotChatService struct {
 	botRepo   *repository.BotRepository
 	modelRepo *repository.ModelRepository
 	factory   *llm.ModelFactory
+
+	attachments *attachmentLoader
+	usage       *TokenUsageService
 }
 
 // NewBotChatService creates a new BotChatService
@@ -34,6 +39,9 @@ func NewBotChatService() *BotChatService {
 		botRepo:   repository.GetBotRepository(),
 		modelRepo: repository.GetModelRepository(),
 		factory:   llm.NewModelFactory(),
+
+		attachments: newAttachmentLoader(),
+		usage:       NewTokenUsageService(),
 	}
 }
 
@@ -43,6 +51,7 @@ type BotChatRequest struct {
 	ConversationID int64               `json:"conversationId,string"`
 	Message        string              `json:"message"`
 	Image          string              `json:"image,omitempty"`
+	Attachments    []*ChatAttachment   `json:"attachments,omitempty"`
 	Stream         bool                `json:"stream"`
 	Options        *BotChatOptions     `json:"options,omitempty"`
 }
@@ -60,11 +69,12 @@ type BotChatOptions struct {
 
 // BotChatResponse represents a non-streaming chat response
 type BotChatResponse struct {
-	ConversationID string `json:"conversationId"`
-	MessageID      string `json:"messageId"`
-	Content        string `json:"content"`
-	Thinking       string `json:"thinking,omitempty"`
-	Role           string `json:"role"`
+	ConversationID string             `json:"conversationId"`
+	MessageID      string             `json:"messageId"`
+	Content        string             `json:"content"`
+	Thinking       string             `json:"thinking,omitempty"`
+	Role           string             `json:"role"`
+	Usage          *entity.TokenUsage `json:"usage,omitempty"`
 }
 
 // StreamCallback is called for each streaming chunk
@@ -78,12 +88,14 @@ type ChatContext struct {
 	Model          *entity.Model
 	Messages       []*entity.BotMessage
 	UserMessage    *entity.BotMessage
+	UserParts      []schema.MessageInputPart // Multimodal parts of the user message, nil for text only
 	AssistantMsgID int64
 	Builder        *protocol.Builder
 	StartTime      time.Time
 	EnableTools    bool                  // Whether tools are enabled for this chat
 	ToolInfos      []*schema.ToolInfo    // Tool infos for LLM binding
-	ToolNames      []string              // Tool names for execution
+	Tools          *aitool.ToolSet       // Request-scoped tools for execution
+	Usage          *chatUsage            // Token usage of the turn, history summarization included
 }
 
 // Chat performs a bot chat (non-streaming)
@@ -92,9 +104,12 @@ func (s *BotChatService) Chat(ctx context.Context, req *BotChatRequest, userID i
 	if err != nil {
 		return nil, err
 	}
+	usage := chatCtx.Usage
+	// Tokens are consumed even when a later step fails
+	defer s.recordUsage(ctx, chatCtx, usage)
 
 	// Build messages for LLM
-	llmMessages := s.buildLLMMessages(chatCtx)
+	llmMessages := s.buildLLMMessages(ctx, chatCtx)
 
 	// Create chat model
 	baseChatModel, err := s.factory.CreateChatModel(ctx, chatCtx.Model)
@@ -119,6 +134,8 @@ func (s *BotChatService) Chat(ctx context.Context, req *BotChatRequest, userID i
 	// Tool call loop - max 5 iterations to prevent infinite loops
 	const maxToolIterations = 5
 	var finalContent string
+	var toolMessages []*schema.Message // Tool calls and results, replayed with the history
+	toolCtx := withWorkflowToolContext(ctx, userID, chatCtx.Builder, nil)
 
 	for i := 0; i < maxToolIterations; i++ {
 		// Generate response
@@ -126,29 +143,28 @@ func (s *BotChatService) Chat(ctx context.Context, req *BotChatRequest, userID i
 		if err != nil {
 			return nil, apierrors.InternalError(fmt.Sprintf("生成回复失败: %v", err))
 		}
+		usage.add(result.ResponseMeta)
 
 		// Check if LLM wants to call tools
 		if len(result.ToolCalls) > 0 {
 			// Add assistant message with tool calls to history
 			llmMessages = append(llmMessages, result)
+			toolMessages = append(toolMessages, result)
 
 			// Execute tools
 			for _, tc := range result.ToolCalls {
-				toolResult, err := s.executeTool(ctx, tc)
+				toolResult, err := s.executeTool(toolCtx, chatCtx, tc)
 				if err != nil {
 					// Add error as tool result
-					llmMessages = append(llmMessages, schema.ToolMessage(
-						fmt.Sprintf("Error: %v", err),
-						tc.ID,
-						schema.WithToolName(tc.Function.Name),
-					))
-				} else {
-					llmMessages = append(llmMessages, schema.ToolMessage(
-						toolResult,
-						tc.ID,
-						schema.WithToolName(tc.Function.Name),
-					))
+					toolResult = fmt.Sprintf("Error: %v", err)
 				}
+				toolMsg := schema.ToolMessage(
+					toolResult,
+					tc.ID,
+					schema.WithToolName(tc.Function.Name),
+				)
+				llmMessages = append(llmMessages, toolMsg)
+				toolMessages = append(toolMessages, toolMsg)
 			}
 			// Continue loop to get final response
 			continue
@@ -167,6 +183,7 @@ func (s *BotChatService) Chat(ctx context.Context, req *BotChatRequest, userID i
 		ConversationID: req.ConversationID,
 		Role:           entity.RoleAssistant,
 		Content:        finalContent,
+		Options:        assistantMessageOptions(chatCtx.Model, usage, "", toolMessages),
 		Created:        time.Now(),
 		Modified:       time.Now(),
 	}
@@ -180,21 +197,82 @@ func (s *BotChatService) Chat(ctx context.Context, req *BotChatRequest, userID i
 		MessageID:      strconv.FormatInt(chatCtx.AssistantMsgID, 10),
 		Content:        finalContent,
 		Role:           entity.RoleAssistant,
+		Usage:          usage.tokenUsage(),
 	}, nil
 }
 
-// executeTool executes a tool and returns the result
-func (s *BotChatService) executeTool(ctx context.Context, tc schema.ToolCall) (string, error) {
-	registry := aitool.GetRegistry()
-	return registry.Execute(ctx, tc.Function.Name, tc.Function.Arguments)
+// executeTool executes a tool of the chat's tool set and returns the result
+func (s *BotChatService) executeTool(ctx context.Context, chatCtx *ChatContext, tc schema.ToolCall) (string, error) {
+	return chatCtx.Tools.Execute(ctx, tc.Function.Name, tc.Function.Arguments)
+}
+
+// recordUsage saves the token usage of a chat turn and reports it to metrics.
+// Nothing is recorded when the provider returned no usage
+func (s *BotChatService) recordUsage(ctx context.Context, chatCtx *ChatContext, usage *chatUsage) {
+	if usage.tokenUsage() == nil {
+		return
+	}
+	metrics.RecordLLMTokens(chatCtx.Model.ModelName, modelProviderType(chatCtx.Model), usage.PromptTokens, usage.CompletionTokens)
+
+	record := &entity.TokenUsageRecord{
+		TenantID:         chatCtx.Bot.TenantID,
+		AccountID:        chatCtx.UserMessage.AccountID,
+		BotID:            chatCtx.Bot.ID,
+		ModelID:          chatCtx.Model.ID,
+		ModelName:        chatCtx.Model.ModelName,
+		ConversationID:   chatCtx.UserMessage.ConversationID,
+		MessageID:        chatCtx.AssistantMsgID,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		Cost:             tokenCost(chatCtx.Model, usage.PromptTokens, usage.CompletionTokens),
+	}
+	// The client may be gone, the usage is still recorded
+	if err := s.usage.Record(context.WithoutCancel(ctx), record); err != nil {
+		fmt.Printf("Failed to record token usage: %v\n", err)
+	}
+}
+
+// assistantOptions are the options saved on an assistant message. Thinking
+// keeps the key assistant messages have always used
+type assistantOptions struct {
+	entity.BotMessageOptions
+	Thinking     string            `json:"thinking,omitempty"`
+	ToolMessages []*schema.Message `json:"toolMessages,omitempty"` // Tool calls and results before the reply
+}
+
+// assistantMessageOptions encodes the usage, thinking and tool exchange of an
+// assistant message
+func assistantMessageOptions(model *entity.Model, usage *chatUsage, thinking string, toolMessages []*schema.Message) string {
+	options := &assistantOptions{
+		BotMessageOptions: entity.BotMessageOptions{
+			TokenUsage:   usage.tokenUsage(),
+			ModelName:    model.ModelName,
+			FinishReason: usage.FinishReason,
+		},
+		Thinking:     thinking,
+		ToolMessages: storedToolMessages(toolMessages),
+	}
+	if options.TokenUsage != nil {
+		options.Cost = tokenCost(model, usage.PromptTokens, usage.CompletionTokens)
+	}
+	data, _ := json.Marshal(options)
+	return string(data)
 }
 
 // ChatStream performs a bot chat with streaming response
 func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, userID int64, callback StreamCallback) error {
 	chatCtx, err := s.prepareChat(ctx, req, userID)
 	if err != nil {
+		if bizErr, ok := isQuotaExceeded(err); ok {
+			// The stream is already open, so the rejection is sent as an event
+			return callback(protocol.NewBuilder("", "").BusinessError("QUOTA_EXCEEDED", bizErr.Message))
+		}
 		return err
 	}
+	usage := chatCtx.Usage
+	// Tokens are consumed even when a later step fails
+	defer s.recordUsage(ctx, chatCtx, usage)
 
 	// Send status: running
 	if err := callback(chatCtx.Builder.SystemStatus("running")); err != nil {
@@ -202,7 +280,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 	}
 
 	// Build messages for LLM
-	llmMessages := s.buildLLMMessages(chatCtx)
+	llmMessages := s.buildLLMMessages(ctx, chatCtx)
 
 	// Create chat model
 	baseChatModel, err := s.factory.CreateChatModel(ctx, chatCtx.Model)
@@ -229,6 +307,9 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 	const maxToolIterations = 5
 	var fullContent string
 	var fullThinking string
+	var toolMessages []*schema.Message // Tool calls and results, replayed with the history
+	// Workflow tools relay their progress to this stream
+	toolCtx := withWorkflowToolContext(ctx, userID, chatCtx.Builder, callback)
 
 	for iteration := 0; iteration < maxToolIterations; iteration++ {
 		// Generate streaming response
@@ -245,6 +326,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 		var inThinking bool
 		toolCallsMap := make(map[int]*schema.ToolCall) // Use map to merge tool calls by index
 		var currentMsg *schema.Message
+		var responseMeta schema.ResponseMeta
 
 		// Read stream chunks
 		for {
@@ -260,6 +342,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 			}
 
 			currentMsg = chunk
+			mergeStreamMeta(&responseMeta, chunk)
 
 			// Collect and merge tool calls from chunks (they come in pieces with Index)
 			for _, tc := range chunk.ToolCalls {
@@ -315,6 +398,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 			}
 		}
 		streamReader.Close()
+		usage.add(&responseMeta)
 
 		// Convert tool calls map to slice, filtering out invalid ones
 		var toolCalls []schema.ToolCall
@@ -335,6 +419,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 				ToolCalls: toolCalls,
 			}
 			llmMessages = append(llmMessages, assistantWithTools)
+			toolMessages = append(toolMessages, assistantWithTools)
 
 			// Execute each tool and add results
 			for _, tc := range toolCalls {
@@ -346,7 +431,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 				}
 
 				// Execute tool
-				toolResult, execErr := s.executeTool(ctx, tc)
+				toolResult, execErr := s.executeTool(toolCtx, chatCtx, tc)
 				status := "success"
 				if execErr != nil {
 					status = "error"
@@ -359,11 +444,13 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 				}
 
 				// Add tool result to messages
-				llmMessages = append(llmMessages, schema.ToolMessage(
+				toolMsg := schema.ToolMessage(
 					toolResult,
 					tc.ID,
 					schema.WithToolName(tc.Function.Name),
-				))
+				)
+				llmMessages = append(llmMessages, toolMsg)
+				toolMessages = append(toolMessages, toolMsg)
 			}
 
 			// Continue loop to get response after tool execution
@@ -390,16 +477,11 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 		ConversationID: req.ConversationID,
 		Role:           entity.RoleAssistant,
 		Content:        fullContent,
+		Options:        assistantMessageOptions(chatCtx.Model, usage, fullThinking, toolMessages),
 		Created:        time.Now(),
 		Modified:       time.Now(),
 	}
 
-	// Store thinking in options if present
-	if fullThinking != "" {
-		thinkingOpts, _ := json.Marshal(map[string]string{"thinking": fullThinking})
-		assistantMsg.Options = string(thinkingOpts)
-	}
-
 	if err := s.botRepo.CreateMessage(ctx, assistantMsg); err != nil {
 		// Log but don't fail
 		fmt.Printf("Failed to save assistant message: %v\n", err)
@@ -407,11 +489,7 @@ func (s *BotChatService) ChatStream(ctx context.Context, req *BotChatRequest, us
 
 	// Send done event with metadata
 	latency := time.Since(chatCtx.StartTime).Milliseconds()
-	meta := &protocol.Meta{
-		LatencyMs: latency,
-		ModelName: chatCtx.Model.ModelName,
-	}
-	if err := callback(chatCtx.Builder.SystemDone(meta)); err != nil {
+	if err := callback(chatCtx.Builder.SystemDone(usage.meta(chatCtx.Model.ModelName, latency))); err != nil {
 		return err
 	}
 
@@ -426,7 +504,8 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 	if req.BotID == 0 {
 		return nil, apierrors.BadRequest("缺少机器人ID")
 	}
-	if req.Message == "" {
+	attachments := requestAttachments(req)
+	if req.Message == "" && len(attachments) == 0 {
 		return nil, apierrors.BadRequest("消息内容不能为空")
 	}
 
@@ -439,6 +518,11 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		return nil, apierrors.NotFound("机器人不存在")
 	}
 
+	// Reject the chat before anything is saved once a quota is used up
+	if err := s.usage.CheckQuota(ctx, bot.TenantID, userID); err != nil {
+		return nil, err
+	}
+
 	// Parse bot model options
 	var botOptions entity.BotModelOptions
 	if bot.ModelOptions != "" {
@@ -464,6 +548,16 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		return nil, apierrors.NotFound("模型不存在")
 	}
 
+	// Turn attachments into multimodal parts before anything is saved, so
+	// media the model can't accept is rejected with a clear error
+	var userParts []schema.MessageInputPart
+	if len(attachments) > 0 {
+		userParts, err = s.attachments.messageParts(ctx, model, req.Message, attachments)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Handle conversation
 	var conversation *entity.BotConversation
 	if req.ConversationID == 0 {
@@ -483,7 +577,7 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		now := time.Now()
 		conversation = &entity.BotConversation{
 			ID:         req.ConversationID,
-			Title:      s.generateConversationTitle(req.Message),
+			Title:      s.generateConversationTitle(conversationTitleText(req.Message, attachments)),
 			BotID:      req.BotID,
 			AccountID:  userID,
 			Created:    now,
@@ -497,18 +591,14 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		}
 	}
 
-	// Get historical messages for context
-	var historyMessages []*entity.BotMessage
-	historyCount := 10 // Default
-	if botOptions.EnableThinking && botOptions.ThinkingBudget > 0 {
-		// Reduce history when using thinking mode to save tokens
-		historyCount = 5
-	}
+	// Get the history not covered by the conversation summary yet; how much of
+	// it is replayed is decided against the token budget in buildLLMMessages
+	historyCount := maxHistoryMessages
 	if req.Options != nil && req.Options.HistoryCount != nil {
 		historyCount = *req.Options.HistoryCount
 	}
 
-	historyMessages, err = s.botRepo.GetRecentMessages(ctx, req.ConversationID, historyCount)
+	historyMessages, err := s.botRepo.GetRecentMessagesAfter(ctx, req.ConversationID, conversation.SummaryMessageID, historyCount)
 	if err != nil {
 		// Log but continue
 		fmt.Printf("Failed to get history messages: %v\n", err)
@@ -527,6 +617,10 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		Created:        time.Now(),
 		Modified:       time.Now(),
 	}
+	if len(req.Attachments) > 0 {
+		options, _ := json.Marshal(&messageOptions{Attachments: attachments})
+		userMsg.Options = string(options)
+	}
 	if err := s.botRepo.CreateMessage(ctx, userMsg); err != nil {
 		// Log but continue
 		fmt.Printf("Failed to save user message: %v\n", err)
@@ -541,43 +635,11 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		strconv.FormatInt(assistantMsgID, 10),
 	)
 
-	// Load tools - builtin tools + Bot plugins
-	var enableTools bool
-	var toolInfos []*schema.ToolInfo
-	var toolNames []string
-
-	registry := aitool.GetRegistry()
-
-	// 1. Load builtin tools
-	builtinTools := registry.GetAll()
-	if len(builtinTools) > 0 {
-		enableTools = true
-		builtinInfos, _ := registry.GetToolInfos(ctx)
-		toolInfos = append(toolInfos, builtinInfos...)
-		for _, t := range builtinTools {
-			toolNames = append(toolNames, t.Name())
-		}
-	}
-
-	// 2. Load Bot plugin tools
-	pluginToolService := NewPluginToolService()
-	pluginToolInfos, err := pluginToolService.LoadBotPluginTools(ctx, req.BotID)
-	if err == nil && len(pluginToolInfos) > 0 {
-		enableTools = true
-		toolInfos = append(toolInfos, pluginToolInfos...)
-		for _, ti := range pluginToolInfos {
-			toolNames = append(toolNames, ti.Name)
-		}
-	}
-
-	// 3. Load Bot knowledge base tools (RAG)
-	knowledgeToolInfos, err := s.loadBotKnowledgeTools(ctx, req.BotID)
-	if err == nil && len(knowledgeToolInfos) > 0 {
-		enableTools = true
-		toolInfos = append(toolInfos, knowledgeToolInfos...)
-		for _, ti := range knowledgeToolInfos {
-			toolNames = append(toolNames, ti.Name)
-		}
+	// Build the chat's tool set from the bot's configuration
+	tools := s.loadBotTools(ctx, bot)
+	toolInfos, err := tools.ToolInfos(ctx)
+	if err != nil {
+		return nil, apierrors.InternalError(fmt.Sprintf("加载工具失败: %v", err))
 	}
 
 	return &ChatContext{
@@ -587,45 +649,72 @@ func (s *BotChatService) prepareChat(ctx context.Context, req *BotChatRequest, u
 		Model:          model,
 		Messages:       historyMessages,
 		UserMessage:    userMsg,
+		UserParts:      userParts,
 		AssistantMsgID: assistantMsgID,
 		Builder:        builder,
 		StartTime:      startTime,
-		EnableTools:    enableTools,
+		EnableTools:    tools.Len() > 0,
 		ToolInfos:      toolInfos,
-		ToolNames:      toolNames,
+		Tools:          tools,
+		Usage:          &chatUsage{},
 	}, nil
 }
 
-// buildLLMMessages builds the message list for LLM
-func (s *BotChatService) buildLLMMessages(chatCtx *ChatContext) []*schema.Message {
+// buildLLMMessages builds the message list for LLM. The history is replayed
+// to the token budget left by the system prompt, tools and current message;
+// older turns are covered by the conversation summary
+func (s *BotChatService) buildLLMMessages(ctx context.Context, chatCtx *ChatContext) []*schema.Message {
 	var messages []*schema.Message
 
-	// Add system prompt if present
-	if chatCtx.BotOptions.SystemPrompt != "" {
-		messages = append(messages, &schema.Message{
-			Role:    schema.System,
-			Content: chatCtx.BotOptions.SystemPrompt,
-		})
+	// Build current user message
+	var current *schema.Message
+	if len(chatCtx.UserParts) > 0 {
+		// The text is the first part; the content must stay empty for multimodal messages
+		current = &schema.Message{
+			Role:                  schema.User,
+			UserInputMultiContent: chatCtx.UserParts,
+		}
+	} else {
+		current = &schema.Message{
+			Role:    schema.User,
+			Content: chatCtx.UserMessage.Content,
+		}
 	}
 
-	// Add historical messages
-	for _, msg := range chatCtx.Messages {
-		role := schema.RoleType(msg.Role)
+	// Select the history, this may update the summary
+	fixedTokens := estimateTokens(chatCtx.BotOptions.SystemPrompt) + estimateMessageTokens(current) + estimateToolTokens(chatCtx.ToolInfos)
+	history := s.historyMessages(ctx, chatCtx, fixedTokens)
+
+	// Add system prompt if present
+	if systemPrompt := withSummary(chatCtx.BotOptions.SystemPrompt, conversationSummary(chatCtx)); systemPrompt != "" {
 		messages = append(messages, &schema.Message{
-			Role:    role,
-			Content: msg.Content,
+			Role:    schema.System,
+			Content: systemPrompt,
 		})
 	}
 
-	// Add current user message
-	messages = append(messages, &schema.Message{
-		Role:    schema.User,
-		Content: chatCtx.UserMessage.Content,
-	})
+	messages = append(messages, history...)
+	messages = append(messages, current)
 
 	return messages
 }
 
+// historyUserMessage rebuilds a user message of the history with its
+// attachments. Attachments that can no longer be sent (the bot's model changed
+// or the file is gone) are replaced by a short note instead of failing the chat
+func (s *BotChatService) historyUserMessage(ctx context.Context, model *entity.Model, msg *entity.BotMessage, attachments []*ChatAttachment) *schema.Message {
+	parts, err := s.attachments.messageParts(ctx, model, msg.Content, attachments)
+	if err == nil {
+		return &schema.Message{Role: schema.User, UserInputMultiContent: parts}
+	}
+
+	content := msg.Content
+	for _, a := range attachments {
+		content += fmt.Sprintf("\n[附件: %s]", a.displayName())
+	}
+	return &schema.Message{Role: schema.User, Content: content}
+}
+
 // applyOptionsOverride applies request options to bot options
 func (s *BotChatService) applyOptionsOverride(botOpts *entity.BotModelOptions, reqOpts *BotChatOptions) {
 	if reqOpts.Temperature != nil {
@@ -661,12 +750,22 @@ func (s *BotChatService) generateConversationTitle(message string) string {
 	return message
 }
 
+// conversationTitleText returns the text a conversation title is generated
+// from: the message, or the first attachment name for attachment-only messages
+func conversationTitleText(message string, attachments []*ChatAttachment) string {
+	if message == "" && len(attachments) > 0 {
+		return attachments[0].displayName()
+	}
+	return message
+}
+
 // GetChatDTO converts BotChatRequest from DTO
 type BotChatRequestDTO struct {
 	BotID          int64           `json:"botId,string"`
 	ConversationID int64           `json:"conversationId,string"`
 	Message        string          `json:"message"`
-	Image          string          `json:"image,omitempty"`
+	Image          string            `json:"image,omitempty"`
+	Attachments    []*ChatAttachment `json:"attachments,omitempty"`
 	Stream         bool            `json:"stream"`
 	Options        *BotChatOptions `json:"options,omitempty"`
 }
@@ -678,6 +777,7 @@ func (d *BotChatRequestDTO) ToBotChatRequest() *BotChatRequest {
 		ConversationID: d.ConversationID,
 		Message:        d.Message,
 		Image:          d.Image,
+		Attachments:    d.Attachments,
 		Stream:         d.Stream,
 		Options:        d.Options,
 	}
@@ -689,8 +789,62 @@ type BotChatHistoryRequest struct {
 	ConversationID int64 `json:"conversationId,string" query:"conversationId"`
 }
 
+// loadBotTools builds the request-scoped tool set of a bot: the builtin tools
+// enabled for it, then its plugins, knowledge bases and workflows. When names
+// collide the tool added first keeps its name and later ones get a suffix
+func (s *BotChatService) loadBotTools(ctx context.Context, bot *entity.Bot) *aitool.ToolSet {
+	tools := aitool.NewToolSet()
+
+	// 1. Builtin tools
+	var botOptions entity.BotOptions
+	if bot.Options != "" {
+		json.Unmarshal([]byte(bot.Options), &botOptions)
+	}
+	for _, t := range builtin.GetBuiltinTools() {
+		if builtinToolEnabled(&botOptions, t.Name()) {
+			tools.Add(t)
+		}
+	}
+
+	// 2. Bot plugin tools
+	pluginTools, err := NewPluginToolService().LoadBotPluginTools(ctx, bot.ID)
+	if err != nil {
+		fmt.Printf("Failed to load plugin tools: %v\n", err)
+	}
+	for _, t := range pluginTools {
+		tools.Add(t)
+	}
+
+	// 3. Bot knowledge base tools (RAG)
+	knowledgeTools, err := s.loadBotKnowledgeTools(ctx, bot.ID)
+	if err != nil {
+		fmt.Printf("Failed to load knowledge tools: %v\n", err)
+	}
+	for _, t := range knowledgeTools {
+		tools.Add(t)
+	}
+
+	// 4. Bot workflow tools (published version of bound workflows)
+	workflowTools, err := NewWorkflowToolService().LoadBotWorkflowTools(ctx, bot.ID)
+	if err != nil {
+		fmt.Printf("Failed to load workflow tools: %v\n", err)
+	}
+	for _, t := range workflowTools {
+		tools.Add(t)
+	}
+
+	return tools
+}
+
+// builtinToolEnabled reports whether a builtin tool is enabled for a bot;
+// tools not configured in the bot options are enabled
+func builtinToolEnabled(options *entity.BotOptions, name string) bool {
+	enabled, ok := options.BuiltinTools[name]
+	return !ok || enabled
+}
+
 // loadBotKnowledgeTools loads knowledge base tools for a bot
-func (s *BotChatService) loadBotKnowledgeTools(ctx context.Context, botID int64) ([]*schema.ToolInfo, error) {
+func (s *BotChatService) loadBotKnowledgeTools(ctx context.Context, botID int64) ([]aitool.Tool, error) {
 	// Get Bot's associated knowledge bases
 	collectionRepo := repository.NewDocumentCollectionRepository()
 	collections, err := collectionRepo.ListByBotID(ctx, botID)
@@ -698,12 +852,7 @@ func (s *BotChatService) loadBotKnowledgeTools(ctx context.Context, botID int64)
 		return nil, err
 	}
 
-	if len(collections) == 0 {
-		return nil, nil
-	}
-
-	var toolInfos []*schema.ToolInfo
-
+	var tools []aitool.Tool
 	for _, bdc := range collections {
 		if bdc.DocumentCollection == nil {
 			continue
@@ -724,35 +873,21 @@ func (s *BotChatService) loadBotKnowledgeTools(ctx context.Context, botID int64)
 			toolDesc = "搜索 " + dc.Title + " 知识库中的相关信息"
 		}
 
-		// Create tool info
-		toolInfo := &schema.ToolInfo{
-			Name: toolName,
-			Desc: toolDesc,
-			ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
-				"input": {
-					Type: schema.String,
-					Desc: "要在知识库中搜索的关键词或问题",
-				},
-			}),
-		}
-
-		// Register the tool in the registry for execution
-		knowledgeTool := &KnowledgeToolWrapper{
+		tools = append(tools, &KnowledgeToolWrapper{
 			CollectionID: dc.ID,
 			ToolName:     toolName,
-		}
-		aitool.GetRegistry().Register(knowledgeTool)
-
-		toolInfos = append(toolInfos, toolInfo)
+			ToolDesc:     toolDesc,
+		})
 	}
 
-	return toolInfos, nil
+	return tools, nil
 }
 
-// KnowledgeToolWrapper wraps knowledge tool for registry
+// KnowledgeToolWrapper wraps a knowledge base as a chat tool
 type KnowledgeToolWrapper struct {
 	CollectionID int64
 	ToolName     string
+	ToolDesc     string
 }
 
 // Name returns the tool name
@@ -762,7 +897,10 @@ func (t *KnowledgeToolWrapper) Name() string {
 
 // Description returns the tool description
 func (t *KnowledgeToolWrapper) Description() string {
-	return "搜索知识库中的相关信息"
+	if t.ToolDesc == "" {
+		return "搜索知识库中的相关信息"
+	}
+	return t.ToolDesc
 }
 
 // Parameters returns the tool parameters
@@ -0,0 +1,398 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+)
+
+// Attachment types
+const (
+	AttachmentTypeImage = "image"
+	AttachmentTypeAudio = "audio"
+	AttachmentTypeVideo = "video"
+	AttachmentTypeFile  = "file"
+)
+
+const (
+	// maxAttachmentSize is the largest attachment inlined into a request
+	maxAttachmentSize = 20 << 20
+	// maxTextAttachmentSize is the largest text file inlined as message text
+	maxTextAttachmentSize = 64 << 10
+	// attachmentDownloadTimeout bounds the download of a remote attachment
+	attachmentDownloadTimeout = 30 * time.Second
+)
+
+// audioMimeTypes maps the audio formats accepted by models to the MIME type
+// understood by the model client
+var audioMimeTypes = map[string]string{
+	"audio/wav":   "audio/wav",
+	"audio/x-wav": "audio/wav",
+	"audio/wave":  "audio/wav",
+	"audio/mpeg":  "audio/mpeg3",
+	"audio/mp3":   "audio/mpeg3",
+	"audio/mpeg3": "audio/mpeg3",
+}
+
+// textMimeTypes are non text/* MIME types of files inlined as text
+var textMimeTypes = map[string]bool{
+	"application/json":   true,
+	"application/xml":    true,
+	"application/x-yaml": true,
+	"application/yaml":   true,
+}
+
+// textExtensions are file extensions inlined as text when the MIME type is unknown
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".csv": true, ".json": true, ".xml": true,
+	".yaml": true, ".yml": true, ".log": true,
+}
+
+// extensionMimeTypes are checked before the system MIME table, which varies
+// between hosts and often lacks audio and video types
+var extensionMimeTypes = map[string]string{
+	".png": "image/png", ".jpg": "image/jpeg", ".jpeg": "image/jpeg", ".gif": "image/gif", ".webp": "image/webp",
+	".wav": "audio/wav", ".mp3": "audio/mpeg",
+	".mp4": "video/mp4", ".webm": "video/webm", ".mov": "video/quicktime",
+	".txt": "text/plain", ".md": "text/markdown", ".csv": "text/csv",
+}
+
+// ChatAttachment is a file attached to a chat message. URL is an http(s) URL,
+// a data URL or a path returned by the upload API. Type is derived from the
+// MIME type when empty
+type ChatAttachment struct {
+	URL      string `json:"url"`
+	Type     string `json:"type,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// messageOptions is the attachment part of BotMessage.Options
+type messageOptions struct {
+	Attachments []*ChatAttachment `json:"attachments,omitempty"`
+}
+
+// requestAttachments collects the attachments of a chat request; the legacy
+// Image field is treated as an image attachment
+func requestAttachments(req *BotChatRequest) []*ChatAttachment {
+	var attachments []*ChatAttachment
+	if req.Image != "" {
+		attachments = append(attachments, &ChatAttachment{URL: req.Image, Type: AttachmentTypeImage})
+	}
+	for _, a := range req.Attachments {
+		if a != nil && a.URL != "" {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments
+}
+
+// messageAttachments restores the attachments saved with a message
+func messageAttachments(msg *entity.BotMessage) []*ChatAttachment {
+	var options messageOptions
+	if msg.Options != "" {
+		json.Unmarshal([]byte(msg.Options), &options)
+	}
+	if len(options.Attachments) > 0 {
+		return options.Attachments
+	}
+	if msg.Image != "" {
+		return []*ChatAttachment{{URL: msg.Image, Type: AttachmentTypeImage}}
+	}
+	return nil
+}
+
+// mimeType resolves the MIME type from the attachment, its data URL or its
+// file extension; it returns "" when unknown
+func (a *ChatAttachment) mimeType() string {
+	if a.MimeType != "" {
+		return strings.ToLower(a.MimeType)
+	}
+	if mimeType, _, ok := parseDataURL(a.URL); ok {
+		return mimeType
+	}
+	for _, name := range []string{a.Name, attachmentPath(a.URL)} {
+		if ext := strings.ToLower(path.Ext(name)); ext != "" {
+			if mimeType, ok := extensionMimeTypes[ext]; ok {
+				return mimeType
+			}
+			if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+				mimeType, _, _ = strings.Cut(mimeType, ";")
+				return mimeType
+			}
+		}
+	}
+	return ""
+}
+
+// kind returns the attachment type
+func (a *ChatAttachment) kind() string {
+	if a.Type != "" {
+		return a.Type
+	}
+	switch major, _, _ := strings.Cut(a.mimeType(), "/"); major {
+	case AttachmentTypeImage, AttachmentTypeAudio, AttachmentTypeVideo:
+		return major
+	default:
+		return AttachmentTypeFile
+	}
+}
+
+// displayName returns the name shown to the model
+func (a *ChatAttachment) displayName() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	if _, _, ok := parseDataURL(a.URL); ok {
+		return a.kind()
+	}
+	return path.Base(attachmentPath(a.URL))
+}
+
+// checkAttachmentSupport reports a clear error when the model can't accept the attachment
+func checkAttachmentSupport(model *entity.Model, a *ChatAttachment) error {
+	switch a.kind() {
+	case AttachmentTypeImage:
+		if !model.SupportImage {
+			return apierrors.BadRequest(fmt.Sprintf("当前模型 %s 不支持图片输入", model.Title))
+		}
+	case AttachmentTypeAudio:
+		if !model.SupportAudio {
+			return apierrors.BadRequest(fmt.Sprintf("当前模型 %s 不支持音频输入", model.Title))
+		}
+		if mimeType := a.mimeType(); mimeType != "" && audioMimeTypes[mimeType] == "" {
+			return apierrors.BadRequest("仅支持 wav 与 mp3 格式的音频: " + a.displayName())
+		}
+	case AttachmentTypeVideo:
+		if !model.SupportVideo {
+			return apierrors.BadRequest(fmt.Sprintf("当前模型 %s 不支持视频输入", model.Title))
+		}
+	case AttachmentTypeFile:
+		if mimeType := a.mimeType(); mimeType != "" && !isTextAttachment(a, mimeType) {
+			return apierrors.BadRequest("不支持该类型的文件，仅支持文本文件: " + a.displayName())
+		}
+	default:
+		return apierrors.BadRequest("无效的附件类型: " + a.Type)
+	}
+	return nil
+}
+
+// isTextAttachment reports whether a file attachment is inlined as text
+func isTextAttachment(a *ChatAttachment, mimeType string) bool {
+	if strings.HasPrefix(mimeType, "text/") || textMimeTypes[mimeType] {
+		return true
+	}
+	return textExtensions[strings.ToLower(path.Ext(a.displayName()))]
+}
+
+// ========================== Loading ==========================
+
+// attachmentLoader turns attachments into multimodal message parts
+type attachmentLoader struct {
+	root   string       // upload root for attachment paths
+	client *http.Client // downloads remote attachments; refuses private addresses
+}
+
+// newAttachmentLoader creates an attachmentLoader reading uploads from the storage root
+func newAttachmentLoader() *attachmentLoader {
+	root := "./uploads"
+	if cfg := config.GetConfig(); cfg != nil && cfg.Storage.LocalRoot != "" {
+		root = cfg.Storage.LocalRoot
+	}
+	return &attachmentLoader{
+		root:   root,
+		client: publicHTTPClient,
+	}
+}
+
+// messageParts builds the multimodal parts of a user message: the text
+// followed by one part per attachment
+func (l *attachmentLoader) messageParts(ctx context.Context, model *entity.Model, text string, attachments []*ChatAttachment) ([]schema.MessageInputPart, error) {
+	parts := make([]schema.MessageInputPart, 0, len(attachments)+1)
+	if text != "" {
+		parts = append(parts, schema.MessageInputPart{Type: schema.ChatMessagePartTypeText, Text: text})
+	}
+	for _, a := range attachments {
+		if err := checkAttachmentSupport(model, a); err != nil {
+			return nil, err
+		}
+		part, err := l.part(ctx, model, a)
+		if err != nil {
+			return nil, apierrors.BadRequest(fmt.Sprintf("读取附件 %s 失败: %v", a.displayName(), err))
+		}
+		parts = append(parts, part)
+	}
+	return parts, nil
+}
+
+// part converts one attachment. Remote images and videos are sent by URL
+// unless the model only accepts base64 images; audio, local uploads and files
+// are inlined
+func (l *attachmentLoader) part(ctx context.Context, model *entity.Model, a *ChatAttachment) (schema.MessageInputPart, error) {
+	kind := a.kind()
+	if isRemoteURL(a.URL) {
+		common := schema.MessagePartCommon{URL: &a.URL, MIMEType: a.mimeType()}
+		switch {
+		case kind == AttachmentTypeImage && !model.SupportImageB64Only:
+			return schema.MessageInputPart{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{MessagePartCommon: common}}, nil
+		case kind == AttachmentTypeVideo:
+			return schema.MessageInputPart{Type: schema.ChatMessagePartTypeVideoURL, Video: &schema.MessageInputVideo{MessagePartCommon: common}}, nil
+		}
+	}
+
+	data, mimeType, err := l.load(ctx, a)
+	if err != nil {
+		return schema.MessageInputPart{}, err
+	}
+
+	if kind == AttachmentTypeFile {
+		if !isTextAttachment(a, mimeType) || !utf8.Valid(data) {
+			return schema.MessageInputPart{}, fmt.Errorf("仅支持文本文件")
+		}
+		text := string(data)
+		if len(data) > maxTextAttachmentSize {
+			text = strings.ToValidUTF8(text[:maxTextAttachmentSize], "") + "\n...(内容过长，已截断)"
+		}
+		return schema.MessageInputPart{
+			Type: schema.ChatMessagePartTypeText,
+			Text: fmt.Sprintf("附件 %s 的内容:\n%s", a.displayName(), text),
+		}, nil
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	common := schema.MessagePartCommon{Base64Data: &encoded, MIMEType: mimeType}
+	switch kind {
+	case AttachmentTypeImage:
+		return schema.MessageInputPart{Type: schema.ChatMessagePartTypeImageURL, Image: &schema.MessageInputImage{MessagePartCommon: common}}, nil
+	case AttachmentTypeAudio:
+		format, ok := audioMimeTypes[mimeType]
+		if !ok {
+			return schema.MessageInputPart{}, fmt.Errorf("仅支持 wav 与 mp3 格式的音频")
+		}
+		common.MIMEType = format
+		return schema.MessageInputPart{Type: schema.ChatMessagePartTypeAudioURL, Audio: &schema.MessageInputAudio{MessagePartCommon: common}}, nil
+	default:
+		return schema.MessageInputPart{Type: schema.ChatMessagePartTypeVideoURL, Video: &schema.MessageInputVideo{MessagePartCommon: common}}, nil
+	}
+}
+
+// load reads the attachment content and resolves its MIME type
+func (l *attachmentLoader) load(ctx context.Context, a *ChatAttachment) ([]byte, string, error) {
+	var data []byte
+	mimeType := a.mimeType()
+
+	if dataMime, encoded, ok := parseDataURL(a.URL); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, "", fmt.Errorf("无效的 data URL: %w", err)
+		}
+		data = decoded
+		if mimeType == "" {
+			mimeType = dataMime
+		}
+	} else if isRemoteURL(a.URL) {
+		downloaded, contentType, err := l.download(ctx, a.URL)
+		if err != nil {
+			return nil, "", err
+		}
+		data = downloaded
+		if mimeType == "" {
+			mimeType = contentType
+		}
+	} else {
+		read, err := l.readUpload(a.URL)
+		if err != nil {
+			return nil, "", err
+		}
+		data = read
+	}
+
+	if len(data) > maxAttachmentSize {
+		return nil, "", fmt.Errorf("附件超过 %d MB", maxAttachmentSize>>20)
+	}
+	if mimeType == "" {
+		mimeType, _, _ = strings.Cut(http.DetectContentType(data), ";")
+	}
+	return data, mimeType, nil
+}
+
+// download fetches a remote attachment
+func (l *attachmentLoader) download(ctx context.Context, rawURL string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, attachmentDownloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("下载失败: HTTP %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxAttachmentSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return data, strings.TrimSpace(contentType), nil
+}
+
+// readUpload reads an uploaded file; the path can't escape the upload root
+func (l *attachmentLoader) readUpload(uploadPath string) ([]byte, error) {
+	fullPath := filepath.Join(l.root, filepath.FromSlash(path.Clean("/"+uploadPath)))
+	file, err := os.Open(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("文件不存在: %s", uploadPath)
+		}
+		return nil, err
+	}
+	defer file.Close()
+	return io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+}
+
+// isRemoteURL reports whether the attachment is an http(s) URL
+func isRemoteURL(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "http://") || strings.HasPrefix(rawURL, "https://")
+}
+
+// attachmentPath returns the path part of an attachment URL
+func attachmentPath(rawURL string) string {
+	if isRemoteURL(rawURL) {
+		if u, err := url.Parse(rawURL); err == nil {
+			return u.Path
+		}
+	}
+	return rawURL
+}
+
+// parseDataURL splits a base64 data URL (data:<mime>;base64,<data>)
+func parseDataURL(rawURL string) (mimeType, data string, ok bool) {
+	header, data, found := strings.Cut(strings.TrimPrefix(rawURL, "data:"), ",")
+	if !found || !strings.HasPrefix(rawURL, "data:") || !strings.HasSuffix(header, ";base64") {
+		return "", "", false
+	}
+	return strings.ToLower(strings.TrimSuffix(header, ";base64")), data, true
+}
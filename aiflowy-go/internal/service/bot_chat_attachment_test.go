@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+)
+
+func TestChatAttachment_Kind(t *testing.T) {
+	tests := []struct {
+		attachment ChatAttachment
+		kind       string
+		mimeType   string
+	}{
+		{ChatAttachment{URL: "2026/05/01/1.PNG"}, AttachmentTypeImage, "image/png"},
+		{ChatAttachment{URL: "https://example.com/voice.mp3?token=1"}, AttachmentTypeAudio, "audio/mpeg"},
+		{ChatAttachment{URL: "data:video/mp4;base64,AAAA"}, AttachmentTypeVideo, "video/mp4"},
+		{ChatAttachment{URL: "2026/05/01/2", Name: "notes.md"}, AttachmentTypeFile, "text/markdown"},
+		{ChatAttachment{URL: "2026/05/01/3", Type: AttachmentTypeImage}, AttachmentTypeImage, ""},
+	}
+	for _, tt := range tests {
+		if kind := tt.attachment.kind(); kind != tt.kind {
+			t.Errorf("%s: expected kind %s, got %s", tt.attachment.URL, tt.kind, kind)
+		}
+		if mimeType := tt.attachment.mimeType(); mimeType != tt.mimeType {
+			t.Errorf("%s: expected mime type %q, got %q", tt.attachment.URL, tt.mimeType, mimeType)
+		}
+	}
+}
+
+func TestCheckAttachmentSupport(t *testing.T) {
+	textOnly := &entity.Model{Title: "text"}
+	multimodal := &entity.Model{Title: "omni", SupportImage: true, SupportAudio: true, SupportVideo: true}
+
+	media := []*ChatAttachment{
+		{URL: "a.png"},
+		{URL: "a.wav"},
+		{URL: "a.mp4"},
+	}
+	for _, a := range media {
+		if err := checkAttachmentSupport(textOnly, a); err == nil {
+			t.Errorf("expected %s to be rejected by a text-only model", a.URL)
+		}
+		if err := checkAttachmentSupport(multimodal, a); err != nil {
+			t.Errorf("expected %s to be accepted: %v", a.URL, err)
+		}
+	}
+
+	if err := checkAttachmentSupport(multimodal, &ChatAttachment{URL: "a.ogg", MimeType: "audio/ogg"}); err == nil {
+		t.Error("expected unsupported audio format to be rejected")
+	}
+	if err := checkAttachmentSupport(textOnly, &ChatAttachment{URL: "a.pdf", MimeType: "application/pdf"}); err == nil {
+		t.Error("expected binary files to be rejected")
+	}
+	if err := checkAttachmentSupport(textOnly, &ChatAttachment{URL: "a.csv"}); err != nil {
+		t.Errorf("expected text files to be accepted: %v", err)
+	}
+}
+
+func TestAttachmentLoader_MessageParts(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "photo.png"), []byte("\x89PNG\r\n\x1a\nimage"), 0644)
+	os.WriteFile(filepath.Join(root, "notes.txt"), []byte("meeting at 10"), 0644)
+	os.WriteFile(filepath.Join(root, "voice.wav"), []byte("RIFFdata"), 0644)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("remote image"))
+	}))
+	defer server.Close()
+
+	loader := &attachmentLoader{root: root, client: server.Client()}
+	ctx := context.Background()
+	model := &entity.Model{SupportImage: true, SupportAudio: true}
+
+	parts, err := loader.messageParts(ctx, model, "describe", []*ChatAttachment{
+		{URL: "/photo.png"},
+		{URL: server.URL + "/cat.jpg"},
+		{URL: "notes.txt"},
+		{URL: "voice.wav"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(parts) != 5 || parts[0].Text != "describe" {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+	if img := parts[1].Image; img == nil || img.Base64Data == nil || img.MIMEType != "image/png" {
+		t.Errorf("local upload should be inlined: %+v", parts[1])
+	}
+	if img := parts[2].Image; img == nil || img.URL == nil || img.Base64Data != nil {
+		t.Errorf("remote image should be sent by URL: %+v", parts[2])
+	}
+	if parts[3].Type != schema.ChatMessagePartTypeText || !strings.Contains(parts[3].Text, "meeting at 10") {
+		t.Errorf("text file should be inlined as text: %+v", parts[3])
+	}
+	if audio := parts[4].Audio; audio == nil || audio.Base64Data == nil || audio.MIMEType != "audio/wav" {
+		t.Errorf("audio should be inlined: %+v", parts[4])
+	}
+
+	// models that only accept base64 images get remote images downloaded
+	model.SupportImageB64Only = true
+	parts, err = loader.messageParts(ctx, model, "", []*ChatAttachment{{URL: server.URL + "/cat", Type: AttachmentTypeImage}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if img := parts[0].Image; len(parts) != 1 || img == nil || img.Base64Data == nil || img.MIMEType != "image/jpeg" {
+		t.Errorf("expected downloaded image, got %+v", parts)
+	}
+}
+
+func TestAttachmentLoader_RejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("internal image"))
+	}))
+	defer server.Close()
+
+	loader := newAttachmentLoader()
+	model := &entity.Model{SupportImage: true, SupportImageB64Only: true}
+	_, err := loader.messageParts(context.Background(), model, "", []*ChatAttachment{{URL: server.URL + "/cat", Type: AttachmentTypeImage}})
+	if err == nil || !strings.Contains(err.Error(), errPrivateAddress.Error()) {
+		t.Errorf("expected loopback download to be refused, got %v", err)
+	}
+}
+
+func TestAttachmentLoader_ReadUploadStaysInRoot(t *testing.T) {
+	root := t.TempDir()
+	outside := filepath.Join(filepath.Dir(root), "secret.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+	defer os.Remove(outside)
+
+	loader := &attachmentLoader{root: root}
+	if _, err := loader.readUpload("../secret.txt"); err == nil {
+		t.Error("expected path outside the upload root to be unreadable")
+	}
+}
+
+func TestMessageAttachments(t *testing.T) {
+	legacy := messageAttachments(&entity.BotMessage{Image: "2026/05/01/1"})
+	if len(legacy) != 1 || legacy[0].kind() != AttachmentTypeImage {
+		t.Errorf("legacy image should be an image attachment: %+v", legacy)
+	}
+
+	saved := messageAttachments(&entity.BotMessage{
+		Image:   "2026/05/01/1",
+		Options: `{"attachments":[{"url":"2026/05/01/1","type":"image"},{"url":"a.txt"}]}`,
+	})
+	if len(saved) != 2 {
+		t.Errorf("expected attachments from options, got %+v", saved)
+	}
+}
+
+func TestBuildLLMMessages_HistoryAttachments(t *testing.T) {
+	s := &BotChatService{attachments: &attachmentLoader{root: t.TempDir()}}
+	chatCtx := &ChatContext{
+		BotOptions: &entity.BotModelOptions{},
+		Model:      &entity.Model{},
+		Messages: []*entity.BotMessage{
+			{Role: entity.RoleUser, Content: "what is this", Image: "gone.png"},
+			{Role: entity.RoleAssistant, Content: "a cat"},
+		},
+		UserMessage: &entity.BotMessage{Role: entity.RoleUser, Content: "thanks"},
+	}
+
+	messages := s.buildLLMMessages(context.Background(), chatCtx)
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	// the model can't take images any more, the attachment becomes a note
+	if messages[0].UserInputMultiContent != nil || messages[0].Content != "what is this\n[附件: gone.png]" {
+		t.Errorf("unexpected history message: %+v", messages[0])
+	}
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+)
+
+const (
+	defaultContextLength  = 8192 // Context length of models that don't configure one
+	defaultReplyTokens    = 1024 // Reserved for the reply when MaxTokens isn't set
+	maxHistoryMessages    = 200  // Messages loaded beyond the conversation summary
+	messageOverheadTokens = 4    // Role and separators of a message
+	mediaPartTokens       = 1000 // Rough cost of an image, audio, video or file part
+	maxStoredToolResult   = 4000 // Runes of a tool result kept with the assistant message
+	maxSummaryInputRunes  = 2000 // Runes of a single message passed to the summarizer
+	minSummaryLength      = 200
+	maxSummaryLength      = 1000
+)
+
+// summaryPrompt instructs the model to fold turns into the rolling summary
+const summaryPrompt = `你负责维护一段对话的摘要。请把"已有摘要"和"新增对话"合并为一份新的摘要：
+保留用户的目标、偏好、已确认的事实、做出的决定、工具调用得到的关键结果和尚未解决的问题，省略寒暄和重复内容。
+直接输出摘要正文，不超过 %d 字。`
+
+// estimateTokens approximates the token count of a text without a tokenizer:
+// CJK characters are about one token each, other text about four bytes a token
+func estimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// estimateMessageTokens approximates the tokens a message takes in the prompt
+func estimateMessageTokens(m *schema.Message) int {
+	tokens := messageOverheadTokens + estimateTokens(m.Content)
+	for _, part := range m.UserInputMultiContent {
+		if part.Type == schema.ChatMessagePartTypeText {
+			tokens += estimateTokens(part.Text)
+		} else {
+			tokens += mediaPartTokens
+		}
+	}
+	for _, tc := range m.ToolCalls {
+		tokens += estimateTokens(tc.Function.Name) + estimateTokens(tc.Function.Arguments)
+	}
+	return tokens
+}
+
+// estimateTurnTokens approximates the tokens a history turn takes once
+// replayed. Attachments count at the flat media cost, so budgeting never
+// downloads or reads them
+func estimateTurnTokens(turn []*entity.BotMessage) int {
+	tokens := 0
+	for _, msg := range turn {
+		tokens += messageOverheadTokens + estimateTokens(msg.Content)
+		switch msg.Role {
+		case entity.RoleUser:
+			tokens += mediaPartTokens * len(messageAttachments(msg))
+		case entity.RoleAssistant:
+			for _, m := range assistantToolMessages(msg) {
+				tokens += estimateMessageTokens(m)
+			}
+		}
+	}
+	return tokens
+}
+
+// estimateToolTokens approximates the tokens the tool definitions take
+func estimateToolTokens(infos []*schema.ToolInfo) int {
+	tokens := 0
+	for _, info := range infos {
+		tokens += estimateTokens(info.Name) + estimateTokens(info.Desc)
+		if params, err := info.ToJSONSchema(); err == nil && params != nil {
+			if data, err := json.Marshal(params); err == nil {
+				tokens += estimateTokens(string(data))
+			}
+		}
+	}
+	return tokens
+}
+
+// historyBudget returns the tokens the history and summary may use: the
+// model's context length minus the reply reserve and the fixed part of the
+// prompt, with a 10% margin for the estimate
+func historyBudget(m *entity.Model, options *entity.BotModelOptions, fixedTokens int) int {
+	contextLength := m.ContextLength
+	if contextLength <= 0 {
+		contextLength = defaultContextLength
+	}
+	reserve := options.MaxTokens
+	if reserve <= 0 {
+		reserve = defaultReplyTokens
+	}
+	if options.EnableThinking {
+		reserve += options.ThinkingBudget
+	}
+
+	budget := contextLength*9/10 - reserve - fixedTokens
+	if budget < 0 {
+		return 0
+	}
+	return budget
+}
+
+// historyTurns splits the history into turns: a user message with the replies
+// to it. Turns are kept or dropped as a whole, so a tool call never loses its
+// result
+func historyTurns(messages []*entity.BotMessage) [][]*entity.BotMessage {
+	var turns [][]*entity.BotMessage
+	for _, msg := range messages {
+		if msg.Role == entity.RoleUser || len(turns) == 0 {
+			turns = append(turns, nil)
+		}
+		turns[len(turns)-1] = append(turns[len(turns)-1], msg)
+	}
+	return turns
+}
+
+// historyWindow selects the turns of the history that fit the budget
+type historyWindow struct {
+	turns  [][]*entity.BotMessage
+	tokens []int // Estimated lazily, from the newest turn back
+}
+
+// newHistoryWindow creates a historyWindow over the turns
+func newHistoryWindow(turns [][]*entity.BotMessage) *historyWindow {
+	return &historyWindow{turns: turns, tokens: make([]int, len(turns))}
+}
+
+// fit returns the index of the oldest turn that still fits the budget together
+// with all newer turns
+func (w *historyWindow) fit(budget int) int {
+	used := 0
+	for i := len(w.turns) - 1; i >= 0; i-- {
+		if w.tokens[i] == 0 {
+			w.tokens[i] = estimateTurnTokens(w.turns[i])
+		}
+		if used+w.tokens[i] > budget {
+			return i + 1
+		}
+		used += w.tokens[i]
+	}
+	return 0
+}
+
+// historyMessages replays the history that fits the token budget. When older
+// turns no longer fit they are folded into the conversation's rolling summary,
+// down to half the budget so the next turns don't summarize again right away.
+// If summarizing fails the older turns are only dropped
+func (s *BotChatService) historyMessages(ctx context.Context, chatCtx *ChatContext, fixedTokens int) []*schema.Message {
+	turns := historyTurns(chatCtx.Messages)
+	window := newHistoryWindow(turns)
+
+	budget := historyBudget(chatCtx.Model, chatCtx.BotOptions, fixedTokens) - estimateTokens(conversationSummary(chatCtx))
+	from := window.fit(budget)
+	if from > 0 && chatCtx.Conversation != nil {
+		keepFrom := window.fit(budget / 2)
+		if err := s.foldHistory(ctx, chatCtx, turns[:keepFrom], budget/4); err != nil {
+			fmt.Printf("Failed to summarize conversation history: %v\n", err)
+		} else {
+			from = keepFrom
+		}
+	}
+
+	// Only the replayed turns load their attachments
+	var messages []*schema.Message
+	for _, turn := range turns[from:] {
+		messages = append(messages, s.turnMessages(ctx, chatCtx.Model, turn)...)
+	}
+	return messages
+}
+
+// turnMessages converts the messages of a turn for the LLM. User messages keep
+// their attachments and assistant messages replay their tool calls
+func (s *BotChatService) turnMessages(ctx context.Context, m *entity.Model, turn []*entity.BotMessage) []*schema.Message {
+	var messages []*schema.Message
+	for _, msg := range turn {
+		role := schema.RoleType(msg.Role)
+		switch {
+		case role == schema.User:
+			if attachments := messageAttachments(msg); len(attachments) > 0 {
+				messages = append(messages, s.historyUserMessage(ctx, m, msg, attachments))
+				continue
+			}
+		case role == schema.Assistant:
+			messages = append(messages, assistantToolMessages(msg)...)
+		}
+		messages = append(messages, &schema.Message{
+			Role:    role,
+			Content: msg.Content,
+		})
+	}
+	return messages
+}
+
+// assistantToolMessages returns the tool calls and results saved with an
+// assistant message. An incomplete exchange is left out, providers reject
+// tool calls without results
+func assistantToolMessages(msg *entity.BotMessage) []*schema.Message {
+	if msg.Options == "" {
+		return nil
+	}
+	var options assistantOptions
+	if err := json.Unmarshal([]byte(msg.Options), &options); err != nil || !toolExchangeComplete(options.ToolMessages) {
+		return nil
+	}
+	return options.ToolMessages
+}
+
+// toolExchangeComplete reports whether every tool call has exactly one result
+// and every result belongs to a call
+func toolExchangeComplete(messages []*schema.Message) bool {
+	pending := make(map[string]bool)
+	for _, m := range messages {
+		switch m.Role {
+		case schema.Assistant:
+			for _, tc := range m.ToolCalls {
+				pending[tc.ID] = true
+			}
+		case schema.Tool:
+			if !pending[m.ToolCallID] {
+				return false
+			}
+			delete(pending, m.ToolCallID)
+		default:
+			return false
+		}
+	}
+	return len(pending) == 0
+}
+
+// storedToolMessages copies the tool exchange of a turn for saving with the
+// assistant message, with long tool results truncated
+func storedToolMessages(messages []*schema.Message) []*schema.Message {
+	stored := make([]*schema.Message, 0, len(messages))
+	for _, m := range messages {
+		if m.Role == schema.Assistant {
+			stored = append(stored, &schema.Message{
+				Role:      schema.Assistant,
+				Content:   m.Content,
+				ToolCalls: m.ToolCalls,
+			})
+			continue
+		}
+		stored = append(stored, &schema.Message{
+			Role:       m.Role,
+			Content:    truncateRunes(m.Content, maxStoredToolResult),
+			ToolCallID: m.ToolCallID,
+			ToolName:   m.ToolName,
+		})
+	}
+	return stored
+}
+
+// foldHistory folds turns into the conversation's rolling summary and saves it.
+// The turns are summarized in batches that fit the model's context
+func (s *BotChatService) foldHistory(ctx context.Context, chatCtx *ChatContext, turns [][]*entity.BotMessage, summaryTokens int) error {
+	if len(turns) == 0 {
+		return nil
+	}
+	chatModel, err := s.factory.CreateChatModel(ctx, chatCtx.Model)
+	if err != nil {
+		return err
+	}
+
+	limit := min(max(summaryTokens, minSummaryLength), maxSummaryLength)
+	batchTokens := historyBudget(chatCtx.Model, &entity.BotModelOptions{MaxTokens: limit}, estimateTokens(summaryPrompt)) / 2
+	summary := chatCtx.Conversation.Summary
+
+	var batch strings.Builder
+	used := 0
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		result, err := summarizeHistory(ctx, chatModel, summary, batch.String(), limit)
+		if err != nil {
+			return err
+		}
+		if chatCtx.Usage != nil {
+			chatCtx.Usage.add(result.ResponseMeta)
+		}
+		summary = strings.TrimSpace(result.Content)
+		batch.Reset()
+		used = 0
+		return nil
+	}
+	for _, turn := range turns {
+		for _, msg := range turn {
+			line := transcriptLine(msg)
+			tokens := estimateTokens(line)
+			if used > 0 && used+tokens > batchTokens {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			batch.WriteString(line)
+			used += tokens
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	lastTurn := turns[len(turns)-1]
+	chatCtx.Conversation.Summary = summary
+	chatCtx.Conversation.SummaryMessageID = lastTurn[len(lastTurn)-1].ID
+	if err := s.botRepo.UpdateConversationSummary(ctx, chatCtx.Conversation.ID, summary, chatCtx.Conversation.SummaryMessageID); err != nil {
+		// The summary is still used for this chat
+		fmt.Printf("Failed to save conversation summary: %v\n", err)
+	}
+	return nil
+}
+
+// summarizeHistory merges a transcript into the previous summary
+func summarizeHistory(ctx context.Context, chatModel model.BaseChatModel, previous, transcript string, limit int) (*schema.Message, error) {
+	if previous == "" {
+		previous = "无"
+	}
+	result, err := chatModel.Generate(ctx, []*schema.Message{
+		schema.SystemMessage(fmt.Sprintf(summaryPrompt, limit)),
+		schema.UserMessage(fmt.Sprintf("已有摘要：\n%s\n\n新增对话：\n%s", previous, transcript)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(result.Content) == "" {
+		return nil, fmt.Errorf("empty summary")
+	}
+	return result, nil
+}
+
+// transcriptLine renders a message with its tool calls for the summarizer
+func transcriptLine(msg *entity.BotMessage) string {
+	var b strings.Builder
+	switch msg.Role {
+	case entity.RoleUser:
+		b.WriteString("用户: " + truncateRunes(msg.Content, maxSummaryInputRunes))
+		for _, a := range messageAttachments(msg) {
+			b.WriteString(fmt.Sprintf(" [附件: %s]", a.displayName()))
+		}
+		b.WriteString("\n")
+	case entity.RoleAssistant:
+		for _, m := range assistantToolMessages(msg) {
+			for _, tc := range m.ToolCalls {
+				b.WriteString(fmt.Sprintf("助手调用工具 %s: %s\n", tc.Function.Name, truncateRunes(tc.Function.Arguments, maxSummaryInputRunes)))
+			}
+			if m.Role == schema.Tool {
+				b.WriteString(fmt.Sprintf("工具 %s 返回: %s\n", m.ToolName, truncateRunes(m.Content, maxSummaryInputRunes)))
+			}
+		}
+		b.WriteString("助手: " + truncateRunes(msg.Content, maxSummaryInputRunes) + "\n")
+	default:
+		b.WriteString(msg.Role + ": " + truncateRunes(msg.Content, maxSummaryInputRunes) + "\n")
+	}
+	return b.String()
+}
+
+// conversationSummary returns the rolling summary of the chat's conversation
+func conversationSummary(chatCtx *ChatContext) string {
+	if chatCtx.Conversation == nil {
+		return ""
+	}
+	return chatCtx.Conversation.Summary
+}
+
+// withSummary appends the conversation summary to the system prompt
+func withSummary(systemPrompt, summary string) string {
+	if summary == "" {
+		return systemPrompt
+	}
+	section := "以下是本次对话早期内容的摘要，可作为上下文参考：\n" + summary
+	if systemPrompt == "" {
+		return section
+	}
+	return systemPrompt + "\n\n" + section
+}
+
+// truncateRunes cuts a text to at most n runes
+func truncateRunes(text string, n int) string {
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	return string([]rune(text)[:n]) + "..."
+}
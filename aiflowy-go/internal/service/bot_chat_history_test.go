@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+)
+
+// fakeChatModel answers every Generate call with a fixed reply
+type fakeChatModel struct {
+	reply    string
+	received []*schema.Message
+}
+
+func (m *fakeChatModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	m.received = input
+	return &schema.Message{
+		Role:         schema.Assistant,
+		Content:      m.reply,
+		ResponseMeta: &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 50, CompletionTokens: 10, TotalTokens: 60}},
+	}, nil
+}
+
+func (m *fakeChatModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{{Role: schema.Assistant, Content: m.reply}}), nil
+}
+
+// toolTurnMessages returns a user question answered with one tool call
+func toolTurnMessages(id int64) []*entity.BotMessage {
+	exchange := []*schema.Message{
+		{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{ID: "call_1", Function: schema.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`}}}},
+		schema.ToolMessage("sunny, 21°C", "call_1", schema.WithToolName("weather")),
+	}
+	return []*entity.BotMessage{
+		{ID: id, Role: entity.RoleUser, Content: "weather in Paris?"},
+		{ID: id + 1, Role: entity.RoleAssistant, Content: "It is sunny.", Options: assistantMessageOptions(&entity.Model{}, &chatUsage{}, "", exchange)},
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens int
+	}{
+		{"", 0},
+		{"hello world!", 3},
+		{"你好世界", 4},
+		{"你好 world", 2 + 2},
+	}
+	for _, tt := range tests {
+		if got := estimateTokens(tt.text); got != tt.tokens {
+			t.Errorf("%q: expected %d tokens, got %d", tt.text, tt.tokens, got)
+		}
+	}
+}
+
+func TestHistoryBudget(t *testing.T) {
+	m := &entity.Model{ContextLength: 10000}
+	if got := historyBudget(m, &entity.BotModelOptions{MaxTokens: 2000}, 1000); got != 6000 {
+		t.Errorf("expected 6000, got %d", got)
+	}
+	if got := historyBudget(m, &entity.BotModelOptions{MaxTokens: 2000, EnableThinking: true, ThinkingBudget: 4000}, 1000); got != 2000 {
+		t.Errorf("thinking budget should be reserved, got %d", got)
+	}
+	// unknown context length and reply size fall back to the defaults
+	if got := historyBudget(&entity.Model{}, &entity.BotModelOptions{}, 0); got != defaultContextLength*9/10-defaultReplyTokens {
+		t.Errorf("unexpected default budget: %d", got)
+	}
+	if got := historyBudget(m, &entity.BotModelOptions{}, 20000); got != 0 {
+		t.Errorf("budget must not be negative, got %d", got)
+	}
+}
+
+func TestTurnMessages_ReplaysToolExchange(t *testing.T) {
+	s := &BotChatService{attachments: &attachmentLoader{root: t.TempDir()}}
+	turns := historyTurns(append(toolTurnMessages(1), &entity.BotMessage{ID: 3, Role: entity.RoleUser, Content: "thanks"}))
+	if len(turns) != 2 || len(turns[0]) != 2 {
+		t.Fatalf("unexpected turns: %+v", turns)
+	}
+
+	messages := s.turnMessages(context.Background(), &entity.Model{}, turns[0])
+	roles := make([]string, 0, len(messages))
+	for _, m := range messages {
+		roles = append(roles, string(m.Role))
+	}
+	if strings.Join(roles, ",") != "user,assistant,tool,assistant" {
+		t.Fatalf("unexpected replay: %v", roles)
+	}
+	if messages[1].ToolCalls[0].ID != "call_1" || messages[2].ToolCallID != "call_1" || messages[3].Content != "It is sunny." {
+		t.Errorf("tool call and result must stay paired: %+v", messages)
+	}
+}
+
+func TestAssistantToolMessages_IncompleteExchange(t *testing.T) {
+	orphan := []*schema.Message{
+		{Role: schema.Assistant, ToolCalls: []schema.ToolCall{{ID: "call_1"}, {ID: "call_2"}}},
+		schema.ToolMessage("ok", "call_1"),
+	}
+	msg := &entity.BotMessage{Role: entity.RoleAssistant, Options: assistantMessageOptions(&entity.Model{}, &chatUsage{}, "", orphan)}
+	if got := assistantToolMessages(msg); got != nil {
+		t.Errorf("a call without result must not be replayed: %+v", got)
+	}
+
+	// messages saved before tool exchanges were stored
+	if got := assistantToolMessages(&entity.BotMessage{Options: `{"thinking":"hmm"}`}); got != nil {
+		t.Errorf("expected no tool messages, got %+v", got)
+	}
+}
+
+func TestStoredToolMessages_TruncatesResults(t *testing.T) {
+	stored := storedToolMessages([]*schema.Message{
+		{Role: schema.Assistant, ReasoningContent: "thinking", ToolCalls: []schema.ToolCall{{ID: "call_1"}}},
+		schema.ToolMessage(strings.Repeat("x", maxStoredToolResult+10), "call_1"),
+	})
+	if stored[0].ReasoningContent != "" || len(stored[0].ToolCalls) != 1 {
+		t.Errorf("unexpected stored call: %+v", stored[0])
+	}
+	if len([]rune(stored[1].Content)) != maxStoredToolResult+len("...") {
+		t.Errorf("expected truncated result, got %d runes", len([]rune(stored[1].Content)))
+	}
+}
+
+func TestHistoryWindow_KeepsWholeTurns(t *testing.T) {
+	var messages []*entity.BotMessage
+	messages = append(messages, &entity.BotMessage{ID: 1, Role: entity.RoleUser, Content: strings.Repeat("old ", 100)})
+	messages = append(messages, toolTurnMessages(2)...)
+	messages = append(messages, &entity.BotMessage{ID: 4, Role: entity.RoleUser, Content: "and tomorrow?"})
+	messages = append(messages, &entity.BotMessage{ID: 5, Role: entity.RoleAssistant, Content: "Rain."})
+
+	turns := historyTurns(messages)
+	window := newHistoryWindow(turns)
+
+	if from := window.fit(10000); from != 0 {
+		t.Errorf("everything fits, got %d", from)
+	}
+	// room for the last turn and most, but not all, of the tool turn
+	last := estimateTurnTokens(turns[2])
+	if from := window.fit(last + 10); from != 2 {
+		t.Errorf("the tool turn must be dropped as a whole, got %d", from)
+	}
+}
+
+func TestEstimateTurnTokens_MatchesReplay(t *testing.T) {
+	s := &BotChatService{attachments: &attachmentLoader{root: t.TempDir()}}
+	turn := toolTurnMessages(1)
+
+	replayed := 0
+	for _, m := range s.turnMessages(context.Background(), &entity.Model{}, turn) {
+		replayed += estimateMessageTokens(m)
+	}
+	if got := estimateTurnTokens(turn); got != replayed {
+		t.Errorf("expected %d tokens, got %d", replayed, got)
+	}
+
+	// attachments count at the flat media cost without being loaded
+	withImage := []*entity.BotMessage{{Role: entity.RoleUser, Content: "what is this", Image: "gone.png"}}
+	if got, want := estimateTurnTokens(withImage), messageOverheadTokens+estimateTokens("what is this")+mediaPartTokens; got != want {
+		t.Errorf("expected %d tokens, got %d", want, got)
+	}
+}
+
+func TestBuildLLMMessages_Summary(t *testing.T) {
+	s := &BotChatService{attachments: &attachmentLoader{root: t.TempDir()}}
+	chatCtx := &ChatContext{
+		BotOptions:   &entity.BotModelOptions{SystemPrompt: "You are helpful."},
+		Model:        &entity.Model{},
+		Conversation: &entity.BotConversation{Summary: "The user plans a trip to Paris."},
+		UserMessage:  &entity.BotMessage{Role: entity.RoleUser, Content: "book it"},
+	}
+
+	messages := s.buildLLMMessages(context.Background(), chatCtx)
+	if len(messages) != 2 || messages[0].Role != schema.System {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	if !strings.HasPrefix(messages[0].Content, "You are helpful.") || !strings.Contains(messages[0].Content, "trip to Paris") {
+		t.Errorf("summary should follow the system prompt: %s", messages[0].Content)
+	}
+
+	if withSummary("", "summary") == "" || withSummary("prompt", "") != "prompt" {
+		t.Error("unexpected withSummary result")
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	chatModel := &fakeChatModel{reply: "  The user asked about the weather in Paris.  "}
+	var transcript strings.Builder
+	for _, msg := range toolTurnMessages(1) {
+		transcript.WriteString(transcriptLine(msg))
+	}
+	if !strings.Contains(transcript.String(), "助手调用工具 weather") || !strings.Contains(transcript.String(), "sunny, 21°C") {
+		t.Errorf("tool calls should be part of the transcript: %s", transcript.String())
+	}
+
+	result, err := summarizeHistory(context.Background(), chatModel, "", transcript.String(), 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ResponseMeta.Usage.TotalTokens != 60 {
+		t.Errorf("usage of the summary call should be returned: %+v", result.ResponseMeta)
+	}
+	if len(chatModel.received) != 2 || !strings.Contains(chatModel.received[0].Content, "300") || !strings.Contains(chatModel.received[1].Content, "weather in Paris?") {
+		t.Errorf("unexpected summarizer input: %+v", chatModel.received)
+	}
+
+	if _, err := summarizeHistory(context.Background(), &fakeChatModel{reply: " "}, "", "x", 300); err == nil {
+		t.Error("expected an empty summary to be an error")
+	}
+}
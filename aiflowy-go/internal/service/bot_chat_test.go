@@ -0,0 +1,35 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+)
+
+func TestBuiltinToolEnabled(t *testing.T) {
+	var options entity.BotOptions
+	if err := json.Unmarshal([]byte(`{"builtinTools":{"get_current_time":false,"calculator":true}}`), &options); err != nil {
+		t.Fatalf("failed to parse options: %v", err)
+	}
+
+	if builtinToolEnabled(&options, "get_current_time") {
+		t.Error("explicitly disabled tool must be disabled")
+	}
+	if !builtinToolEnabled(&options, "calculator") {
+		t.Error("explicitly enabled tool must be enabled")
+	}
+	if !builtinToolEnabled(&options, "random") || !builtinToolEnabled(&entity.BotOptions{}, "random") {
+		t.Error("tools not configured must default to enabled")
+	}
+}
+
+func TestKnowledgeToolWrapper_Description(t *testing.T) {
+	tool := &KnowledgeToolWrapper{ToolName: "faq", ToolDesc: "Search the product FAQ"}
+	if tool.Description() != "Search the product FAQ" {
+		t.Errorf("unexpected description: %s", tool.Description())
+	}
+	if (&KnowledgeToolWrapper{ToolName: "faq"}).Description() == "" {
+		t.Error("expected a default description")
+	}
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// DocumentText 文档解析结果
+type DocumentText struct {
+	Content string      // 纯文本内容
+	Data    interface{} // 结构化内容 (json 为解析后的值，csv 为以表头为键的行数组)
+}
+
+// 支持解析的文档类型
+var supportedDocTypes = map[string]bool{
+	"txt": true, "md": true, "markdown": true, "html": true, "htm": true,
+	"csv": true, "json": true, "docx": true, "pdf": true,
+}
+
+// IsSupportedDocType 判断是否支持解析该扩展名的文档
+func IsSupportedDocType(ext string) bool {
+	return supportedDocTypes[strings.ToLower(strings.TrimPrefix(ext, "."))]
+}
+
+// ReadDocumentText 按扩展名解析文档内容
+func ReadDocumentText(ext string, data []byte) (*DocumentText, error) {
+	switch strings.ToLower(strings.TrimPrefix(ext, ".")) {
+	case "txt", "md", "markdown":
+		return &DocumentText{Content: decodeText(data)}, nil
+	case "html", "htm":
+		return &DocumentText{Content: extractHTMLText(decodeText(data))}, nil
+	case "json":
+		content := decodeText(data)
+		var value interface{}
+		if err := json.Unmarshal([]byte(content), &value); err != nil {
+			return nil, fmt.Errorf("JSON 文档格式错误: %w", err)
+		}
+		return &DocumentText{Content: content, Data: value}, nil
+	case "csv":
+		content := decodeText(data)
+		rows, err := parseCSVRows(content)
+		if err != nil {
+			return nil, err
+		}
+		return &DocumentText{Content: content, Data: rows}, nil
+	case "docx":
+		content, err := extractDocxText(data)
+		if err != nil {
+			return nil, err
+		}
+		return &DocumentText{Content: content}, nil
+	case "pdf":
+		content, err := extractPDFText(data)
+		if err != nil {
+			return nil, err
+		}
+		return &DocumentText{Content: content}, nil
+	default:
+		return nil, fmt.Errorf("不支持的文档类型: %s", ext)
+	}
+}
+
+// decodeText 去掉 BOM，非 UTF-8 内容按 GBK 解码
+func decodeText(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	if decoded, err := simplifiedchinese.GBK.NewDecoder().Bytes(data); err == nil {
+		return string(decoded)
+	}
+	return strings.ToValidUTF8(string(data), "")
+}
+
+// 块级元素前后换行
+var htmlBlockTags = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "table": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true, "pre": true, "blockquote": true,
+}
+
+// extractHTMLText 提取 HTML 正文文本，忽略脚本与样式
+func extractHTMLText(content string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+	var sb strings.Builder
+	skip := 0
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return normalizeLines(sb.String())
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if tag == "script" || tag == "style" || tag == "noscript" {
+				skip++
+			}
+			if htmlBlockTags[tag] {
+				sb.WriteByte('\n')
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			tag := string(name)
+			if (tag == "script" || tag == "style" || tag == "noscript") && skip > 0 {
+				skip--
+			}
+			if htmlBlockTags[tag] {
+				sb.WriteByte('\n')
+			}
+		case html.TextToken:
+			if skip == 0 {
+				sb.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// parseCSVRows 解析 CSV，首行为表头
+func parseCSVRows(content string) ([]interface{}, error) {
+	reader := csv.NewReader(strings.NewReader(content))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("CSV 文档格式错误: %w", err)
+	}
+	if len(records) == 0 {
+		return []interface{}{}, nil
+	}
+
+	header := records[0]
+	rows := make([]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			if i < len(record) {
+				row[name] = record[i]
+			} else {
+				row[name] = ""
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// extractDocxText 提取 docx 正文 (word/document.xml)，段落之间换行
+func extractDocxText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("docx 文档格式错误: %w", err)
+	}
+
+	var document *zip.File
+	for _, f := range archive.File {
+		if f.Name == "word/document.xml" {
+			document = f
+			break
+		}
+	}
+	if document == nil {
+		return "", fmt.Errorf("docx 文档缺少正文")
+	}
+
+	rc, err := document.Open()
+	if err != nil {
+		return "", fmt.Errorf("读取 docx 正文失败: %w", err)
+	}
+	defer rc.Close()
+
+	var sb strings.Builder
+	decoder := xml.NewDecoder(rc)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("解析 docx 正文失败: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				sb.WriteByte('\t')
+			case "br", "cr":
+				sb.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				sb.WriteByte('\n')
+			case "tc":
+				sb.WriteByte('\t')
+			}
+		case xml.CharData:
+			if inText {
+				sb.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String()), nil
+}
+
+// extractPDFText 提取 PDF 文本 (扫描件等无文本层的 PDF 结果为空)
+func extractPDFText(data []byte) (content string, err error) {
+	// 解析库在遇到损坏的文件时可能 panic
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("PDF 文档格式错误: %v", rec)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("PDF 文档格式错误: %w", err)
+	}
+	text, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("提取 PDF 文本失败: %w", err)
+	}
+	raw, err := io.ReadAll(text)
+	if err != nil {
+		return "", fmt.Errorf("提取 PDF 文本失败: %w", err)
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// normalizeLines 合并多余空白与空行
+func normalizeLines(content string) string {
+	lines := strings.Split(content, "\n")
+	result := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return strings.Join(result, "\n")
+}
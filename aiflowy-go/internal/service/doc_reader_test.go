@@ -0,0 +1,71 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadDocumentText(t *testing.T) {
+	var docx bytes.Buffer
+	zw := zip.NewWriter(&docx)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:r><w:t>合同编号</w:t><w:tab/><w:t>A-001</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t xml:space="preserve">甲方: </w:t><w:t>某公司</w:t></w:r></w:p>` +
+		`</w:body></w:document>`))
+	zw.Close()
+
+	tests := []struct {
+		ext    string
+		data   []byte
+		expect string
+	}{
+		{"txt", []byte("\xef\xbb\xbfhello"), "hello"},
+		{"md", []byte{0xc4, 0xe3, 0xba, 0xc3}, "你好"},
+		{"html", []byte(`<html><head><style>p{}</style><script>var a=1</script></head><body><h1>标题</h1><p>a &amp; b</p></body></html>`), "标题\na & b"},
+		{"docx", docx.Bytes(), "合同编号\tA-001\n甲方: 某公司"},
+	}
+	for _, tt := range tests {
+		doc, err := ReadDocumentText(tt.ext, tt.data)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.ext, err)
+			continue
+		}
+		if doc.Content != tt.expect {
+			t.Errorf("%s: expected %q, got %q", tt.ext, tt.expect, doc.Content)
+		}
+	}
+
+	doc, err := ReadDocumentText("csv", []byte("name,amount\nfoo,1\nbar,2\n"))
+	if err != nil {
+		t.Fatalf("csv: unexpected error: %v", err)
+	}
+	rows, _ := doc.Data.([]interface{})
+	if len(rows) != 2 || rows[1].(map[string]interface{})["name"] != "bar" {
+		t.Errorf("csv: unexpected rows %v", doc.Data)
+	}
+
+	if _, err := ReadDocumentText("pdf", []byte("not a pdf")); err == nil {
+		t.Error("pdf: expected error for malformed file")
+	}
+	if _, err := ReadDocumentText("exe", nil); err == nil {
+		t.Error("expected error for unsupported type")
+	}
+}
+
+func TestDocFullPath_StaysInsideRoot(t *testing.T) {
+	root, _ := filepath.Abs("./uploads")
+	for _, path := range []string{"2026/01/02/a.pdf", "../../etc/passwd", "/uploads/../../etc/passwd", "a/../../b"} {
+		fullPath, err := docFullPath(path)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", path, err)
+			continue
+		}
+		if !strings.HasPrefix(fullPath, root+string(filepath.Separator)) {
+			t.Errorf("%s escaped the storage root: %s", path, fullPath)
+		}
+	}
+}
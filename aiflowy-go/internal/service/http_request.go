@@ -0,0 +1,311 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTP 请求默认值
+const (
+	defaultHTTPTimeout    = 30 * time.Second
+	maxHTTPTimeout        = 5 * time.Minute
+	maxHTTPResponseSize   = 10 * 1024 * 1024
+	contentTypeJSON       = "application/json"
+	contentTypeForm       = "application/x-www-form-urlencoded"
+	authPositionHeaders   = "headers"
+	authTypeAPIKey        = "apiKey"
+	authTypeBearer        = "bearer"
+	authTypeBasic         = "basic"
+	maskedHTTPHeaderValue = "******"
+	maxHTTPLogBodyLength  = 4096
+)
+
+// httpRequestSpec HTTP 请求描述，插件工具与 HTTP 节点共用
+type httpRequestSpec struct {
+	Method  string
+	URL     string
+	Query   url.Values
+	Headers map[string]string
+	Body    []byte
+	Timeout time.Duration
+}
+
+// newHTTPRequestSpec 创建请求描述，method 为空时使用 GET
+func newHTTPRequestSpec(method, rawURL string) *httpRequestSpec {
+	method = strings.ToUpper(strings.TrimSpace(method))
+	if method == "" {
+		method = http.MethodGet
+	}
+	return &httpRequestSpec{
+		Method:  method,
+		URL:     rawURL,
+		Query:   url.Values{},
+		Headers: make(map[string]string),
+		Timeout: defaultHTTPTimeout,
+	}
+}
+
+// allowsBody GET / HEAD 请求不发送请求体
+func (s *httpRequestSpec) allowsBody() bool {
+	return s.Method != http.MethodGet && s.Method != http.MethodHead
+}
+
+// setBody 设置请求体，未指定 Content-Type 时使用 contentType
+func (s *httpRequestSpec) setBody(body []byte, contentType string) {
+	s.Body = body
+	if s.header("Content-Type") == "" && contentType != "" {
+		s.Headers["Content-Type"] = contentType
+	}
+}
+
+// setJSONBody 以 JSON 编码请求体
+func (s *httpRequestSpec) setJSONBody(value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("请求体编码失败: %w", err)
+	}
+	s.setBody(data, contentTypeJSON)
+	return nil
+}
+
+// setAPIKey 按位置设置 API Key 认证：position 为 headers 时放在请求头，否则放在查询参数
+func (s *httpRequestSpec) setAPIKey(position, key, value string) {
+	if key == "" || value == "" {
+		return
+	}
+	if position == authPositionHeaders {
+		s.Headers[key] = value
+	} else {
+		s.Query.Set(key, value)
+	}
+}
+
+// header 按不区分大小写的方式读取请求头
+func (s *httpRequestSpec) header(name string) string {
+	for k, v := range s.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+	return ""
+}
+
+// fullURL 拼接查询参数后的完整 URL
+func (s *httpRequestSpec) fullURL() string {
+	if len(s.Query) == 0 {
+		return s.URL
+	}
+	if strings.Contains(s.URL, "?") {
+		return s.URL + "&" + s.Query.Encode()
+	}
+	return s.URL + "?" + s.Query.Encode()
+}
+
+// httpResponse HTTP 响应
+type httpResponse struct {
+	StatusCode int
+	Status     string
+	Headers    http.Header
+	Body       []byte
+}
+
+// decodeBody 响应体为 JSON 时返回解析后的值，否则返回字符串
+func (r *httpResponse) decodeBody() interface{} {
+	var result interface{}
+	if err := json.Unmarshal(r.Body, &result); err != nil {
+		return string(r.Body)
+	}
+	return result
+}
+
+// errPrivateAddress 访问用户提供的 URL 时目标为内网地址
+var errPrivateAddress = errors.New("不允许访问内网地址")
+
+// 除回环、私有、链路本地 (含云厂商元数据 169.254.169.254) 等地址外，同样不允许访问的网段
+var blockedAddressPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT，部分云厂商的元数据地址 (100.100.100.200)
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 可映射到内网 IPv4
+}
+
+// publicHTTPClient 只能访问公网地址的 HTTP 客户端，用于请求用户提供的 URL (HTTP 节点、对话附件)。
+// 在拨号时检查解析后的地址，DNS 重绑定与重定向到内网地址同样会被拒绝；不使用环境变量中的代理
+var publicHTTPClient = newPublicHTTPClient()
+
+func newPublicHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicAddressControl,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("重定向次数过多")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("不支持重定向到 %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// publicAddressControl 拨号前检查目标地址，拒绝内网地址
+func publicAddressControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errPrivateAddress, address)
+	}
+	if !isPublicAddress(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", errPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// isPublicAddress 判断地址是否为可访问的公网地址
+func isPublicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedAddressPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// doHTTPRequest 使用 client 发送请求并读取响应，响应体超过上限时返回错误
+func doHTTPRequest(ctx context.Context, client *http.Client, spec *httpRequestSpec) (*httpResponse, error) {
+	var body io.Reader
+	if spec.Body != nil && spec.allowsBody() {
+		body = bytes.NewReader(spec.Body)
+	}
+
+	timeout := spec.Timeout
+	if timeout <= 0 {
+		timeout = defaultHTTPTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, spec.Method, spec.fullURL(), body)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	for k, v := range spec.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			// 不保留网络错误类型，避免按网络错误重试
+			return nil, fmt.Errorf("请求失败: %v", err)
+		}
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(respBody) > maxHTTPResponseSize {
+		return nil, fmt.Errorf("响应体超过 %d 字节", maxHTTPResponseSize)
+	}
+
+	return &httpResponse{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Headers:    resp.Header,
+		Body:       respBody,
+	}, nil
+}
+
+// formatHTTPExchange 将请求与响应格式化为调试日志，认证相关的请求头会被隐藏，过长的请求体会被截断
+func formatHTTPExchange(spec *httpRequestSpec, resp *httpResponse, duration time.Duration) string {
+	var b strings.Builder
+	logged := *spec
+	logged.Query = url.Values{}
+	for name, values := range spec.Query {
+		if isSensitiveHeader(name) {
+			values = []string{maskedHTTPHeaderValue}
+		}
+		logged.Query[name] = values
+	}
+	fmt.Fprintf(&b, "> %s %s\n", spec.Method, logged.fullURL())
+	headerNames := make([]string, 0, len(spec.Headers))
+	for name := range spec.Headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	for _, name := range headerNames {
+		value := spec.Headers[name]
+		if isSensitiveHeader(name) {
+			value = maskedHTTPHeaderValue
+		}
+		fmt.Fprintf(&b, "> %s: %s\n", name, value)
+	}
+	if len(spec.Body) > 0 && spec.allowsBody() {
+		fmt.Fprintf(&b, ">\n%s\n", truncateHTTPLogBody(spec.Body))
+	}
+	if resp == nil {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n< %s (%dms)\n", resp.Status, duration.Milliseconds())
+	names := make([]string, 0, len(resp.Headers))
+	for name := range resp.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(resp.Headers[name], ", ")
+		if isSensitiveHeader(name) {
+			value = maskedHTTPHeaderValue
+		}
+		fmt.Fprintf(&b, "< %s: %s\n", name, value)
+	}
+	if len(resp.Body) > 0 {
+		fmt.Fprintf(&b, "<\n%s\n", truncateHTTPLogBody(resp.Body))
+	}
+	return b.String()
+}
+
+// isSensitiveHeader 判断请求头、响应头或查询参数是否包含凭证
+func isSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	return name == "authorization" || name == "proxy-authorization" || name == "cookie" || name == "set-cookie" ||
+		strings.Contains(name, "token") || strings.Contains(name, "key") || strings.Contains(name, "secret")
+}
+
+// truncateHTTPLogBody 截断日志中的请求体或响应体
+func truncateHTTPLogBody(body []byte) string {
+	if len(body) <= maxHTTPLogBodyLength {
+		return string(body)
+	}
+	return fmt.Sprintf("%s... (共 %d 字节)", body[:maxHTTPLogBodyLength], len(body))
+}
@@ -5,8 +5,11 @@ import (
 	"fmt"
 	"io"
 
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/schema"
 
+	"github.com/aiflowy/aiflowy-go/internal/entity"
 	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
 	"github.com/aiflowy/aiflowy-go/internal/repository"
 )
@@ -30,6 +33,23 @@ type ChatRequest struct {
 	ModelID  int64    `json:"modelId"`
 	Messages []Message `json:"messages"`
 	Options  *ChatOptions `json:"options,omitempty"`
+	// Output requests a JSON reply instead of free text (synchronous Chat only)
+	Output *OutputFormat `json:"-"`
+}
+
+// Structured output modes
+const (
+	OutputModeAuto   = "auto"   // tool forcing when the model supports tools, otherwise JSON mode
+	OutputModeTool   = "tool"   // force a call to OutputFormat.Tool and return its arguments
+	OutputModeJSON   = "json"   // native JSON mode (response_format json_object)
+	OutputModePrompt = "prompt" // rely on the prompt only
+)
+
+// OutputFormat describes a structured (JSON) reply
+type OutputFormat struct {
+	Mode string
+	// Tool is forced in tool mode; its parameters describe the expected JSON object
+	Tool *schema.ToolInfo
 }
 
 // Message represents a chat message
@@ -53,6 +73,8 @@ type ChatResponse struct {
 	ModelName    string `json:"modelName"`
 	ProviderType string `json:"providerType"`
 	FinishReason string `json:"finishReason,omitempty"`
+	// OutputMode is the structured output mode actually used
+	OutputMode string `json:"outputMode,omitempty"`
 }
 
 // StreamChunk represents a streaming response chunk
@@ -88,25 +110,93 @@ func (s *ChatService) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse
 		}
 	}
 
+	providerType := ""
+	if model.ModelProvider != nil {
+		providerType = model.ModelProvider.ProviderType
+	}
+
+	// Structured output: bind the forced tool or ask for JSON mode
+	var generator einomodel.BaseChatModel = chatModel
+	var opts []einomodel.Option
+	outputMode := resolveOutputMode(req.Output, model.SupportTool, providerType)
+	switch outputMode {
+	case OutputModeTool:
+		tcm, ok := chatModel.(einomodel.ToolCallingChatModel)
+		if !ok {
+			return nil, apierrors.InternalError("模型不支持工具调用")
+		}
+		toolModel, err := tcm.WithTools([]*schema.ToolInfo{req.Output.Tool})
+		if err != nil {
+			return nil, apierrors.InternalError(fmt.Sprintf("绑定工具失败: %v", err))
+		}
+		generator = toolModel
+		opts = append(opts, einomodel.WithToolChoice(schema.ToolChoiceForced, req.Output.Tool.Name))
+	case OutputModeJSON:
+		opts = append(opts, openai.WithExtraFields(map[string]any{
+			"response_format": map[string]any{"type": "json_object"},
+		}))
+	}
+
 	// Generate response
-	result, err := chatModel.Generate(ctx, messages)
+	result, err := generator.Generate(ctx, messages, opts...)
 	if err != nil {
+		// Cancelled or timed out by the caller
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
 		return nil, apierrors.InternalError(fmt.Sprintf("生成回复失败: %v", err))
 	}
 
-	providerType := ""
-	if model.ModelProvider != nil {
-		providerType = model.ModelProvider.ProviderType
+	// In tool mode the reply is the arguments of the forced tool call
+	content := result.Content
+	if outputMode == OutputModeTool {
+		for _, tc := range result.ToolCalls {
+			if tc.Function.Name == req.Output.Tool.Name {
+				content = tc.Function.Arguments
+				break
+			}
+		}
 	}
 
 	return &ChatResponse{
-		Content:      result.Content,
+		Content:      content,
 		Role:         string(result.Role),
 		ModelName:    model.ModelName,
 		ProviderType: providerType,
+		OutputMode:   outputMode,
 	}, nil
 }
 
+// resolveOutputMode picks the structured output mode supported by the model.
+// Tool forcing needs SupportTool; JSON mode is sent as an OpenAI-compatible
+// response_format, which Ollama models don't accept per request.
+func resolveOutputMode(output *OutputFormat, supportTool bool, providerType string) string {
+	if output == nil {
+		return ""
+	}
+	jsonMode := providerType != entity.ProviderTypeOllama
+	switch output.Mode {
+	case OutputModeTool:
+		if supportTool && output.Tool != nil {
+			return OutputModeTool
+		}
+	case OutputModeJSON:
+		if jsonMode {
+			return OutputModeJSON
+		}
+		return OutputModePrompt
+	case OutputModePrompt:
+		return OutputModePrompt
+	}
+	if supportTool && output.Tool != nil {
+		return OutputModeTool
+	}
+	if jsonMode {
+		return OutputModeJSON
+	}
+	return OutputModePrompt
+}
+
 // ChatStream performs a streaming chat completion
 func (s *ChatService) ChatStream(ctx context.Context, req *ChatRequest, onChunk func(*StreamChunk) error) error {
 	// Get model with provider info
@@ -136,12 +226,19 @@ func (s *ChatService) ChatStream(ctx context.Context, req *ChatRequest, onChunk
 	// Generate streaming response
 	streamReader, err := chatModel.Stream(ctx, messages)
 	if err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
 		return apierrors.InternalError(fmt.Sprintf("开始流式生成失败: %v", err))
 	}
 	defer streamReader.Close()
 
-	// Read stream chunks
+	// Read stream chunks, stopping as soon as the caller cancels
 	for {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
 		chunk, err := streamReader.Recv()
 		if err == io.EOF {
 			// Send final done chunk
@@ -151,6 +248,9 @@ func (s *ChatService) ChatStream(ctx context.Context, req *ChatRequest, onChunk
 			break
 		}
 		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
 			return apierrors.InternalError(fmt.Sprintf("读取流失败: %v", err))
 		}
 
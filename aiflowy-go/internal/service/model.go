@@ -212,6 +212,9 @@ func (s *ModelService) SaveModel(ctx context.Context, req *dto.ModelSaveRequest)
 		SupportVideo:        req.SupportVideo,
 		SupportAudio:        req.SupportAudio,
 		SupportFree:         req.SupportFree,
+		InputPrice:          req.InputPrice,
+		OutputPrice:         req.OutputPrice,
+		ContextLength:       req.ContextLength,
 	}
 
 	if err := s.repo.CreateModel(ctx, model); err != nil {
@@ -256,6 +259,9 @@ func (s *ModelService) UpdateModel(ctx context.Context, req *dto.ModelSaveReques
 	existing.SupportVideo = req.SupportVideo
 	existing.SupportAudio = req.SupportAudio
 	existing.SupportFree = req.SupportFree
+	existing.InputPrice = req.InputPrice
+	existing.OutputPrice = req.OutputPrice
+	existing.ContextLength = req.ContextLength
 
 	if err := s.repo.UpdateModel(ctx, existing); err != nil {
 		return nil, apierrors.InternalError("更新模型失败")
@@ -308,6 +314,9 @@ func (s *ModelService) AddAllLlm(ctx context.Context, req *dto.AddAllLlmRequest)
 			SupportVideo:        modelReq.SupportVideo,
 			SupportAudio:        modelReq.SupportAudio,
 			SupportFree:         modelReq.SupportFree,
+			InputPrice:          modelReq.InputPrice,
+			OutputPrice:         modelReq.OutputPrice,
+			ContextLength:       modelReq.ContextLength,
 		}
 		if err := s.repo.CreateModel(ctx, model); err != nil {
 			return apierrors.InternalError("批量创建模型失败")
@@ -1,16 +1,13 @@
 package service
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
-	"net/url"
+	"sort"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/cloudwego/eino/schema"
 
@@ -121,28 +118,22 @@ func (s *PluginToolService) executeHTTPRequest(ctx context.Context, plugin *enti
 		json.Unmarshal([]byte(item.InputData), &params)
 	}
 
-	// 分类参数
-	queryParams := url.Values{}
+	spec := newHTTPRequestSpec(item.RequestMethod, fullURL)
 	bodyParams := make(map[string]interface{})
-	headers := make(map[string]string)
 
 	// 设置插件请求头
 	if plugin.Headers != "" {
 		var headerList []dto.PluginHeader
 		if err := json.Unmarshal([]byte(plugin.Headers), &headerList); err == nil {
 			for _, h := range headerList {
-				headers[h.Label] = h.Value
+				spec.Headers[h.Label] = h.Value
 			}
 		}
 	}
 
 	// 设置认证
-	if plugin.AuthType == "apiKey" && plugin.TokenKey != "" && plugin.TokenValue != "" {
-		if plugin.Position == "headers" {
-			headers[plugin.TokenKey] = plugin.TokenValue
-		} else {
-			queryParams.Set(plugin.TokenKey, plugin.TokenValue)
-		}
+	if plugin.AuthType == authTypeAPIKey {
+		spec.setAPIKey(plugin.Position, plugin.TokenKey, plugin.TokenValue)
 	}
 
 	// 处理参数
@@ -168,73 +159,28 @@ func (s *PluginToolService) executeHTTPRequest(ctx context.Context, plugin *enti
 		// 根据参数位置分类
 		switch strings.ToLower(p.Method) {
 		case "query":
-			queryParams.Set(p.Name, fmt.Sprintf("%v", value))
+			spec.Query.Set(p.Name, fmt.Sprintf("%v", value))
 		case "body":
 			bodyParams[p.Name] = value
 		case "header":
-			headers[p.Name] = fmt.Sprintf("%v", value)
+			spec.Headers[p.Name] = fmt.Sprintf("%v", value)
 		case "path":
-			fullURL = strings.ReplaceAll(fullURL, "{"+p.Name+"}", fmt.Sprintf("%v", value))
-		}
-	}
-
-	// 添加 query 参数到 URL
-	if len(queryParams) > 0 {
-		if strings.Contains(fullURL, "?") {
-			fullURL += "&" + queryParams.Encode()
-		} else {
-			fullURL += "?" + queryParams.Encode()
+			spec.URL = strings.ReplaceAll(spec.URL, "{"+p.Name+"}", fmt.Sprintf("%v", value))
 		}
 	}
 
-	// 构建请求
-	method := strings.ToUpper(item.RequestMethod)
-	if method == "" {
-		method = "GET"
-	}
-
-	var body io.Reader
-	if method != "GET" && method != "HEAD" && len(bodyParams) > 0 {
-		bodyBytes, _ := json.Marshal(bodyParams)
-		body = bytes.NewReader(bodyBytes)
-		if headers["Content-Type"] == "" {
-			headers["Content-Type"] = "application/json"
+	if spec.allowsBody() && len(bodyParams) > 0 {
+		if err := spec.setJSONBody(bodyParams); err != nil {
+			return nil, err
 		}
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	// 设置请求头
-	for k, v := range headers {
-		req.Header.Set(k, v)
-	}
-
-	// 发送请求
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// 读取响应
-	respBody, err := io.ReadAll(resp.Body)
+	// 发送请求，响应为 JSON 时返回解析后的值，否则返回原始字符串
+	resp, err := doHTTPRequest(ctx, http.DefaultClient, spec)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	// 尝试解析为 JSON
-	var result interface{}
-	if err := json.Unmarshal(respBody, &result); err != nil {
-		// 返回原始字符串
-		return string(respBody), nil
+		return nil, err
 	}
-	return result, nil
+	return resp.decodeBody(), nil
 }
 
 // ========================== PluginTool (Eino Tool 实现) ==========================
@@ -317,49 +263,25 @@ func (t *PluginTool) Execute(ctx context.Context, args map[string]interface{}) (
 
 // ========================== 加载 Bot 插件工具 ==========================
 
-// LoadBotPluginTools 加载 Bot 关联的插件工具并注册到 Registry
-func (s *PluginToolService) LoadBotPluginTools(ctx context.Context, botID int64) ([]*schema.ToolInfo, error) {
+// LoadBotPluginTools 加载 Bot 关联的插件工具，按插件工具 ID 排序
+func (s *PluginToolService) LoadBotPluginTools(ctx context.Context, botID int64) ([]aitool.Tool, error) {
 	// 获取 Bot 关联的插件工具
 	items, err := s.repo.ListPluginItemsByBotID(ctx, botID)
 	if err != nil {
 		return nil, err
 	}
+	// 名称冲突时先加入的工具保留名称，排序保证结果稳定
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
 
-	if len(items) == 0 {
-		return nil, nil
-	}
-
-	var toolInfos []*schema.ToolInfo
-
+	var tools []aitool.Tool
 	for _, item := range items {
 		// 获取插件
 		plugin, err := s.repo.GetPluginByID(ctx, item.PluginID)
 		if err != nil || plugin == nil {
 			continue
 		}
-
-		// 创建 PluginTool
-		pluginTool := NewPluginTool(plugin, item)
-
-		// 转换为 Eino ToolInfo
-		toolInfo := &schema.ToolInfo{
-			Name: pluginTool.Name(),
-			Desc: pluginTool.Description(),
-			ParamsOneOf: schema.NewParamsOneOfByParams(
-				convertToSchemaParams(pluginTool.Parameters()),
-			),
-		}
-		toolInfos = append(toolInfos, toolInfo)
-
-		// 注册到全局 Registry (用于执行)
-		registry := aitool.GetRegistry()
-		registry.Register(pluginTool)
+		tools = append(tools, NewPluginTool(plugin, item))
 	}
 
-	return toolInfos, nil
-}
-
-// convertToSchemaParams 转换参数格式
-func convertToSchemaParams(params map[string]*schema.ParameterInfo) map[string]*schema.ParameterInfo {
-	return params
+	return tools, nil
 }
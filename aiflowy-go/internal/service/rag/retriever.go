@@ -184,13 +184,27 @@ func (s *RAGService) Search(ctx context.Context, collectionID int64, query strin
 		return nil, fmt.Errorf("collection %d not found", collectionID)
 	}
 
+	config := &RetrieverConfig{
+		TopK:           topK,
+		ScoreThreshold: 0.3, // 较低的阈值以获取更多结果
+	}
+
+	return s.SearchCollection(ctx, collection, query, config)
+}
+
+// SearchCollection 按检索配置搜索知识库，向量存储未启用时回退到全文搜索
+func (s *RAGService) SearchCollection(ctx context.Context, collection *entity.DocumentCollection, query string, config *RetrieverConfig) ([]*VectorDocument, error) {
+	if config == nil {
+		config = DefaultRetrieverConfig()
+	}
+
 	if !collection.VectorStoreEnable {
 		// 向量存储未启用，回退到全文搜索
-		return s.fullTextSearch(ctx, collectionID, query, topK)
+		return s.fullTextSearch(ctx, collection.ID, query, config.TopK)
 	}
 
 	if collection.VectorEmbedModelID == nil || *collection.VectorEmbedModelID == 0 {
-		return s.fullTextSearch(ctx, collectionID, query, topK)
+		return s.fullTextSearch(ctx, collection.ID, query, config.TopK)
 	}
 
 	// 获取 retriever
@@ -199,11 +213,6 @@ func (s *RAGService) Search(ctx context.Context, collectionID int64, query strin
 		return nil, err
 	}
 
-	config := &RetrieverConfig{
-		TopK:           topK,
-		ScoreThreshold: 0.3, // 较低的阈值以获取更多结果
-	}
-
 	return retriever.Retrieve(ctx, query, config)
 }
 
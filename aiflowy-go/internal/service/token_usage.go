@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+)
+
+// TokenUsageService 大模型 token 用量统计与配额
+type TokenUsageService struct {
+	repo  *repository.TokenUsageRepository
+	quota config.QuotaConfig
+	now   func() time.Time
+}
+
+// NewTokenUsageService 创建 TokenUsageService
+func NewTokenUsageService() *TokenUsageService {
+	s := &TokenUsageService{
+		repo: repository.NewTokenUsageRepository(),
+		now:  time.Now,
+	}
+	if cfg := config.GetConfig(); cfg != nil {
+		s.quota = cfg.Quota
+	}
+	return s
+}
+
+// Record 保存一轮对话的用量
+func (s *TokenUsageService) Record(ctx context.Context, record *entity.TokenUsageRecord) error {
+	if record.Created.IsZero() {
+		record.Created = s.now()
+	}
+	return s.repo.Create(ctx, record)
+}
+
+// CheckQuota 检查用户和租户本日、本月的用量，任一配额用完时返回 CodeQuotaExceeded 错误。
+// 统计失败时不阻断对话
+func (s *TokenUsageService) CheckQuota(ctx context.Context, tenantID, userID int64) error {
+	scopes := []struct {
+		scope string
+		id    int64
+		name  string
+		limit config.QuotaLimit
+	}{
+		{repository.TokenUsageScopeAccount, userID, "用户", s.quota.User},
+		{repository.TokenUsageScopeTenant, tenantID, "租户", s.quota.Tenant},
+	}
+
+	now := s.now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	for _, sc := range scopes {
+		if !quotaLimited(sc.limit) {
+			continue
+		}
+		month, day, err := s.repo.SumByScope(ctx, sc.scope, sc.id, monthStart, dayStart)
+		if err != nil {
+			fmt.Printf("Failed to check token quota: %v\n", err)
+			continue
+		}
+		if err := checkQuotaLimit(sc.name, sc.limit, month, day); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Summarize 按用户、机器人、模型或租户汇总租户在时间范围内的用量
+func (s *TokenUsageService) Summarize(ctx context.Context, req *dto.TokenUsageSummaryRequest, tenantID int64) ([]*entity.TokenUsageStat, error) {
+	switch req.GroupBy {
+	case "":
+		req.GroupBy = dto.TokenUsageGroupModel
+	case dto.TokenUsageGroupUser, dto.TokenUsageGroupBot, dto.TokenUsageGroupModel, dto.TokenUsageGroupTenant:
+	default:
+		return nil, apierrors.BadRequest("不支持的汇总维度: " + req.GroupBy)
+	}
+
+	from, _, err := parseExecTime(req.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	to, dateOnly, err := parseExecTime(req.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	} else if !to.IsZero() {
+		to = to.Add(time.Second)
+	}
+
+	stats, err := s.repo.Summarize(ctx, req, tenantID, from, to)
+	if err != nil {
+		return nil, apierrors.InternalError("获取用量统计失败")
+	}
+	return stats, nil
+}
+
+// quotaLimited 是否配置了任一上限
+func quotaLimited(limit config.QuotaLimit) bool {
+	return limit.DailyTokens > 0 || limit.MonthlyTokens > 0 || limit.DailyCost > 0 || limit.MonthlyCost > 0
+}
+
+// checkQuotaLimit 用量达到上限时返回错误
+func checkQuotaLimit(name string, limit config.QuotaLimit, month, day entity.TokenUsageTotal) error {
+	switch {
+	case limit.DailyTokens > 0 && day.Tokens >= limit.DailyTokens:
+		return quotaError(fmt.Sprintf("%s今日 token 用量已达上限 (%d)", name, limit.DailyTokens))
+	case limit.MonthlyTokens > 0 && month.Tokens >= limit.MonthlyTokens:
+		return quotaError(fmt.Sprintf("%s本月 token 用量已达上限 (%d)", name, limit.MonthlyTokens))
+	case limit.DailyCost > 0 && day.Cost >= limit.DailyCost:
+		return quotaError(fmt.Sprintf("%s今日费用已达上限 (%.2f)", name, limit.DailyCost))
+	case limit.MonthlyCost > 0 && month.Cost >= limit.MonthlyCost:
+		return quotaError(fmt.Sprintf("%s本月费用已达上限 (%.2f)", name, limit.MonthlyCost))
+	}
+	return nil
+}
+
+// quotaError 创建配额用完的错误
+func quotaError(message string) error {
+	return apierrors.New(apierrors.CodeQuotaExceeded, message)
+}
+
+// isQuotaExceeded 判断是否为配额用完的错误
+func isQuotaExceeded(err error) (*apierrors.BusinessError, bool) {
+	var bizErr *apierrors.BusinessError
+	if errors.As(err, &bizErr) && bizErr.Code == apierrors.CodeQuotaExceeded {
+		return bizErr, true
+	}
+	return nil, false
+}
+
+// tokenCost 按模型每百万 tokens 的输入、输出价格计算费用
+func tokenCost(model *entity.Model, promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*model.InputPrice + float64(completionTokens)*model.OutputPrice) / 1e6
+}
+
+// modelProviderType 模型的供应商类型，用于指标标签
+func modelProviderType(model *entity.Model) string {
+	if model.ModelProvider != nil && model.ModelProvider.ProviderType != "" {
+		return model.ModelProvider.ProviderType
+	}
+	return "unknown"
+}
+
+// chatUsage 一轮对话的用量，累加工具调用循环中每次模型调用的用量
+type chatUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	FinishReason     string
+	reported         bool
+}
+
+// add 累加一次模型调用的响应元数据，没有用量时只记录结束原因
+func (u *chatUsage) add(meta *schema.ResponseMeta) {
+	if meta == nil {
+		return
+	}
+	if meta.FinishReason != "" {
+		u.FinishReason = meta.FinishReason
+	}
+	if meta.Usage == nil {
+		return
+	}
+	total := meta.Usage.TotalTokens
+	if total == 0 {
+		total = meta.Usage.PromptTokens + meta.Usage.CompletionTokens
+	}
+	u.PromptTokens += meta.Usage.PromptTokens
+	u.CompletionTokens += meta.Usage.CompletionTokens
+	u.TotalTokens += total
+	u.reported = true
+}
+
+// tokenUsage 保存到消息中的用量，模型没有返回用量时为 nil
+func (u *chatUsage) tokenUsage() *entity.TokenUsage {
+	if !u.reported {
+		return nil
+	}
+	return &entity.TokenUsage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+	}
+}
+
+// meta 转换为 system.done 的元数据
+func (u *chatUsage) meta(modelName string, latencyMs int64) *protocol.Meta {
+	return &protocol.Meta{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		LatencyMs:        latencyMs,
+		ModelName:        modelName,
+		FinishReason:     u.FinishReason,
+	}
+}
+
+// mergeStreamMeta 合并流式分片的响应元数据。有的供应商只在最后一个分片返回用量，
+// 有的每个分片都返回累计用量，所以保留最后一次出现的用量
+func mergeStreamMeta(dst *schema.ResponseMeta, chunk *schema.Message) {
+	if chunk.ResponseMeta == nil {
+		return
+	}
+	if chunk.ResponseMeta.FinishReason != "" {
+		dst.FinishReason = chunk.ResponseMeta.FinishReason
+	}
+	if chunk.ResponseMeta.Usage != nil {
+		dst.Usage = chunk.ResponseMeta.Usage
+	}
+}
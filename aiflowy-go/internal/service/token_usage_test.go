@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+)
+
+func TestChatUsage_ToolLoop(t *testing.T) {
+	usage := &chatUsage{}
+	if usage.tokenUsage() != nil {
+		t.Error("expected no usage before anything is reported")
+	}
+
+	// first iteration asks for a tool, the second answers
+	usage.add(&schema.ResponseMeta{FinishReason: "tool_calls", Usage: &schema.TokenUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}})
+	usage.add(&schema.ResponseMeta{FinishReason: "stop", Usage: &schema.TokenUsage{PromptTokens: 150, CompletionTokens: 30}})
+	usage.add(nil)
+
+	got := usage.tokenUsage()
+	if got == nil || got.PromptTokens != 250 || got.CompletionTokens != 50 || got.TotalTokens != 300 {
+		t.Errorf("unexpected usage: %+v", got)
+	}
+	if usage.FinishReason != "stop" {
+		t.Errorf("expected finish reason of the last call, got %s", usage.FinishReason)
+	}
+
+	meta := usage.meta("gpt-4o", 42)
+	if meta.TotalTokens != 300 || meta.LatencyMs != 42 || meta.ModelName != "gpt-4o" || meta.FinishReason != "stop" {
+		t.Errorf("unexpected meta: %+v", meta)
+	}
+}
+
+func TestMergeStreamMeta(t *testing.T) {
+	var meta schema.ResponseMeta
+	chunks := []*schema.Message{
+		{Content: "Hel"},
+		{Content: "lo", ResponseMeta: &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 1, TotalTokens: 11}}},
+		{ResponseMeta: &schema.ResponseMeta{FinishReason: "stop"}},
+		// running totals: the last usage wins
+		{ResponseMeta: &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}}},
+	}
+	for _, chunk := range chunks {
+		mergeStreamMeta(&meta, chunk)
+	}
+
+	if meta.FinishReason != "stop" || meta.Usage == nil || meta.Usage.TotalTokens != 12 {
+		t.Errorf("unexpected merged meta: %+v", meta)
+	}
+}
+
+func TestCheckQuotaLimit(t *testing.T) {
+	limit := config.QuotaLimit{DailyTokens: 1000, MonthlyCost: 5}
+
+	if err := checkQuotaLimit("用户", limit, entity.TokenUsageTotal{Tokens: 5000, Cost: 4.9}, entity.TokenUsageTotal{Tokens: 999}); err != nil {
+		t.Errorf("expected usage under the limits to pass: %v", err)
+	}
+
+	err := checkQuotaLimit("用户", limit, entity.TokenUsageTotal{Tokens: 5000}, entity.TokenUsageTotal{Tokens: 1000})
+	if bizErr, ok := isQuotaExceeded(err); !ok || bizErr.Message == "" {
+		t.Errorf("expected daily token quota error, got %v", err)
+	}
+	if _, ok := isQuotaExceeded(checkQuotaLimit("租户", limit, entity.TokenUsageTotal{Cost: 5}, entity.TokenUsageTotal{})); !ok {
+		t.Error("expected monthly cost quota error")
+	}
+
+	if quotaLimited(config.QuotaLimit{}) {
+		t.Error("an empty limit must not be enforced")
+	}
+}
+
+func TestAssistantMessageOptions(t *testing.T) {
+	model := &entity.Model{ModelName: "gpt-4o", InputPrice: 2.5, OutputPrice: 10}
+	usage := &chatUsage{}
+	usage.add(&schema.ResponseMeta{FinishReason: "stop", Usage: &schema.TokenUsage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500}})
+
+	var options map[string]interface{}
+	if err := json.Unmarshal([]byte(assistantMessageOptions(model, usage, "let me think", nil)), &options); err != nil {
+		t.Fatalf("invalid options: %v", err)
+	}
+	if options["thinking"] != "let me think" || options["modelName"] != "gpt-4o" || options["finishReason"] != "stop" {
+		t.Errorf("unexpected options: %v", options)
+	}
+	// 1000 * 2.5 / 1M + 500 * 10 / 1M
+	if cost, _ := options["cost"].(float64); math.Abs(cost-0.0075) > 1e-9 {
+		t.Errorf("expected cost 0.0075, got %v", options["cost"])
+	}
+	if tokenUsage, ok := options["tokenUsage"].(map[string]interface{}); !ok || tokenUsage["totalTokens"] != float64(1500) {
+		t.Errorf("unexpected token usage: %v", options["tokenUsage"])
+	}
+
+	// providers without usage still get the model name saved
+	if data := assistantMessageOptions(model, &chatUsage{}, "", nil); data != `{"modelName":"gpt-4o"}` {
+		t.Errorf("unexpected options without usage: %s", data)
+	}
+}
+
+func TestTokenUsageService_SummarizeRejectsUnknownGroup(t *testing.T) {
+	s := &TokenUsageService{}
+	if _, err := s.Summarize(context.Background(), &dto.TokenUsageSummaryRequest{GroupBy: "department"}, 1); err == nil {
+		t.Error("expected unknown dimension to be rejected")
+	}
+	if _, err := s.Summarize(context.Background(), &dto.TokenUsageSummaryRequest{StartTime: "yesterday"}, 1); err == nil {
+		t.Error("expected invalid time to be rejected")
+	}
+}
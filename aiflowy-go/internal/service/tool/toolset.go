@@ -0,0 +1,103 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// maxToolNameLength is the longest tool name accepted by LLM providers
+const maxToolNameLength = 64
+
+// ToolSet is an ordered, request-scoped set of tools. Unlike the global
+// Registry it is built for a single chat, so tools of different bots never
+// see each other
+type ToolSet struct {
+	names []string
+	tools map[string]Tool
+}
+
+// NewToolSet creates an empty ToolSet
+func NewToolSet() *ToolSet {
+	return &ToolSet{tools: make(map[string]Tool)}
+}
+
+// Add adds a tool and returns the name it is exposed under. A tool whose name
+// is already taken is renamed with a numeric suffix (name_2, name_3, ...), so
+// the earlier tool always keeps its name and the result only depends on the
+// order tools are added
+func (s *ToolSet) Add(t Tool) string {
+	name := t.Name()
+	for i := 2; s.has(name); i++ {
+		suffix := "_" + strconv.Itoa(i)
+		base := t.Name()
+		if len(base)+len(suffix) > maxToolNameLength {
+			base = base[:maxToolNameLength-len(suffix)]
+		}
+		name = base + suffix
+	}
+
+	if name != t.Name() {
+		t = &renamedTool{Tool: t, name: name}
+	}
+	s.names = append(s.names, name)
+	s.tools[name] = t
+	return name
+}
+
+// has reports whether a name is taken
+func (s *ToolSet) has(name string) bool {
+	_, ok := s.tools[name]
+	return ok
+}
+
+// Len returns the number of tools
+func (s *ToolSet) Len() int {
+	return len(s.names)
+}
+
+// Names returns the exposed tool names in the order they were added
+func (s *ToolSet) Names() []string {
+	return append([]string(nil), s.names...)
+}
+
+// Get retrieves a tool by its exposed name
+func (s *ToolSet) Get(name string) (Tool, bool) {
+	t, ok := s.tools[name]
+	return t, ok
+}
+
+// ToolInfos returns the tool infos for LLM binding in the order tools were added
+func (s *ToolSet) ToolInfos(ctx context.Context) ([]*schema.ToolInfo, error) {
+	infos := make([]*schema.ToolInfo, 0, len(s.names))
+	for _, name := range s.names {
+		info, err := NewToolWrapper(s.tools[name]).Info(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tool info for %s: %w", name, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Execute runs a tool of the set by name with the given arguments
+func (s *ToolSet) Execute(ctx context.Context, name string, argsJSON string) (string, error) {
+	t, ok := s.Get(name)
+	if !ok {
+		return "", fmt.Errorf("tool not found: %s", name)
+	}
+	return NewToolWrapper(t).InvokableRun(ctx, argsJSON)
+}
+
+// renamedTool exposes a tool under another name to resolve a collision
+type renamedTool struct {
+	Tool
+	name string
+}
+
+// Name returns the exposed name
+func (t *renamedTool) Name() string {
+	return t.name
+}
@@ -0,0 +1,75 @@
+package tool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestToolSet_Collisions(t *testing.T) {
+	set := NewToolSet()
+
+	if name := set.Add(NewMockTool("search", "first")); name != "search" {
+		t.Errorf("expected first tool to keep its name, got %s", name)
+	}
+	if name := set.Add(NewMockTool("search", "second")); name != "search_2" {
+		t.Errorf("expected search_2, got %s", name)
+	}
+	// a tool that happens to use the generated name is renamed in turn
+	if name := set.Add(NewMockTool("search_2", "third")); name != "search_2_2" {
+		t.Errorf("expected search_2_2, got %s", name)
+	}
+	if name := set.Add(NewMockTool("search", "fourth")); name != "search_3" {
+		t.Errorf("expected search_3, got %s", name)
+	}
+
+	infos, err := set.ToolInfos(context.Background())
+	if err != nil {
+		t.Fatalf("failed to get tool infos: %v", err)
+	}
+	want := []string{"search", "search_2", "search_2_2", "search_3"}
+	for i, info := range infos {
+		if info.Name != want[i] {
+			t.Errorf("tool %d: expected %s, got %s", i, want[i], info.Name)
+		}
+	}
+	if found, _ := set.Get("search_2"); found.Description() != "second" {
+		t.Errorf("search_2 should resolve to the second tool, got %s", found.Description())
+	}
+}
+
+func TestToolSet_LongNames(t *testing.T) {
+	set := NewToolSet()
+	long := strings.Repeat("a", maxToolNameLength)
+	set.Add(NewMockTool(long, "first"))
+
+	name := set.Add(NewMockTool(long, "second"))
+	if len(name) != maxToolNameLength || !strings.HasSuffix(name, "_2") {
+		t.Errorf("unexpected renamed tool: %s", name)
+	}
+}
+
+func TestToolSet_Execute(t *testing.T) {
+	ctx := context.Background()
+	set := NewToolSet()
+
+	tool := NewMockTool("echo", "Echo tool")
+	tool.execFn = func(ctx context.Context, args map[string]interface{}) (interface{}, error) {
+		return "echo: " + args["input"].(string), nil
+	}
+	set.Add(NewMockTool("echo", "Shadowed"))
+	set.Add(tool)
+
+	result, err := set.Execute(ctx, "echo_2", `{"input": "hi"}`)
+	if err != nil || result != "echo: hi" {
+		t.Errorf("unexpected result: %s %v", result, err)
+	}
+	if _, err := set.Execute(ctx, "missing", ""); err == nil {
+		t.Error("expected error for unknown tool")
+	}
+
+	// the global registry is not involved
+	if _, ok := GetRegistry().Get("echo"); ok {
+		t.Error("tool set must not register into the global registry")
+	}
+}
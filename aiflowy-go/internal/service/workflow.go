@@ -14,13 +14,15 @@ import (
 
 // WorkflowService 工作流服务
 type WorkflowService struct {
-	repo *repository.WorkflowRepository
+	repo     *repository.WorkflowRepository
+	execRepo *repository.WorkflowExecRepository
 }
 
 // NewWorkflowService 创建 WorkflowService
 func NewWorkflowService() *WorkflowService {
 	return &WorkflowService{
-		repo: repository.NewWorkflowRepository(),
+		repo:     repository.NewWorkflowRepository(),
+		execRepo: repository.NewWorkflowExecRepository(),
 	}
 }
 
@@ -53,8 +55,8 @@ func (s *WorkflowService) ListWorkflows(ctx context.Context, tenantID int64) ([]
 	return s.repo.ListWorkflows(ctx, tenantID)
 }
 
-// SaveWorkflow 保存工作流 (创建或更新)
-func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSaveRequest, tenantID, userID, deptID int64) (*entity.Workflow, error) {
+// SaveWorkflow 保存工作流 (创建或更新)，同时返回草稿的校验结果
+func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSaveRequest, tenantID, userID, deptID int64) (*entity.Workflow, *dto.WorkflowValidationResult, error) {
 	var workflow *entity.Workflow
 	var isNew bool
 
@@ -62,21 +64,21 @@ func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSav
 		// 更新
 		idInt, err := strconv.ParseInt(req.ID, 10, 64)
 		if err != nil {
-			return nil, apierrors.BadRequest("无效的工作流 ID")
+			return nil, nil, apierrors.BadRequest("无效的工作流 ID")
 		}
 		workflow, err = s.repo.GetWorkflowByID(ctx, idInt)
 		if err != nil {
-			return nil, apierrors.InternalError("获取工作流失败")
+			return nil, nil, apierrors.InternalError("获取工作流失败")
 		}
 		if workflow == nil {
-			return nil, apierrors.NotFound("工作流不存在")
+			return nil, nil, apierrors.NotFound("工作流不存在")
 		}
 
 		// 检查别名是否重复
 		if req.Alias != "" && req.Alias != workflow.Alias {
 			existing, _ := s.repo.GetWorkflowByAlias(ctx, req.Alias)
 			if existing != nil && existing.ID != workflow.ID {
-				return nil, apierrors.BadRequest("别名已存在")
+				return nil, nil, apierrors.BadRequest("别名已存在")
 			}
 		}
 
@@ -90,7 +92,7 @@ func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSav
 		if req.Alias != "" {
 			existing, _ := s.repo.GetWorkflowByAlias(ctx, req.Alias)
 			if existing != nil {
-				return nil, apierrors.BadRequest("别名已存在")
+				return nil, nil, apierrors.BadRequest("别名已存在")
 			}
 		}
 
@@ -103,6 +105,17 @@ func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSav
 		}
 	}
 
+	// 保存时完整校验：表达式与脚本语法错误拒绝保存，其余问题随结果返回，草稿允许暂不完整
+	var validation *dto.WorkflowValidationResult
+	if req.Content != "" {
+		validation = NewWorkflowDSLParser().CheckContent(req.Content)
+		for _, issue := range validation.Issues {
+			if issue.Code == dto.ValidationInvalidExpression || issue.Code == dto.ValidationInvalidScript {
+				return nil, nil, apierrors.BadRequest(issue.Message)
+			}
+		}
+	}
+
 	// 更新字段
 	workflow.Alias = req.Alias
 	workflow.Title = req.Title
@@ -119,15 +132,31 @@ func (s *WorkflowService) SaveWorkflow(ctx context.Context, req *dto.WorkflowSav
 
 	if isNew {
 		if err := s.repo.CreateWorkflow(ctx, workflow); err != nil {
-			return nil, apierrors.InternalError("创建工作流失败")
+			return nil, nil, apierrors.InternalError("创建工作流失败")
 		}
 	} else {
 		if err := s.repo.UpdateWorkflow(ctx, workflow); err != nil {
-			return nil, apierrors.InternalError("更新工作流失败")
+			return nil, nil, apierrors.InternalError("更新工作流失败")
 		}
 	}
 
-	return workflow, nil
+	return workflow, validation, nil
+}
+
+// ValidateWorkflow 校验工作流，content 为空时校验工作流的草稿
+func (s *WorkflowService) ValidateWorkflow(ctx context.Context, req *dto.WorkflowValidateRequest) (*dto.WorkflowValidationResult, error) {
+	content := req.Content
+	if content == "" {
+		if req.ID == "" {
+			return nil, apierrors.BadRequest("工作流 ID 与内容不能同时为空")
+		}
+		workflow, err := s.GetWorkflow(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		content = workflow.Content
+	}
+	return NewWorkflowDSLParser().CheckContent(content), nil
 }
 
 // DeleteWorkflow 删除工作流
@@ -204,9 +233,20 @@ func (s *WorkflowService) GetRunningParameters(ctx context.Context, id string) (
 		return nil, apierrors.NotFound("工作流不存在")
 	}
 
-	// 解析工作流 DSL 获取参数
+	// 解析工作流 DSL 获取参数：已发布的工作流以发布版本为准
+	content := workflow.Content
+	if workflow.PublishedVersionID != 0 {
+		version, err := s.repo.GetWorkflowVersionByID(ctx, workflow.PublishedVersionID)
+		if err != nil {
+			return nil, apierrors.InternalError("获取工作流版本失败")
+		}
+		if version != nil {
+			content = version.Content
+		}
+	}
+
 	parser := NewWorkflowDSLParser()
-	definition, err := parser.Parse(workflow.Content)
+	definition, err := parser.Parse(content)
 	if err != nil {
 		return nil, apierrors.BadRequest("工作流配置解析失败: " + err.Error())
 	}
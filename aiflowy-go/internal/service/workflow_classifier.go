@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/internal/service/llm"
+)
+
+// ========================== ClassifierNodeExecutor ==========================
+
+// ClassifierNodeExecutor 意图分类节点执行器
+//
+//	{"modelId": "1", "query": "${start.message}", "instruction": "按售后问题分类",
+//	 "classes": [{"name": "refund", "description": "退款、退货"}, {"name": "invoice", "description": "开具或修改发票"}],
+//	 "outputVariable": "class"}
+//
+// 模型从 classes 中选择一个类别，condition 与 outputVariable 均输出类别名，
+// 出边的 condition 或 sourcePort 为类别名时命中；无法归入任何类别时输出 default
+type ClassifierNodeExecutor struct {
+	llm *LLMNodeExecutor
+}
+
+// NewClassifierNodeExecutor 创建意图分类节点执行器
+func NewClassifierNodeExecutor() *ClassifierNodeExecutor {
+	return &ClassifierNodeExecutor{
+		llm: NewLLMNodeExecutor(),
+	}
+}
+
+// classifierClass 分类类别
+type classifierClass struct {
+	Name        string
+	Description string
+}
+
+// Execute 执行意图分类节点
+func (e *ClassifierNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("意图分类节点缺少配置")
+	}
+
+	modelID, err := llmModelID(node.Data, "意图分类节点")
+	if err != nil {
+		return nil, err
+	}
+	classes, err := parseClassifierClasses(node.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := state.SnapshotVariables()
+	query := strings.TrimSpace(templateValueString(resolveValue(node.Data["query"], variables)))
+	if query == "" {
+		return nil, fmt.Errorf("意图分类节点的输入内容为空")
+	}
+	instruction := resolveTemplateString(getStringFromMap(node.Data, "instruction"), variables)
+
+	req := &llm.ChatRequest{
+		ModelID: modelID,
+		Messages: []llm.Message{
+			{Role: "system", Content: classifierPrompt(classes, instruction)},
+			{Role: "user", Content: query},
+		},
+	}
+	result, err := e.llm.generateStructured(ctx, req, &llmOutputSchema{
+		Schema:     classifierSchema(classes),
+		Mode:       llm.OutputModeAuto,
+		MaxRetries: defaultStructuredRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outputVar := getStringFromMap(node.Data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "class"
+	}
+	return classifierOutputs(result, outputVar), nil
+}
+
+// parseClassifierClasses 解析类别配置，类别名称不能为空、重复或使用保留的 default
+func parseClassifierClasses(data map[string]interface{}) ([]*classifierClass, error) {
+	items, _ := data["classes"].([]interface{})
+	classes := make([]*classifierClass, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		class := &classifierClass{
+			Name:        strings.TrimSpace(getStringFromMap(m, "name")),
+			Description: strings.TrimSpace(getStringFromMap(m, "description")),
+		}
+		switch {
+		case class.Name == "":
+			return nil, fmt.Errorf("类别名称不能为空")
+		case class.Name == dto.EdgePortDefault:
+			return nil, fmt.Errorf("类别名称不能为 %s", dto.EdgePortDefault)
+		case seen[class.Name]:
+			return nil, fmt.Errorf("类别名称重复: %s", class.Name)
+		}
+		seen[class.Name] = true
+		classes = append(classes, class)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("意图分类节点未配置类别")
+	}
+	return classes, nil
+}
+
+// classifierSchema 分类结果的输出结构：class 只能是类别名或 default
+func classifierSchema(classes []*classifierClass) map[string]interface{} {
+	names := make([]interface{}, 0, len(classes)+1)
+	for _, class := range classes {
+		names = append(names, class.Name)
+	}
+	names = append(names, dto.EdgePortDefault)
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"class"},
+		"properties": map[string]interface{}{
+			"class": map[string]interface{}{
+				"type":        "string",
+				"description": "用户输入所属的类别",
+				"enum":        names,
+			},
+			"reason": map[string]interface{}{
+				"type":        "string",
+				"description": "简要说明分类依据",
+			},
+		},
+	}
+}
+
+// classifierPrompt 分类的系统提示词
+func classifierPrompt(classes []*classifierClass, instruction string) string {
+	var b strings.Builder
+	b.WriteString("你是一个意图分类器，请判断用户输入属于以下哪个类别：\n")
+	for _, class := range classes {
+		if class.Description != "" {
+			fmt.Fprintf(&b, "- %s: %s\n", class.Name, class.Description)
+		} else {
+			fmt.Fprintf(&b, "- %s\n", class.Name)
+		}
+	}
+	fmt.Fprintf(&b, "如果都不符合，选择 %s。", dto.EdgePortDefault)
+	if instruction = strings.TrimSpace(instruction); instruction != "" {
+		b.WriteString("\n\n")
+		b.WriteString(instruction)
+	}
+	return b.String()
+}
+
+// classifierOutputs 节点输出：condition 供分支选择使用
+func classifierOutputs(result map[string]interface{}, outputVar string) map[string]interface{} {
+	class, _ := result["class"].(string)
+	if class == "" {
+		class = dto.EdgePortDefault
+	}
+	reason, _ := result["reason"].(string)
+	return map[string]interface{}{
+		"condition": class,
+		outputVar:   class,
+		"reason":    reason,
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestParseClassifierClasses(t *testing.T) {
+	class := func(name string) interface{} {
+		return map[string]interface{}{"name": name, "description": name + " requests"}
+	}
+
+	classes, err := parseClassifierClasses(map[string]interface{}{
+		"classes": []interface{}{class("refund"), class(" invoice ")},
+	})
+	if err != nil || len(classes) != 2 || classes[1].Name != "invoice" {
+		t.Fatalf("unexpected classes: %v %v", classes, err)
+	}
+
+	invalid := [][]interface{}{
+		nil,
+		{class("")},
+		{class("refund"), class("refund")},
+		{class(dto.EdgePortDefault)},
+	}
+	for _, items := range invalid {
+		if _, err := parseClassifierClasses(map[string]interface{}{"classes": items}); err == nil {
+			t.Errorf("expected error for classes %v", items)
+		}
+	}
+}
+
+func TestClassifierSchema(t *testing.T) {
+	classes := []*classifierClass{{Name: "refund"}, {Name: "invoice", Description: "invoice requests"}}
+	s := classifierSchema(classes)
+
+	if errs := validateJSONSchema(map[string]interface{}{"class": "invoice"}, s, "$"); len(errs) > 0 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if errs := validateJSONSchema(map[string]interface{}{"class": dto.EdgePortDefault}, s, "$"); len(errs) > 0 {
+		t.Errorf("default must be accepted: %v", errs)
+	}
+	if errs := validateJSONSchema(map[string]interface{}{"class": "shipping"}, s, "$"); len(errs) == 0 {
+		t.Error("expected unknown class to be rejected")
+	}
+
+	prompt := classifierPrompt(classes, "  prefer refund  ")
+	if !strings.Contains(prompt, "- invoice: invoice requests") || !strings.HasSuffix(prompt, "prefer refund") {
+		t.Errorf("unexpected prompt: %s", prompt)
+	}
+}
+
+func TestClassifierNode_Routing(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "intent", Type: dto.NodeTypeClassifier},
+			{ID: "refund", Type: dto.NodeTypeCode},
+			{ID: "invoice", Type: dto.NodeTypeCode},
+			{ID: "fallback", Type: dto.NodeTypeCode},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "intent", Target: "refund", SourcePort: "refund"},
+			{Source: "intent", Target: "invoice", Condition: "invoice"},
+			{Source: "intent", Target: "fallback", SourcePort: dto.EdgePortDefault},
+		},
+	}
+	tests := []struct {
+		class  string
+		target string
+	}{
+		{"refund", "refund"},
+		{"invoice", "invoice"},
+		{"", "fallback"},
+	}
+	for _, tt := range tests {
+		state := &repository.ChainState{Status: entity.ExecStatusRunning}
+		state.SetNodeState(&repository.NodeState{
+			NodeID: "intent",
+			Status: entity.ExecStatusCompleted,
+			Output: classifierOutputs(map[string]interface{}{"class": tt.class}, "class"),
+		})
+		executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+		run := executor.newChainRun(context.Background(), state, definition)
+
+		for _, node := range definition.Nodes[1:] {
+			want := joinSkip
+			if node.ID == tt.target {
+				want = joinFire
+			}
+			if got := run.joinDecision(node); got != want {
+				t.Errorf("class %q: expected %s to get decision %d, got %d", tt.class, node.ID, want, got)
+			}
+		}
+	}
+}
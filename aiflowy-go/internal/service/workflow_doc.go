@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/internal/service/rag"
+)
+
+// 文档节点读取的文件大小上限
+const maxDocFileSize = 50 << 20
+
+// ========================== DocNodeExecutor ==========================
+
+// DocNodeExecutor 文档解析节点执行器
+//
+//	{"file": "${contract}", "fileType": "pdf", "outputVariable": "content",
+//	 "split": true, "splitter": "SimpleDocumentSplitter", "chunkSize": 500, "overlapSize": 50}
+//
+// file 为上传接口返回的相对路径 (相对 Storage.LocalRoot)，也可以引用包含 path 字段的上传对象；
+// fileType 为空时按扩展名识别
+type DocNodeExecutor struct{}
+
+// NewDocNodeExecutor 创建文档解析节点执行器
+func NewDocNodeExecutor() *DocNodeExecutor {
+	return &DocNodeExecutor{}
+}
+
+// Execute 执行文档解析节点
+func (e *DocNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("文档节点缺少配置")
+	}
+
+	variables := state.SnapshotVariables()
+	relativePath, err := resolveDocPath(node.Data["file"], variables)
+	if err != nil {
+		return nil, err
+	}
+
+	fullPath, err := docFullPath(relativePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("文件不存在: %s", relativePath)
+		}
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("不是文件: %s", relativePath)
+	}
+	if info.Size() > maxDocFileSize {
+		return nil, fmt.Errorf("文件过大: %d 字节，上限 %d 字节", info.Size(), maxDocFileSize)
+	}
+
+	fileType := getStringFromMap(node.Data, "fileType")
+	if fileType == "" {
+		fileType = getFileExtension(fullPath)
+	}
+	fileType = strings.ToLower(fileType)
+	if !IsSupportedDocType(fileType) {
+		return nil, fmt.Errorf("不支持的文档类型: %s", fileType)
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	doc, err := ReadDocumentText(fileType, data)
+	if err != nil {
+		return nil, err
+	}
+
+	outputVar := getStringFromMap(node.Data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "content"
+	}
+	result := map[string]interface{}{
+		outputVar:  doc.Content,
+		"fileName": filepath.Base(relativePath),
+		"fileType": fileType,
+		"length":   len([]rune(doc.Content)),
+	}
+	if doc.Data != nil {
+		result["data"] = doc.Data
+	}
+
+	// 可选分块
+	splitterName := getStringFromMap(node.Data, "splitter")
+	if getBoolFromMap(node.Data, "split") || splitterName != "" {
+		splitter := rag.GetDocumentSplitter(
+			splitterName,
+			getIntFromMap(node.Data, "chunkSize"),
+			getIntFromMap(node.Data, "overlapSize"),
+			getStringFromMap(node.Data, "regex"),
+		)
+		chunks := splitter.Split(doc.Content)
+		if chunks == nil {
+			chunks = []string{}
+		}
+		result["chunks"] = chunks
+		result["chunkCount"] = len(chunks)
+	}
+
+	return result, nil
+}
+
+// resolveDocPath 解析文件参数：支持 ${var} 引用、路径字符串以及包含 path / url 字段的上传对象
+func resolveDocPath(raw interface{}, variables map[string]interface{}) (string, error) {
+	value := raw
+	if s, ok := raw.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}") {
+			path := strings.TrimSuffix(strings.TrimPrefix(s, "${"), "}")
+			resolved, found := lookupPath(variables, path)
+			if !found {
+				return "", fmt.Errorf("文件变量不存在: %s", path)
+			}
+			value = resolved
+		} else {
+			value = resolveTemplateString(s, variables)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return "", fmt.Errorf("文档节点未指定文件")
+		}
+		return strings.TrimSpace(v), nil
+	case map[string]interface{}:
+		for _, key := range []string{"path", "url", "filePath"} {
+			if p := getStringFromMap(v, key); p != "" {
+				return p, nil
+			}
+		}
+		return "", fmt.Errorf("上传对象缺少 path 字段")
+	case []interface{}:
+		if len(v) == 1 {
+			return resolveDocPath(v[0], variables)
+		}
+		return "", fmt.Errorf("文档节点一次只能读取一个文件，实际为 %d 个", len(v))
+	case nil:
+		return "", fmt.Errorf("文档节点未指定文件")
+	default:
+		return "", fmt.Errorf("文件参数类型错误: %s", valueTypeName(v))
+	}
+}
+
+// docFullPath 将相对路径转换为存储根目录下的绝对路径，禁止访问根目录之外的文件
+func docFullPath(relativePath string) (string, error) {
+	rootPath := "./uploads"
+	if cfg := config.GetConfig(); cfg != nil && cfg.Storage.LocalRoot != "" {
+		rootPath = cfg.Storage.LocalRoot
+	}
+	root, err := filepath.Abs(rootPath)
+	if err != nil {
+		return "", fmt.Errorf("存储目录无效: %w", err)
+	}
+
+	// 兼容带有 /uploads/ 前缀的访问路径
+	cleaned := filepath.Clean("/" + strings.TrimPrefix(relativePath, "/uploads/"))
+	fullPath := filepath.Join(root, cleaned)
+	if rel, err := filepath.Rel(root, fullPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("文件路径无效: %s", relativePath)
+	}
+	return fullPath, nil
+}
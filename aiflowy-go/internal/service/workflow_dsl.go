@@ -2,6 +2,7 @@ package service
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/aiflowy/aiflowy-go/internal/dto"
@@ -139,6 +140,27 @@ func (p *WorkflowDSLParser) GetPreviousNodes(definition *dto.WorkflowDefinition,
 	return prevNodes
 }
 
+// SubGraph 获取指定作用域内的节点与边：parentID 为空时为顶层流程，否则为循环节点的循环体
+func (p *WorkflowDSLParser) SubGraph(definition *dto.WorkflowDefinition, parentID string) *dto.WorkflowDefinition {
+	graph := *definition
+	graph.Nodes = nil
+	graph.Edges = nil
+
+	inScope := make(map[string]bool)
+	for _, node := range definition.Nodes {
+		if node.ParentID == parentID {
+			graph.Nodes = append(graph.Nodes, node)
+			inScope[node.ID] = true
+		}
+	}
+	for _, edge := range definition.Edges {
+		if inScope[edge.Source] && inScope[edge.Target] {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	return &graph
+}
+
 // GetStartNode 获取开始节点
 func (p *WorkflowDSLParser) GetStartNode(definition *dto.WorkflowDefinition) *dto.WorkflowNode {
 	if definition == nil {
@@ -170,50 +192,13 @@ func (p *WorkflowDSLParser) GetEndNodes(definition *dto.WorkflowDefinition) []*d
 	return endNodes
 }
 
-// Validate 验证工作流定义
+// Validate 验证工作流定义，返回第一个 error 级别的问题 (完整的问题列表见 Check)
 func (p *WorkflowDSLParser) Validate(definition *dto.WorkflowDefinition) error {
-	if definition == nil {
-		return fmt.Errorf("工作流定义为空")
-	}
-
-	if len(definition.Nodes) == 0 {
-		return fmt.Errorf("工作流没有节点")
-	}
-
-	// 检查开始节点
-	startNode := p.GetStartNode(definition)
-	if startNode == nil {
-		return fmt.Errorf("工作流没有开始节点")
-	}
-
-	// 检查结束节点
-	endNodes := p.GetEndNodes(definition)
-	if len(endNodes) == 0 {
-		return fmt.Errorf("工作流没有结束节点")
-	}
-
-	// 检查节点 ID 唯一性
-	nodeIDs := make(map[string]bool)
-	for _, node := range definition.Nodes {
-		if node.ID == "" {
-			return fmt.Errorf("节点 ID 不能为空")
-		}
-		if nodeIDs[node.ID] {
-			return fmt.Errorf("节点 ID 重复: %s", node.ID)
+	for _, issue := range p.Check(definition).Issues {
+		if issue.Level == dto.ValidationLevelError {
+			return errors.New(issue.Message)
 		}
-		nodeIDs[node.ID] = true
 	}
-
-	// 检查边的有效性
-	for _, edge := range definition.Edges {
-		if !nodeIDs[edge.Source] {
-			return fmt.Errorf("边的源节点不存在: %s", edge.Source)
-		}
-		if !nodeIDs[edge.Target] {
-			return fmt.Errorf("边的目标节点不存在: %s", edge.Target)
-		}
-	}
-
 	return nil
 }
 
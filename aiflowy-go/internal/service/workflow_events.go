@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+)
+
+// 单个订阅者的事件缓冲，消费过慢时断开订阅，客户端可重新附加
+const workflowEventBuffer = 512
+
+// workflowEventHub 按执行 ID 分发执行事件，没有订阅者时不构建事件
+type workflowEventHub struct {
+	mu      sync.Mutex
+	streams map[string]*workflowEventStream
+}
+
+// workflowEventStream 单个执行的订阅者
+type workflowEventStream struct {
+	builder *protocol.Builder
+	subs    map[*WorkflowSubscription]struct{}
+}
+
+// WorkflowSubscription 执行事件订阅
+// 执行结束 (完成、失败、取消或暂停等待输入) 后推送 system.done 并关闭事件通道
+type WorkflowSubscription struct {
+	ExecuteID string
+
+	hub    *workflowEventHub
+	events chan *protocol.Envelope
+	closed bool // 由 hub.mu 保护
+	lagged bool
+}
+
+func newWorkflowEventHub() *workflowEventHub {
+	return &workflowEventHub{streams: make(map[string]*workflowEventStream)}
+}
+
+// Events 事件通道，执行结束或订阅断开时关闭
+func (s *WorkflowSubscription) Events() <-chan *protocol.Envelope {
+	return s.events
+}
+
+// Lagged 订阅是否因消费过慢被断开 (事件通道关闭后调用)
+func (s *WorkflowSubscription) Lagged() bool {
+	return s.lagged
+}
+
+// Close 取消订阅，不影响执行本身
+func (s *WorkflowSubscription) Close() {
+	if s.hub == nil {
+		return
+	}
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+// subscribe 订阅执行事件
+// replay 在持有锁时调用，生成当前状态的事件，保证与之后推送的实时事件不会缺失；done 为 true 时执行已结束，不再登记订阅
+func (h *workflowEventHub) subscribe(executeID string, replay func(b *protocol.Builder) (events []*protocol.Envelope, done bool)) *WorkflowSubscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream, ok := h.streams[executeID]
+	builder := protocol.NewBuilder(executeID, "")
+	if ok {
+		builder = stream.builder
+	}
+
+	events, done := replay(builder)
+	size := workflowEventBuffer
+	if len(events) >= size {
+		size = len(events) + workflowEventBuffer
+	}
+	sub := &WorkflowSubscription{
+		ExecuteID: executeID,
+		events:    make(chan *protocol.Envelope, size),
+	}
+	for _, env := range events {
+		sub.events <- env
+	}
+	if done {
+		sub.closed = true
+		close(sub.events)
+		return sub
+	}
+
+	if !ok {
+		stream = &workflowEventStream{builder: builder, subs: make(map[*WorkflowSubscription]struct{})}
+		h.streams[executeID] = stream
+	}
+	sub.hub = h
+	stream.subs[sub] = struct{}{}
+	return sub
+}
+
+// active 执行是否有订阅者
+func (h *workflowEventHub) active(executeID string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.streams[executeID]
+	return ok
+}
+
+// publish 向执行的所有订阅者推送事件，订阅者缓冲已满时断开该订阅
+func (h *workflowEventHub) publish(executeID string, build func(b *protocol.Builder) []*protocol.Envelope) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stream, ok := h.streams[executeID]
+	if !ok {
+		return
+	}
+	for _, env := range build(stream.builder) {
+		for sub := range stream.subs {
+			select {
+			case sub.events <- env:
+			default:
+				sub.lagged = true
+				h.remove(sub)
+			}
+		}
+	}
+}
+
+// finish 推送最后的事件并关闭所有订阅
+func (h *workflowEventHub) finish(executeID string, build func(b *protocol.Builder) []*protocol.Envelope) {
+	h.publish(executeID, build)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if stream, ok := h.streams[executeID]; ok {
+		for sub := range stream.subs {
+			h.remove(sub)
+		}
+	}
+}
+
+// remove 移除订阅并关闭事件通道 (调用方需持有 h.mu)
+func (h *workflowEventHub) remove(sub *WorkflowSubscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.events)
+
+	stream, ok := h.streams[sub.ExecuteID]
+	if !ok {
+		return
+	}
+	delete(stream.subs, sub)
+	if len(stream.subs) == 0 {
+		delete(h.streams, sub.ExecuteID)
+	}
+}
+
+// ========================== 执行事件 ==========================
+
+// Subscribe 附加到租户已有的执行：先推送当前状态，再推送之后的实时事件
+func (e *ChainExecutor) Subscribe(ctx context.Context, executeID string, tenantID int64) (*WorkflowSubscription, error) {
+	state, err := e.loadTenantState(ctx, executeID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, apierrors.NotFound("执行记录不存在")
+	}
+	return e.subscribeState(state), nil
+}
+
+// subscribeState 订阅执行事件并回放当前状态
+func (e *ChainExecutor) subscribeState(state *repository.ChainState) *WorkflowSubscription {
+	return e.events.subscribe(state.ExecuteID, func(b *protocol.Builder) ([]*protocol.Envelope, bool) {
+		return replayEvents(b, state.Snapshot())
+	})
+}
+
+// replayEvents 根据执行状态快照生成事件：执行开始、各节点当前状态，执行已结束时附加结束事件
+func replayEvents(b *protocol.Builder, snapshot *repository.ChainState) ([]*protocol.Envelope, bool) {
+	events := []*protocol.Envelope{b.WorkflowStatus("", protocol.WorkflowStateStart, "")}
+
+	nodes := make([]*repository.NodeState, 0, len(snapshot.NodeStates))
+	for _, ns := range snapshot.NodeStates {
+		nodes = append(nodes, ns)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].StartTime.Equal(nodes[j].StartTime) {
+			return nodes[i].NodeID < nodes[j].NodeID
+		}
+		return nodes[i].StartTime.Before(nodes[j].StartTime)
+	})
+
+	for _, ns := range nodes {
+		reason := ""
+		if ns.Error != nil {
+			reason = ns.Error.Error()
+		}
+		var output interface{}
+		if ns.Status == entity.ExecStatusCompleted || ns.Status == entity.ExecStatusFailed {
+			if ns.Output != nil {
+				output = ns.Output
+			}
+		}
+		events = append(events, b.WorkflowNodeStatus(ns.NodeID, ns.NodeName, nodeEventState(ns.Status), reason, output))
+	}
+
+	if snapshot.Status == entity.ExecStatusRunning {
+		return events, false
+	}
+	if snapshot.Status == entity.ExecStatusSuspended {
+		if ns, ok := snapshot.NodeStates[snapshot.SuspendedNodeID]; ok {
+			events = append(events, formRequestEvent(b, snapshot.SuspendedNodeID, ns.NodeName, snapshot.SuspendedParams))
+		}
+	}
+	return append(events, doneEvents(b, snapshot)...), true
+}
+
+// nodeEventState 节点执行状态对应的事件状态
+func nodeEventState(status entity.WorkflowExecStatus) string {
+	switch status {
+	case entity.ExecStatusCompleted:
+		return protocol.WorkflowStateEnd
+	case entity.ExecStatusFailed:
+		return protocol.WorkflowStateError
+	case entity.ExecStatusSuspended:
+		return protocol.WorkflowStateSuspend
+	case entity.ExecStatusSkipped:
+		return protocol.WorkflowStateSkip
+	case entity.ExecStatusCancelled:
+		return protocol.WorkflowStateCancel
+	default:
+		return protocol.WorkflowStateStart
+	}
+}
+
+// formRequestEvent 人工确认节点等待输入的表单请求，表单 ID 为节点 ID
+func formRequestEvent(b *protocol.Builder, nodeID, nodeName string, params []*repository.SuspendedParam) *protocol.Envelope {
+	properties := make(map[string]interface{}, len(params))
+	required := make([]string, 0, len(params))
+	for _, param := range params {
+		property := map[string]interface{}{
+			"type":  formFieldType(param.Type),
+			"title": param.Name,
+		}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		if param.DefaultValue != nil {
+			property["default"] = param.DefaultValue
+		}
+		properties[param.Name] = property
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+	return b.FormRequest(nodeID, nodeName, "", schema)
+}
+
+// formFieldType 参数类型转换为 JSON Schema 类型
+func formFieldType(paramType string) string {
+	switch kind := normalizeParamType(paramType); kind {
+	case "", "file":
+		return "string"
+	default:
+		return kind
+	}
+}
+
+// doneEvents 执行结束事件：整体状态与 system.done
+func doneEvents(b *protocol.Builder, snapshot *repository.ChainState) []*protocol.Envelope {
+	reason := ""
+	if snapshot.Error != nil {
+		reason = snapshot.Error.Error()
+	}
+
+	var status *protocol.Envelope
+	switch snapshot.Status {
+	case entity.ExecStatusCompleted:
+		status = b.WorkflowNodeStatus("", "", protocol.WorkflowStateEnd, "", snapshot.Result)
+	case entity.ExecStatusSuspended:
+		status = b.WorkflowNodeStatus(snapshot.SuspendedNodeID, "", protocol.WorkflowStateSuspend, "interaction", nil)
+	case entity.ExecStatusCancelled:
+		status = b.WorkflowStatus("", protocol.WorkflowStateCancel, reason)
+	default:
+		status = b.WorkflowStatus("", protocol.WorkflowStateError, reason)
+	}
+	return []*protocol.Envelope{status, b.SystemDone(nil)}
+}
+
+// publishNodeStatus 推送节点状态事件
+func (e *ChainExecutor) publishNodeStatus(state *repository.ChainState, nodeID, eventState, reason string, output map[string]interface{}) {
+	if !e.events.active(state.ExecuteID) {
+		return
+	}
+	nodeName := ""
+	if ns, ok := state.GetNodeState(nodeID); ok {
+		nodeName = ns.NodeName
+	}
+	var payloadOutput interface{}
+	if output != nil {
+		payloadOutput = output
+	}
+	e.events.publish(state.ExecuteID, func(b *protocol.Builder) []*protocol.Envelope {
+		return []*protocol.Envelope{b.WorkflowNodeStatus(nodeID, nodeName, eventState, reason, payloadOutput)}
+	})
+}
+
+// publishSuspend 推送节点暂停与表单请求事件
+func (e *ChainExecutor) publishSuspend(state *repository.ChainState, nodeID string, params []*repository.SuspendedParam) {
+	if !e.events.active(state.ExecuteID) {
+		return
+	}
+	nodeName := ""
+	if ns, ok := state.GetNodeState(nodeID); ok {
+		nodeName = ns.NodeName
+	}
+	e.events.publish(state.ExecuteID, func(b *protocol.Builder) []*protocol.Envelope {
+		return []*protocol.Envelope{
+			b.WorkflowNodeStatus(nodeID, nodeName, protocol.WorkflowStateSuspend, "interaction", nil),
+			formRequestEvent(b, nodeID, nodeName, params),
+		}
+	})
+}
+
+// publishDone 执行调度结束，推送结束事件并关闭订阅
+func (e *ChainExecutor) publishDone(state *repository.ChainState) {
+	if state.Scoped || !e.events.active(state.ExecuteID) {
+		return
+	}
+	snapshot := state.Snapshot()
+	e.events.finish(state.ExecuteID, func(b *protocol.Builder) []*protocol.Envelope {
+		return doneEvents(b, snapshot)
+	})
+}
+
+type nodeEventKey struct{}
+
+// nodeEventTarget 节点执行期间推送增量事件的目标
+type nodeEventTarget struct {
+	hub       *workflowEventHub
+	executeID string
+	nodeID    string
+}
+
+// withNodeEvents 在 context 中携带节点事件目标，供 LLM 等节点推送增量输出
+func (e *ChainExecutor) withNodeEvents(ctx context.Context, state *repository.ChainState, nodeID string) context.Context {
+	return context.WithValue(ctx, nodeEventKey{}, &nodeEventTarget{hub: e.events, executeID: state.ExecuteID, nodeID: nodeID})
+}
+
+// llmDeltaEmitter 返回推送 LLM 增量输出的函数，执行没有订阅者时返回 nil
+// 增量事件的 message_id 为节点 ID
+func llmDeltaEmitter(ctx context.Context) func(delta string) {
+	target, ok := ctx.Value(nodeEventKey{}).(*nodeEventTarget)
+	if !ok || !target.hub.active(target.executeID) {
+		return nil
+	}
+	return func(delta string) {
+		target.hub.publish(target.executeID, func(b *protocol.Builder) []*protocol.Envelope {
+			env := b.LLMMessageDelta(delta)
+			env.MessageID = target.nodeID
+			return []*protocol.Envelope{env}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+)
+
+func collectEvents(sub *WorkflowSubscription) []*protocol.Envelope {
+	var events []*protocol.Envelope
+	for env := range sub.Events() {
+		events = append(events, env)
+	}
+	return events
+}
+
+func TestWorkflowEvents_RunningExecution(t *testing.T) {
+	executor := &ChainExecutor{events: newWorkflowEventHub()}
+	state := &repository.ChainState{
+		ExecuteID:  "exec-1",
+		Status:     entity.ExecStatusRunning,
+		Variables:  make(map[string]interface{}),
+		NodeStates: make(map[string]*repository.NodeState),
+	}
+	state.SetNodeState(&repository.NodeState{NodeID: "llm", NodeName: "LLM", Status: entity.ExecStatusRunning})
+
+	sub := executor.subscribeState(state)
+	emit := llmDeltaEmitter(executor.withNodeEvents(t.Context(), state, "llm"))
+	if emit == nil {
+		t.Fatal("expected delta emitter while subscribed")
+	}
+	emit("Hel")
+	executor.publishNodeStatus(state, "llm", protocol.WorkflowStateEnd, "", map[string]interface{}{"llmOutput": "Hello"})
+	state.Complete(map[string]interface{}{"answer": "Hello"})
+	executor.publishDone(state)
+
+	events := collectEvents(sub)
+	var kinds []string
+	for _, env := range events {
+		kind := env.Domain + "." + env.Type
+		if payload, ok := env.Payload.(*protocol.WorkflowStatusPayload); ok {
+			kind += ":" + payload.NodeID + ":" + payload.State
+		}
+		kinds = append(kinds, kind)
+	}
+	expect := []string{
+		"workflow.status::start",
+		"workflow.status:llm:start",
+		"llm.message",
+		"workflow.status:llm:end",
+		"workflow.status::end",
+		"system.done",
+	}
+	if len(kinds) != len(expect) {
+		t.Fatalf("unexpected events: %v", kinds)
+	}
+	for i := range expect {
+		if kinds[i] != expect[i] {
+			t.Errorf("event %d: expected %s, got %s", i, expect[i], kinds[i])
+		}
+	}
+	if events[2].MessageID != "llm" {
+		t.Errorf("expected delta message id to be the node id, got %q", events[2].MessageID)
+	}
+	if executor.events.active("exec-1") {
+		t.Error("expected stream to be removed after done")
+	}
+	if llmDeltaEmitter(executor.withNodeEvents(t.Context(), state, "llm")) != nil {
+		t.Error("expected no delta emitter without subscribers")
+	}
+}
+
+func TestWorkflowEvents_ReplaySuspended(t *testing.T) {
+	executor := &ChainExecutor{events: newWorkflowEventHub()}
+	state := &repository.ChainState{
+		ExecuteID:  "exec-2",
+		Status:     entity.ExecStatusRunning,
+		Variables:  make(map[string]interface{}),
+		NodeStates: make(map[string]*repository.NodeState),
+	}
+	state.SetNodeState(&repository.NodeState{NodeID: "confirm", NodeName: "审批", Status: entity.ExecStatusSuspended})
+	state.Suspend("confirm", []*repository.SuspendedParam{{Name: "approved", Type: "bool", Required: true}})
+
+	events := collectEvents(executor.subscribeState(state))
+	if len(events) != 5 {
+		t.Fatalf("expected 5 replayed events, got %d", len(events))
+	}
+	form, ok := events[2].Payload.(*protocol.FormRequestPayload)
+	if !ok {
+		t.Fatalf("expected form request, got %s.%s", events[2].Domain, events[2].Type)
+	}
+	properties := form.Schema["properties"].(map[string]interface{})
+	if properties["approved"].(map[string]interface{})["type"] != "boolean" {
+		t.Errorf("unexpected form schema: %v", form.Schema)
+	}
+	if events[4].Domain != protocol.DomainSystem || events[4].Type != protocol.TypeDone {
+		t.Errorf("expected system.done last, got %s.%s", events[4].Domain, events[4].Type)
+	}
+	if executor.events.active("exec-2") {
+		t.Error("finished execution should not keep a subscription")
+	}
+}
+
+func TestWorkflowEvents_LaggedSubscriber(t *testing.T) {
+	hub := newWorkflowEventHub()
+	sub := hub.subscribe("exec-3", func(b *protocol.Builder) ([]*protocol.Envelope, bool) {
+		return nil, false
+	})
+	for i := 0; i <= workflowEventBuffer; i++ {
+		hub.publish("exec-3", func(b *protocol.Builder) []*protocol.Envelope {
+			return []*protocol.Envelope{b.SystemError("X", "x", false)}
+		})
+	}
+
+	if n := len(collectEvents(sub)); n != workflowEventBuffer {
+		t.Errorf("expected %d buffered events, got %d", workflowEventBuffer, n)
+	}
+	if !sub.Lagged() {
+		t.Error("expected subscriber to be dropped as lagged")
+	}
+	sub.Close()
+}
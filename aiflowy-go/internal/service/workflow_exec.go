@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+)
+
+// 执行记录查询支持的时间格式，只有日期时结束时间包含当天
+var execTimeLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ========================== 执行记录 ==========================
+
+// PageExecResults 分页查询执行记录，按执行开始时间倒序
+func (s *WorkflowService) PageExecResults(ctx context.Context, req *dto.WorkflowExecListRequest, tenantID int64) ([]*entity.WorkflowExecResult, int64, error) {
+	from, _, err := parseExecTime(req.StartTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	to, dateOnly, err := parseExecTime(req.EndTime)
+	if err != nil {
+		return nil, 0, err
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	} else if !to.IsZero() {
+		to = to.Add(time.Second)
+	}
+
+	results, total, err := s.execRepo.PageExecResults(ctx, req, tenantID, from, to)
+	if err != nil {
+		return nil, 0, apierrors.InternalError("获取执行记录失败")
+	}
+	return results, total, nil
+}
+
+// GetExecDetail 获取执行记录详情与步骤时间线，其他租户的执行视为不存在
+func (s *WorkflowService) GetExecDetail(ctx context.Context, executeID string, tenantID int64) (*dto.WorkflowExecDetail, error) {
+	execResult, err := s.execRepo.GetTenantExecResultByExecKey(ctx, executeID, tenantID)
+	if err != nil {
+		return nil, apierrors.InternalError("获取执行记录失败")
+	}
+	if execResult == nil {
+		return nil, apierrors.NotFound("执行记录不存在")
+	}
+
+	steps, err := s.execRepo.GetExecStepsByExecKey(ctx, executeID)
+	if err != nil {
+		return nil, apierrors.InternalError("获取执行步骤失败")
+	}
+	children, err := s.execRepo.ListChildExecKeys(ctx, executeID)
+	if err != nil {
+		return nil, apierrors.InternalError("获取子工作流执行记录失败")
+	}
+
+	// 节点类型取自执行时的工作流快照，快照无法解析时不影响时间线
+	parser := NewWorkflowDSLParser()
+	definition, _ := parser.Parse(execResult.WorkflowJSON)
+	return buildExecDetail(parser, definition, execResult, steps, children), nil
+}
+
+// ListExecSteps 获取执行的步骤时间线
+func (s *WorkflowService) ListExecSteps(ctx context.Context, executeID string, tenantID int64) ([]*dto.WorkflowExecStepInfo, error) {
+	detail, err := s.GetExecDetail(ctx, executeID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return detail.Steps, nil
+}
+
+// buildExecDetail 组装执行详情，子工作流的执行记录不能单独重新执行
+func buildExecDetail(parser *WorkflowDSLParser, definition *dto.WorkflowDefinition, execResult *entity.WorkflowExecResult, steps []*entity.WorkflowExecStep, children map[int64]string) *dto.WorkflowExecDetail {
+	detail := &dto.WorkflowExecDetail{
+		ID:              execResult.ID,
+		ExecKey:         execResult.ExecKey,
+		WorkflowID:      execResult.WorkflowID,
+		Title:           execResult.Title,
+		WorkflowVersion: execResult.WorkflowVersion,
+		Status:          int(execResult.Status),
+		Input:           decodeExecJSON(execResult.Input),
+		Output:          decodeExecJSON(execResult.Output),
+		ErrorInfo:       execResult.ErrorInfo,
+		StartTime:       execResult.StartTime,
+		EndTime:         execResult.EndTime,
+		Duration:        execDuration(execResult.StartTime, execResult.EndTime),
+		Tokens:          execResult.Tokens,
+		CreatedKey:      execResult.CreatedKey,
+		CreatedBy:       execResult.CreatedBy,
+		ParentExecKey:   execResult.ParentExecKey,
+		Steps:           make([]*dto.WorkflowExecStepInfo, 0, len(steps)),
+	}
+
+	for _, step := range steps {
+		info := &dto.WorkflowExecStepInfo{
+			ID:           step.ID,
+			NodeID:       step.NodeID,
+			NodeName:     step.NodeName,
+			Attempt:      step.Attempt,
+			Status:       int(step.Status),
+			Input:        decodeExecJSON(step.Input),
+			Output:       decodeExecJSON(step.Output),
+			ErrorInfo:    step.ErrorInfo,
+			Logs:         step.Logs,
+			StartTime:    step.StartTime,
+			EndTime:      step.EndTime,
+			Duration:     execDuration(step.StartTime, step.EndTime),
+			ChildExecKey: children[step.ID],
+		}
+		if definition != nil {
+			node := parser.GetNodeByID(definition, loopBaseID(step.NodeID))
+			if node != nil {
+				info.NodeType = node.Type
+			}
+			info.Rerunnable = execResult.ParentExecKey == "" && rerunnableNode(node, step.NodeID)
+		}
+		detail.Steps = append(detail.Steps, info)
+	}
+	return detail
+}
+
+// parseExecTime 解析查询时间，返回值是否只有日期；为空时返回零值
+func parseExecTime(value string) (time.Time, bool, error) {
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	for _, layout := range execTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, layout == "2006-01-02", nil
+		}
+	}
+	return time.Time{}, false, apierrors.BadRequest("无效的时间: " + value)
+}
+
+// decodeExecJSON 解析记录中的 JSON，无效时原样返回字符串
+func decodeExecJSON(data string) interface{} {
+	if data == "" {
+		return nil
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		return data
+	}
+	return value
+}
+
+// execDuration 耗时 (毫秒)，未结束时为 0
+func execDuration(start time.Time, end *time.Time) int64 {
+	if end == nil {
+		return 0
+	}
+	return end.Sub(start).Milliseconds()
+}
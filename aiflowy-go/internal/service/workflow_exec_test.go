@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestParseExecTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		dateOnly bool
+		ok       bool
+	}{
+		{"", false, true},
+		{"2026-05-01", true, true},
+		{"2026-05-01 08:30:00", false, true},
+		{"2026-05-01T08:30:00+08:00", false, true},
+		{"yesterday", false, false},
+	}
+	for _, tt := range tests {
+		_, dateOnly, err := parseExecTime(tt.value)
+		if (err == nil) != tt.ok || dateOnly != tt.dateOnly {
+			t.Errorf("%q: unexpected result dateOnly=%v err=%v", tt.value, dateOnly, err)
+		}
+	}
+}
+
+func TestBuildExecDetail(t *testing.T) {
+	parser := NewWorkflowDSLParser()
+	definition := &dto.WorkflowDefinition{Nodes: []*dto.WorkflowNode{
+		{ID: "start", Type: dto.NodeTypeStart},
+		{ID: "sub", Type: dto.NodeTypeWorkflow},
+		{ID: "loop", Type: dto.NodeTypeLoop},
+		{ID: "body", Type: dto.NodeTypeCode, ParentID: "loop"},
+	}}
+	start := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	end := start.Add(1500 * time.Millisecond)
+	execResult := &entity.WorkflowExecResult{
+		ExecKey: "exec", Input: `{"q":"hi"}`, Output: "not json", StartTime: start, EndTime: &end,
+		Status: entity.ExecStatusCompleted,
+	}
+	steps := []*entity.WorkflowExecStep{
+		{ID: 1, NodeID: "start", StartTime: start, EndTime: &end},
+		{ID: 2, NodeID: "sub", StartTime: start},
+		{ID: 3, NodeID: "body#0", StartTime: start},
+	}
+
+	detail := buildExecDetail(parser, definition, execResult, steps, map[int64]string{2: "child"})
+	if detail.Duration != 1500 || detail.Output != "not json" {
+		t.Errorf("unexpected detail: %+v", detail)
+	}
+	if input, _ := detail.Input.(map[string]interface{}); input["q"] != "hi" {
+		t.Errorf("expected decoded input, got %v", detail.Input)
+	}
+	if s := detail.Steps[1]; s.NodeType != dto.NodeTypeWorkflow || s.ChildExecKey != "child" || s.Duration != 0 || !s.Rerunnable {
+		t.Errorf("unexpected sub-workflow step: %+v", s)
+	}
+	if s := detail.Steps[2]; s.NodeType != dto.NodeTypeCode || s.Rerunnable {
+		t.Errorf("loop iteration must not be rerunnable: %+v", s)
+	}
+
+	// runs of sub-workflows are rerun from their parent
+	execResult.ParentExecKey = "parent"
+	if detail := buildExecDetail(parser, definition, execResult, steps, nil); detail.Steps[0].Rerunnable {
+		t.Error("steps of a child execution must not be rerunnable")
+	}
+}
+
+func TestChainState_TryResume(t *testing.T) {
+	state := &repository.ChainState{Status: entity.ExecStatusRunning}
+	state.Suspend("approve", nil)
+
+	// concurrent resume requests: only one of them wins
+	var resumed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if state.TryResume("approve") {
+				resumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if resumed.Load() != 1 {
+		t.Fatalf("expected exactly one resume, got %d", resumed.Load())
+	}
+
+	// a cancelled execution stays cancelled
+	state.Suspend("approve", nil)
+	state.Cancel(errors.New("cancelled"))
+	if state.TryResume("approve") || state.GetStatus() != entity.ExecStatusCancelled {
+		t.Errorf("cancelled execution was resumed: %v", state.GetStatus())
+	}
+
+	// a resume for an earlier suspension doesn't resume a later one
+	state = &repository.ChainState{Status: entity.ExecStatusRunning}
+	state.Suspend("review", nil)
+	if state.TryResume("approve") {
+		t.Error("resumed a different suspended node")
+	}
+}
+
+func TestChainExecutor_EvictsFinishedStates(t *testing.T) {
+	executor := &ChainExecutor{}
+	definition := &dto.WorkflowDefinition{}
+
+	suspended := &repository.ChainState{ExecuteID: "suspended", Status: entity.ExecStatusRunning}
+	completed := &repository.ChainState{ExecuteID: "completed", Status: entity.ExecStatusRunning}
+	for _, state := range []*repository.ChainState{suspended, completed} {
+		executor.states.Store(state.ExecuteID, state)
+	}
+
+	_, release := executor.newRunContext(context.Background(), suspended, definition)
+	suspended.Suspend("approve", nil)
+	release()
+	_, release = executor.newRunContext(context.Background(), completed, definition)
+	completed.Complete(nil)
+	release()
+
+	// a suspended execution stays in memory to be resumed, a finished one is read from its checkpoint
+	if _, ok := executor.states.Load("suspended"); !ok {
+		t.Error("suspended execution was evicted")
+	}
+	if _, ok := executor.states.Load("completed"); ok {
+		t.Error("completed execution was not evicted")
+	}
+}
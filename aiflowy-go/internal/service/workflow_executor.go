@@ -3,8 +3,11 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -12,7 +15,11 @@ import (
 
 	"github.com/aiflowy/aiflowy-go/internal/dto"
 	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
 	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/logger"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+	"go.uber.org/zap"
 )
 
 // ChainExecutor 工作流执行引擎
@@ -24,6 +31,12 @@ type ChainExecutor struct {
 	// 执行状态缓存 (内存)
 	states sync.Map // map[string]*repository.ChainState
 
+	// 正在调度的执行 (用于取消)
+	runs sync.Map // map[string]*runHandle
+
+	// 执行事件订阅 (SSE)
+	events *workflowEventHub
+
 	// 节点执行器
 	nodeExecutors map[string]NodeExecutor
 }
@@ -39,6 +52,7 @@ func NewChainExecutor() *ChainExecutor {
 		workflowRepo:  repository.NewWorkflowRepository(),
 		execRepo:      repository.NewWorkflowExecRepository(),
 		parser:        NewWorkflowDSLParser(),
+		events:        newWorkflowEventHub(),
 		nodeExecutors: make(map[string]NodeExecutor),
 	}
 
@@ -59,175 +73,290 @@ func (e *ChainExecutor) registerNodeExecutors() {
 	e.nodeExecutors[dto.NodeTypePlugin] = NewPluginNodeExecutor()
 	e.nodeExecutors[dto.NodeTypeCode] = &CodeNodeExecutor{}
 	e.nodeExecutors[dto.NodeTypeWorkflow] = NewSubWorkflowNodeExecutor(e)
+	e.nodeExecutors[dto.NodeTypeLoop] = NewLoopNodeExecutor(e)
+	e.nodeExecutors[dto.NodeTypeSQL] = NewSQLNodeExecutor()
+	e.nodeExecutors[dto.NodeTypeDoc] = NewDocNodeExecutor()
+	e.nodeExecutors[dto.NodeTypeHTTP] = NewHTTPNodeExecutor()
+	e.nodeExecutors[dto.NodeTypeKnowledge] = NewKnowledgeNodeExecutor()
+	e.nodeExecutors[dto.NodeTypeClassifier] = NewClassifierNodeExecutor()
+	e.nodeExecutors[dto.NodeTypeExtractor] = NewExtractorNodeExecutor()
 }
 
-// ExecuteAsync 异步执行工作流
-func (e *ChainExecutor) ExecuteAsync(ctx context.Context, workflowID string, variables map[string]interface{}, userID, createdBy string) (string, error) {
-	// 生成执行 ID
-	executeID := uuid.New().String()
-
-	// 解析工作流 ID
-	wfID, err := strconv.ParseInt(workflowID, 10, 64)
+// ExecuteAsync 异步执行工作流的发布版本，draft 为 true 时执行草稿
+func (e *ChainExecutor) ExecuteAsync(ctx context.Context, workflowID string, draft bool, variables map[string]interface{}, userID, createdBy string) (string, error) {
+	state, definition, err := e.prepareExecution(ctx, workflowID, draft, variables, userID, createdBy)
 	if err != nil {
-		return "", fmt.Errorf("无效的工作流 ID: %s", workflowID)
+		return "", err
 	}
 
-	// 加载工作流
-	workflow, err := e.workflowRepo.GetWorkflowByID(ctx, wfID)
+	e.runAsync(state, definition)
+	return state.ExecuteID, nil
+}
+
+// ExecuteStream 异步执行工作流并订阅执行事件，订阅在执行开始前登记，不会遗漏事件
+// 订阅断开不影响执行，可通过 Subscribe 重新附加
+func (e *ChainExecutor) ExecuteStream(ctx context.Context, workflowID string, draft bool, variables map[string]interface{}, userID, createdBy string) (*WorkflowSubscription, error) {
+	state, definition, err := e.prepareExecution(ctx, workflowID, draft, variables, userID, createdBy)
 	if err != nil {
-		return "", fmt.Errorf("加载工作流失败: %w", err)
+		return nil, err
 	}
-	if workflow == nil {
-		return "", fmt.Errorf("工作流不存在: %s", workflowID)
+
+	sub := e.subscribeState(state)
+	e.runAsync(state, definition)
+	return sub, nil
+}
+
+// prepareExecution 加载并校验工作流，创建执行状态与执行记录
+func (e *ChainExecutor) prepareExecution(ctx context.Context, workflowID string, draft bool, variables map[string]interface{}, userID, createdBy string) (*repository.ChainState, *dto.WorkflowDefinition, error) {
+	// 加载工作流及执行的版本
+	workflow, version, err := e.loadRunnableWorkflow(ctx, workflowID, draft, 0)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// 解析工作流定义
-	definition, err := e.parser.Parse(workflow.Content)
+	definition, err := e.parser.Parse(version.Content)
 	if err != nil {
-		return "", fmt.Errorf("解析工作流定义失败: %w", err)
+		return nil, nil, fmt.Errorf("解析工作流定义失败: %w", err)
 	}
 
 	// 验证工作流
 	if err := e.parser.Validate(definition); err != nil {
-		return "", fmt.Errorf("工作流定义无效: %w", err)
+		return nil, nil, fmt.Errorf("工作流定义无效: %w", err)
 	}
 
-	// 确保 variables 不为 nil
-	if variables == nil {
-		variables = make(map[string]interface{})
+	// 按开始节点的参数声明校验输入并应用默认值，初始化全局变量
+	variables, err = initWorkflowVariables(e.parser, definition, variables)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	state, err := e.newExecution(ctx, &entity.WorkflowExecResult{
+		WorkflowID:      workflow.ID,
+		Title:           workflow.Title,
+		Description:     workflow.Description,
+		WorkflowJSON:    version.Content,
+		CreatedKey:      userID,
+		CreatedBy:       createdBy,
+		WorkflowVersion: version.Version,
+	}, variables, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, definition, nil
+}
+
+// newExecution 创建执行状态与执行记录，execResult 提供工作流信息与执行人，
+// nodeStates 为预先恢复的节点状态 (从步骤重新执行时使用)
+func (e *ChainExecutor) newExecution(ctx context.Context, execResult *entity.WorkflowExecResult, variables map[string]interface{}, nodeStates map[string]*repository.NodeState) (*repository.ChainState, error) {
+	// 生成执行 ID
+	executeID := uuid.New().String()
+	if nodeStates == nil {
+		nodeStates = make(map[string]*repository.NodeState)
 	}
 
 	// 初始化执行状态
 	state := &repository.ChainState{
 		ExecuteID:  executeID,
-		WorkflowID: workflow.ID,
+		WorkflowID: execResult.WorkflowID,
 		Status:     entity.ExecStatusRunning,
 		Variables:  variables,
-		NodeStates: make(map[string]*repository.NodeState),
+		NodeStates: nodeStates,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
-	// 存储状态到内存
-	e.states.Store(executeID, state)
-
 	// 创建执行记录
 	inputJSON, _ := json.Marshal(variables)
-	execResult := &entity.WorkflowExecResult{
-		ExecKey:      executeID,
-		WorkflowID:   workflow.ID,
-		Title:        workflow.Title,
-		Description:  workflow.Description,
-		Input:        string(inputJSON),
-		WorkflowJSON: workflow.Content,
-		StartTime:    time.Now(),
-		Status:       entity.ExecStatusRunning,
-		CreatedKey:   userID,
-		CreatedBy:    createdBy,
-	}
+	execResult.ExecKey = executeID
+	execResult.Input = string(inputJSON)
+	execResult.StartTime = time.Now()
+	execResult.Status = entity.ExecStatusRunning
 
 	if err := e.execRepo.CreateExecResult(ctx, execResult); err != nil {
-		return "", fmt.Errorf("创建执行记录失败: %w", err)
+		return nil, fmt.Errorf("创建执行记录失败: %w", err)
 	}
 
+	// 存储状态到内存，执行结束后移除
 	state.RecordID = execResult.ID
+	e.states.Store(executeID, state)
+	e.checkpoint(ctx, state)
+	return state, nil
+}
 
-	// 异步执行
-	go e.executeWorkflow(context.Background(), state, definition)
-
-	return executeID, nil
+// runAsync 在后台调度执行
+func (e *ChainExecutor) runAsync(state *repository.ChainState, definition *dto.WorkflowDefinition) {
+	runCtx, release := e.newRunContext(context.Background(), state, definition)
+	go func() {
+		defer release()
+		e.executeWorkflow(runCtx, state, definition)
+	}()
 }
 
 // executeWorkflow 执行工作流
 func (e *ChainExecutor) executeWorkflow(ctx context.Context, state *repository.ChainState, definition *dto.WorkflowDefinition) {
 	defer func() {
 		if r := recover(); r != nil {
-			e.handleError(ctx, state, fmt.Errorf("执行异常: %v", r))
+			e.handleError(context.WithoutCancel(ctx), state, fmt.Errorf("执行异常: %v", r))
+			e.publishDone(state)
 		}
 	}()
 
 	// 找到开始节点
 	startNode := e.parser.GetStartNode(definition)
 	if startNode == nil {
-		e.handleError(ctx, state, fmt.Errorf("工作流没有开始节点"))
+		e.handleError(context.WithoutCancel(ctx), state, fmt.Errorf("工作流没有开始节点"))
+		e.publishDone(state)
 		return
 	}
 
-	// 从开始节点执行
-	e.executeNode(ctx, state, definition, startNode)
+	// 从开始节点调度，等待所有分支结束
+	run := e.newChainRun(ctx, state, definition)
+	run.start(startNode)
+	run.wait()
 }
 
-// executeNode 执行单个节点
-func (e *ChainExecutor) executeNode(ctx context.Context, state *repository.ChainState, definition *dto.WorkflowDefinition, node *dto.WorkflowNode) {
+// executeNode 执行单个节点并记录步骤，返回节点输出以及是否继续调度下游
+// 按节点的重试策略重试，每次尝试记录为独立的步骤；配置了错误分支时失败不会中断工作流
+func (e *ChainExecutor) executeNode(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, errorRoute bool) (map[string]interface{}, bool) {
+	// 分支 context 可能已被取消，落库使用不可取消的 context
+	dbCtx := context.WithoutCancel(ctx)
+
 	// 检查状态是否已终止
-	if state.Status == entity.ExecStatusFailed || state.Status == entity.ExecStatusCompleted {
-		return
+	if state.GetStatus() != entity.ExecStatusRunning {
+		return nil, false
 	}
 
-	// 创建节点状态
-	nodeName := node.Name
-	if nodeName == "" {
-		nodeName = node.Type
+	// 执行已被取消或超时，不再启动新节点
+	if ctx.Err() != nil {
+		e.handleInterrupt(dbCtx, state, node, nil, context.Cause(ctx))
+		return nil, false
 	}
 
-	nodeState := &repository.NodeState{
-		NodeID:    node.ID,
-		NodeName:  nodeName,
-		Status:    entity.ExecStatusRunning,
-		Input:     state.Variables,
-		StartTime: time.Now(),
+	nodeName := nodeDisplayName(node)
+	input := state.SnapshotVariables()
+
+	// 创建节点状态 (调度器可能已认领该节点)
+	startTime := time.Now()
+	if _, ok := state.GetNodeState(node.ID); ok {
+		state.UpdateNodeState(node.ID, func(ns *repository.NodeState) {
+			ns.Input = input
+			ns.StartTime = startTime
+		})
+	} else {
+		state.SetNodeState(&repository.NodeState{
+			NodeID:    node.ID,
+			NodeName:  nodeName,
+			Status:    entity.ExecStatusRunning,
+			Input:     input,
+			StartTime: startTime,
+		})
 	}
-	state.NodeStates[node.ID] = nodeState
-	state.UpdatedAt = time.Now()
+	e.publishNodeStatus(state, node.ID, protocol.WorkflowStateStart, "", nil)
 
-	// 记录步骤开始
 	nodeDataJSON, _ := json.Marshal(node.Data)
-	inputJSON, _ := json.Marshal(state.Variables)
-	step := &entity.WorkflowExecStep{
-		RecordID:  state.RecordID,
-		ExecKey:   state.ExecuteID,
-		NodeID:    node.ID,
-		NodeName:  nodeName,
-		Input:     string(inputJSON),
-		NodeData:  string(nodeDataJSON),
-		StartTime: time.Now(),
-		Status:    entity.ExecStatusRunning,
-	}
-	e.execRepo.CreateExecStep(ctx, step)
+	inputJSON, _ := json.Marshal(input)
+	policy := parseRetryPolicy(node)
+	nodeCtx := e.withNodeEvents(ctx, state, node.ID)
+
+	for attempt := 1; ; attempt++ {
+		// 记录步骤开始
+		step := &entity.WorkflowExecStep{
+			RecordID:  state.RecordID,
+			ExecKey:   state.ExecuteID,
+			NodeID:    node.ID,
+			NodeName:  nodeName,
+			Input:     string(inputJSON),
+			NodeData:  string(nodeDataJSON),
+			StartTime: time.Now(),
+			Status:    entity.ExecStatusRunning,
+			Attempt:   attempt,
+		}
+		e.execRepo.CreateExecStep(dbCtx, step)
+
+		result, err := e.runAttempt(withExecStep(nodeCtx, step), state, node)
+		if err == nil {
+			e.completeNode(dbCtx, state, node.ID, step, result)
+			return result, true
+		}
+
+		// 检查是否是暂停错误
+		if suspendErr, ok := err.(*SuspendError); ok {
+			e.handleSuspend(dbCtx, state, step, node.ID, suspendErr.Params)
+			return nil, false
+		}
+
+		// 整个执行被取消或超时
+		if ctx.Err() != nil {
+			e.handleInterrupt(dbCtx, state, node, step, context.Cause(ctx))
+			return nil, false
+		}
 
+		// 可重试的错误：记录本次失败，退避后重试
+		if attempt < policy.MaxAttempts && policy.retryable(err) {
+			now := time.Now()
+			step.Status = entity.ExecStatusFailed
+			step.ErrorInfo = err.Error()
+			step.EndTime = &now
+			e.execRepo.UpdateExecStep(dbCtx, step)
+
+			select {
+			case <-time.After(policy.delay(attempt)):
+				continue
+			case <-ctx.Done():
+				e.handleInterrupt(dbCtx, state, node, nil, context.Cause(ctx))
+				return nil, false
+			}
+		}
+
+		// 配置了错误分支：记录失败并转到错误分支
+		if errorRoute {
+			output := e.routeNodeError(dbCtx, state, node, step, err)
+			return output, true
+		}
+
+		e.handleNodeError(dbCtx, state, node.ID, step, err)
+		return nil, false
+	}
+}
+
+// runAttempt 执行一次节点，节点级超时对每次尝试单独计时
+func (e *ChainExecutor) runAttempt(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
 	// 获取节点执行器
 	executor, ok := e.nodeExecutors[node.Type]
 	if !ok {
-		e.handleNodeError(ctx, state, nodeState, step, fmt.Errorf("未知的节点类型: %s", node.Type))
-		return
+		return nil, fmt.Errorf("未知的节点类型: %s", node.Type)
 	}
 
-	// 执行节点
-	result, err := executor.Execute(ctx, state, node)
+	// 节点级超时
+	nodeCtx := ctx
+	timeout := nodeTimeout(node)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		nodeCtx, cancel = context.WithTimeoutCause(ctx, timeout, errNodeTimeout)
+		defer cancel()
+	}
 
-	if err != nil {
-		// 检查是否是暂停错误
-		if suspendErr, ok := err.(*SuspendError); ok {
-			e.handleSuspend(ctx, state, nodeState, step, node.ID, suspendErr.Params)
-			return
-		}
-		e.handleNodeError(ctx, state, nodeState, step, err)
-		return
+	// 执行节点
+	result, err := executor.Execute(nodeCtx, state, node)
+	if err != nil && ctx.Err() == nil && nodeCtx.Err() != nil {
+		err = fmt.Errorf("%w (%s)", errNodeTimeout, timeout)
 	}
+	return result, err
+}
 
+// completeNode 记录节点执行成功
+func (e *ChainExecutor) completeNode(ctx context.Context, state *repository.ChainState, nodeID string, step *entity.WorkflowExecStep, result map[string]interface{}) {
 	// 更新节点状态
 	now := time.Now()
-	nodeState.Status = entity.ExecStatusCompleted
-	nodeState.Output = result
-	nodeState.EndTime = &now
+	state.UpdateNodeState(nodeID, func(ns *repository.NodeState) {
+		ns.Status = entity.ExecStatusCompleted
+		ns.Output = result
+		ns.EndTime = &now
+	})
 
-	// 合并输出到变量
-	if state.Variables == nil {
-		state.Variables = make(map[string]interface{})
-	}
-	for k, v := range result {
-		state.Variables[k] = v
-	}
+	// 输出以节点 ID 为作用域保存到变量
+	state.SetNodeOutput(loopBaseID(nodeID), result)
 
 	// 更新步骤记录
 	outputJSON, _ := json.Marshal(result)
@@ -236,35 +365,50 @@ func (e *ChainExecutor) executeNode(ctx context.Context, state *repository.Chain
 	step.Status = entity.ExecStatusCompleted
 	e.execRepo.UpdateExecStep(ctx, step)
 
-	// 检查是否是结束节点
-	if node.Type == dto.NodeTypeEnd {
-		e.handleComplete(ctx, state, result)
-		return
-	}
+	// 每个节点完成后保存检查点
+	e.checkpoint(ctx, state)
+	e.publishNodeStatus(state, nodeID, protocol.WorkflowStateEnd, "", result)
+}
 
-	// 获取下一个节点
-	nextNodes := e.parser.GetNextNodes(definition, node.ID)
-	if len(nextNodes) == 0 {
-		// 没有下一个节点，工作流完成
-		e.handleComplete(ctx, state, result)
-		return
+// routeNodeError 节点失败但配置了错误分支：节点标记为失败，错误信息作为输出供错误分支使用
+func (e *ChainExecutor) routeNodeError(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, step *entity.WorkflowExecStep, err error) map[string]interface{} {
+	now := time.Now()
+	output := map[string]interface{}{
+		"error": map[string]interface{}{
+			"nodeId":   node.ID,
+			"nodeName": nodeDisplayName(node),
+			"message":  err.Error(),
+			"type":     classifyError(err),
+		},
 	}
 
-	// 条件节点：根据条件选择下一个节点
-	if node.Type == dto.NodeTypeCondition {
-		selectedNode := e.selectNextNodeByCondition(definition, node, result)
-		if selectedNode != nil {
-			e.executeNode(ctx, state, definition, selectedNode)
-		} else {
-			e.handleComplete(ctx, state, result)
-		}
-		return
-	}
+	state.UpdateNodeState(node.ID, func(ns *repository.NodeState) {
+		ns.Status = entity.ExecStatusFailed
+		ns.Error = err
+		ns.Output = output
+		ns.EndTime = &now
+	})
+	state.SetNodeOutput(loopBaseID(node.ID), output)
 
-	// 执行下一个节点
-	for _, nextNode := range nextNodes {
-		e.executeNode(ctx, state, definition, nextNode)
+	outputJSON, _ := json.Marshal(output)
+	step.Output = string(outputJSON)
+	step.Status = entity.ExecStatusFailed
+	step.ErrorInfo = err.Error()
+	step.EndTime = &now
+	e.execRepo.UpdateExecStep(ctx, step)
+
+	e.checkpoint(ctx, state)
+	e.publishNodeStatus(state, node.ID, protocol.WorkflowStateError, err.Error(), output)
+	return output
+}
+
+// isBranchNode 节点是否按输出的 condition 选择分支：条件节点、意图分类节点与参数提取节点
+func isBranchNode(nodeType string) bool {
+	switch nodeType {
+	case dto.NodeTypeCondition, dto.NodeTypeClassifier, dto.NodeTypeExtractor:
+		return true
 	}
+	return false
 }
 
 // selectNextNodeByCondition 根据条件选择下一个节点
@@ -275,11 +419,13 @@ func (e *ChainExecutor) selectNextNodeByCondition(definition *dto.WorkflowDefini
 		conditionResult = "default"
 	}
 
-	// 遍历边，找到匹配的条件
+	// 遍历边，找到匹配的条件 (错误分支只在节点失败时使用)
+	// 只按 sourcePort 分支的边不是无条件边，不能匹配任意结果
 	for _, edge := range definition.Edges {
-		if edge.Source == node.ID {
+		if edge.Source == node.ID && !isErrorEdge(edge) {
 			// 检查条件是否匹配
-			if edge.Condition == conditionResult || edge.Condition == "" || edge.SourcePort == conditionResult {
+			if edge.Condition == conditionResult || edge.SourcePort == conditionResult ||
+				(edge.Condition == "" && edge.SourcePort == "") {
 				return e.parser.GetNodeByID(definition, edge.Target)
 			}
 		}
@@ -287,7 +433,7 @@ func (e *ChainExecutor) selectNextNodeByCondition(definition *dto.WorkflowDefini
 
 	// 找不到匹配的条件，返回第一个下一节点
 	for _, edge := range definition.Edges {
-		if edge.Source == node.ID {
+		if edge.Source == node.ID && !isErrorEdge(edge) {
 			return e.parser.GetNodeByID(definition, edge.Target)
 		}
 	}
@@ -296,18 +442,21 @@ func (e *ChainExecutor) selectNextNodeByCondition(definition *dto.WorkflowDefini
 }
 
 // handleSuspend 处理工作流暂停
-func (e *ChainExecutor) handleSuspend(ctx context.Context, state *repository.ChainState, nodeState *repository.NodeState, step *entity.WorkflowExecStep, nodeID string, params []*repository.SuspendedParam) {
-	state.Status = entity.ExecStatusSuspended
-	state.SuspendedNodeID = nodeID
-	state.SuspendedParams = params
-	state.UpdatedAt = time.Now()
-
-	nodeState.Status = entity.ExecStatusSuspended
+func (e *ChainExecutor) handleSuspend(ctx context.Context, state *repository.ChainState, step *entity.WorkflowExecStep, nodeID string, params []*repository.SuspendedParam) {
+	state.Suspend(nodeID, params)
+	state.UpdateNodeState(nodeID, func(ns *repository.NodeState) {
+		ns.Status = entity.ExecStatusSuspended
+	})
 
 	step.Status = entity.ExecStatusSuspended
 	now := time.Now()
 	step.EndTime = &now
 	e.execRepo.UpdateExecStep(ctx, step)
+	e.publishSuspend(state, nodeID, params)
+
+	if state.Scoped {
+		return
+	}
 
 	// 更新执行记录
 	execResult, _ := e.execRepo.GetExecResultByExecKey(ctx, state.ExecuteID)
@@ -316,13 +465,16 @@ func (e *ChainExecutor) handleSuspend(ctx context.Context, state *repository.Cha
 		execResult.EndTime = &now
 		e.execRepo.UpdateExecResult(ctx, execResult)
 	}
+
+	e.checkpoint(ctx, state)
 }
 
 // handleError 处理执行错误
 func (e *ChainExecutor) handleError(ctx context.Context, state *repository.ChainState, err error) {
-	state.Status = entity.ExecStatusFailed
-	state.Error = err
-	state.UpdatedAt = time.Now()
+	// 并行分支可能同时失败，只记录第一个错误
+	if !state.Fail(err) || state.Scoped {
+		return
+	}
 
 	// 更新执行记录
 	execResult, _ := e.execRepo.GetExecResultByExecKey(ctx, state.ExecuteID)
@@ -333,29 +485,63 @@ func (e *ChainExecutor) handleError(ctx context.Context, state *repository.Chain
 		execResult.EndTime = &now
 		e.execRepo.UpdateExecResult(ctx, execResult)
 	}
+
+	e.checkpoint(ctx, state)
 }
 
 // handleNodeError 处理节点执行错误
-func (e *ChainExecutor) handleNodeError(ctx context.Context, state *repository.ChainState, nodeState *repository.NodeState, step *entity.WorkflowExecStep, err error) {
+func (e *ChainExecutor) handleNodeError(ctx context.Context, state *repository.ChainState, nodeID string, step *entity.WorkflowExecStep, err error) {
 	now := time.Now()
 
-	nodeState.Status = entity.ExecStatusFailed
-	nodeState.Error = err
-	nodeState.EndTime = &now
+	state.UpdateNodeState(nodeID, func(ns *repository.NodeState) {
+		ns.Status = entity.ExecStatusFailed
+		ns.Error = err
+		ns.EndTime = &now
+	})
 
 	step.Status = entity.ExecStatusFailed
 	step.ErrorInfo = err.Error()
 	step.EndTime = &now
 	e.execRepo.UpdateExecStep(ctx, step)
+	e.publishNodeStatus(state, nodeID, protocol.WorkflowStateError, err.Error(), nil)
 
 	e.handleError(ctx, state, err)
 }
 
+// handleInterrupt 处理被取消或超时中断的节点，记录中断位置
+func (e *ChainExecutor) handleInterrupt(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, step *entity.WorkflowExecStep, cause error) {
+	now := time.Now()
+	status := entity.ExecStatusCancelled
+	if errors.Is(cause, errWorkflowTimeout) {
+		status = entity.ExecStatusFailed
+	}
+
+	state.UpdateNodeState(node.ID, func(ns *repository.NodeState) {
+		ns.Status = status
+		ns.Error = cause
+		ns.EndTime = &now
+	})
+
+	if step != nil {
+		step.Status = status
+		step.ErrorInfo = cause.Error()
+		step.EndTime = &now
+		e.execRepo.UpdateExecStep(ctx, step)
+	}
+	e.publishNodeStatus(state, node.ID, nodeEventState(status), cause.Error(), nil)
+
+	// 取消由 Cancel 负责更新执行记录；其它分支失败导致的中断无需处理
+	if errors.Is(cause, errWorkflowTimeout) {
+		e.handleError(ctx, state, fmt.Errorf("%w，中断节点: %s", cause, nodeDisplayName(node)))
+	}
+}
+
 // handleComplete 处理执行完成
 func (e *ChainExecutor) handleComplete(ctx context.Context, state *repository.ChainState, result map[string]interface{}) {
-	state.Status = entity.ExecStatusCompleted
-	state.Result = result
-	state.UpdatedAt = time.Now()
+	state.Complete(result)
+	if state.Scoped {
+		return
+	}
 
 	// 更新执行记录
 	execResult, _ := e.execRepo.GetExecResultByExecKey(ctx, state.ExecuteID)
@@ -367,23 +553,80 @@ func (e *ChainExecutor) handleComplete(ctx context.Context, state *repository.Ch
 		execResult.EndTime = &now
 		e.execRepo.UpdateExecResult(ctx, execResult)
 	}
+
+	e.checkpoint(ctx, state)
+}
+
+// checkpoint 持久化执行状态，服务重启后可据此恢复
+func (e *ChainExecutor) checkpoint(ctx context.Context, state *repository.ChainState) {
+	if state.Scoped {
+		return
+	}
+	if err := e.execRepo.SaveChainState(ctx, state); err != nil {
+		logger.Warn("Failed to save workflow checkpoint",
+			zap.String("execute_id", state.ExecuteID),
+			zap.Error(err),
+		)
+	}
 }
 
-// GetChainStatus 获取执行状态
-func (e *ChainExecutor) GetChainStatus(ctx context.Context, executeID string, requestNodes []*dto.NodeInfo) (*dto.ChainInfo, error) {
-	// 尝试从内存获取状态
+// loadState 获取执行状态：优先内存，其次从检查点恢复
+func (e *ChainExecutor) loadState(ctx context.Context, executeID string) (*repository.ChainState, error) {
 	if stateVal, ok := e.states.Load(executeID); ok {
-		state := stateVal.(*repository.ChainState)
+		return stateVal.(*repository.ChainState), nil
+	}
+
+	state, err := e.execRepo.GetChainState(ctx, executeID)
+	if err != nil {
+		return nil, fmt.Errorf("加载执行状态失败: %w", err)
+	}
+	if state == nil {
+		return nil, nil
+	}
+	// 已结束的执行不再缓存，每次从检查点加载
+	if executionFinished(state.Status) {
+		return state, nil
+	}
+
+	// 并发请求可能同时恢复，以先写入的为准
+	actual, _ := e.states.LoadOrStore(executeID, state)
+	return actual.(*repository.ChainState), nil
+}
+
+// loadTenantState 加载租户的执行状态，执行的工作流不属于该租户时视为不存在
+func (e *ChainExecutor) loadTenantState(ctx context.Context, executeID string, tenantID int64) (*repository.ChainState, error) {
+	state, err := e.loadState(ctx, executeID)
+	if err != nil || state == nil {
+		return nil, err
+	}
+	workflow, err := e.workflowRepo.GetWorkflowByID(ctx, state.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	if workflow == nil || workflow.TenantID != tenantID {
+		return nil, nil
+	}
+	return state, nil
+}
+
+// GetChainStatus 获取租户的执行状态
+func (e *ChainExecutor) GetChainStatus(ctx context.Context, executeID string, tenantID int64, requestNodes []*dto.NodeInfo) (*dto.ChainInfo, error) {
+	// 尝试从内存或检查点获取状态
+	state, err := e.loadTenantState(ctx, executeID, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
 		return e.buildChainInfo(state, requestNodes), nil
 	}
 
-	// 从数据库加载
-	execResult, err := e.execRepo.GetExecResultByExecKey(ctx, executeID)
+	// 没有检查点 (历史数据)，从执行记录和步骤构建
+	execResult, err := e.execRepo.GetTenantExecResultByExecKey(ctx, executeID, tenantID)
 	if err != nil {
 		return nil, fmt.Errorf("加载执行记录失败: %w", err)
 	}
 	if execResult == nil {
-		return nil, fmt.Errorf("执行记录不存在: %s", executeID)
+		return nil, apierrors.NotFound("执行记录不存在")
 	}
 
 	// 构建 ChainInfo
@@ -429,6 +672,9 @@ func (e *ChainExecutor) GetChainStatus(ctx context.Context, executeID string, re
 
 // buildChainInfo 构建 ChainInfo
 func (e *ChainExecutor) buildChainInfo(state *repository.ChainState, requestNodes []*dto.NodeInfo) *dto.ChainInfo {
+	// 执行仍在进行中，基于快照构建避免与执行 goroutine 竞争
+	state = state.Snapshot()
+
 	chainInfo := &dto.ChainInfo{
 		ExecuteID: state.ExecuteID,
 		Status:    int(state.Status),
@@ -472,67 +718,211 @@ func (e *ChainExecutor) buildChainInfo(state *repository.ChainState, requestNode
 	return chainInfo
 }
 
-// Resume 恢复执行
-func (e *ChainExecutor) Resume(ctx context.Context, executeID string, confirmParams map[string]interface{}) error {
-	// 从内存获取状态
-	stateVal, ok := e.states.Load(executeID)
-	if !ok {
-		return fmt.Errorf("执行记录不存在或已完成: %s", executeID)
+// Resume 恢复租户暂停中的执行
+func (e *ChainExecutor) Resume(ctx context.Context, executeID string, tenantID int64, confirmParams map[string]interface{}) error {
+	// 从内存或检查点获取状态
+	state, err := e.loadTenantState(ctx, executeID, tenantID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return apierrors.NotFound("执行记录不存在")
 	}
 
-	state := stateVal.(*repository.ChainState)
-
-	if state.Status != entity.ExecStatusSuspended {
+	snapshot := state.Snapshot()
+	if snapshot.Status != entity.ExecStatusSuspended {
 		return fmt.Errorf("工作流未处于暂停状态")
 	}
 
-	// 合并确认参数
-	for k, v := range confirmParams {
-		state.Variables[k] = v
+	// 使用执行时的工作流配置快照，工作流在暂停期间重新发布不影响本次执行
+	execResult, err := e.execRepo.GetExecResultByExecKey(ctx, executeID)
+	if err != nil {
+		return fmt.Errorf("加载执行记录失败: %w", err)
+	}
+	if execResult == nil {
+		return fmt.Errorf("执行记录不存在: %s", executeID)
 	}
 
-	// 重新加载工作流定义
-	workflow, err := e.workflowRepo.GetWorkflowByID(ctx, state.WorkflowID)
+	definition, err := e.parser.Parse(execResult.WorkflowJSON)
 	if err != nil {
-		return fmt.Errorf("加载工作流失败: %w", err)
+		return fmt.Errorf("解析工作流定义失败: %w", err)
 	}
 
-	definition, err := e.parser.Parse(workflow.Content)
+	// 按暂停节点等待的参数校验确认参数并应用默认值
+	confirmParams, err = bindWorkflowParams(suspendedParamsToParameters(snapshot.SuspendedParams), confirmParams)
 	if err != nil {
-		return fmt.Errorf("解析工作流定义失败: %w", err)
+		return err
+	}
+
+	// 并发的恢复请求与取消在此处决出结果，之后才修改执行状态
+	if !state.TryResume(snapshot.SuspendedNodeID) {
+		return fmt.Errorf("工作流未处于暂停状态")
 	}
 
-	// 获取暂停的节点
-	suspendedNodeID := state.SuspendedNodeID
-	state.Status = entity.ExecStatusRunning
-	state.SuspendedNodeID = ""
-	state.SuspendedParams = nil
-	state.UpdatedAt = time.Now()
+	// 确认参数作为暂停节点的输出
+	state.SetNodeOutput(loopBaseID(snapshot.SuspendedNodeID), confirmParams)
 
-	// 更新执行记录状态
-	execResult, _ := e.execRepo.GetExecResultByExecKey(ctx, executeID)
-	if execResult != nil {
-		execResult.Status = entity.ExecStatusRunning
-		e.execRepo.UpdateExecResult(ctx, execResult)
+	e.prepareResume(state, snapshot.SuspendedNodeID, confirmParams)
+	e.checkpoint(ctx, state)
+
+	// 更新执行记录状态，只更新仍为暂停的记录，不覆盖同时到达的取消
+	if _, err := e.execRepo.ResumeExecResult(ctx, execResult.ID); err != nil {
+		logger.Warn("Failed to update workflow exec result",
+			zap.String("execute_id", executeID),
+			zap.Error(err),
+		)
 	}
 
-	// 获取下一个节点并继续执行
-	nextNodes := e.parser.GetNextNodes(definition, suspendedNodeID)
-	if len(nextNodes) == 0 {
-		e.handleComplete(ctx, state, state.Variables)
+	// 异步继续执行：重新评估尚未执行的节点
+	runCtx, release := e.newRunContext(context.Background(), state, definition)
+	// 取消先修改状态再查找调度：登记调度前已被取消时不再继续执行
+	if state.GetStatus() != entity.ExecStatusRunning {
+		release()
 		return nil
 	}
-
-	// 异步继续执行
 	go func() {
-		for _, nextNode := range nextNodes {
-			e.executeNode(context.Background(), state, definition, nextNode)
-		}
+		defer release()
+		run := e.newChainRun(runCtx, state, definition)
+		run.resume()
+		run.wait()
 	}()
 
 	return nil
 }
 
+// prepareResume 将暂停节点标记为完成，其它并行分支上暂停的节点清除状态以便重新执行
+func (e *ChainExecutor) prepareResume(state *repository.ChainState, suspendedNodeID string, confirmParams map[string]interface{}) {
+	now := time.Now()
+	for nodeID, ns := range state.SnapshotNodeStates() {
+		if ns.Status != entity.ExecStatusSuspended {
+			continue
+		}
+		if nodeID != suspendedNodeID {
+			state.DeleteNodeState(nodeID)
+			continue
+		}
+		state.UpdateNodeState(nodeID, func(ns *repository.NodeState) {
+			ns.Status = entity.ExecStatusCompleted
+			ns.Output = confirmParams
+			ns.EndTime = &now
+		})
+	}
+}
+
+// Cancel 取消租户的执行：运行中的执行中断正在执行的节点，暂停中的执行直接结束
+func (e *ChainExecutor) Cancel(ctx context.Context, executeID string, tenantID int64) error {
+	state, err := e.loadTenantState(ctx, executeID, tenantID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return apierrors.NotFound("执行记录不存在")
+	}
+
+	// 记录被中断的节点
+	var interrupted []string
+	for _, ns := range state.SnapshotNodeStates() {
+		if ns.Status == entity.ExecStatusRunning || ns.Status == entity.ExecStatusSuspended {
+			interrupted = append(interrupted, ns.NodeName)
+		}
+	}
+	sort.Strings(interrupted)
+
+	cause := errExecutionCancelled
+	if len(interrupted) > 0 {
+		cause = fmt.Errorf("%w，中断节点: %s", errExecutionCancelled, strings.Join(interrupted, ", "))
+	}
+	if !state.Cancel(cause) {
+		return fmt.Errorf("工作流已结束，无法取消")
+	}
+
+	// 先更新状态再中断调度，避免节点中断被记录为失败
+	handle, running := e.runs.Load(executeID)
+	if running {
+		handle.(*runHandle).cancel(errExecutionCancelled)
+	}
+
+	e.recordCancelled(ctx, state, cause)
+	// 调度中的执行由调度结束时推送结束事件并移除执行状态
+	if !running {
+		e.publishDone(state)
+		e.evictState(state)
+	}
+	return nil
+}
+
+// recordCancelled 将执行记录更新为已取消
+func (e *ChainExecutor) recordCancelled(ctx context.Context, state *repository.ChainState, cause error) {
+	if state.Scoped {
+		return
+	}
+
+	execResult, _ := e.execRepo.GetExecResultByExecKey(ctx, state.ExecuteID)
+	if execResult != nil {
+		now := time.Now()
+		execResult.Status = entity.ExecStatusCancelled
+		execResult.ErrorInfo = cause.Error()
+		execResult.EndTime = &now
+		e.execRepo.UpdateExecResult(ctx, execResult)
+	}
+
+	e.checkpoint(ctx, state)
+}
+
+// runHandle 正在调度的执行
+type runHandle struct {
+	cancel context.CancelCauseFunc
+}
+
+// newRunContext 创建调度使用的 context：登记取消函数并应用工作流级超时
+// parent 为顶层执行时传入 context.Background()，子工作流传入父节点的 context 以继承取消
+// 返回的 release 需在调度结束后调用
+func (e *ChainExecutor) newRunContext(parent context.Context, state *repository.ChainState, definition *dto.WorkflowDefinition) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(parent)
+	handle := &runHandle{cancel: cancel}
+	e.runs.Store(state.ExecuteID, handle)
+
+	stopTimeout := func() bool { return false }
+	if definition.Timeout > 0 {
+		timer := time.AfterFunc(time.Duration(definition.Timeout)*time.Second, func() {
+			cancel(errWorkflowTimeout)
+		})
+		stopTimeout = timer.Stop
+	}
+
+	release := func() {
+		stopTimeout()
+		// 恢复执行可能已登记新的调度，只移除自己
+		e.runs.CompareAndDelete(state.ExecuteID, handle)
+		cancel(nil)
+		e.evictState(state)
+	}
+	return ctx, release
+}
+
+// evictState 执行结束后从内存移除执行状态，之后的查询从检查点加载；暂停中的执行保留以便恢复
+func (e *ChainExecutor) evictState(state *repository.ChainState) {
+	if executionFinished(state.GetStatus()) {
+		e.states.CompareAndDelete(state.ExecuteID, state)
+	}
+}
+
+// executionFinished 执行是否已结束 (完成、失败或取消)
+func executionFinished(status entity.WorkflowExecStatus) bool {
+	return status == entity.ExecStatusCompleted || status == entity.ExecStatusFailed || status == entity.ExecStatusCancelled
+}
+
+// nodeTimeout 节点超时配置 (data.timeout，单位秒)
+func nodeTimeout(node *dto.WorkflowNode) time.Duration {
+	if node.Data == nil {
+		return 0
+	}
+	if seconds := getIntFromMap(node.Data, "timeout"); seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
 // ExecuteNode 执行单个节点 (用于调试)
 func (e *ChainExecutor) ExecuteNode(ctx context.Context, workflowID, nodeID string, variables map[string]interface{}) (map[string]interface{}, error) {
 	// 解析工作流 ID
@@ -586,8 +976,129 @@ func (e *ChainExecutor) ExecuteNode(ctx context.Context, workflowID, nodeID stri
 	return executor.Execute(ctx, state, node)
 }
 
+// ========================== 重启恢复 ==========================
+
+// RecoverExecutions 服务启动时处理上次遗留的执行中记录
+// 有检查点的执行重新挂载并从中断处继续 (中断时正在执行的节点会重新执行)，无法恢复的直接标记失败
+func (e *ChainExecutor) RecoverExecutions(ctx context.Context) error {
+	results, err := e.execRepo.ListExecResultsByStatus(ctx, entity.ExecStatusRunning)
+	if err != nil {
+		return fmt.Errorf("加载执行中记录失败: %w", err)
+	}
+
+	for _, execResult := range results {
+		e.closeInterruptedSteps(ctx, execResult.ExecKey)
+
+		// 子工作流由父执行重新执行对应节点，不单独恢复
+		if execResult.ParentExecKey != "" {
+			e.failInterrupted(ctx, execResult, fmt.Errorf("子工作流随父执行 %s 重新执行", execResult.ParentExecKey))
+			continue
+		}
+
+		if err := e.reattach(ctx, execResult); err != nil {
+			logger.Warn("Workflow execution cannot be recovered",
+				zap.String("execute_id", execResult.ExecKey),
+				zap.Error(err),
+			)
+			e.failInterrupted(ctx, execResult, err)
+			continue
+		}
+		logger.Info("Workflow execution re-attached", zap.String("execute_id", execResult.ExecKey))
+	}
+
+	return nil
+}
+
+// reattach 从检查点恢复执行状态并继续调度
+func (e *ChainExecutor) reattach(ctx context.Context, execResult *entity.WorkflowExecResult) error {
+	state, err := e.execRepo.GetChainState(ctx, execResult.ExecKey)
+	if err != nil {
+		return fmt.Errorf("加载执行状态失败: %w", err)
+	}
+	if state == nil {
+		return fmt.Errorf("没有可恢复的执行状态")
+	}
+
+	// 使用执行时的工作流配置快照，避免工作流修改后状态不一致
+	definition, err := e.parser.Parse(execResult.WorkflowJSON)
+	if err != nil {
+		return fmt.Errorf("解析工作流定义失败: %w", err)
+	}
+	startNode := e.parser.GetStartNode(definition)
+	if startNode == nil {
+		return fmt.Errorf("工作流没有开始节点")
+	}
+
+	// 中断时正在执行的节点清除状态以便重新执行
+	for nodeID, ns := range state.SnapshotNodeStates() {
+		if ns.Status == entity.ExecStatusRunning {
+			state.DeleteNodeState(nodeID)
+		}
+	}
+	state.SetStatus(entity.ExecStatusRunning)
+	e.states.Store(state.ExecuteID, state)
+	e.checkpoint(ctx, state)
+
+	runCtx, release := e.newRunContext(context.Background(), state, definition)
+	go func() {
+		defer release()
+		run := e.newChainRun(runCtx, state, definition)
+		if _, started := state.GetNodeState(startNode.ID); started {
+			run.resume()
+		} else {
+			run.start(startNode)
+		}
+		run.wait()
+	}()
+
+	return nil
+}
+
+// closeInterruptedSteps 将重启时仍处于执行中的步骤标记为失败
+func (e *ChainExecutor) closeInterruptedSteps(ctx context.Context, executeID string) {
+	steps, err := e.execRepo.GetExecStepsByExecKey(ctx, executeID)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, step := range steps {
+		if step.Status != entity.ExecStatusRunning {
+			continue
+		}
+		step.Status = entity.ExecStatusFailed
+		step.ErrorInfo = "服务重启，节点执行中断"
+		step.EndTime = &now
+		e.execRepo.UpdateExecStep(ctx, step)
+	}
+}
+
+// failInterrupted 将无法恢复的执行标记为失败
+func (e *ChainExecutor) failInterrupted(ctx context.Context, execResult *entity.WorkflowExecResult, cause error) {
+	state, _ := e.execRepo.GetChainState(ctx, execResult.ExecKey)
+	if state == nil {
+		state = &repository.ChainState{
+			ExecuteID:  execResult.ExecKey,
+			WorkflowID: execResult.WorkflowID,
+			RecordID:   execResult.ID,
+			Status:     entity.ExecStatusRunning,
+			Variables:  make(map[string]interface{}),
+			NodeStates: make(map[string]*repository.NodeState),
+			CreatedAt:  execResult.StartTime,
+			UpdatedAt:  time.Now(),
+		}
+	}
+	e.handleError(ctx, state, fmt.Errorf("服务重启，执行中断: %v", cause))
+}
+
 // ========================== 错误类型 ==========================
 
+var (
+	errExecutionCancelled = errors.New("执行已取消")
+	errWorkflowTimeout    = errors.New("工作流执行超时")
+	errNodeTimeout        = errors.New("节点执行超时")
+)
+
 // SuspendError 暂停错误 (用于人工确认节点)
 type SuspendError struct {
 	Params []*repository.SuspendedParam
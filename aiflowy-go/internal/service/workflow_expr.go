@@ -0,0 +1,1075 @@
+package service
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// ========================== 条件表达式 ==========================
+//
+// 条件节点与边条件使用的表达式语言，只做求值，不支持函数调用、赋值与循环：
+//
+//	${score} > 0.8 && ${lang} == "zh"
+//	${llm.output.items[0].name} contains "发票"
+//	${tag} in ["a", "b"] || !(${email} matches "^.+@example\\.com$")
+//	${user} != null
+//
+// 未加引号的单词视为字符串字面量，兼容旧的 ${var} == value 写法
+
+// 表达式限制，防止恶意或失控的表达式
+const (
+	maxExprLength  = 4096
+	maxExprDepth   = 64
+	maxRegexLength = 1024
+
+	maxCachedExprs   = 1024
+	maxCachedRegexes = 256
+)
+
+// ExprError 表达式解析或求值错误
+type ExprError struct {
+	Pos int
+	Msg string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("位置 %d: %s", e.Pos, e.Msg)
+}
+
+// Expression 编译后的表达式
+type Expression struct {
+	source string
+	root   exprNode
+}
+
+// exprCache 已编译表达式缓存 (表达式文本 -> *Expression)
+var exprCache = newLRUCache[*Expression](maxCachedExprs)
+
+// CompileExpression 编译表达式
+func CompileExpression(source string) (*Expression, error) {
+	if cached, ok := exprCache.get(source); ok {
+		return cached, nil
+	}
+	if len(source) > maxExprLength {
+		return nil, &ExprError{Pos: 0, Msg: fmt.Sprintf("表达式长度超过 %d", maxExprLength)}
+	}
+
+	tokens, err := lexExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &ExprError{Pos: tok.pos, Msg: fmt.Sprintf("无法识别的内容: %s", tok.text)}
+	}
+
+	expr := &Expression{source: source, root: root}
+	exprCache.put(source, expr)
+	return expr, nil
+}
+
+// Eval 计算表达式的值
+func (x *Expression) Eval(variables map[string]interface{}) (interface{}, error) {
+	return x.root.eval(variables)
+}
+
+// EvalBool 计算表达式并转换为布尔值
+func (x *Expression) EvalBool(variables map[string]interface{}) (bool, error) {
+	v, err := x.root.eval(variables)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// ========================== 词法分析 ==========================
+
+type exprTokenKind int
+
+const (
+	tokEOF    exprTokenKind = iota
+	tokNumber               // 数字
+	tokString               // 带引号的字符串
+	tokWord                 // 关键字或不带引号的单词
+	tokVar                  // ${path}
+	tokOp                   // 运算符与括号
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+// lexExpression 将表达式拆分为词法单元
+func lexExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(src)
+	i := 0
+
+	for i < len(runes) {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case c == '$' && i+1 < len(runes) && runes[i+1] == '{':
+			end := i + 2
+			for end < len(runes) && runes[end] != '}' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, &ExprError{Pos: i, Msg: "变量引用缺少 }"}
+			}
+			path := strings.TrimSpace(string(runes[i+2 : end]))
+			if path == "" {
+				return nil, &ExprError{Pos: i, Msg: "变量引用为空"}
+			}
+			if _, err := parsePath(path); err != nil {
+				return nil, &ExprError{Pos: i, Msg: err.Error()}
+			}
+			tokens = append(tokens, exprToken{kind: tokVar, text: path, pos: i})
+			i = end + 1
+
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			start := i
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					switch runes[i+1] {
+					case 'n':
+						sb.WriteRune('\n')
+					case 't':
+						sb.WriteRune('\t')
+					default:
+						sb.WriteRune(runes[i+1])
+					}
+					i += 2
+					continue
+				}
+				if runes[i] == c {
+					closed = true
+					i++
+					break
+				}
+				sb.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, &ExprError{Pos: start, Msg: "字符串缺少结束引号"}
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: sb.String(), pos: start})
+
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))) {
+				i++
+			}
+			text := string(runes[start:i])
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, &ExprError{Pos: start, Msg: fmt.Sprintf("无效的数字: %s", text)}
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: text, num: num, pos: start})
+
+		case isWordRune(c):
+			start := i
+			for i < len(runes) && (isWordRune(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokWord, text: string(runes[start:i]), pos: start})
+
+		default:
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case "==", "!=", ">=", "<=", "&&", "||", "=~":
+					tokens = append(tokens, exprToken{kind: tokOp, text: two, pos: i})
+					i += 2
+					continue
+				}
+			}
+			if strings.ContainsRune("><!()[],+-*/%", c) {
+				tokens = append(tokens, exprToken{kind: tokOp, text: string(c), pos: i})
+				i++
+				continue
+			}
+			return nil, &ExprError{Pos: i, Msg: fmt.Sprintf("无法识别的字符: %c", c)}
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokEOF, pos: len(runes)}), nil
+}
+
+// isWordRune 单词首字符 (字母、下划线或中文等)
+func isWordRune(c rune) bool {
+	return unicode.IsLetter(c) || c == '_'
+}
+
+// ========================== 语法分析 ==========================
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	depth  int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOp 当前词法单元是否为指定运算符或关键字
+func (p *exprParser) isOp(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokOp && tok.kind != tokWord {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.kind == tokOp && tok.text == op {
+			return op, true
+		}
+		if tok.kind == tokWord && strings.EqualFold(tok.text, op) {
+			return strings.ToLower(op), true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) enter() error {
+	p.depth++
+	if p.depth > maxExprDepth {
+		return &ExprError{Pos: p.peek().pos, Msg: "表达式嵌套过深"}
+	}
+	return nil
+}
+
+func (p *exprParser) leave() {
+	p.depth--
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("||", "or"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "||", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp("&&", "and"); !ok {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "&&", left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if _, ok := p.isOp("!", "not"); ok {
+		p.next()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	tok := p.peek()
+	op, ok := p.isOp("==", "!=", ">=", "<=", ">", "<", "=~", "in", "contains", "matches", "not")
+	if !ok {
+		return left, nil
+	}
+	p.next()
+
+	// not in
+	if op == "not" {
+		if _, ok := p.isOp("in"); !ok {
+			return nil, &ExprError{Pos: tok.pos, Msg: "not 之后应为 in"}
+		}
+		p.next()
+		op = "not in"
+	}
+	if op == "=~" {
+		op = "matches"
+	}
+
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &compareNode{op: op, left: left, right: right, pos: tok.pos}
+	// 字面量正则在编译期检查
+	if op == "matches" {
+		if lit, ok := right.(*literalNode); ok {
+			pattern, _ := lit.value.(string)
+			re, err := compileRegex(pattern)
+			if err != nil {
+				return nil, &ExprError{Pos: tok.pos, Msg: err.Error()}
+			}
+			node.regex = re
+		}
+	}
+	return node, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		op, ok := p.isOp("+", "-")
+		if !ok || tok.kind != tokOp {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		op, ok := p.isOp("*", "/", "%")
+		if !ok || tok.kind != tokOp {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: op, left: left, right: right, pos: tok.pos}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok := p.peek()
+	if tok.kind == tokOp && tok.text == "-" {
+		p.next()
+		if err := p.enter(); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &literalNode{value: float64(0)}, right: x, pos: tok.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokNumber:
+		return &literalNode{value: tok.num}, nil
+	case tokString:
+		return &literalNode{value: tok.text}, nil
+	case tokVar:
+		path, _ := parsePath(tok.text)
+		return &varNode{path: path}, nil
+	case tokWord:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			return &literalNode{value: nil}, nil
+		case "and", "or", "not", "in", "contains", "matches":
+			return nil, &ExprError{Pos: tok.pos, Msg: fmt.Sprintf("%s 缺少左侧操作数", tok.text)}
+		}
+		return &literalNode{value: tok.text}, nil
+	case tokOp:
+		switch tok.text {
+		case "(":
+			if err := p.enter(); err != nil {
+				return nil, err
+			}
+			defer p.leave()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if closing := p.next(); closing.kind != tokOp || closing.text != ")" {
+				return nil, &ExprError{Pos: closing.pos, Msg: "缺少 )"}
+			}
+			return x, nil
+		case "[":
+			return p.parseList(tok)
+		}
+		return nil, &ExprError{Pos: tok.pos, Msg: fmt.Sprintf("意外的运算符: %s", tok.text)}
+	}
+	return nil, &ExprError{Pos: tok.pos, Msg: "表达式不完整"}
+}
+
+func (p *exprParser) parseList(open exprToken) (exprNode, error) {
+	list := &listNode{}
+	if tok := p.peek(); tok.kind == tokOp && tok.text == "]" {
+		p.next()
+		return list, nil
+	}
+	for {
+		item, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+
+		tok := p.next()
+		if tok.kind == tokOp && tok.text == "]" {
+			return list, nil
+		}
+		if tok.kind != tokOp || tok.text != "," {
+			return nil, &ExprError{Pos: open.pos, Msg: "列表缺少 ]"}
+		}
+	}
+}
+
+// ========================== 语法树与求值 ==========================
+
+type exprNode interface {
+	eval(variables map[string]interface{}) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(map[string]interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+type varNode struct {
+	path []pathSegment
+}
+
+func (n *varNode) eval(variables map[string]interface{}) (interface{}, error) {
+	// 不存在的变量视为 null
+	v, _ := lookupSegments(variables, n.path)
+	return v, nil
+}
+
+type listNode struct {
+	items []exprNode
+}
+
+func (n *listNode) eval(variables map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(variables)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type notNode struct {
+	x exprNode
+}
+
+func (n *notNode) eval(variables map[string]interface{}) (interface{}, error) {
+	v, err := n.x.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(variables map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	// 短路求值
+	if n.op == "&&" && !truthy(l) {
+		return false, nil
+	}
+	if n.op == "||" && truthy(l) {
+		return true, nil
+	}
+	r, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(r), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+	regex       *regexp.Regexp
+	pos         int
+}
+
+func (n *compareNode) eval(variables map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return looseEqual(l, r), nil
+	case "!=":
+		return !looseEqual(l, r), nil
+	case ">", ">=", "<", "<=":
+		if l == nil || r == nil {
+			return false, nil
+		}
+		cmp, ok := compareValues(l, r)
+		if !ok {
+			return nil, &ExprError{Pos: n.pos, Msg: fmt.Sprintf("无法比较 %s 与 %s", valueTypeName(l), valueTypeName(r))}
+		}
+		switch n.op {
+		case ">":
+			return cmp > 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		case "<":
+			return cmp < 0, nil
+		default:
+			return cmp <= 0, nil
+		}
+	case "in":
+		return containsValue(r, l), nil
+	case "not in":
+		return !containsValue(r, l), nil
+	case "contains":
+		return containsValue(l, r), nil
+	case "matches":
+		if l == nil {
+			return false, nil
+		}
+		re := n.regex
+		if re == nil {
+			re, err = compileRegex(stringify(r))
+			if err != nil {
+				return nil, &ExprError{Pos: n.pos, Msg: err.Error()}
+			}
+		}
+		return re.MatchString(stringify(l)), nil
+	}
+	return nil, &ExprError{Pos: n.pos, Msg: fmt.Sprintf("未知的运算符: %s", n.op)}
+}
+
+type arithNode struct {
+	op          string
+	left, right exprNode
+	pos         int
+}
+
+func (n *arithNode) eval(variables map[string]interface{}) (interface{}, error) {
+	l, err := n.left.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	lf, lok := toFloat(l)
+	rf, rok := toFloat(r)
+	if !lok || !rok {
+		// + 用于字符串拼接
+		if n.op == "+" {
+			return stringify(l) + stringify(r), nil
+		}
+		return nil, &ExprError{Pos: n.pos, Msg: fmt.Sprintf("%s 只能用于数字", n.op)}
+	}
+
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, &ExprError{Pos: n.pos, Msg: "除数为 0"}
+		}
+		return lf / rf, nil
+	default:
+		if rf == 0 {
+			return nil, &ExprError{Pos: n.pos, Msg: "除数为 0"}
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+// regexCache 已编译的正则 (字符串 -> *regexp.Regexp)。字面量正则保存在编译后的表达式中，
+// 这里缓存的是运行时变量给出的正则，因此限制数量
+var regexCache = newLRUCache[*regexp.Regexp](maxCachedRegexes)
+
+// compileRegex 编译正则 (RE2 语法，匹配时间与输入长度线性相关)
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxRegexLength {
+		return nil, fmt.Errorf("正则长度超过 %d", maxRegexLength)
+	}
+	if cached, ok := regexCache.get(pattern); ok {
+		return cached, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("无效的正则: %v", err)
+	}
+	regexCache.put(pattern, re)
+	return re, nil
+}
+
+// lruCache 有容量上限的缓存，超出时淘汰最久未使用的项
+type lruCache[V any] struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // 最近使用的在前
+	items    map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRUCache[V any](capacity int) *lruCache[V] {
+	return &lruCache[V]{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		return elem.Value.(*lruEntry[V]).value, true
+	}
+	var zero V
+	return zero, false
+}
+
+func (c *lruCache[V]) put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruEntry[V]).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lruCache[V]) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// ========================== 值运算 ==========================
+
+// truthy 值的真假判断
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		s := strings.TrimSpace(x)
+		return s != "" && s != "false" && s != "0"
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return rv.Len() > 0
+	}
+	return true
+}
+
+// toFloat 转换为数字，数字字符串同样视为数字
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case float32:
+		return float64(x), true
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// looseEqual 宽松相等：数字与数字字符串、布尔与 "true"/"false" 可以相等
+func looseEqual(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if ab, ok := a.(bool); ok {
+		return ab == truthy(b) && isBoolLike(b)
+	}
+	if bb, ok := b.(bool); ok {
+		return bb == truthy(a) && isBoolLike(a)
+	}
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			return af == bf
+		}
+	}
+	switch a.(type) {
+	case []interface{}, map[string]interface{}:
+		return reflect.DeepEqual(a, b)
+	}
+	return stringify(a) == stringify(b)
+}
+
+// isBoolLike 是否可以和布尔值比较
+func isBoolLike(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return true
+	case string:
+		s := strings.ToLower(strings.TrimSpace(x))
+		return s == "true" || s == "false"
+	}
+	return false
+}
+
+// compareValues 比较大小：数字按数值，字符串按字典序
+func compareValues(a, b interface{}) (int, bool) {
+	if af, ok := toFloat(a); ok {
+		if bf, ok := toFloat(b); ok {
+			switch {
+			case af < bf:
+				return -1, true
+			case af > bf:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	as, aok := a.(string)
+	bs, bok := b.(string)
+	if aok && bok {
+		return strings.Compare(as, bs), true
+	}
+	return 0, false
+}
+
+// containsValue 容器是否包含元素：字符串子串、数组元素或对象的键
+func containsValue(container, item interface{}) bool {
+	switch c := container.(type) {
+	case nil:
+		return false
+	case string:
+		if item == nil {
+			return false
+		}
+		return strings.Contains(c, stringify(item))
+	case []interface{}:
+		for _, v := range c {
+			if looseEqual(v, item) {
+				return true
+			}
+		}
+		return false
+	case map[string]interface{}:
+		_, ok := c[stringify(item)]
+		return ok
+	}
+
+	rv := reflect.ValueOf(container)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if looseEqual(rv.Index(i).Interface(), item) {
+				return true
+			}
+		}
+	case reflect.Map:
+		for _, key := range rv.MapKeys() {
+			if stringify(key.Interface()) == stringify(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringify 转换为字符串，对象与数组使用 JSON
+func stringify(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(x)
+	case []interface{}, map[string]interface{}:
+		data, err := json.Marshal(x)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// valueTypeName 值类型名称 (用于错误信息)
+func valueTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	if _, ok := toFloat(v); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// ========================== 变量路径 ==========================
+
+// pathSegment 变量路径中的一段：对象字段或数组下标
+type pathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath 解析变量路径，如 llm.output.items[0].name 或 data["key"]
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	i := 0
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("变量路径缺少 ]: %s", path)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+			if len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("无效的数组下标: %s", inner)
+			}
+			segments = append(segments, pathSegment{index: idx, isIndex: true})
+		default:
+			start := i
+			for i < len(path) && path[i] != '.' && path[i] != '[' {
+				i++
+			}
+			segments = append(segments, pathSegment{key: strings.TrimSpace(path[start:i])})
+		}
+	}
+	if len(segments) == 0 || segments[0].isIndex || segments[0].key == "" {
+		return nil, fmt.Errorf("无效的变量路径: %s", path)
+	}
+	return segments, nil
+}
+
+// lookupPath 按路径查找变量，不存在时返回 false
+func lookupPath(variables map[string]interface{}, path string) (interface{}, bool) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return lookupSegments(variables, segments)
+}
+
+// lookupSegments 按路径段查找变量
+// 变量名本身可能包含点号，优先匹配最长的变量名前缀
+func lookupSegments(variables map[string]interface{}, segments []pathSegment) (interface{}, bool) {
+	prefix := 0
+	for prefix < len(segments) && !segments[prefix].isIndex {
+		prefix++
+	}
+
+	for n := prefix; n >= 1; n-- {
+		keys := make([]string, n)
+		for i := 0; i < n; i++ {
+			keys[i] = segments[i].key
+		}
+		current, ok := variables[strings.Join(keys, ".")]
+		if !ok {
+			continue
+		}
+		for _, seg := range segments[n:] {
+			current, ok = walkSegment(current, seg)
+			if !ok {
+				return nil, false
+			}
+		}
+		return current, true
+	}
+	return nil, false
+}
+
+// walkSegment 进入对象字段或数组元素；JSON 字符串会先解析
+func walkSegment(v interface{}, seg pathSegment) (interface{}, bool) {
+	if s, ok := v.(string); ok {
+		trimmed := strings.TrimSpace(s)
+		if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+			return nil, false
+		}
+		if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+			return nil, false
+		}
+	}
+
+	switch c := v.(type) {
+	case map[string]interface{}:
+		if seg.isIndex {
+			val, ok := c[strconv.Itoa(seg.index)]
+			return val, ok
+		}
+		val, ok := c[seg.key]
+		return val, ok
+	case []interface{}:
+		if !seg.isIndex || seg.index >= len(c) {
+			return nil, false
+		}
+		return c[seg.index], true
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || seg.isIndex {
+			return nil, false
+		}
+		val := rv.MapIndex(reflect.ValueOf(seg.key).Convert(rv.Type().Key()))
+		if !val.IsValid() {
+			return nil, false
+		}
+		return val.Interface(), true
+	case reflect.Slice, reflect.Array:
+		if !seg.isIndex || seg.index >= rv.Len() {
+			return nil, false
+		}
+		return rv.Index(seg.index).Interface(), true
+	}
+	return nil, false
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExpression_Eval(t *testing.T) {
+	variables := map[string]interface{}{
+		"score": 0.92,
+		"count": "3",
+		"lang":  "zh",
+		"flag":  true,
+		"tags":  []interface{}{"a", "b"},
+		"email": "bob@example.com",
+		"llm": map[string]interface{}{
+			"output": map[string]interface{}{
+				"items": []interface{}{
+					map[string]interface{}{"name": "invoice"},
+				},
+			},
+		},
+		"raw":        `{"items":[{"name":"json"}]}`,
+		"node.field": "flat",
+	}
+
+	tests := []struct {
+		expr   string
+		expect bool
+	}{
+		{`${score} > 0.8 && ${lang} == "zh"`, true},
+		{`${score} > 0.95 || ${lang} == "en"`, false},
+		{`${count} == 3`, true},
+		{`${count} >= 2 and ${count} < 4`, true},
+		{`${count} + 1 == 4`, true},
+		{`${lang} == zh`, true},
+		{`${flag}`, true},
+		{`!${flag}`, false},
+		{`${flag} == "true"`, true},
+		{`"b" in ${tags}`, true},
+		{`"c" not in ${tags}`, true},
+		{`${lang} in ["en", "zh"]`, true},
+		{`${tags} contains "a"`, true},
+		{`${email} contains "@"`, true},
+		{`${email} matches "^.+@example\\.com$"`, true},
+		{`${email} =~ "^admin@"`, false},
+		{`${missing} == null`, true},
+		{`${missing} != null`, false},
+		{`${lang} != null`, true},
+		{`${llm.output.items[0].name} == "invoice"`, true},
+		{`${llm.output.items[1].name} == null`, true},
+		{`${raw.items[0].name} == "json"`, true},
+		{`${node.field} == "flat"`, true},
+		{`(${score} > 1 || ${flag}) && not (${lang} == "en")`, true},
+		{`true`, true},
+		{`1`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := CompileExpression(tt.expr)
+			if err != nil {
+				t.Fatalf("compile failed: %v", err)
+			}
+			got, err := expr.EvalBool(variables)
+			if err != nil {
+				t.Fatalf("eval failed: %v", err)
+			}
+			if got != tt.expect {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestExpression_ParseErrors(t *testing.T) {
+	tests := []string{
+		`${score} >`,
+		`${score > 1`,
+		`"unterminated`,
+		`(${a} == 1`,
+		`${a} matches "("`,
+		`&& ${a}`,
+		`${a} == 1 2`,
+		strings.Repeat("(", maxExprDepth+1) + "1" + strings.Repeat(")", maxExprDepth+1),
+	}
+
+	for _, src := range tests {
+		if _, err := CompileExpression(src); err == nil {
+			t.Errorf("expected parse error for %q", src)
+		}
+	}
+}
+
+func TestExpression_TypeMismatchIsFalse(t *testing.T) {
+	if evaluateCondition(`${items} > 3`, map[string]interface{}{"items": []interface{}{1}}) {
+		t.Error("expected comparison between array and number to be false")
+	}
+}
+
+func TestExpression_DynamicRegexCacheIsBounded(t *testing.T) {
+	expr, err := CompileExpression(`${text} matches ${pattern}`)
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	for i := 0; i < maxCachedRegexes*2; i++ {
+		pattern := fmt.Sprintf("^item-%d$", i)
+		ok, err := expr.EvalBool(map[string]interface{}{"text": fmt.Sprintf("item-%d", i), "pattern": pattern})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected match, got %v %v", pattern, ok, err)
+		}
+	}
+	if n := regexCache.len(); n > maxCachedRegexes {
+		t.Errorf("regex cache grew to %d entries", n)
+	}
+}
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLRUCache[int](2)
+	c.put("a", 1)
+	c.put("b", 2)
+	c.get("a")
+	c.put("c", 3)
+	if _, ok := c.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	if v, ok := c.get("a"); !ok || v != 1 {
+		t.Errorf("expected a to stay cached, got %v %v", v, ok)
+	}
+}
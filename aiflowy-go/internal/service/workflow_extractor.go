@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/internal/service/llm"
+)
+
+// 参数提取节点的保留输出，参数不能使用这些名称
+var extractorReservedOutputs = map[string]bool{
+	"condition": true,
+	"missing":   true,
+}
+
+// ========================== ExtractorNodeExecutor ==========================
+
+// ExtractorNodeExecutor 参数提取节点执行器
+//
+//	{"modelId": "1", "query": "${start.message}", "instruction": "日期统一为 YYYY-MM-DD",
+//	 "parameters": [{"name": "city", "type": "string", "description": "出发城市", "required": true},
+//	                {"name": "days", "type": "integer", "description": "出行天数", "defaultValue": 1}],
+//	 "outputVariable": "params"}
+//
+// 每个参数按声明的类型作为独立的输出变量，未提取到时使用 defaultValue 或 null；
+// missing 为未提取到的必填参数，condition 为 complete 或 missing，
+// 出边的 condition 或 sourcePort 可按此分支，例如缺少参数时追问用户
+type ExtractorNodeExecutor struct {
+	llm *LLMNodeExecutor
+}
+
+// NewExtractorNodeExecutor 创建参数提取节点执行器
+func NewExtractorNodeExecutor() *ExtractorNodeExecutor {
+	return &ExtractorNodeExecutor{
+		llm: NewLLMNodeExecutor(),
+	}
+}
+
+// Execute 执行参数提取节点
+func (e *ExtractorNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("参数提取节点缺少配置")
+	}
+
+	modelID, err := llmModelID(node.Data, "参数提取节点")
+	if err != nil {
+		return nil, err
+	}
+	params, err := parseExtractorParameters(node.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	variables := state.SnapshotVariables()
+	query := strings.TrimSpace(templateValueString(resolveValue(node.Data["query"], variables)))
+	if query == "" {
+		return nil, fmt.Errorf("参数提取节点的输入内容为空")
+	}
+	instruction := resolveTemplateString(getStringFromMap(node.Data, "instruction"), variables)
+
+	req := &llm.ChatRequest{
+		ModelID: modelID,
+		Messages: []llm.Message{
+			{Role: "system", Content: extractorPrompt(params, instruction)},
+			{Role: "user", Content: query},
+		},
+	}
+	result, err := e.llm.generateStructured(ctx, req, &llmOutputSchema{
+		Schema:     extractorSchema(params),
+		Mode:       llm.OutputModeAuto,
+		MaxRetries: defaultStructuredRetries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	outputVar := getStringFromMap(node.Data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "params"
+	}
+	return extractorOutputs(params, result, outputVar), nil
+}
+
+// parseExtractorParameters 解析要提取的参数，类型默认为 string，不支持文件类型
+func parseExtractorParameters(data map[string]interface{}) ([]*dto.WorkflowParameter, error) {
+	items, _ := data["parameters"].([]interface{})
+	params := make([]*dto.WorkflowParameter, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		param := &dto.WorkflowParameter{
+			Name:         strings.TrimSpace(getStringFromMap(m, "name")),
+			Type:         "string",
+			Description:  getStringFromMap(m, "description"),
+			Required:     getBoolFromMap(m, "required"),
+			DefaultValue: m["defaultValue"],
+		}
+		switch {
+		case param.Name == "":
+			return nil, fmt.Errorf("参数名称不能为空")
+		case extractorReservedOutputs[param.Name]:
+			return nil, fmt.Errorf("参数名称 %s 为保留的输出名称", param.Name)
+		case seen[param.Name]:
+			return nil, fmt.Errorf("参数名称重复: %s", param.Name)
+		}
+		if paramType := getStringFromMap(m, "type"); paramType != "" {
+			param.Type = normalizeParamType(paramType)
+			if param.Type == "" || param.Type == "file" {
+				return nil, fmt.Errorf("参数 %s 的类型不支持: %s", param.Name, paramType)
+			}
+		}
+		seen[param.Name] = true
+		params = append(params, param)
+	}
+	if len(params) == 0 {
+		return nil, fmt.Errorf("参数提取节点未配置参数")
+	}
+	return params, nil
+}
+
+// extractorSchema 提取结果的输出结构：所有参数都可以为 null，必填校验在提取后进行
+func extractorSchema(params []*dto.WorkflowParameter) map[string]interface{} {
+	properties := make(map[string]interface{}, len(params))
+	for _, param := range params {
+		property := map[string]interface{}{"type": param.Type}
+		if param.Description != "" {
+			property["description"] = param.Description
+		}
+		properties[param.Name] = property
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   []interface{}{},
+	}
+}
+
+// extractorPrompt 参数提取的系统提示词
+func extractorPrompt(params []*dto.WorkflowParameter, instruction string) string {
+	var b strings.Builder
+	b.WriteString("请从用户输入中提取以下参数：\n")
+	for _, param := range params {
+		fmt.Fprintf(&b, "- %s (%s", param.Name, param.Type)
+		if param.Required {
+			b.WriteString(", 必填")
+		}
+		b.WriteString(")")
+		if param.Description != "" {
+			b.WriteString(": ")
+			b.WriteString(param.Description)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("只提取输入中明确给出或可以直接推断的值，无法确定的参数填 null，不要编造。")
+	if instruction = strings.TrimSpace(instruction); instruction != "" {
+		b.WriteString("\n\n")
+		b.WriteString(instruction)
+	}
+	return b.String()
+}
+
+// extractorOutputs 节点输出：按类型转换的参数、缺少的必填参数与分支 condition
+// 空字符串或无法转换为声明类型的值视为未提取到
+func extractorOutputs(params []*dto.WorkflowParameter, result map[string]interface{}, outputVar string) map[string]interface{} {
+	outputs := make(map[string]interface{}, len(params)+3)
+	values := make(map[string]interface{}, len(params))
+	missing := make([]interface{}, 0)
+	for _, param := range params {
+		value := result[param.Name]
+		if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+			value = nil
+		}
+		if value != nil {
+			coerced, err := coerceParamValue(param.Type, value)
+			if err != nil {
+				coerced = nil
+			}
+			value = coerced
+		}
+		if value == nil {
+			value = param.DefaultValue
+		}
+		if value == nil && param.Required {
+			missing = append(missing, param.Name)
+		}
+		outputs[param.Name] = value
+		values[param.Name] = value
+	}
+
+	condition := dto.ExtractorBranchComplete
+	if len(missing) > 0 {
+		condition = dto.ExtractorBranchMissing
+	}
+	outputs["missing"] = missing
+	outputs["condition"] = condition
+	outputs[outputVar] = values
+	return outputs
+}
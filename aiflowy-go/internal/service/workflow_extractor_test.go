@@ -0,0 +1,67 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+func TestParseExtractorParameters(t *testing.T) {
+	params, err := parseExtractorParameters(map[string]interface{}{
+		"parameters": []interface{}{
+			map[string]interface{}{"name": "city", "required": true},
+			map[string]interface{}{"name": "days", "type": "int", "defaultValue": 1.0},
+		},
+	})
+	if err != nil || len(params) != 2 {
+		t.Fatalf("unexpected parameters: %v %v", params, err)
+	}
+	if params[0].Type != "string" || !params[0].Required || params[1].Type != "integer" || params[1].Required {
+		t.Errorf("unexpected parameters: %+v %+v", params[0], params[1])
+	}
+
+	invalid := []map[string]interface{}{
+		{},
+		{"name": ""},
+		{"name": "missing"},
+		{"name": "file", "type": "file"},
+		{"name": "city", "type": "unknown"},
+	}
+	for _, param := range invalid {
+		if _, err := parseExtractorParameters(map[string]interface{}{"parameters": []interface{}{param}}); err == nil {
+			t.Errorf("expected error for parameter %v", param)
+		}
+	}
+}
+
+func TestExtractorOutputs(t *testing.T) {
+	params := []*dto.WorkflowParameter{
+		{Name: "city", Type: "string", Required: true},
+		{Name: "date", Type: "string", Required: true},
+		{Name: "days", Type: "integer", DefaultValue: 1.0},
+		{Name: "adults", Type: "integer"},
+	}
+
+	// nulls are allowed by the schema, required fields are checked afterwards
+	if errs := validateJSONSchema(map[string]interface{}{"city": "Paris", "date": nil}, extractorSchema(params), "$"); len(errs) > 0 {
+		t.Errorf("unexpected schema errors: %v", errs)
+	}
+
+	outputs := extractorOutputs(params, map[string]interface{}{"city": "Paris", "date": " ", "adults": 2.0}, "params")
+	if outputs["city"] != "Paris" || outputs["date"] != nil || outputs["days"] != 1.0 || outputs["adults"] != int64(2) {
+		t.Errorf("unexpected outputs: %v", outputs)
+	}
+	missing, _ := outputs["missing"].([]interface{})
+	if len(missing) != 1 || missing[0] != "date" || outputs["condition"] != dto.ExtractorBranchMissing {
+		t.Errorf("expected date to be missing, got %v %v", outputs["missing"], outputs["condition"])
+	}
+	values, _ := outputs["params"].(map[string]interface{})
+	if values["city"] != "Paris" || len(values) != len(params) {
+		t.Errorf("unexpected params object: %v", values)
+	}
+
+	outputs = extractorOutputs(params, map[string]interface{}{"city": "Paris", "date": "2026-05-01"}, "params")
+	if outputs["condition"] != dto.ExtractorBranchComplete {
+		t.Errorf("expected complete, got %v", outputs["condition"])
+	}
+}
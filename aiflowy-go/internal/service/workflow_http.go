@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+// HTTP 节点请求体类型
+const (
+	httpBodyNone = "none"
+	httpBodyJSON = "json"
+	httpBodyForm = "form"
+	httpBodyRaw  = "raw"
+)
+
+// 允许的请求方法
+var httpNodeMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+}
+
+// httpFixedOutputs 节点固定输出的字段，outputVariable 与 extract 不能使用
+var httpFixedOutputs = map[string]bool{
+	"statusCode": true,
+	"headers":    true,
+}
+
+// ========================== HTTPNodeExecutor ==========================
+
+// HTTPNodeExecutor HTTP 请求节点执行器
+//
+//	{"method": "POST", "url": "https://api.example.com/users/${start.userId}",
+//	 "headers": [{"name": "X-Trace", "value": "${traceId}"}], "query": [{"name": "page", "value": "1"}],
+//	 "bodyType": "json", "body": {"name": "${name}"},
+//	 "auth": {"type": "bearer", "token": "${token}"},
+//	 "extract": [{"name": "userId", "path": "$.data.id"}], "outputVariable": "body", "timeout": 30}
+//
+// bodyType 为 json / form / raw / none；auth.type 为 bearer / basic / apiKey。
+// 输出 statusCode、headers、响应体 (JSON 响应为解析后的值) 与 extract 提取的字段。
+// 出边的 sourcePort 为状态码 (如 404) 或状态码段 (如 2xx、default) 时按状态码分支，
+// 未配置状态码分支时 4xx / 5xx 视为节点失败，可配合重试与错误分支使用。
+// 请求只能发往公网地址，回环、私有与链路本地地址 (包括重定向的目标) 会被拒绝
+type HTTPNodeExecutor struct {
+	client *http.Client
+}
+
+// NewHTTPNodeExecutor 创建 HTTP 节点执行器
+func NewHTTPNodeExecutor() *HTTPNodeExecutor {
+	return &HTTPNodeExecutor{client: publicHTTPClient}
+}
+
+// Execute 执行 HTTP 节点，请求与响应记录到执行步骤的日志中
+func (e *HTTPNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("HTTP 节点缺少配置")
+	}
+
+	spec, err := buildHTTPNodeRequest(node.Data, state.SnapshotVariables())
+	if err != nil {
+		return nil, err
+	}
+	if seconds := getIntFromMap(node.Data, "timeout"); seconds > 0 {
+		spec.Timeout = time.Duration(min(seconds, int(maxHTTPTimeout/time.Second))) * time.Second
+	}
+
+	start := time.Now()
+	resp, err := doHTTPRequest(ctx, e.client, spec)
+	if step := execStepFromContext(ctx); step != nil {
+		step.Logs = formatHTTPExchange(spec, resp, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 未配置状态码分支时，错误状态码视为失败
+	if resp.StatusCode >= 400 && !hasStatusPorts(workflowDefinitionFromContext(ctx), loopBaseID(node.ID)) {
+		return nil, fmt.Errorf("HTTP 请求返回 %s", resp.Status)
+	}
+
+	return httpNodeOutput(node.Data, resp), nil
+}
+
+// buildHTTPNodeRequest 根据节点配置构建请求，配置中的 ${path} 引用按变量解析
+func buildHTTPNodeRequest(data map[string]interface{}, variables map[string]interface{}) (*httpRequestSpec, error) {
+	method := strings.ToUpper(getStringFromMap(data, "method"))
+	if method != "" && !httpNodeMethods[method] {
+		return nil, fmt.Errorf("不支持的请求方法: %s", method)
+	}
+	rawURL := strings.TrimSpace(resolveTemplateString(getStringFromMap(data, "url"), variables))
+	if rawURL == "" {
+		return nil, fmt.Errorf("HTTP 节点未配置 URL")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("无效的 URL: %s", rawURL)
+	}
+
+	spec := newHTTPRequestSpec(method, rawURL)
+	for name, value := range httpNameValues(data["headers"], variables) {
+		spec.Headers[name] = value
+	}
+	for name, value := range httpNameValues(data["query"], variables) {
+		spec.Query.Set(name, value)
+	}
+	if err := applyHTTPAuth(spec, data["auth"], variables); err != nil {
+		return nil, err
+	}
+
+	if !spec.allowsBody() {
+		return spec, nil
+	}
+	switch bodyType := getStringFromMap(data, "bodyType"); bodyType {
+	case "", httpBodyNone:
+	case httpBodyJSON:
+		switch body := data["body"].(type) {
+		case nil:
+		case string:
+			if strings.TrimSpace(body) != "" {
+				spec.setBody([]byte(resolveTemplateString(body, variables)), contentTypeJSON)
+			}
+		default:
+			if err := spec.setJSONBody(resolveValue(body, variables)); err != nil {
+				return nil, err
+			}
+		}
+	case httpBodyForm:
+		form := url.Values{}
+		for name, value := range httpNameValues(data["body"], variables) {
+			form.Set(name, value)
+		}
+		spec.setBody([]byte(form.Encode()), contentTypeForm)
+	case httpBodyRaw:
+		contentType := getStringFromMap(data, "contentType")
+		if contentType == "" {
+			contentType = "text/plain; charset=utf-8"
+		}
+		spec.setBody([]byte(resolveTemplateString(getStringFromMap(data, "body"), variables)), contentType)
+	default:
+		return nil, fmt.Errorf("不支持的请求体类型: %s", bodyType)
+	}
+	return spec, nil
+}
+
+// httpNameValues 解析请求头、查询参数与表单：支持 [{"name", "value"}] 列表或对象，值按变量解析
+func httpNameValues(config interface{}, variables map[string]interface{}) map[string]string {
+	values := make(map[string]string)
+	add := func(name string, value interface{}) {
+		if name == "" || value == nil {
+			return
+		}
+		values[name] = templateValueString(resolveValue(value, variables))
+	}
+
+	switch c := config.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if m, ok := item.(map[string]interface{}); ok {
+				if enabled, ok := m["enabled"].(bool); ok && !enabled {
+					continue
+				}
+				add(getStringFromMap(m, "name"), m["value"])
+			}
+		}
+	case map[string]interface{}:
+		for name, value := range c {
+			add(name, value)
+		}
+	}
+	return values
+}
+
+// applyHTTPAuth 设置认证：bearer 令牌、basic 用户名密码或 apiKey (请求头或查询参数)
+func applyHTTPAuth(spec *httpRequestSpec, config interface{}, variables map[string]interface{}) error {
+	auth, ok := config.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value := func(key string) string {
+		return resolveTemplateString(getStringFromMap(auth, key), variables)
+	}
+
+	switch authType := getStringFromMap(auth, "type"); authType {
+	case "", "none":
+	case authTypeBearer:
+		if token := value("token"); token != "" {
+			spec.Headers["Authorization"] = "Bearer " + token
+		}
+	case authTypeBasic:
+		credentials := value("username") + ":" + value("password")
+		spec.Headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	case authTypeAPIKey:
+		position := getStringFromMap(auth, "position")
+		if position == "" {
+			position = authPositionHeaders
+		}
+		spec.setAPIKey(position, value("key"), value("value"))
+	default:
+		return fmt.Errorf("不支持的认证方式: %s", authType)
+	}
+	return nil
+}
+
+// httpNodeOutput 节点输出：状态码、响应头、响应体与提取的字段
+func httpNodeOutput(data map[string]interface{}, resp *httpResponse) map[string]interface{} {
+	headers := make(map[string]interface{}, len(resp.Headers))
+	for name, values := range resp.Headers {
+		headers[name] = strings.Join(values, ", ")
+	}
+	body := resp.decodeBody()
+
+	outputVar := getStringFromMap(data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "body"
+	}
+	result := make(map[string]interface{})
+	for name, path := range httpExtractPaths(data["extract"]) {
+		value, _ := extractJSONPath(body, path)
+		result[name] = value
+	}
+	// 固定输出最后写入，不会被同名的提取字段或输出变量覆盖 (校验时拒绝这类配置)
+	result[outputVar] = body
+	result["statusCode"] = resp.StatusCode
+	result["headers"] = headers
+	return result
+}
+
+// httpExtractPaths 解析响应提取配置：[{"name", "path"}] 列表或 {"name": "path"} 对象
+func httpExtractPaths(config interface{}) map[string]string {
+	paths := make(map[string]string)
+	switch c := config.(type) {
+	case []interface{}:
+		for _, item := range c {
+			if m, ok := item.(map[string]interface{}); ok {
+				if name := getStringFromMap(m, "name"); name != "" {
+					paths[name] = getStringFromMap(m, "path")
+				}
+			}
+		}
+	case map[string]interface{}:
+		for name, path := range c {
+			if s, ok := path.(string); ok {
+				paths[name] = s
+			}
+		}
+	}
+	return paths
+}
+
+// extractJSONPath 按 JSON 路径提取值，如 $.data.items[0].id，$ 表示整个值
+func extractJSONPath(value interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	path = strings.TrimPrefix(path, ".")
+	if path == "" {
+		return value, true
+	}
+	segments, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	for _, seg := range segments {
+		var ok bool
+		if value, ok = walkSegment(value, seg); !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// ========================== 状态码分支 ==========================
+
+// isStatusPort 判断边的 sourcePort 是否为状态码分支：状态码 (404)、状态码段 (2xx) 或 default
+func isStatusPort(port string) bool {
+	if port == dto.EdgePortDefault {
+		return true
+	}
+	if len(port) != 3 || port[0] < '1' || port[0] > '5' {
+		return false
+	}
+	for _, c := range strings.ToLower(port[1:]) {
+		if c != 'x' && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// statusPortMatches 状态码是否匹配状态码或状态码段 (default 不参与匹配)
+func statusPortMatches(port string, code int) bool {
+	if port == dto.EdgePortDefault || !isStatusPort(port) {
+		return false
+	}
+	digits := fmt.Sprintf("%03d", code)
+	for i, c := range strings.ToLower(port) {
+		if c != 'x' && byte(c) != digits[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasStatusPorts 节点是否配置了状态码分支
+func hasStatusPorts(definition *dto.WorkflowDefinition, nodeID string) bool {
+	if definition == nil {
+		return false
+	}
+	for _, edge := range definition.Edges {
+		if edge.Source == nodeID && isStatusPort(edge.SourcePort) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectStatusPorts 状态码命中的分支端口，没有端口匹配时命中 default
+func selectStatusPorts(edges []*dto.WorkflowEdge, code int) map[string]bool {
+	selected := make(map[string]bool)
+	for _, edge := range edges {
+		if statusPortMatches(edge.SourcePort, code) {
+			selected[edge.SourcePort] = true
+		}
+	}
+	if len(selected) == 0 {
+		selected[dto.EdgePortDefault] = true
+	}
+	return selected
+}
+
+// httpStatusCode 读取 HTTP 节点输出的状态码
+func httpStatusCode(output map[string]interface{}) int {
+	code, _ := toFloat(output["statusCode"])
+	return int(code)
+}
+
+// sortedHTTPMethods 允许的请求方法，用于校验提示
+func sortedHTTPMethods() []string {
+	methods := make([]string, 0, len(httpNodeMethods))
+	for method := range httpNodeMethods {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
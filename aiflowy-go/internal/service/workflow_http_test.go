@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestHTTPNodeExecutor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc123")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"method": r.Method,
+			"path":   r.URL.Path,
+			"page":   r.URL.Query().Get("page"),
+			"auth":   r.Header.Get("Authorization"),
+			"body":   string(body),
+			"items":  []interface{}{map[string]interface{}{"id": 7}},
+		})
+	}))
+	defer server.Close()
+
+	state := &repository.ChainState{Variables: map[string]interface{}{
+		"start": map[string]interface{}{"userId": 42.0, "name": "alice"},
+		"token": "secret",
+	}}
+	node := &dto.WorkflowNode{ID: "http", Type: dto.NodeTypeHTTP, Data: map[string]interface{}{
+		"method":   "post",
+		"url":      server.URL + "/users/${start.userId}",
+		"query":    []interface{}{map[string]interface{}{"name": "page", "value": 2.0}},
+		"bodyType": "json",
+		"body":     map[string]interface{}{"name": "${start.name}"},
+		"auth":     map[string]interface{}{"type": "bearer", "token": "${token}"},
+		"extract":  []interface{}{map[string]interface{}{"name": "firstId", "path": "$.items[0].id"}},
+	}}
+
+	// the test server listens on loopback, which the public client refuses
+	executor := &HTTPNodeExecutor{client: server.Client()}
+	step := &entity.WorkflowExecStep{}
+	result, err := executor.Execute(withExecStep(context.Background(), step), state, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result["statusCode"] != http.StatusOK || result["firstId"] != 7.0 {
+		t.Errorf("unexpected result: %v", result)
+	}
+	body, _ := result["body"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"method": "POST",
+		"path":   "/users/42",
+		"page":   "2",
+		"auth":   "Bearer secret",
+		"body":   `{"name":"alice"}`,
+	}
+	for key, want := range expected {
+		if body[key] != want {
+			t.Errorf("expected %s to be %v, got %v", key, want, body[key])
+		}
+	}
+
+	// request and response are logged on the step with credentials masked
+	if !strings.Contains(step.Logs, "> POST "+server.URL+"/users/42?page=2") || !strings.Contains(step.Logs, "< 200 OK") {
+		t.Errorf("unexpected logs: %s", step.Logs)
+	}
+	if !strings.Contains(step.Logs, "> Authorization: "+maskedHTTPHeaderValue) {
+		t.Error("authorization header must be masked in logs")
+	}
+	if strings.Contains(step.Logs, "abc123") || !strings.Contains(step.Logs, "< Set-Cookie: "+maskedHTTPHeaderValue) {
+		t.Errorf("response cookies must be masked in logs: %s", step.Logs)
+	}
+
+	// error status fails the node unless status branches are configured
+	node.Data["url"] = server.URL + "/missing"
+	if _, err := executor.Execute(context.Background(), state, node); err == nil || classifyError(err) != "" {
+		t.Errorf("expected non-retryable status error, got %v", err)
+	}
+	definition := &dto.WorkflowDefinition{Edges: []*dto.WorkflowEdge{{Source: "http", Target: "end", SourcePort: "4xx"}}}
+	result, err = executor.Execute(withWorkflowDefinition(context.Background(), definition), state, node)
+	if err != nil || result["statusCode"] != http.StatusNotFound {
+		t.Errorf("expected 404 to be routed, got %v %v", result, err)
+	}
+}
+
+func TestHTTPNodeOutput_FixedKeys(t *testing.T) {
+	resp := &httpResponse{StatusCode: http.StatusOK, Headers: http.Header{"X-Id": {"1"}}, Body: []byte(`{"statusCode":"x"}`)}
+	data := map[string]interface{}{
+		"outputVariable": "headers",
+		"extract":        []interface{}{map[string]interface{}{"name": "statusCode", "path": "$.statusCode"}},
+	}
+	result := httpNodeOutput(data, resp)
+	if result["statusCode"] != http.StatusOK {
+		t.Errorf("status code was overwritten: %v", result["statusCode"])
+	}
+	if headers, _ := result["headers"].(map[string]interface{}); headers["X-Id"] != "1" {
+		t.Errorf("headers were overwritten: %v", result["headers"])
+	}
+
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart},
+			{ID: "http", Type: dto.NodeTypeHTTP, Data: map[string]interface{}{"url": "https://example.com", "outputVariable": "headers",
+				"extract": []interface{}{map[string]interface{}{"name": "statusCode", "path": "$.statusCode"}}}},
+			{ID: "end", Type: dto.NodeTypeEnd},
+		},
+		Edges: []*dto.WorkflowEdge{{Source: "start", Target: "http"}, {Source: "http", Target: "end"}},
+	}
+	var fields []string
+	for _, issue := range NewWorkflowDSLParser().Check(definition).Issues {
+		if issue.Code == dto.ValidationMissingConfig && issue.NodeID == "http" {
+			fields = append(fields, issue.Field)
+		}
+	}
+	if strings.Join(fields, ",") != "outputVariable,extract" {
+		t.Errorf("expected outputVariable and extract to be rejected, got %v", fields)
+	}
+}
+
+func TestHTTPNodeExecutor_RejectsPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer server.Close()
+
+	state := &repository.ChainState{Variables: map[string]interface{}{}}
+	node := &dto.WorkflowNode{ID: "http", Type: dto.NodeTypeHTTP, Data: map[string]interface{}{"url": server.URL}}
+	_, err := NewHTTPNodeExecutor().Execute(context.Background(), state, node)
+	if err == nil || !strings.Contains(err.Error(), errPrivateAddress.Error()) {
+		t.Fatalf("expected loopback address to be rejected, got %v", err)
+	}
+	if classifyError(err) != "" {
+		t.Errorf("a rejected address must not be retried, got %s", classifyError(err))
+	}
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	tests := map[string]bool{
+		"8.8.8.8":         true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"172.16.0.1":      false,
+		"192.168.1.1":     false,
+		"169.254.169.254": false,
+		"100.100.100.200": false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00:ec2::254":   false,
+		"fe80::1":         false,
+		"::ffff:10.0.0.1": false,
+	}
+	for addr, public := range tests {
+		if got := isPublicAddress(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("%s: expected public=%v, got %v", addr, public, got)
+		}
+	}
+}
+
+func TestSelectStatusPorts(t *testing.T) {
+	edges := []*dto.WorkflowEdge{
+		{Source: "http", Target: "ok", SourcePort: "2xx"},
+		{Source: "http", Target: "missing", SourcePort: "404"},
+		{Source: "http", Target: "client", SourcePort: "4XX"},
+		{Source: "http", Target: "other", SourcePort: dto.EdgePortDefault},
+		{Source: "http", Target: "failed", SourcePort: dto.EdgePortError},
+	}
+
+	tests := []struct {
+		code     int
+		expected []string
+	}{
+		{200, []string{"2xx"}},
+		{404, []string{"404", "4XX"}},
+		{429, []string{"4XX"}},
+		{503, []string{dto.EdgePortDefault}},
+	}
+	for _, tt := range tests {
+		selected := selectStatusPorts(edges, tt.code)
+		if len(selected) != len(tt.expected) {
+			t.Errorf("status %d: unexpected ports %v", tt.code, selected)
+			continue
+		}
+		for _, port := range tt.expected {
+			if !selected[port] {
+				t.Errorf("status %d: expected port %s, got %v", tt.code, port, selected)
+			}
+		}
+	}
+	if isStatusPort(dto.EdgePortError) || isStatusPort("true") {
+		t.Error("error and condition ports are not status ports")
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/internal/service/rag"
+)
+
+// 知识库检索节点默认值
+const (
+	defaultKnowledgeTopK = 5
+	maxKnowledgeTopK     = 50
+)
+
+// ========================== KnowledgeNodeExecutor ==========================
+
+// KnowledgeNodeExecutor 知识库检索节点执行器
+//
+//	{"collectionIds": ["1", "2"], "query": "${rewrite.llmOutput}", "topK": 5, "scoreThreshold": 0.5,
+//	 "outputVariable": "chunks"}
+//
+// 在多个知识库中检索后按分数合并排序，输出分块内容、分数与来源文档；
+// context 为带 [序号] 的拼接文本，可直接放入 LLM 提示词并要求按序号引用。
+// 未启用向量存储的知识库使用全文检索，分数固定为 0.5。只能检索工作流所属租户的知识库
+type KnowledgeNodeExecutor struct {
+	ragService     *rag.RAGService
+	collectionRepo *repository.DocumentCollectionRepository
+	docRepo        *repository.DocumentRepository
+	workflowRepo   *repository.WorkflowRepository
+}
+
+// NewKnowledgeNodeExecutor 创建知识库检索节点执行器
+func NewKnowledgeNodeExecutor() *KnowledgeNodeExecutor {
+	return &KnowledgeNodeExecutor{
+		ragService:     rag.GetRAGService(),
+		collectionRepo: repository.NewDocumentCollectionRepository(),
+		docRepo:        repository.NewDocumentRepository(),
+		workflowRepo:   repository.NewWorkflowRepository(),
+	}
+}
+
+// knowledgeHit 一条检索结果
+type knowledgeHit struct {
+	collection *entity.DocumentCollection
+	doc        *rag.VectorDocument
+}
+
+// Execute 执行知识库检索节点
+func (e *KnowledgeNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("知识库节点缺少配置")
+	}
+
+	variables := state.SnapshotVariables()
+	collectionIDs, err := knowledgeCollectionIDs(node.Data, variables)
+	if err != nil {
+		return nil, err
+	}
+	if len(collectionIDs) == 0 {
+		return nil, fmt.Errorf("知识库节点未选择知识库")
+	}
+
+	query := strings.TrimSpace(templateValueString(resolveValue(node.Data["query"], variables)))
+	if query == "" {
+		return nil, fmt.Errorf("知识库节点的检索内容为空")
+	}
+
+	topK := getIntFromMap(node.Data, "topK")
+	if topK <= 0 {
+		topK = defaultKnowledgeTopK
+	}
+	if topK > maxKnowledgeTopK {
+		topK = maxKnowledgeTopK
+	}
+	threshold, _ := toFloat(node.Data["scoreThreshold"])
+	outputVar := getStringFromMap(node.Data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "chunks"
+	}
+
+	workflow, err := e.workflowRepo.GetWorkflowByID(ctx, state.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	if workflow == nil {
+		return nil, fmt.Errorf("工作流不存在: %d", state.WorkflowID)
+	}
+
+	// 每个知识库各取 topK，合并后再截取
+	var hits []*knowledgeHit
+	for _, id := range collectionIDs {
+		collection, err := e.collectionRepo.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("获取知识库失败: %w", err)
+		}
+		// 知识库 ID 可以来自运行时变量，其他租户的知识库视为不存在
+		if collection == nil || collection.TenantID != workflow.TenantID {
+			return nil, fmt.Errorf("知识库不存在: %d", id)
+		}
+		docs, err := e.ragService.SearchCollection(ctx, collection, query, &rag.RetrieverConfig{
+			TopK:           topK,
+			ScoreThreshold: threshold,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("检索知识库 %s 失败: %w", collection.Title, err)
+		}
+		for _, doc := range docs {
+			hits = append(hits, &knowledgeHit{collection: collection, doc: doc})
+		}
+	}
+	hits = rankKnowledgeHits(hits, topK, threshold)
+
+	chunkIDs := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		chunkIDs = append(chunkIDs, hit.doc.ID)
+	}
+	sources, err := e.docRepo.ListChunkSources(ctx, chunkIDs)
+	if err != nil {
+		return nil, fmt.Errorf("获取分块来源失败: %w", err)
+	}
+
+	chunks := make([]interface{}, 0, len(hits))
+	for i, hit := range hits {
+		chunks = append(chunks, knowledgeChunkOutput(i+1, hit, sources[hit.doc.ID]))
+	}
+	return map[string]interface{}{
+		outputVar: chunks,
+		"context": knowledgeContext(chunks),
+		"count":   len(chunks),
+	}, nil
+}
+
+// knowledgeCollectionIDs 解析要检索的知识库：collectionIds 列表 (可引用变量) 或单个 collectionId
+func knowledgeCollectionIDs(data map[string]interface{}, variables map[string]interface{}) ([]int64, error) {
+	var raw []interface{}
+	switch v := resolveValue(data["collectionIds"], variables).(type) {
+	case []interface{}:
+		raw = v
+	case nil:
+	default:
+		raw = []interface{}{v}
+	}
+	if id := resolveValue(data["collectionId"], variables); id != nil {
+		raw = append(raw, id)
+	}
+
+	seen := make(map[int64]bool, len(raw))
+	ids := make([]int64, 0, len(raw))
+	for _, item := range raw {
+		s := strings.TrimSpace(templateValueString(item))
+		if s == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("无效的知识库 ID: %s", s)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// rankKnowledgeHits 过滤低于阈值的结果，按分数从高到低排序并保留前 topK 条
+// 同一分块只保留一次，分数相同时保持各知识库的检索顺序
+func rankKnowledgeHits(hits []*knowledgeHit, topK int, threshold float64) []*knowledgeHit {
+	seen := make(map[int64]bool, len(hits))
+	ranked := make([]*knowledgeHit, 0, len(hits))
+	for _, hit := range hits {
+		if hit.doc.Score < threshold || seen[hit.doc.ID] {
+			continue
+		}
+		seen[hit.doc.ID] = true
+		ranked = append(ranked, hit)
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].doc.Score > ranked[j].doc.Score
+	})
+	if len(ranked) > topK {
+		ranked = ranked[:topK]
+	}
+	return ranked
+}
+
+// knowledgeChunkOutput 单个分块的输出，source 为空时 (分块已删除) 只保留检索结果
+func knowledgeChunkOutput(rank int, hit *knowledgeHit, source *repository.ChunkSource) map[string]interface{} {
+	chunk := map[string]interface{}{
+		"rank":            rank,
+		"chunkId":         strconv.FormatInt(hit.doc.ID, 10),
+		"content":         hit.doc.Content,
+		"score":           hit.doc.Score,
+		"collectionId":    strconv.FormatInt(hit.collection.ID, 10),
+		"collectionTitle": hit.collection.Title,
+	}
+	if source != nil {
+		chunk["documentId"] = strconv.FormatInt(source.DocumentID, 10)
+		chunk["documentTitle"] = source.Title
+		chunk["documentType"] = source.DocumentType
+		chunk["documentPath"] = source.DocumentPath
+		chunk["sorting"] = source.Sorting
+	}
+	return chunk
+}
+
+// knowledgeContext 拼接检索结果，每段以 [序号] 开头并注明来源文档
+func knowledgeContext(chunks []interface{}) string {
+	var b strings.Builder
+	for _, item := range chunks {
+		chunk := item.(map[string]interface{})
+		if b.Len() > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%d]", chunk["rank"])
+		if title, _ := chunk["documentTitle"].(string); title != "" {
+			fmt.Fprintf(&b, " 《%s》", title)
+		}
+		b.WriteString("\n")
+		b.WriteString(chunk["content"].(string))
+	}
+	return b.String()
+}
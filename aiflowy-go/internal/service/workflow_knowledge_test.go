@@ -0,0 +1,60 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/internal/service/rag"
+)
+
+func TestRankKnowledgeHits(t *testing.T) {
+	faq := &entity.DocumentCollection{ID: 1, Title: "FAQ"}
+	manual := &entity.DocumentCollection{ID: 2, Title: "手册"}
+	hits := []*knowledgeHit{
+		{collection: faq, doc: &rag.VectorDocument{ID: 10, Content: "a", Score: 0.6}},
+		{collection: faq, doc: &rag.VectorDocument{ID: 11, Content: "b", Score: 0.2}},
+		{collection: manual, doc: &rag.VectorDocument{ID: 20, Content: "c", Score: 0.9}},
+		{collection: manual, doc: &rag.VectorDocument{ID: 21, Content: "d", Score: 0.6}},
+		{collection: manual, doc: &rag.VectorDocument{ID: 10, Content: "a", Score: 0.6}},
+	}
+
+	ranked := rankKnowledgeHits(hits, 3, 0.5)
+	var ids []int64
+	for _, hit := range ranked {
+		ids = append(ids, hit.doc.ID)
+	}
+	// below threshold dropped, duplicates removed, ties keep retrieval order
+	if !reflect.DeepEqual(ids, []int64{20, 10, 21}) {
+		t.Errorf("unexpected ranking: %v", ids)
+	}
+
+	chunks := []interface{}{
+		knowledgeChunkOutput(1, ranked[0], &repository.ChunkSource{DocumentID: 5, Title: "安装指南"}),
+		knowledgeChunkOutput(2, ranked[1], nil),
+	}
+	if got := knowledgeContext(chunks); got != "[1] 《安装指南》\nc\n\n[2]\na" {
+		t.Errorf("unexpected context: %q", got)
+	}
+	if chunks[0].(map[string]interface{})["documentId"] != "5" {
+		t.Errorf("expected source document metadata, got %v", chunks[0])
+	}
+}
+
+func TestKnowledgeCollectionIDs(t *testing.T) {
+	variables := map[string]interface{}{"start": map[string]interface{}{"kb": "3"}}
+	ids, err := knowledgeCollectionIDs(map[string]interface{}{
+		"collectionIds": []interface{}{"1", 2.0, "${start.kb}", "1"},
+	}, variables)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3}) {
+		t.Errorf("unexpected ids: %v", ids)
+	}
+
+	if _, err := knowledgeCollectionIDs(map[string]interface{}{"collectionId": "abc"}, variables); err == nil {
+		t.Error("expected invalid collection id to fail")
+	}
+}
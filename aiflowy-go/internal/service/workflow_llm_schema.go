@@ -0,0 +1,385 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+
+	"github.com/aiflowy/aiflowy-go/internal/service/llm"
+)
+
+// 结构化输出默认值
+const (
+	defaultStructuredRetries = 2
+	maxStructuredRetries     = 5
+	structuredOutputToolName = "submit_result"
+	maxSchemaErrorsReported  = 10
+)
+
+// JSON Schema 支持的类型
+var jsonSchemaTypes = map[string]bool{
+	"object":  true,
+	"array":   true,
+	"string":  true,
+	"number":  true,
+	"integer": true,
+	"boolean": true,
+	"null":    true,
+}
+
+// llmOutputSchema LLM 节点的结构化输出配置 (节点 data.outputSchema)
+//
+// outputSchema 可以是 JSON Schema 对象 (支持 type / properties / required / items / enum / description)，
+// 也可以是字段列表 [{"name": "score", "type": "integer", "description": "...", "required": true}]，
+// 字段列表中的字段默认必填。outputMode 为 auto / tool / json / prompt，见 llm.OutputMode*
+type llmOutputSchema struct {
+	Schema     map[string]interface{}
+	Mode       string
+	MaxRetries int
+}
+
+// parseLLMOutputSchema 解析节点的结构化输出配置，未配置时返回 nil
+func parseLLMOutputSchema(data map[string]interface{}) (*llmOutputSchema, error) {
+	if isEmptyConfig(data["outputSchema"]) {
+		return nil, nil
+	}
+
+	var root map[string]interface{}
+	switch v := data["outputSchema"].(type) {
+	case map[string]interface{}:
+		root = v
+	case []interface{}:
+		root = fieldListSchema(v)
+	case string:
+		if err := json.Unmarshal([]byte(v), &root); err != nil {
+			return nil, fmt.Errorf("输出结构不是有效的 JSON: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("输出结构格式无效")
+	}
+	if err := checkJSONSchema(root, "$"); err != nil {
+		return nil, err
+	}
+	if schemaType(root) != "object" {
+		return nil, fmt.Errorf("输出结构的顶层类型必须为 object")
+	}
+
+	config := &llmOutputSchema{
+		Schema:     root,
+		Mode:       getStringFromMap(data, "outputMode"),
+		MaxRetries: defaultStructuredRetries,
+	}
+	switch config.Mode {
+	case "":
+		config.Mode = llm.OutputModeAuto
+	case llm.OutputModeAuto, llm.OutputModeTool, llm.OutputModeJSON, llm.OutputModePrompt:
+	default:
+		return nil, fmt.Errorf("无效的输出模式: %s", config.Mode)
+	}
+	if _, ok := data["maxRetries"]; ok {
+		config.MaxRetries = getIntFromMap(data, "maxRetries")
+		if config.MaxRetries < 0 {
+			config.MaxRetries = 0
+		}
+		if config.MaxRetries > maxStructuredRetries {
+			config.MaxRetries = maxStructuredRetries
+		}
+	}
+	return config, nil
+}
+
+// fieldListSchema 字段列表转换为 JSON Schema
+func fieldListSchema(fields []interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []interface{}
+	for _, item := range fields {
+		field, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := getStringFromMap(field, "name")
+		if name == "" {
+			continue
+		}
+		property := make(map[string]interface{})
+		for key, value := range field {
+			if key != "name" && key != "required" {
+				property[key] = value
+			}
+		}
+		if kind := normalizeParamType(getStringFromMap(field, "type")); kind != "" && kind != "file" {
+			property["type"] = kind
+		}
+		properties[name] = property
+		if req, ok := field["required"].(bool); !ok || req {
+			required = append(required, name)
+		}
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}
+}
+
+// checkJSONSchema 检查 Schema 本身是否有效 (只支持结构化输出需要的子集)
+func checkJSONSchema(s map[string]interface{}, path string) error {
+	kind := schemaType(s)
+	if kind == "" {
+		if _, ok := s["type"]; ok {
+			return fmt.Errorf("输出结构 %s 的类型无效: %v", path, s["type"])
+		}
+		return fmt.Errorf("输出结构 %s 未声明类型", path)
+	}
+	switch kind {
+	case "object":
+		properties, _ := s["properties"].(map[string]interface{})
+		for name, value := range properties {
+			property, ok := value.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("输出结构 %s.%s 必须为对象", path, name)
+			}
+			if err := checkJSONSchema(property, path+"."+name); err != nil {
+				return err
+			}
+		}
+		for _, name := range schemaRequired(s) {
+			if _, ok := properties[name]; !ok {
+				return fmt.Errorf("输出结构 %s 的必填字段 %s 未定义", path, name)
+			}
+		}
+	case "array":
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			return checkJSONSchema(items, path+"[]")
+		}
+	}
+	return nil
+}
+
+// schemaType Schema 声明的类型，未声明或无效时返回空
+func schemaType(s map[string]interface{}) string {
+	kind, _ := s["type"].(string)
+	if !jsonSchemaTypes[kind] {
+		return ""
+	}
+	return kind
+}
+
+// schemaRequired Schema 的必填字段
+func schemaRequired(s map[string]interface{}) []string {
+	var required []string
+	switch list := s["required"].(type) {
+	case []interface{}:
+		for _, item := range list {
+			if name, ok := item.(string); ok {
+				required = append(required, name)
+			}
+		}
+	case []string:
+		required = list
+	}
+	return required
+}
+
+// schemaProperties 按名称排序的字段
+func schemaProperties(s map[string]interface{}) ([]string, map[string]interface{}) {
+	properties, _ := s["properties"].(map[string]interface{})
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, properties
+}
+
+// validateJSONSchema 校验值是否符合 Schema，返回所有不符合的位置
+func validateJSONSchema(value interface{}, s map[string]interface{}, path string) []string {
+	var errs []string
+	kind := schemaType(s)
+	switch kind {
+	case "object":
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s 应为对象", path)}
+		}
+		for _, name := range schemaRequired(s) {
+			if v, ok := obj[name]; !ok || v == nil {
+				errs = append(errs, fmt.Sprintf("缺少必填字段 %s.%s", path, name))
+			}
+		}
+		names, properties := schemaProperties(s)
+		for _, name := range names {
+			v, ok := obj[name]
+			if !ok || v == nil {
+				continue
+			}
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				errs = append(errs, validateJSONSchema(v, property, path+"."+name)...)
+			}
+		}
+	case "array":
+		arr, ok := value.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s 应为数组", path)}
+		}
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range arr {
+				errs = append(errs, validateJSONSchema(item, items, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		if _, ok := value.(string); !ok {
+			return []string{fmt.Sprintf("%s 应为字符串", path)}
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return []string{fmt.Sprintf("%s 应为数字", path)}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s 应为整数", path)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s 应为布尔值", path)}
+		}
+	case "null":
+		if value != nil {
+			return []string{fmt.Sprintf("%s 应为 null", path)}
+		}
+	}
+
+	if enum, ok := s["enum"].([]interface{}); ok && len(enum) > 0 {
+		matched := false
+		for _, candidate := range enum {
+			if fmt.Sprint(candidate) == fmt.Sprint(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fmt.Sprintf("%s 应为以下值之一: %s", path, templateValueString(enum)))
+		}
+	}
+	return errs
+}
+
+// schemaToolInfo 将输出结构转换为强制调用的工具，工具参数即期望的 JSON 对象
+func schemaToolInfo(s map[string]interface{}) *schema.ToolInfo {
+	desc, _ := s["description"].(string)
+	if desc == "" {
+		desc = "提交结构化的处理结果"
+	}
+	return &schema.ToolInfo{
+		Name:        structuredOutputToolName,
+		Desc:        desc,
+		ParamsOneOf: schema.NewParamsOneOfByParams(schemaParameterInfo(s).SubParams),
+	}
+}
+
+// schemaParameterInfo 将 JSON Schema 转换为工具参数描述
+func schemaParameterInfo(s map[string]interface{}) *schema.ParameterInfo {
+	info := &schema.ParameterInfo{}
+	info.Desc, _ = s["description"].(string)
+	switch schemaType(s) {
+	case "object":
+		info.Type = schema.Object
+		names, properties := schemaProperties(s)
+		required := make(map[string]bool)
+		for _, name := range schemaRequired(s) {
+			required[name] = true
+		}
+		info.SubParams = make(map[string]*schema.ParameterInfo, len(names))
+		for _, name := range names {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				sub := schemaParameterInfo(property)
+				sub.Required = required[name]
+				info.SubParams[name] = sub
+			}
+		}
+	case "array":
+		info.Type = schema.Array
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			info.ElemInfo = schemaParameterInfo(items)
+		} else {
+			info.ElemInfo = &schema.ParameterInfo{Type: schema.String}
+		}
+	case "number":
+		info.Type = schema.Number
+	case "integer":
+		info.Type = schema.Integer
+	case "boolean":
+		info.Type = schema.Boolean
+	case "null":
+		info.Type = schema.Null
+	default:
+		info.Type = schema.String
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		for _, value := range enum {
+			info.Enum = append(info.Enum, fmt.Sprint(value))
+		}
+	}
+	return info
+}
+
+// structuredOutputPrompt 追加到系统提示词的输出要求
+func structuredOutputPrompt(s map[string]interface{}) string {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return "请只输出一个符合以下 JSON Schema 的 JSON 对象，不要输出任何其它内容：\n" + string(data)
+}
+
+// parseStructuredReply 解析模型回复中的 JSON 对象，兼容 ```json 代码块与前后的说明文字
+func parseStructuredReply(content string) (map[string]interface{}, error) {
+	content = strings.TrimSpace(content)
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+	}
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &result); err == nil {
+		return result, nil
+	}
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return nil, fmt.Errorf("回复不是 JSON 对象")
+	}
+	if err := json.Unmarshal([]byte(content[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("回复不是有效的 JSON: %v", err)
+	}
+	return result, nil
+}
+
+// structuredOutputs 将校验通过的结果展开为节点输出：每个顶层字段按声明的类型转换为独立的变量
+func structuredOutputs(s map[string]interface{}, result map[string]interface{}, outputVar string) map[string]interface{} {
+	outputs := map[string]interface{}{outputVar: result}
+	names, properties := schemaProperties(s)
+	for _, name := range names {
+		value, ok := result[name]
+		if !ok {
+			outputs[name] = nil
+			continue
+		}
+		if property, ok := properties[name].(map[string]interface{}); ok && value != nil {
+			if coerced, err := coerceParamValue(normalizeParamType(schemaType(property)), value); err == nil {
+				value = coerced
+			}
+		}
+		outputs[name] = value
+	}
+	return outputs
+}
+
+// schemaErrorsMessage 重新提示时告知模型的校验错误
+func schemaErrorsMessage(errs []string) string {
+	if len(errs) > maxSchemaErrorsReported {
+		errs = append(errs[:maxSchemaErrorsReported:maxSchemaErrorsReported], fmt.Sprintf("... 共 %d 处错误", len(errs)))
+	}
+	return strings.Join(errs, "; ")
+}
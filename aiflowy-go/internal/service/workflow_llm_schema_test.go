@@ -0,0 +1,86 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/service/llm"
+)
+
+func TestParseLLMOutputSchema_FieldList(t *testing.T) {
+	config, err := parseLLMOutputSchema(map[string]interface{}{
+		"outputSchema": []interface{}{
+			map[string]interface{}{"name": "sentiment", "type": "string", "enum": []interface{}{"positive", "negative"}},
+			map[string]interface{}{"name": "score", "type": "int"},
+			map[string]interface{}{"name": "tags", "type": "array", "items": map[string]interface{}{"type": "string"}, "required": false},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if config.Mode != llm.OutputModeAuto || config.MaxRetries != defaultStructuredRetries {
+		t.Errorf("unexpected defaults: %+v", config)
+	}
+	if !reflect.DeepEqual(schemaRequired(config.Schema), []string{"sentiment", "score"}) {
+		t.Errorf("unexpected required fields: %v", schemaRequired(config.Schema))
+	}
+
+	tool := schemaToolInfo(config.Schema)
+	if tool.Name != structuredOutputToolName || tool.ParamsOneOf == nil {
+		t.Errorf("unexpected tool: %+v", tool)
+	}
+
+	if _, err := parseLLMOutputSchema(map[string]interface{}{"outputSchema": map[string]interface{}{"type": "array"}}); err == nil {
+		t.Error("expected non-object schema to be rejected")
+	}
+	if config, err := parseLLMOutputSchema(map[string]interface{}{}); config != nil || err != nil {
+		t.Error("expected nil config without outputSchema")
+	}
+}
+
+func TestValidateStructuredReply(t *testing.T) {
+	config, _ := parseLLMOutputSchema(map[string]interface{}{
+		"outputSchema": map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"sentiment", "score"},
+			"properties": map[string]interface{}{
+				"sentiment": map[string]interface{}{"type": "string", "enum": []interface{}{"positive", "negative"}},
+				"score":     map[string]interface{}{"type": "integer"},
+				"items": map[string]interface{}{
+					"type":  "array",
+					"items": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"id": map[string]interface{}{"type": "number"}}},
+				},
+			},
+		},
+	})
+
+	result, err := parseStructuredReply("好的：\n```json\n{\"sentiment\": \"neutral\", \"score\": 1.5, \"items\": [{\"id\": \"x\"}]}\n```")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	errs := validateJSONSchema(result, config.Schema, "$")
+	expected := []string{
+		"$.items[0].id 应为数字",
+		"$.score 应为整数",
+		`$.sentiment 应为以下值之一: ["positive","negative"]`,
+	}
+	if !reflect.DeepEqual(errs, expected) {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	result, _ = parseStructuredReply(`{"sentiment": "positive", "score": 3}`)
+	if errs := validateJSONSchema(result, config.Schema, "$"); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	outputs := structuredOutputs(config.Schema, result, "llmOutput")
+	if outputs["score"] != int64(3) || outputs["sentiment"] != "positive" || outputs["items"] != nil {
+		t.Errorf("unexpected outputs: %v", outputs)
+	}
+	if !reflect.DeepEqual(outputs["llmOutput"], result) {
+		t.Errorf("expected full result in output variable, got %v", outputs["llmOutput"])
+	}
+
+	if _, err := parseStructuredReply("no json here"); err == nil {
+		t.Error("expected non-JSON reply to fail")
+	}
+}
@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+// 循环节点默认值
+const (
+	defaultLoopMaxIterations = 100
+	maxLoopConcurrency       = 16
+)
+
+// loopConfig 循环节点配置 (节点 data)
+//
+//	{"mode": "array", "items": "${list}", "itemVariable": "item", "indexVariable": "index",
+//	 "maxIterations": 100, "concurrency": 4, "outputVariable": "loopOutput", "iterationOutput": "llm.output"}
+//
+// while 模式使用 condition 表达式，每次迭代前求值，迭代产生的变量带入下一次迭代
+type loopConfig struct {
+	Mode            string
+	Items           interface{}
+	Condition       string
+	ItemVariable    string
+	IndexVariable   string
+	MaxIterations   int
+	Concurrency     int
+	OutputVariable  string
+	IterationOutput string
+}
+
+// parseLoopConfig 解析循环节点配置
+func parseLoopConfig(node *dto.WorkflowNode) *loopConfig {
+	config := &loopConfig{
+		Mode:           dto.LoopModeArray,
+		ItemVariable:   "item",
+		IndexVariable:  "index",
+		MaxIterations:  defaultLoopMaxIterations,
+		Concurrency:    1,
+		OutputVariable: "loopOutput",
+	}
+	if node.Data == nil {
+		return config
+	}
+
+	if mode := getStringFromMap(node.Data, "mode"); mode != "" {
+		config.Mode = mode
+	}
+	config.Items = node.Data["items"]
+	config.Condition = getStringFromMap(node.Data, "condition")
+	if v := getStringFromMap(node.Data, "itemVariable"); v != "" {
+		config.ItemVariable = v
+	}
+	if v := getStringFromMap(node.Data, "indexVariable"); v != "" {
+		config.IndexVariable = v
+	}
+	if n := getIntFromMap(node.Data, "maxIterations"); n > 0 {
+		config.MaxIterations = n
+	}
+	if n := getIntFromMap(node.Data, "concurrency"); n > 1 {
+		config.Concurrency = n
+		if n > maxLoopConcurrency {
+			config.Concurrency = maxLoopConcurrency
+		}
+	}
+	if v := getStringFromMap(node.Data, "outputVariable"); v != "" {
+		config.OutputVariable = v
+	}
+	config.IterationOutput = getStringFromMap(node.Data, "iterationOutput")
+	return config
+}
+
+// ========================== LoopNodeExecutor ==========================
+
+// LoopNodeExecutor 循环节点执行器
+// 循环体为 parentId 指向本节点的子图，每次迭代在独立的作用域状态中调度，
+// 迭代内的节点以 "节点ID#序号" 记录到执行状态与步骤中
+type LoopNodeExecutor struct {
+	executor *ChainExecutor
+}
+
+// NewLoopNodeExecutor 创建循环节点执行器
+func NewLoopNodeExecutor(executor *ChainExecutor) *LoopNodeExecutor {
+	return &LoopNodeExecutor{
+		executor: executor,
+	}
+}
+
+// Execute 执行循环节点
+func (e *LoopNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	definition := workflowDefinitionFromContext(ctx)
+	if definition == nil {
+		return nil, fmt.Errorf("循环节点不支持单独运行")
+	}
+
+	body := e.executor.parser.SubGraph(definition, loopBaseID(node.ID))
+	if len(body.Nodes) == 0 {
+		return nil, fmt.Errorf("循环节点 %s 没有循环体", nodeDisplayName(node))
+	}
+
+	config := parseLoopConfig(node)
+	var (
+		results []interface{}
+		err     error
+	)
+	switch config.Mode {
+	case dto.LoopModeArray:
+		results, err = e.runArray(ctx, state, node, body, config)
+	case dto.LoopModeWhile:
+		results, err = e.runWhile(ctx, state, node, body, config)
+	default:
+		return nil, fmt.Errorf("未知的循环模式: %s", config.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		config.OutputVariable: results,
+		"iterations":          len(results),
+	}, nil
+}
+
+// runArray 遍历数组，concurrency > 1 时并发执行迭代，任一迭代失败即取消其余迭代
+func (e *LoopNodeExecutor) runArray(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, body *dto.WorkflowDefinition, config *loopConfig) ([]interface{}, error) {
+	base := state.Snapshot()
+	items, err := resolveLoopItems(config.Items, base.Variables)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > config.MaxIterations {
+		return nil, fmt.Errorf("循环次数 %d 超过上限 %d", len(items), config.MaxIterations)
+	}
+
+	loopCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	results := make([]interface{}, len(items))
+	sem := make(chan struct{}, config.Concurrency)
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+dispatch:
+	for i, item := range items {
+		select {
+		case sem <- struct{}{}:
+		case <-loopCtx.Done():
+			break dispatch
+		}
+
+		wg.Add(1)
+		go func(i int, item interface{}) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			child := base.NewScope(map[string]interface{}{
+				config.ItemVariable:  item,
+				config.IndexVariable: i,
+			})
+			output, _, err := e.runIteration(loopCtx, state, node, body, config, i, child)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel(err)
+				})
+				return
+			}
+			results[i] = output
+		}(i, item)
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// runWhile 条件成立时重复执行循环体，超过最大次数视为失败
+func (e *LoopNodeExecutor) runWhile(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, body *dto.WorkflowDefinition, config *loopConfig) ([]interface{}, error) {
+	if config.Condition == "" {
+		return nil, fmt.Errorf("while 循环未配置条件")
+	}
+	if _, err := CompileExpression(config.Condition); err != nil {
+		return nil, fmt.Errorf("循环条件表达式错误: %w", err)
+	}
+
+	var results []interface{}
+	current := state.Snapshot()
+	for i := 0; ; i++ {
+		child := current.NewScope(map[string]interface{}{config.IndexVariable: i})
+		if !evaluateCondition(config.Condition, child.SnapshotVariables()) {
+			return results, nil
+		}
+		if i >= config.MaxIterations {
+			return nil, fmt.Errorf("循环次数超过上限 %d", config.MaxIterations)
+		}
+
+		output, next, err := e.runIteration(ctx, state, node, body, config, i, child)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, output)
+		current = next
+	}
+}
+
+// runIteration 在作用域状态 child 中执行一次迭代，返回迭代输出与迭代结束时的状态
+func (e *LoopNodeExecutor) runIteration(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode, body *dto.WorkflowDefinition, config *loopConfig, index int, child *repository.ChainState) (interface{}, *repository.ChainState, error) {
+	if ctx.Err() != nil {
+		return nil, nil, context.Cause(ctx)
+	}
+
+	suffix := loopSuffix(node.ID) + fmt.Sprintf("#%d", index)
+	run := e.executor.newScopedRun(ctx, child, workflowDefinitionFromContext(ctx), cloneLoopBody(body, suffix))
+	run.startEntries()
+	run.wait()
+
+	// 迭代内的节点状态合并到父状态，便于在 ChainInfo 中查看每次迭代
+	for _, ns := range child.SnapshotNodeStates() {
+		state.SetNodeState(ns)
+	}
+	e.executor.checkpoint(context.WithoutCancel(ctx), state)
+
+	if ctx.Err() != nil {
+		return nil, nil, context.Cause(ctx)
+	}
+
+	snapshot := child.Snapshot()
+	switch snapshot.Status {
+	case entity.ExecStatusCompleted:
+	case entity.ExecStatusSuspended:
+		return nil, nil, fmt.Errorf("第 %d 次迭代失败: 循环体内不支持暂停等待", index+1)
+	case entity.ExecStatusFailed:
+		return nil, nil, fmt.Errorf("第 %d 次迭代失败: %w", index+1, snapshot.Error)
+	default:
+		return nil, nil, fmt.Errorf("第 %d 次迭代未完成", index+1)
+	}
+
+	if config.IterationOutput != "" {
+		output, _ := lookupPath(snapshot.Variables, config.IterationOutput)
+		return output, snapshot, nil
+	}
+	return snapshot.Result, snapshot, nil
+}
+
+// resolveLoopItems 解析循环数组：支持 ${path} 引用、JSON 数组字符串与数组值
+func resolveLoopItems(raw interface{}, variables map[string]interface{}) ([]interface{}, error) {
+	value := raw
+	if s, ok := raw.(string); ok {
+		s = strings.TrimSpace(s)
+		if strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}") {
+			path := strings.TrimSuffix(strings.TrimPrefix(s, "${"), "}")
+			resolved, found := lookupPath(variables, path)
+			if !found {
+				return nil, fmt.Errorf("循环数组变量不存在: %s", path)
+			}
+			value = resolved
+		} else {
+			value = s
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return nil, fmt.Errorf("循环节点未配置数组")
+	case []interface{}:
+		return v, nil
+	case string:
+		var items []interface{}
+		if err := json.Unmarshal([]byte(v), &items); err != nil {
+			return nil, fmt.Errorf("循环数组必须为数组类型，实际为 string")
+		}
+		return items, nil
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("循环数组必须为数组类型，实际为 %s", valueTypeName(value))
+}
+
+// cloneLoopBody 复制循环体，节点 ID 追加迭代后缀
+func cloneLoopBody(body *dto.WorkflowDefinition, suffix string) *dto.WorkflowDefinition {
+	graph := *body
+	graph.Nodes = make([]*dto.WorkflowNode, len(body.Nodes))
+	for i, node := range body.Nodes {
+		clone := *node
+		clone.ID = node.ID + suffix
+		graph.Nodes[i] = &clone
+	}
+	graph.Edges = make([]*dto.WorkflowEdge, len(body.Edges))
+	for i, edge := range body.Edges {
+		clone := *edge
+		clone.ID = edge.ID + suffix
+		clone.Source = edge.Source + suffix
+		clone.Target = edge.Target + suffix
+		graph.Edges[i] = &clone
+	}
+	return &graph
+}
+
+// loopBaseID 去掉迭代后缀的节点 ID (嵌套循环中的节点形如 "node#0#2")
+func loopBaseID(nodeID string) string {
+	if i := strings.Index(nodeID, "#"); i >= 0 {
+		return nodeID[:i]
+	}
+	return nodeID
+}
+
+// loopSuffix 节点 ID 的迭代后缀
+func loopSuffix(nodeID string) string {
+	return nodeID[len(loopBaseID(nodeID)):]
+}
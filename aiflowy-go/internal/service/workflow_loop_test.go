@@ -0,0 +1,85 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+func TestLoop_BodyScopeAndClone(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart},
+			{ID: "loop", Type: dto.NodeTypeLoop},
+			{ID: "llm", Type: dto.NodeTypeLLM, ParentID: "loop"},
+			{ID: "code", Type: dto.NodeTypeCode, ParentID: "loop"},
+			{ID: "end", Type: dto.NodeTypeEnd},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{ID: "e1", Source: "start", Target: "loop"},
+			{ID: "e2", Source: "llm", Target: "code"},
+			{ID: "e3", Source: "loop", Target: "end"},
+		},
+	}
+	parser := NewWorkflowDSLParser()
+
+	top := parser.SubGraph(definition, "")
+	if len(top.Nodes) != 3 || len(top.Edges) != 2 {
+		t.Fatalf("expected 3 top-level nodes and 2 edges, got %d and %d", len(top.Nodes), len(top.Edges))
+	}
+
+	body := parser.SubGraph(definition, "loop")
+	if len(body.Nodes) != 2 || len(body.Edges) != 1 {
+		t.Fatalf("expected 2 body nodes and 1 edge, got %d and %d", len(body.Nodes), len(body.Edges))
+	}
+
+	clone := cloneLoopBody(body, loopSuffix("loop#1")+"#2")
+	if clone.Nodes[0].ID != "llm#1#2" || clone.Edges[0].Source != "llm#1#2" || clone.Edges[0].Target != "code#1#2" {
+		t.Errorf("unexpected clone ids: %s, %s -> %s", clone.Nodes[0].ID, clone.Edges[0].Source, clone.Edges[0].Target)
+	}
+	if body.Nodes[0].ID != "llm" {
+		t.Error("expected clone to leave the body untouched")
+	}
+	if loopBaseID("code#1#2") != "code" {
+		t.Errorf("unexpected base id %q", loopBaseID("code#1#2"))
+	}
+}
+
+func TestLoop_ResolveItems(t *testing.T) {
+	variables := map[string]interface{}{
+		"list": []interface{}{"a", "b"},
+		"llm":  map[string]interface{}{"output": `[1, 2, 3]`},
+		"name": "bob",
+	}
+
+	tests := []struct {
+		raw     interface{}
+		length  int
+		wantErr bool
+	}{
+		{"${list}", 2, false},
+		{"${llm.output}", 3, false},
+		{`["x"]`, 1, false},
+		{[]interface{}{1, 2}, 2, false},
+		{"${name}", 0, true},
+		{"${missing}", 0, true},
+		{nil, 0, true},
+	}
+
+	for _, tt := range tests {
+		items, err := resolveLoopItems(tt.raw, variables)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("expected error for %v", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("unexpected error for %v: %v", tt.raw, err)
+			continue
+		}
+		if len(items) != tt.length {
+			t.Errorf("expected %d items for %v, got %d", tt.length, tt.raw, len(items))
+		}
+	}
+}
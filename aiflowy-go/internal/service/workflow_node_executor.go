@@ -21,7 +21,7 @@ type StartNodeExecutor struct{}
 // Execute 执行开始节点
 func (e *StartNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
 	// 开始节点只是传递输入变量
-	return state.Variables, nil
+	return state.SnapshotVariables(), nil
 }
 
 // ========================== EndNodeExecutor ==========================
@@ -29,38 +29,50 @@ func (e *StartNodeExecutor) Execute(ctx context.Context, state *repository.Chain
 // EndNodeExecutor 结束节点执行器
 type EndNodeExecutor struct{}
 
-// Execute 执行结束节点
+// Execute 执行结束节点：按 outputs 映射工作流的输出
+//
+//	{"outputs": [{"name": "answer", "value": "${llm.llmOutput}"}, {"name": "count", "value": "${sql.rowCount}", "type": "number"},
+//	             {"name": "summary", "value": "共 ${sql.rowCount} 条"}]}
+//
+// value 为单个引用时保留原始类型，声明 type 时按类型转换；未配置输出时返回所有变量 (兼容旧工作流)
 func (e *EndNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
-	// 结束节点返回最终结果
-	result := make(map[string]interface{})
+	variables := state.SnapshotVariables()
 
-	// 从节点数据中提取输出映射
-	if node.Data != nil {
-		if outputs, ok := node.Data["outputs"].([]interface{}); ok {
-			for _, output := range outputs {
-				if outputMap, ok := output.(map[string]interface{}); ok {
-					key := getStringFromMap(outputMap, "name")
-					value := getStringFromMap(outputMap, "value")
-					if key != "" && value != "" {
-						// 尝试从变量中解析值
-						result[key] = resolveVariable(value, state.Variables)
-					}
-				}
-			}
-		}
+	outputs, _ := node.Data["outputs"].([]interface{})
+	if len(outputs) == 0 {
+		return variables, nil
 	}
 
-	// 如果没有配置输出，返回所有变量
-	if len(result) == 0 {
-		return state.Variables, nil
-	}
+	result := make(map[string]interface{}, len(outputs))
+	for _, output := range outputs {
+		outputMap, ok := output.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key := getStringFromMap(outputMap, "name")
+		if key == "" {
+			continue
+		}
 
+		value := resolveValue(outputMap["value"], variables)
+		if kind := normalizeParamType(getStringFromMap(outputMap, "type")); kind != "" && value != nil {
+			coerced, err := coerceParamValue(kind, value)
+			if err != nil {
+				return nil, fmt.Errorf("输出 %s %v", key, err)
+			}
+			value = coerced
+		}
+		result[key] = value
+	}
 	return result, nil
 }
 
 // ========================== LLMNodeExecutor ==========================
 
 // LLMNodeExecutor LLM 节点执行器
+//
+// 配置 outputSchema 时要求模型输出符合结构的 JSON (见 llmOutputSchema)：
+// 模型支持工具调用时强制调用以结构为参数的工具，否则使用 JSON 模式或仅通过提示词约束
 type LLMNodeExecutor struct {
 	chatService *llm.ChatService
 }
@@ -79,17 +91,9 @@ func (e *LLMNodeExecutor) Execute(ctx context.Context, state *repository.ChainSt
 	}
 
 	// 获取模型 ID
-	modelIDStr := getStringFromMap(node.Data, "modelId")
-	if modelIDStr == "" {
-		modelIDStr = getStringFromMap(node.Data, "llmId")
-	}
-	if modelIDStr == "" {
-		return nil, fmt.Errorf("LLM 节点未配置模型")
-	}
-
-	modelID, err := strconv.ParseInt(modelIDStr, 10, 64)
+	modelID, err := llmModelID(node.Data, "LLM 节点")
 	if err != nil {
-		return nil, fmt.Errorf("无效的模型 ID: %s", modelIDStr)
+		return nil, err
 	}
 
 	// 获取提示词
@@ -105,8 +109,9 @@ func (e *LLMNodeExecutor) Execute(ctx context.Context, state *repository.ChainSt
 	}
 
 	// 替换变量
-	userMessage := resolveTemplateString(userTemplate, state.Variables)
-	systemPrompt = resolveTemplateString(systemPrompt, state.Variables)
+	variables := state.SnapshotVariables()
+	userMessage := resolveTemplateString(userTemplate, variables)
+	systemPrompt = resolveTemplateString(systemPrompt, variables)
 
 	// 构建消息
 	messages := []llm.Message{}
@@ -123,22 +128,112 @@ func (e *LLMNodeExecutor) Execute(ctx context.Context, state *repository.ChainSt
 		Messages: messages,
 	}
 
-	response, err := e.chatService.Chat(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("LLM 调用失败: %w", err)
-	}
-
 	// 获取输出变量名
 	outputVar := getStringFromMap(node.Data, "outputVariable")
 	if outputVar == "" {
 		outputVar = "llmOutput"
 	}
 
+	// 配置了输出结构时生成 JSON，每个字段作为独立的输出变量
+	outputSchema, err := parseLLMOutputSchema(node.Data)
+	if err != nil {
+		return nil, err
+	}
+	if outputSchema != nil {
+		result, err := e.generateStructured(ctx, req, outputSchema)
+		if err != nil {
+			return nil, err
+		}
+		return structuredOutputs(outputSchema.Schema, result, outputVar), nil
+	}
+
+	content, err := e.generate(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("LLM 调用失败: %w", err)
+	}
+
 	return map[string]interface{}{
-		outputVar: response.Content,
+		outputVar: content,
 	}, nil
 }
 
+// generateStructured 按输出结构生成 JSON 并返回校验通过的对象：回复无法解析或不符合结构时，
+// 将错误反馈给模型重新生成，最多重试 MaxRetries 次
+func (e *LLMNodeExecutor) generateStructured(ctx context.Context, req *llm.ChatRequest, config *llmOutputSchema) (map[string]interface{}, error) {
+	instruction := structuredOutputPrompt(config.Schema)
+	if len(req.Messages) > 0 && req.Messages[0].Role == "system" {
+		req.Messages[0].Content += "\n\n" + instruction
+	} else {
+		req.Messages = append([]llm.Message{{Role: "system", Content: instruction}}, req.Messages...)
+	}
+	req.Output = &llm.OutputFormat{Mode: config.Mode, Tool: schemaToolInfo(config.Schema)}
+
+	var problems string
+	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
+		response, err := e.chatService.Chat(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("LLM 调用失败: %w", err)
+		}
+
+		result, err := parseStructuredReply(response.Content)
+		if err != nil {
+			problems = err.Error()
+		} else if errs := validateJSONSchema(result, config.Schema, "$"); len(errs) > 0 {
+			problems = schemaErrorsMessage(errs)
+		} else {
+			return result, nil
+		}
+
+		req.Messages = append(req.Messages,
+			llm.Message{Role: "assistant", Content: response.Content},
+			llm.Message{Role: "user", Content: "上面的输出不符合要求: " + problems + "。请修正后重新输出完整的 JSON 对象。"},
+		)
+	}
+	return nil, fmt.Errorf("LLM 输出不符合输出结构: %s", problems)
+}
+
+// llmModelID 节点配置的模型 ID (modelId 或 llmId)，label 为错误信息中的节点类型名称
+func llmModelID(data map[string]interface{}, label string) (int64, error) {
+	modelIDStr := getStringFromMap(data, "modelId")
+	if modelIDStr == "" {
+		modelIDStr = getStringFromMap(data, "llmId")
+	}
+	if modelIDStr == "" {
+		return 0, fmt.Errorf("%s未配置模型", label)
+	}
+
+	modelID, err := strconv.ParseInt(modelIDStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("无效的模型 ID: %s", modelIDStr)
+	}
+	return modelID, nil
+}
+
+// generate 调用 LLM：执行有事件订阅时流式生成并推送增量输出，否则同步生成
+func (e *LLMNodeExecutor) generate(ctx context.Context, req *llm.ChatRequest) (string, error) {
+	emit := llmDeltaEmitter(ctx)
+	if emit == nil {
+		response, err := e.chatService.Chat(ctx, req)
+		if err != nil {
+			return "", err
+		}
+		return response.Content, nil
+	}
+
+	var sb strings.Builder
+	err := e.chatService.ChatStream(ctx, req, func(chunk *llm.StreamChunk) error {
+		if chunk.Content != "" {
+			sb.WriteString(chunk.Content)
+			emit(chunk.Content)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
 // ========================== ToolNodeExecutor ==========================
 
 // ToolNodeExecutor 工具节点执行器
@@ -175,19 +270,19 @@ func (e *ToolNodeExecutor) Execute(ctx context.Context, state *repository.ChainS
 	}
 
 	// 解析参数
+	variables := state.SnapshotVariables()
 	args := make(map[string]interface{})
 	if params, ok := node.Data["parameters"].(map[string]interface{}); ok {
 		for k, v := range params {
-			args[k] = resolveVariable(fmt.Sprintf("%v", v), state.Variables)
+			args[k] = resolveValue(v, variables)
 		}
 	}
 	if inputsVal, ok := node.Data["inputs"].([]interface{}); ok {
 		for _, input := range inputsVal {
 			if inputMap, ok := input.(map[string]interface{}); ok {
 				name := getStringFromMap(inputMap, "name")
-				value := getStringFromMap(inputMap, "value")
-				if name != "" && value != "" {
-					args[name] = resolveVariable(value, state.Variables)
+				if name != "" && !isEmptyConfig(inputMap["value"]) {
+					args[name] = resolveValue(inputMap["value"], variables)
 				}
 			}
 		}
@@ -233,6 +328,7 @@ func (e *ConditionNodeExecutor) Execute(ctx context.Context, state *repository.C
 	}
 
 	// 评估条件
+	variables := state.SnapshotVariables()
 	for _, cond := range conditions {
 		condMap, ok := cond.(map[string]interface{})
 		if !ok {
@@ -242,7 +338,7 @@ func (e *ConditionNodeExecutor) Execute(ctx context.Context, state *repository.C
 		name := getStringFromMap(condMap, "name")
 		expression := getStringFromMap(condMap, "expression")
 
-		if evaluateCondition(expression, state.Variables) {
+		if evaluateCondition(expression, variables) {
 			return map[string]interface{}{
 				"condition": name,
 			}, nil
@@ -252,35 +348,21 @@ func (e *ConditionNodeExecutor) Execute(ctx context.Context, state *repository.C
 	return map[string]interface{}{"condition": "default"}, nil
 }
 
-// evaluateCondition 评估条件表达式
+// evaluateCondition 评估条件表达式，表达式无效或求值出错时视为不成立
 func evaluateCondition(expression string, variables map[string]interface{}) bool {
-	if expression == "" {
+	if strings.TrimSpace(expression) == "" {
 		return false
 	}
 
-	// 简单的条件评估
-	// 支持格式: ${variable} == value, ${variable} != value, ${variable} > value 等
-
-	// 替换变量
-	resolved := resolveTemplateString(expression, variables)
-
-	// 简单比较
-	parts := strings.SplitN(resolved, "==", 2)
-	if len(parts) == 2 {
-		left := strings.TrimSpace(parts[0])
-		right := strings.TrimSpace(parts[1])
-		return left == right
+	expr, err := CompileExpression(expression)
+	if err != nil {
+		return false
 	}
-
-	parts = strings.SplitN(resolved, "!=", 2)
-	if len(parts) == 2 {
-		left := strings.TrimSpace(parts[0])
-		right := strings.TrimSpace(parts[1])
-		return left != right
+	result, err := expr.EvalBool(variables)
+	if err != nil {
+		return false
 	}
-
-	// 如果表达式本身是 true/false
-	return resolved == "true" || resolved == "1"
+	return result
 }
 
 // ========================== HumanConfirmNodeExecutor ==========================
@@ -288,8 +370,11 @@ func evaluateCondition(expression string, variables map[string]interface{}) bool
 // HumanConfirmNodeExecutor 人工确认节点执行器
 type HumanConfirmNodeExecutor struct{}
 
-// Execute 执行人工确认节点
+// Execute 执行人工确认节点，确认参数保存为节点的输出 (${nodeId.param})
 func (e *HumanConfirmNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	confirmed, _ := state.GetVariable(loopBaseID(node.ID))
+	confirmedParams, _ := confirmed.(map[string]interface{})
+
 	// 检查是否已有确认参数
 	if node.Data != nil {
 		if params, ok := node.Data["confirmParameters"].([]interface{}); ok {
@@ -300,13 +385,14 @@ func (e *HumanConfirmNodeExecutor) Execute(ctx context.Context, state *repositor
 			for _, param := range params {
 				if paramMap, ok := param.(map[string]interface{}); ok {
 					name := getStringFromMap(paramMap, "name")
-					if _, exists := state.Variables[name]; !exists {
+					if _, exists := confirmedParams[name]; !exists {
 						allProvided = false
 						suspendParams = append(suspendParams, &repository.SuspendedParam{
-							Name:        name,
-							Type:        getStringFromMap(paramMap, "type"),
-							Description: getStringFromMap(paramMap, "description"),
-							Required:    getBoolFromMap(paramMap, "required"),
+							Name:         name,
+							Type:         getStringFromMap(paramMap, "type"),
+							Description:  getStringFromMap(paramMap, "description"),
+							Required:     getBoolFromMap(paramMap, "required"),
+							DefaultValue: paramMap["defaultValue"],
 						})
 					}
 				}
@@ -319,7 +405,11 @@ func (e *HumanConfirmNodeExecutor) Execute(ctx context.Context, state *repositor
 	}
 
 	// 所有确认参数已提供，继续执行
-	return state.Variables, nil
+	output := make(map[string]interface{}, len(confirmedParams))
+	for k, v := range confirmedParams {
+		output[k] = v
+	}
+	return output, nil
 }
 
 // ========================== PluginNodeExecutor ==========================
@@ -357,10 +447,11 @@ func (e *PluginNodeExecutor) Execute(ctx context.Context, state *repository.Chai
 	}
 
 	// 解析参数
+	variables := state.SnapshotVariables()
 	args := make(map[string]interface{})
 	if params, ok := node.Data["parameters"].(map[string]interface{}); ok {
 		for k, v := range params {
-			args[k] = resolveVariable(fmt.Sprintf("%v", v), state.Variables)
+			args[k] = resolveValue(v, variables)
 		}
 	}
 
@@ -391,7 +482,7 @@ func (e *PluginNodeExecutor) Execute(ctx context.Context, state *repository.Chai
 // CodeNodeExecutor 代码节点执行器
 type CodeNodeExecutor struct{}
 
-// Execute 执行代码节点 (简单实现 - 仅支持 JSON 转换)
+// Execute 执行代码节点：json 转换、template 模板替换，starlark 在沙箱中执行脚本
 func (e *CodeNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
 	if node.Data == nil {
 		return nil, fmt.Errorf("代码节点缺少配置")
@@ -402,13 +493,14 @@ func (e *CodeNodeExecutor) Execute(ctx context.Context, state *repository.ChainS
 	code := getStringFromMap(node.Data, "code")
 
 	result := make(map[string]interface{})
+	variables := state.SnapshotVariables()
 
 	switch codeType {
 	case "json":
 		// JSON 转换
 		if code != "" {
 			// 替换变量
-			resolved := resolveTemplateString(code, state.Variables)
+			resolved := resolveTemplateString(code, variables)
 			if err := json.Unmarshal([]byte(resolved), &result); err != nil {
 				return nil, fmt.Errorf("JSON 解析失败: %w", err)
 			}
@@ -416,12 +508,22 @@ func (e *CodeNodeExecutor) Execute(ctx context.Context, state *repository.ChainS
 	case "template":
 		// 模板替换
 		if code != "" {
-			resolved := resolveTemplateString(code, state.Variables)
+			resolved := resolveTemplateString(code, variables)
 			result["output"] = resolved
 		}
+	case "starlark", "python":
+		// 沙箱脚本，print 输出记录到执行步骤
+		scriptResult, err := RunScript(ctx, code, variables, parseScriptLimits(node.Data))
+		if step := execStepFromContext(ctx); step != nil && scriptResult != nil {
+			step.Logs = scriptResult.Logs
+		}
+		if err != nil {
+			return nil, err
+		}
+		result = scriptResult.Output
 	default:
 		// 简单变量传递
-		result = state.Variables
+		result = variables
 	}
 
 	return result, nil
@@ -454,20 +556,47 @@ func (e *SubWorkflowNodeExecutor) Execute(ctx context.Context, state *repository
 	}
 
 	// 解析参数
+	parentVariables := state.SnapshotVariables()
 	variables := make(map[string]interface{})
 	if params, ok := node.Data["parameters"].(map[string]interface{}); ok {
 		for k, v := range params {
-			variables[k] = resolveVariable(fmt.Sprintf("%v", v), state.Variables)
+			variables[k] = resolveValue(v, parentVariables)
 		}
 	}
 
-	// 同步执行子工作流 (执行开始节点)
-	result, err := e.executor.ExecuteNode(ctx, workflowIDStr, "", variables)
+	// 同步执行子工作流，直到结束节点
+	child, err := e.executor.executeSubWorkflow(ctx, state, workflowIDStr, variables)
 	if err != nil {
 		return nil, fmt.Errorf("执行子工作流失败: %w", err)
 	}
+	result := child.Snapshot().Result
+	if result == nil {
+		result = make(map[string]interface{})
+	}
 
-	return result, nil
+	// 按 outputs 映射子工作流的结束节点输出，未配置时返回全部输出
+	outputs, _ := node.Data["outputs"].([]interface{})
+	if len(outputs) == 0 {
+		return result, nil
+	}
+	mapped := make(map[string]interface{}, len(outputs))
+	for _, output := range outputs {
+		outputMap, ok := output.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := getStringFromMap(outputMap, "name")
+		if name == "" {
+			continue
+		}
+		ref := getStringFromMap(outputMap, "value")
+		if ref == "" {
+			ref = name
+		}
+		ref = strings.TrimSuffix(strings.TrimPrefix(ref, "${"), "}")
+		mapped[name], _ = lookupPath(result, ref)
+	}
+	return mapped, nil
 }
 
 // ========================== 辅助函数 ==========================
@@ -485,6 +614,24 @@ func getStringFromMap(m map[string]interface{}, key string) string {
 	return ""
 }
 
+// getIntFromMap 从 map 中获取整数
+func getIntFromMap(m map[string]interface{}, key string) int {
+	if v, ok := m[key]; ok {
+		switch n := v.(type) {
+		case float64:
+			return int(n)
+		case int:
+			return n
+		case int64:
+			return int(n)
+		case string:
+			i, _ := strconv.Atoi(n)
+			return i
+		}
+	}
+	return 0
+}
+
 // getBoolFromMap 从 map 中获取布尔值
 func getBoolFromMap(m map[string]interface{}, key string) bool {
 	if v, ok := m[key]; ok {
@@ -495,24 +642,74 @@ func getBoolFromMap(m map[string]interface{}, key string) bool {
 	return false
 }
 
-// resolveVariable 解析变量 (支持 ${var} 格式)
+// resolveVariable 解析变量：单个 ${path} 引用返回原始值 (支持 ${nodeId.field.items[0]} 路径)，
+// 包含引用的字符串按模板渲染，引用不存在时原样返回
 func resolveVariable(value string, variables map[string]interface{}) interface{} {
-	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
-		varName := strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")
-		if resolved, ok := variables[varName]; ok {
+	if path := referencePath(value); path != "" {
+		if resolved, ok := lookupPath(variables, path); ok {
 			return resolved
 		}
+		return value
+	}
+	if strings.Contains(value, "${") {
+		return resolveTemplateString(value, variables)
+	}
+	return value
+}
+
+// resolveValue 解析配置值：字符串按 resolveVariable 解析，对象与数组逐项解析，其它值原样返回
+func resolveValue(value interface{}, variables map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return resolveVariable(v, variables)
+	case map[string]interface{}:
+		resolved := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			resolved[key] = resolveValue(item, variables)
+		}
+		return resolved
+	case []interface{}:
+		resolved := make([]interface{}, len(v))
+		for i, item := range v {
+			resolved[i] = resolveValue(item, variables)
+		}
+		return resolved
 	}
 	return value
 }
 
-// resolveTemplateString 解析模板字符串 (替换所有 ${var})
+// resolveTemplateString 解析模板字符串：替换所有 ${path}，字符串原样插入，其它值渲染为 JSON，引用不存在时保留占位符
 func resolveTemplateString(template string, variables map[string]interface{}) string {
-	result := template
-	for key, value := range variables {
-		placeholder := "${" + key + "}"
-		valueStr := fmt.Sprintf("%v", value)
-		result = strings.ReplaceAll(result, placeholder, valueStr)
+	if !strings.Contains(template, "${") {
+		return template
 	}
-	return result
+	return variableRefPattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		path := strings.TrimSpace(placeholder[2 : len(placeholder)-1])
+		value, ok := lookupPath(variables, path)
+		if !ok {
+			return placeholder
+		}
+		return templateValueString(value)
+	})
+}
+
+// templateValueString 模板中插入的值：字符串原样插入，其它值渲染为 JSON
+func templateValueString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// referencePath 值恰好是单个 ${path} 引用时返回路径
+func referencePath(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") || strings.Count(value, "${") != 1 {
+		return ""
+	}
+	return strings.TrimSpace(value[2 : len(value)-1])
 }
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestSetNodeOutput_Scoped(t *testing.T) {
+	state := &repository.ChainState{
+		Variables: map[string]interface{}{"topic": "go"},
+		NodeStates: map[string]*repository.NodeState{
+			"summary":   {NodeID: "summary"},
+			"translate": {NodeID: "translate"},
+		},
+	}
+	state.SetNodeOutput("summary", map[string]interface{}{"llmOutput": "short", "words": 2})
+	state.SetNodeOutput("translate", map[string]interface{}{"llmOutput": "kurz", "summary": "clobber", "topic": "rust"})
+
+	variables := state.SnapshotVariables()
+	if v, _ := lookupPath(variables, "summary.llmOutput"); v != "short" {
+		t.Errorf("expected summary output to be kept, got %v", v)
+	}
+	if v, _ := lookupPath(variables, "translate.llmOutput"); v != "kurz" {
+		t.Errorf("unexpected translate output: %v", v)
+	}
+	// a field produced by a single node stays reachable through the legacy flat name
+	if variables["words"] != 2 {
+		t.Errorf("expected the flat words output, got %v", variables["words"])
+	}
+	// a field produced by several nodes is ambiguous and no longer flat
+	if _, ok := variables["llmOutput"]; ok {
+		t.Errorf("unexpected flat output: %v", variables["llmOutput"])
+	}
+	if variables["topic"] != "go" {
+		t.Errorf("start parameter was overwritten: %v", variables["topic"])
+	}
+
+	// the index survives a checkpoint, a third producer keeps the field ambiguous
+	data, err := state.MarshalCheckpoint()
+	if err != nil {
+		t.Fatalf("marshal checkpoint: %v", err)
+	}
+	restored, err := repository.UnmarshalCheckpoint(data)
+	if err != nil {
+		t.Fatalf("unmarshal checkpoint: %v", err)
+	}
+	restored.SetNodeOutput("review", map[string]interface{}{"llmOutput": "ok"})
+	if v, ok := restored.GetVariable("llmOutput"); ok {
+		t.Errorf("expected llmOutput to stay ambiguous, got %v", v)
+	}
+
+	// loop variables of a scope are not overwritten by node outputs
+	scope := state.NewScope(map[string]interface{}{"words": "item"})
+	scope.SetNodeOutput("count", map[string]interface{}{"words": 3})
+	if v, _ := scope.GetVariable("words"); v != "item" {
+		t.Errorf("loop variable was overwritten: %v", v)
+	}
+}
+
+func TestHumanConfirmNodeExecutor_ScopedParams(t *testing.T) {
+	node := &dto.WorkflowNode{ID: "approve", Type: dto.NodeTypeHumanConfirm, Data: map[string]interface{}{
+		"confirmParameters": []interface{}{map[string]interface{}{"name": "approved", "type": "boolean"}},
+	}}
+	// a start parameter with the same name doesn't confirm the node
+	state := &repository.ChainState{Variables: map[string]interface{}{"approved": true}}
+
+	_, err := (&HumanConfirmNodeExecutor{}).Execute(context.Background(), state, node)
+	if _, ok := err.(*SuspendError); !ok {
+		t.Fatalf("expected the node to suspend, got %v", err)
+	}
+
+	state.SetNodeOutput("approve", map[string]interface{}{"approved": false})
+	output, err := (&HumanConfirmNodeExecutor{}).Execute(context.Background(), state, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(output, map[string]interface{}{"approved": false}) {
+		t.Errorf("unexpected output: %v", output)
+	}
+}
+
+func TestResolveVariable(t *testing.T) {
+	variables := map[string]interface{}{
+		"name": "world",
+		"http": map[string]interface{}{
+			"body": map[string]interface{}{"items": []interface{}{"a", "b"}, "count": 2.0},
+		},
+	}
+
+	tests := []struct {
+		value    string
+		expected interface{}
+	}{
+		{"${name}", "world"},
+		{"${http.body.items[1]}", "b"},
+		{"${http.body.count}", 2.0},
+		{"${missing}", "${missing}"},
+		{"hello ${name}", "hello world"},
+		{"items: ${http.body.items}, count: ${http.body.count}", `items: ["a","b"], count: 2`},
+		{"${name} ${missing.field}", "world ${missing.field}"},
+	}
+	for _, tt := range tests {
+		if got := resolveVariable(tt.value, variables); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("resolveVariable(%q) = %#v, want %#v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestEndNodeExecutor_Outputs(t *testing.T) {
+	state := &repository.ChainState{
+		Variables: map[string]interface{}{
+			"sql": map[string]interface{}{"rowCount": 3.0, "rows": []interface{}{"r1"}},
+			"llm": map[string]interface{}{"llmOutput": "done"},
+		},
+	}
+	node := &dto.WorkflowNode{ID: "end", Type: dto.NodeTypeEnd, Data: map[string]interface{}{
+		"outputs": []interface{}{
+			map[string]interface{}{"name": "answer", "value": "${llm.llmOutput}"},
+			map[string]interface{}{"name": "count", "value": "${sql.rowCount}", "type": "integer"},
+			map[string]interface{}{"name": "summary", "value": "共 ${sql.rowCount} 条"},
+			map[string]interface{}{"name": "meta", "value": map[string]interface{}{"first": "${sql.rows[0]}"}},
+		},
+	}}
+
+	result, err := (&EndNodeExecutor{}).Execute(context.Background(), state, node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"answer":  "done",
+		"count":   int64(3),
+		"summary": "共 3 条",
+		"meta":    map[string]interface{}{"first": "r1"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("unexpected outputs: %#v", result)
+	}
+}
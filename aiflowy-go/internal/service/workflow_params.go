@@ -0,0 +1,200 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/response"
+)
+
+// 参数校验错误码
+const (
+	paramErrorRequired    = "required"
+	paramErrorInvalidType = "invalid_type"
+)
+
+// WorkflowParamsError 执行或恢复参数不符合声明，Errors 为字段级错误
+type WorkflowParamsError struct {
+	Errors []response.ValidationError
+}
+
+func (e *WorkflowParamsError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		messages = append(messages, fieldErr.Message)
+	}
+	return "参数校验失败: " + strings.Join(messages, "; ")
+}
+
+// bindWorkflowParams 按参数声明校验输入：检查必填、按类型转换并应用默认值
+// 未声明的参数原样保留，返回新的变量表，不修改 input
+func bindWorkflowParams(params []*dto.WorkflowParameter, input map[string]interface{}) (map[string]interface{}, error) {
+	variables := make(map[string]interface{}, len(input)+len(params))
+	for k, v := range input {
+		variables[k] = v
+	}
+
+	var fieldErrors []response.ValidationError
+	for _, param := range params {
+		if param == nil || param.Name == "" {
+			continue
+		}
+		kind := normalizeParamType(param.Type)
+
+		value, ok := variables[param.Name]
+		if !ok || isMissingParam(kind, value) {
+			switch {
+			case param.DefaultValue != nil:
+				value = param.DefaultValue
+			case param.Required:
+				fieldErrors = append(fieldErrors, response.ValidationError{
+					Field:   param.Name,
+					Message: fmt.Sprintf("参数 %s 不能为空", param.Name),
+					Code:    paramErrorRequired,
+				})
+				continue
+			default:
+				continue
+			}
+		}
+
+		coerced, err := coerceParamValue(kind, value)
+		if err != nil {
+			fieldErrors = append(fieldErrors, response.ValidationError{
+				Field:   param.Name,
+				Message: fmt.Sprintf("参数 %s %v", param.Name, err),
+				Code:    paramErrorInvalidType,
+			})
+			continue
+		}
+		variables[param.Name] = coerced
+	}
+
+	if len(fieldErrors) > 0 {
+		return nil, &WorkflowParamsError{Errors: fieldErrors}
+	}
+	return variables, nil
+}
+
+// initWorkflowVariables 初始化执行变量：按开始节点的参数声明校验输入，
+// 再加入 WorkflowDefinition.Variables 声明的全局变量 (输入中的同名参数优先)
+func initWorkflowVariables(parser *WorkflowDSLParser, definition *dto.WorkflowDefinition, input map[string]interface{}) (map[string]interface{}, error) {
+	variables, err := bindWorkflowParams(parser.GetStartParameters(definition), input)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range definition.Variables {
+		if _, ok := variables[name]; !ok {
+			variables[name] = value
+		}
+	}
+	return variables, nil
+}
+
+// suspendedParamsToParameters 人工确认节点等待的参数转换为参数声明
+func suspendedParamsToParameters(suspended []*repository.SuspendedParam) []*dto.WorkflowParameter {
+	params := make([]*dto.WorkflowParameter, 0, len(suspended))
+	for _, param := range suspended {
+		params = append(params, &dto.WorkflowParameter{
+			Name:         param.Name,
+			Type:         param.Type,
+			Description:  param.Description,
+			Required:     param.Required,
+			DefaultValue: param.DefaultValue,
+		})
+	}
+	return params
+}
+
+// isMissingParam 参数未提供：nil，或非字符串类型的空字符串 (表单未填写)
+func isMissingParam(kind string, value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	if s, ok := value.(string); ok && strings.TrimSpace(s) == "" {
+		return kind != "string"
+	}
+	return false
+}
+
+// coerceParamValue 将参数值转换为声明的类型 (规范化后的类型，见 normalizeParamType)，未知类型原样返回
+func coerceParamValue(kind string, value interface{}) (interface{}, error) {
+	switch kind {
+	case "string":
+		switch val := value.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case int, int64, bool, json.Number:
+			return fmt.Sprintf("%v", val), nil
+		}
+		return nil, fmt.Errorf("应为字符串")
+
+	case "number", "integer":
+		n, ok := toFloat(value)
+		if !ok || math.IsNaN(n) || math.IsInf(n, 0) {
+			return nil, fmt.Errorf("应为数字")
+		}
+		if kind == "number" {
+			return n, nil
+		}
+		if n != math.Trunc(n) {
+			return nil, fmt.Errorf("应为整数")
+		}
+		return int64(n), nil
+
+	case "boolean":
+		switch val := value.(type) {
+		case bool:
+			return val, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(val))
+			if err != nil {
+				return nil, fmt.Errorf("应为布尔值")
+			}
+			return b, nil
+		}
+		return nil, fmt.Errorf("应为布尔值")
+
+	case "array":
+		if s, ok := value.(string); ok {
+			var parsed interface{}
+			if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+				return nil, fmt.Errorf("应为数组")
+			}
+			value = parsed
+		}
+		if arr, ok := value.([]interface{}); ok {
+			return arr, nil
+		}
+		return nil, fmt.Errorf("应为数组")
+
+	case "object":
+		if s, ok := value.(string); ok {
+			var parsed interface{}
+			if err := json.Unmarshal([]byte(s), &parsed); err != nil {
+				return nil, fmt.Errorf("应为对象")
+			}
+			value = parsed
+		}
+		if obj, ok := value.(map[string]interface{}); ok {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("应为对象")
+
+	case "file":
+		// 上传接口返回的路径或包含 path / url 字段的上传对象
+		switch value.(type) {
+		case string, map[string]interface{}:
+			return value, nil
+		}
+		return nil, fmt.Errorf("应为文件路径或上传对象")
+	}
+	return value, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestBindWorkflowParams(t *testing.T) {
+	params := []*dto.WorkflowParameter{
+		{Name: "name", Type: "string", Required: true},
+		{Name: "count", Type: "integer", DefaultValue: "3"},
+		{Name: "ratio", Type: "number"},
+		{Name: "enabled", Type: "boolean"},
+		{Name: "tags", Type: "array"},
+		{Name: "meta", Type: "object"},
+		{Name: "optional", Type: "number"},
+	}
+	input := map[string]interface{}{
+		"name":    42.0,
+		"count":   "",
+		"ratio":   "0.5",
+		"enabled": "true",
+		"tags":    `["a","b"]`,
+		"meta":    map[string]interface{}{"k": "v"},
+		"extra":   "kept",
+	}
+
+	variables, err := bindWorkflowParams(params, input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{
+		"name":    "42",
+		"count":   int64(3),
+		"ratio":   0.5,
+		"enabled": true,
+		"tags":    []interface{}{"a", "b"},
+		"meta":    map[string]interface{}{"k": "v"},
+		"extra":   "kept",
+	}
+	if !reflect.DeepEqual(variables, expected) {
+		t.Errorf("unexpected variables: %#v", variables)
+	}
+	if input["count"] != "" {
+		t.Error("input map must not be modified")
+	}
+}
+
+func TestBindWorkflowParams_FieldErrors(t *testing.T) {
+	params := []*dto.WorkflowParameter{
+		{Name: "name", Type: "string", Required: true},
+		{Name: "count", Type: "integer"},
+		{Name: "enabled", Type: "boolean"},
+		{Name: "tags", Type: "array"},
+	}
+	_, err := bindWorkflowParams(params, map[string]interface{}{
+		"count":   1.5,
+		"enabled": "maybe",
+		"tags":    "not json",
+	})
+
+	var paramsErr *WorkflowParamsError
+	if !errors.As(err, &paramsErr) {
+		t.Fatalf("expected WorkflowParamsError, got %v", err)
+	}
+	codes := make(map[string]string)
+	for _, fieldErr := range paramsErr.Errors {
+		codes[fieldErr.Field] = fieldErr.Code
+	}
+	expected := map[string]string{
+		"name":    paramErrorRequired,
+		"count":   paramErrorInvalidType,
+		"enabled": paramErrorInvalidType,
+		"tags":    paramErrorInvalidType,
+	}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Errorf("unexpected field errors: %v", codes)
+	}
+}
+
+func TestBindWorkflowParams_ResumeParams(t *testing.T) {
+	suspended := []*repository.SuspendedParam{
+		{Name: "approved", Type: "bool", Required: true},
+		{Name: "comment", Type: "string", DefaultValue: "none"},
+	}
+	params := suspendedParamsToParameters(suspended)
+
+	variables, err := bindWorkflowParams(params, map[string]interface{}{"approved": "false"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if variables["approved"] != false || variables["comment"] != "none" {
+		t.Errorf("unexpected variables: %v", variables)
+	}
+
+	if _, err := bindWorkflowParams(params, nil); err == nil {
+		t.Error("expected missing required confirm parameter to fail")
+	}
+}
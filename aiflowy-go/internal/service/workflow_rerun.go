@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+// ========================== 重新执行 ==========================
+//
+// 重新执行使用原执行的工作流配置快照 (tb_workflow_exec_result.workflow_json)，
+// 工作流在此之后修改或重新发布不影响重放结果；新执行是独立的执行记录
+
+// Rerun 使用原执行的输入重新执行，返回新的执行 ID
+func (e *ChainExecutor) Rerun(ctx context.Context, executeID string, tenantID int64, userID, createdBy string) (string, error) {
+	source, definition, err := e.loadRerunSource(ctx, executeID, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	var input map[string]interface{}
+	if source.Input != "" {
+		if err := json.Unmarshal([]byte(source.Input), &input); err != nil {
+			return "", fmt.Errorf("解析原执行的输入失败: %w", err)
+		}
+	}
+	variables, err := initWorkflowVariables(e.parser, definition, input)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := e.newExecution(ctx, rerunExecResult(source, userID, createdBy), variables, nil)
+	if err != nil {
+		return "", err
+	}
+	e.runAsync(state, definition)
+	return state.ExecuteID, nil
+}
+
+// RerunFromStep 从原执行的某个步骤重新执行：恢复该步骤开始时的变量，
+// 在它之前已结束的节点沿用原执行的结果，从该步骤的节点继续调度，返回新的执行 ID
+func (e *ChainExecutor) RerunFromStep(ctx context.Context, executeID string, tenantID, stepID int64, userID, createdBy string) (string, error) {
+	source, definition, err := e.loadRerunSource(ctx, executeID, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	steps, err := e.execRepo.GetExecStepsByExecKey(ctx, source.ExecKey)
+	if err != nil {
+		return "", fmt.Errorf("加载执行步骤失败: %w", err)
+	}
+	var target *entity.WorkflowExecStep
+	for _, step := range steps {
+		if step.ID == stepID {
+			target = step
+			break
+		}
+	}
+	if target == nil {
+		return "", fmt.Errorf("执行步骤不存在: %d", stepID)
+	}
+	node := e.parser.GetNodeByID(definition, target.NodeID)
+	if !rerunnableNode(node, target.NodeID) {
+		return "", fmt.Errorf("只能从顶层节点的步骤重新执行，循环体内的节点请从循环节点重新执行")
+	}
+
+	variables := make(map[string]interface{})
+	if target.Input != "" {
+		if err := json.Unmarshal([]byte(target.Input), &variables); err != nil {
+			return "", fmt.Errorf("解析步骤的变量失败: %w", err)
+		}
+	}
+
+	nodeStates := restoreNodeStates(e.parser, definition, steps, target, variables)
+	state, err := e.newExecution(ctx, rerunExecResult(source, userID, createdBy), variables, nodeStates)
+	if err != nil {
+		return "", err
+	}
+
+	runCtx, release := e.newRunContext(context.Background(), state, definition)
+	go func() {
+		defer release()
+		run := e.newChainRun(runCtx, state, definition)
+		// 开始节点没有入边，不会被 resume 重新评估
+		if len(run.incoming[node.ID]) == 0 {
+			run.start(node)
+		} else {
+			run.resume()
+		}
+		run.wait()
+	}()
+	return state.ExecuteID, nil
+}
+
+// loadRerunSource 加载租户的原执行记录并解析执行时的工作流快照，其他租户的执行视为不存在
+func (e *ChainExecutor) loadRerunSource(ctx context.Context, executeID string, tenantID int64) (*entity.WorkflowExecResult, *dto.WorkflowDefinition, error) {
+	source, err := e.execRepo.GetTenantExecResultByExecKey(ctx, executeID, tenantID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载执行记录失败: %w", err)
+	}
+	if source == nil {
+		return nil, nil, apierrors.NotFound("执行记录不存在")
+	}
+	if source.ParentExecKey != "" {
+		return nil, nil, fmt.Errorf("子工作流的执行需要从父执行 %s 重新执行", source.ParentExecKey)
+	}
+
+	definition, err := e.parser.Parse(source.WorkflowJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析工作流定义失败: %w", err)
+	}
+	if err := e.parser.Validate(definition); err != nil {
+		return nil, nil, fmt.Errorf("工作流定义无效: %w", err)
+	}
+	return source, definition, nil
+}
+
+// rerunExecResult 新执行记录沿用原执行的工作流快照与版本号
+func rerunExecResult(source *entity.WorkflowExecResult, userID, createdBy string) *entity.WorkflowExecResult {
+	return &entity.WorkflowExecResult{
+		WorkflowID:      source.WorkflowID,
+		Title:           source.Title,
+		Description:     source.Description,
+		WorkflowJSON:    source.WorkflowJSON,
+		CreatedKey:      userID,
+		CreatedBy:       createdBy,
+		WorkflowVersion: source.WorkflowVersion,
+	}
+}
+
+// rerunnableNode 步骤是否可以作为重新执行的起点：顶层流程中的节点，不含循环体的迭代
+func rerunnableNode(node *dto.WorkflowNode, stepNodeID string) bool {
+	return node != nil && node.ParentID == "" && loopBaseID(stepNodeID) == stepNodeID
+}
+
+// restoreNodeStates 根据原执行的步骤恢复目标步骤开始前已结束的顶层节点状态
+//
+// 节点取最后一次结束于目标步骤开始前的尝试：成功的沿用输出；失败的只有在是该节点最后一次尝试时
+// 才恢复 (之后还有重试说明失败并未结束节点)；人工确认节点在目标步骤开始前已恢复时，
+// 确认参数在变量中以节点 ID 保存，作为节点输出。未恢复的节点 (包括未命中的分支) 由调度器重新判断
+func restoreNodeStates(parser *WorkflowDSLParser, definition *dto.WorkflowDefinition, steps []*entity.WorkflowExecStep, target *entity.WorkflowExecStep, variables map[string]interface{}) map[string]*repository.NodeState {
+	lastAttempt := make(map[string]int64, len(steps))
+	for _, step := range steps {
+		lastAttempt[step.NodeID] = step.ID
+	}
+
+	nodeStates := make(map[string]*repository.NodeState)
+	for _, step := range steps {
+		if step.NodeID == target.NodeID || step.EndTime == nil || step.EndTime.After(target.StartTime) {
+			continue
+		}
+		node := parser.GetNodeByID(definition, step.NodeID)
+		if !rerunnableNode(node, step.NodeID) {
+			continue
+		}
+
+		ns := &repository.NodeState{
+			NodeID:    step.NodeID,
+			NodeName:  step.NodeName,
+			StartTime: step.StartTime,
+			EndTime:   step.EndTime,
+			Tokens:    step.Tokens,
+		}
+		switch step.Status {
+		case entity.ExecStatusCompleted:
+			ns.Status = entity.ExecStatusCompleted
+			ns.Output = decodeStepOutput(step.Output)
+		case entity.ExecStatusFailed:
+			if lastAttempt[step.NodeID] != step.ID {
+				continue
+			}
+			ns.Status = entity.ExecStatusFailed
+			ns.Error = errors.New(step.ErrorInfo)
+			ns.Output = decodeStepOutput(step.Output)
+		case entity.ExecStatusSuspended:
+			output, ok := variables[step.NodeID].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			ns.Status = entity.ExecStatusCompleted
+			ns.Output = output
+		default:
+			continue
+		}
+		nodeStates[step.NodeID] = ns
+	}
+	return nodeStates
+}
+
+// decodeStepOutput 解析步骤记录的输出，为空或无效时返回 nil
+func decodeStepOutput(data string) map[string]interface{} {
+	var output map[string]interface{}
+	if data != "" {
+		json.Unmarshal([]byte(data), &output)
+	}
+	return output
+}
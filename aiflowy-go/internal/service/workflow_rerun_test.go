@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestRestoreNodeStates(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart},
+			{ID: "fetch", Type: dto.NodeTypeHTTP},
+			{ID: "confirm", Type: dto.NodeTypeHumanConfirm},
+			{ID: "side", Type: dto.NodeTypeCode},
+			{ID: "llm", Type: dto.NodeTypeLLM},
+			{ID: "loop", Type: dto.NodeTypeLoop},
+			{ID: "body", Type: dto.NodeTypeCode, ParentID: "loop"},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "fetch"},
+			{Source: "fetch", Target: "confirm"},
+			{Source: "start", Target: "side"},
+			{Source: "confirm", Target: "llm"},
+			{Source: "side", Target: "llm"},
+			{Source: "llm", Target: "loop"},
+		},
+	}
+
+	base := time.Date(2026, 5, 1, 8, 0, 0, 0, time.Local)
+	at := func(seconds int) *time.Time {
+		t := base.Add(time.Duration(seconds) * time.Second)
+		return &t
+	}
+	step := func(id int64, nodeID string, status entity.WorkflowExecStatus, start, end int, output string) *entity.WorkflowExecStep {
+		return &entity.WorkflowExecStep{
+			ID: id, NodeID: nodeID, Status: status, StartTime: *at(start), EndTime: at(end), Output: output,
+		}
+	}
+	target := step(7, "llm", entity.ExecStatusCompleted, 10, 12, `{"llmOutput":"old"}`)
+	steps := []*entity.WorkflowExecStep{
+		step(1, "start", entity.ExecStatusCompleted, 0, 1, `{"q":"hi"}`),
+		step(2, "fetch", entity.ExecStatusFailed, 1, 2, ""),
+		step(3, "fetch", entity.ExecStatusCompleted, 3, 4, `{"statusCode":200}`),
+		step(4, "confirm", entity.ExecStatusSuspended, 4, 5, ""),
+		step(5, "side", entity.ExecStatusFailed, 1, 2, ""),
+		step(6, "side", entity.ExecStatusCompleted, 11, 13, "{}"),
+		target,
+		step(8, "body#0", entity.ExecStatusCompleted, 13, 14, "{}"),
+	}
+	variables := map[string]interface{}{"confirm": map[string]interface{}{"approved": true}}
+
+	states := restoreNodeStates(NewWorkflowDSLParser(), definition, steps, target, variables)
+	if len(states) != 3 {
+		t.Fatalf("expected start, fetch and confirm to be restored, got %v", states)
+	}
+	if ns := states["fetch"]; ns.Status != entity.ExecStatusCompleted || ns.Output["statusCode"] != 200.0 {
+		t.Errorf("expected the successful retry of fetch to be restored, got %+v", ns)
+	}
+	if ns := states["confirm"]; ns.Status != entity.ExecStatusCompleted || ns.Output["approved"] != true {
+		t.Errorf("expected confirm to be restored from confirm params, got %+v", ns)
+	}
+	// side failed once before the target but retried afterwards, so it runs again
+	if _, ok := states["side"]; ok {
+		t.Error("side must not be restored")
+	}
+
+	// resuming from the restored states fires side and waits on it before llm
+	state := &repository.ChainState{Status: entity.ExecStatusRunning, NodeStates: states}
+	executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+	run := executor.newChainRun(context.Background(), state, definition)
+	if got := run.joinDecision(definition.Nodes[3]); got != joinFire {
+		t.Errorf("expected side to fire, got %d", got)
+	}
+	if got := run.joinDecision(definition.Nodes[4]); got != joinWait {
+		t.Errorf("expected llm to wait for side, got %d", got)
+	}
+}
+
+func TestRerunnableNode(t *testing.T) {
+	top := &dto.WorkflowNode{ID: "llm"}
+	body := &dto.WorkflowNode{ID: "body", ParentID: "loop"}
+	if !rerunnableNode(top, "llm") {
+		t.Error("top-level node must be rerunnable")
+	}
+	if rerunnableNode(body, "body") || rerunnableNode(top, "llm#1") || rerunnableNode(nil, "gone") {
+		t.Error("loop bodies, iterations and unknown nodes are not rerunnable")
+	}
+}
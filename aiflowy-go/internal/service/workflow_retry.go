@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+// 重试策略默认值
+const (
+	defaultRetryBackoff    = time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+	defaultRetryMultiplier = 2.0
+)
+
+// retryPolicy 节点重试策略 (节点 data.retry)
+//
+//	{"maxAttempts": 3, "backoff": 1000, "maxBackoff": 30000, "multiplier": 2, "retryOn": ["timeout", "server"]}
+//
+// backoff / maxBackoff 单位为毫秒；retryOn 为空时重试超时、网络、限流和服务端错误
+type retryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Multiplier  float64
+	RetryOn     map[string]bool
+}
+
+// parseRetryPolicy 解析节点的重试策略，未配置时只执行一次
+func parseRetryPolicy(node *dto.WorkflowNode) *retryPolicy {
+	policy := &retryPolicy{
+		MaxAttempts: 1,
+		Backoff:     defaultRetryBackoff,
+		MaxBackoff:  defaultRetryMaxBackoff,
+		Multiplier:  defaultRetryMultiplier,
+		RetryOn: map[string]bool{
+			dto.RetryOnTimeout:   true,
+			dto.RetryOnNetwork:   true,
+			dto.RetryOnRateLimit: true,
+			dto.RetryOnServer:    true,
+		},
+	}
+	if node.Data == nil {
+		return policy
+	}
+	config, ok := node.Data["retry"].(map[string]interface{})
+	if !ok {
+		return policy
+	}
+
+	if n := getIntFromMap(config, "maxAttempts"); n > 1 {
+		policy.MaxAttempts = n
+	}
+	if ms := getIntFromMap(config, "backoff"); ms > 0 {
+		policy.Backoff = time.Duration(ms) * time.Millisecond
+	}
+	if ms := getIntFromMap(config, "maxBackoff"); ms > 0 {
+		policy.MaxBackoff = time.Duration(ms) * time.Millisecond
+	}
+	if m, ok := config["multiplier"].(float64); ok && m >= 1 {
+		policy.Multiplier = m
+	}
+	if classes, ok := config["retryOn"].([]interface{}); ok && len(classes) > 0 {
+		policy.RetryOn = make(map[string]bool, len(classes))
+		for _, c := range classes {
+			if name, ok := c.(string); ok {
+				policy.RetryOn[name] = true
+			}
+		}
+	}
+
+	return policy
+}
+
+// retryable 判断错误是否属于可重试的类别
+func (p *retryPolicy) retryable(err error) bool {
+	if _, ok := err.(*SuspendError); ok {
+		return false
+	}
+	if p.RetryOn[dto.RetryOnAny] {
+		return true
+	}
+	class := classifyError(err)
+	return class != "" && p.RetryOn[class]
+}
+
+// delay 第 attempt 次失败后的退避时间 (指数退避，不超过 MaxBackoff)
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := float64(p.Backoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+		if d >= float64(p.MaxBackoff) {
+			return p.MaxBackoff
+		}
+	}
+	return time.Duration(d)
+}
+
+// classifyError 将节点错误归类，无法识别时返回空字符串
+// LLM 与插件调用的错误大多只保留了文本，因此同时按错误信息匹配
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, errNodeTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return dto.RetryOnTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return dto.RetryOnTimeout
+		}
+		return dto.RetryOnNetwork
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "429", "rate limit", "ratelimit", "too many requests", "限流"):
+		return dto.RetryOnRateLimit
+	case containsAny(msg, "500 internal", "502", "503", "504", "internal server error", "bad gateway", "service unavailable", "overloaded"):
+		return dto.RetryOnServer
+	case containsAny(msg, "timeout", "timed out", "deadline exceeded", "超时"):
+		return dto.RetryOnTimeout
+	case containsAny(msg, "connection refused", "connection reset", "broken pipe", "no such host", "unexpected eof"):
+		return dto.RetryOnNetwork
+	}
+	return ""
+}
+
+// containsAny 判断字符串是否包含任一子串
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// isErrorEdge 判断是否为错误分支 (sourcePort 为 error 的边)
+func isErrorEdge(edge *dto.WorkflowEdge) bool {
+	return edge.SourcePort == dto.EdgePortError
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+func TestParseRetryPolicy_Defaults(t *testing.T) {
+	policy := parseRetryPolicy(&dto.WorkflowNode{ID: "llm"})
+	if policy.MaxAttempts != 1 {
+		t.Errorf("expected a single attempt without retry config, got %d", policy.MaxAttempts)
+	}
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	policy := parseRetryPolicy(&dto.WorkflowNode{
+		ID: "llm",
+		Data: map[string]interface{}{
+			"retry": map[string]interface{}{
+				"maxAttempts": float64(5),
+				"backoff":     float64(100),
+				"maxBackoff":  float64(500),
+			},
+		},
+	})
+
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 500 * time.Millisecond}
+	for i, want := range expected {
+		if got := policy.delay(i + 1); got != want {
+			t.Errorf("attempt %d: expected delay %s, got %s", i+1, want, got)
+		}
+	}
+}
+
+func TestRetryPolicy_Retryable(t *testing.T) {
+	tests := []struct {
+		name    string
+		retryOn []interface{}
+		err     error
+		expect  bool
+	}{
+		{"node timeout", nil, fmt.Errorf("%w (5s)", errNodeTimeout), true},
+		{"rate limited", nil, errors.New("LLM 调用失败: 生成回复失败: status code 429, Too Many Requests"), true},
+		{"server error", nil, errors.New("请求失败: 503 Service Unavailable"), true},
+		{"business error", nil, errors.New("LLM 节点未配置模型"), false},
+		{"class not selected", []interface{}{"timeout"}, errors.New("503 Service Unavailable"), false},
+		{"any", []interface{}{"any"}, errors.New("LLM 节点未配置模型"), true},
+		{"suspend never retried", []interface{}{"any"}, &SuspendError{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retry := map[string]interface{}{"maxAttempts": float64(3)}
+			if tt.retryOn != nil {
+				retry["retryOn"] = tt.retryOn
+			}
+			policy := parseRetryPolicy(&dto.WorkflowNode{ID: "n", Data: map[string]interface{}{"retry": retry}})
+			if got := policy.retryable(tt.err); got != tt.expect {
+				t.Errorf("expected retryable=%v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+)
+
+// chainRun 单次工作流执行的 DAG 调度器
+// 就绪节点各自在独立的 goroutine 中执行，汇聚节点按 joinMode 判断且只触发一次
+type chainRun struct {
+	executor *ChainExecutor
+	state    *repository.ChainState
+	// workflow 完整的工作流定义，definition 为本次调度的作用域 (顶层流程或一次循环迭代)
+	workflow   *dto.WorkflowDefinition
+	definition *dto.WorkflowDefinition
+
+	// parent 用于落库等收尾操作，ctx 在执行失败时被取消以中断其它分支
+	parent context.Context
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// mu 保证汇聚判断与节点认领的原子性
+	mu       sync.Mutex
+	incoming map[string][]*dto.WorkflowEdge
+	outgoing map[string][]*dto.WorkflowEdge
+
+	resultMu   sync.Mutex
+	endResult  map[string]interface{}
+	lastResult map[string]interface{}
+}
+
+// joinDecision 汇聚判断结果
+type joinDecision int
+
+const (
+	joinWait joinDecision = iota // 继续等待上游
+	joinFire                     // 触发执行
+	joinSkip                     // 跳过 (上游分支均未命中)
+)
+
+// newChainRun 创建顶层流程的调度器 (循环体内的节点不参与)
+func (e *ChainExecutor) newChainRun(ctx context.Context, state *repository.ChainState, definition *dto.WorkflowDefinition) *chainRun {
+	return e.newScopedRun(ctx, state, definition, e.parser.SubGraph(definition, ""))
+}
+
+// newScopedRun 创建指定作用域的调度器
+func (e *ChainExecutor) newScopedRun(ctx context.Context, state *repository.ChainState, workflow, definition *dto.WorkflowDefinition) *chainRun {
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &chainRun{
+		executor:   e,
+		state:      state,
+		workflow:   workflow,
+		definition: definition,
+		parent:     context.WithoutCancel(ctx),
+		ctx:        runCtx,
+		cancel:     cancel,
+		incoming:   make(map[string][]*dto.WorkflowEdge),
+		outgoing:   make(map[string][]*dto.WorkflowEdge),
+	}
+	for _, edge := range definition.Edges {
+		run.incoming[edge.Target] = append(run.incoming[edge.Target], edge)
+		run.outgoing[edge.Source] = append(run.outgoing[edge.Source], edge)
+	}
+	return run
+}
+
+// start 从指定节点开始调度
+func (r *chainRun) start(node *dto.WorkflowNode) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fire(node)
+}
+
+// startEntries 触发所有没有入边的节点 (循环体的入口)
+func (r *chainRun) startEntries() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range r.definition.Nodes {
+		if len(r.incoming[node.ID]) == 0 {
+			r.fire(node)
+		}
+	}
+}
+
+// resume 恢复执行：重新评估所有尚未执行的节点
+func (r *chainRun) resume() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, node := range r.definition.Nodes {
+		// 没有入边的节点 (开始节点) 在首次执行时已处理
+		if len(r.incoming[node.ID]) == 0 {
+			continue
+		}
+		r.evaluate(node)
+	}
+}
+
+// wait 等待所有分支结束，并在仍处于运行状态时完成整个工作流
+func (r *chainRun) wait() {
+	r.wg.Wait()
+	// 外部取消 (如父工作流被取消) 时节点已中断，不能视为完成
+	interrupted := context.Cause(r.ctx)
+	r.cancel()
+	defer r.executor.publishDone(r.state)
+
+	if r.state.GetStatus() != entity.ExecStatusRunning {
+		return
+	}
+	if interrupted != nil {
+		if r.state.Cancel(interrupted) {
+			r.executor.recordCancelled(r.parent, r.state, interrupted)
+		}
+		return
+	}
+	r.executor.handleComplete(r.parent, r.state, r.finalResult())
+}
+
+// finalResult 工作流的最终输出：优先使用结束节点结果
+func (r *chainRun) finalResult() map[string]interface{} {
+	r.resultMu.Lock()
+	defer r.resultMu.Unlock()
+
+	if r.endResult != nil {
+		return r.endResult
+	}
+	// 结束节点可能在暂停之前已执行
+	for _, node := range r.executor.parser.GetEndNodes(r.definition) {
+		if ns, ok := r.state.GetNodeState(node.ID); ok && ns.Status == entity.ExecStatusCompleted {
+			return ns.Output
+		}
+	}
+	if r.lastResult != nil {
+		return r.lastResult
+	}
+	return r.state.SnapshotVariables()
+}
+
+// fire 认领节点并在新 goroutine 中执行 (调用方需持有 r.mu)
+func (r *chainRun) fire(node *dto.WorkflowNode) {
+	nodeState := &repository.NodeState{
+		NodeID:    node.ID,
+		NodeName:  nodeDisplayName(node),
+		Status:    entity.ExecStatusRunning,
+		StartTime: time.Now(),
+	}
+	if !r.state.ClaimNodeState(nodeState) {
+		return
+	}
+
+	r.wg.Add(1)
+	go r.runNode(node)
+}
+
+// skip 将节点标记为跳过，并继续向下游传播 (调用方需持有 r.mu)
+func (r *chainRun) skip(node *dto.WorkflowNode) {
+	now := time.Now()
+	nodeState := &repository.NodeState{
+		NodeID:    node.ID,
+		NodeName:  nodeDisplayName(node),
+		Status:    entity.ExecStatusSkipped,
+		StartTime: now,
+		EndTime:   &now,
+	}
+	if !r.state.ClaimNodeState(nodeState) {
+		return
+	}
+	r.executor.publishNodeStatus(r.state, node.ID, protocol.WorkflowStateSkip, "", nil)
+	r.evaluateTargets(node.ID)
+}
+
+// runNode 执行单个节点，每个分支使用独立的 context
+func (r *chainRun) runNode(node *dto.WorkflowNode) {
+	defer r.wg.Done()
+
+	branchCtx, cancel := context.WithCancel(withWorkflowDefinition(r.ctx, r.workflow))
+	defer cancel()
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			r.executor.handleError(r.parent, r.state, fmt.Errorf("节点 %s 执行异常: %v", node.ID, rec))
+			r.cancel()
+		}
+	}()
+
+	result, ok := r.executor.executeNode(branchCtx, r.state, node, r.hasErrorRoute(node.ID))
+	if !ok {
+		if r.state.GetStatus() == entity.ExecStatusFailed {
+			r.cancel()
+		}
+		return
+	}
+
+	r.resultMu.Lock()
+	if node.Type == dto.NodeTypeEnd {
+		if r.endResult == nil {
+			r.endResult = result
+		}
+	} else {
+		r.lastResult = result
+	}
+	r.resultMu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.evaluateTargets(node.ID)
+}
+
+// evaluateTargets 评估节点的所有下游节点 (调用方需持有 r.mu)
+func (r *chainRun) evaluateTargets(nodeID string) {
+	seen := make(map[string]bool)
+	for _, edge := range r.outgoing[nodeID] {
+		if seen[edge.Target] {
+			continue
+		}
+		seen[edge.Target] = true
+		if target := r.executor.parser.GetNodeByID(r.definition, edge.Target); target != nil {
+			r.evaluate(target)
+		}
+	}
+}
+
+// evaluate 根据上游状态决定节点是否触发 (调用方需持有 r.mu)
+func (r *chainRun) evaluate(node *dto.WorkflowNode) {
+	if r.state.GetStatus() != entity.ExecStatusRunning {
+		return
+	}
+	if _, exists := r.state.GetNodeState(node.ID); exists {
+		return
+	}
+
+	switch r.joinDecision(node) {
+	case joinFire:
+		r.fire(node)
+	case joinSkip:
+		r.skip(node)
+	}
+}
+
+// joinDecision 统计上游分支并按 joinMode 判断
+func (r *chainRun) joinDecision(node *dto.WorkflowNode) joinDecision {
+	taken, dead, pending := r.upstreamCounts(node.ID)
+	total := taken + dead + pending
+
+	mode := dto.JoinModeAll
+	if node.Data != nil {
+		if m := getStringFromMap(node.Data, "joinMode"); m != "" {
+			mode = m
+		}
+	}
+
+	switch mode {
+	case dto.JoinModeAny:
+		if taken > 0 {
+			return joinFire
+		}
+		if pending == 0 {
+			return joinSkip
+		}
+	case dto.JoinModeFirstN:
+		n := getIntFromMap(node.Data, "joinCount")
+		if n <= 0 {
+			n = 1
+		}
+		if n > total {
+			n = total
+		}
+		if taken >= n {
+			return joinFire
+		}
+		if taken+pending < n {
+			return joinSkip
+		}
+	default:
+		if pending > 0 {
+			return joinWait
+		}
+		if taken > 0 {
+			return joinFire
+		}
+		return joinSkip
+	}
+
+	return joinWait
+}
+
+// upstreamCounts 按上游节点统计：taken 已命中，dead 已跳过或未命中，pending 尚未结束
+func (r *chainRun) upstreamCounts(nodeID string) (taken, dead, pending int) {
+	bySource := make(map[string][]*dto.WorkflowEdge)
+	var sources []string
+	for _, edge := range r.incoming[nodeID] {
+		if _, ok := bySource[edge.Source]; !ok {
+			sources = append(sources, edge.Source)
+		}
+		bySource[edge.Source] = append(bySource[edge.Source], edge)
+	}
+
+	for _, sourceID := range sources {
+		ns, ok := r.state.GetNodeState(sourceID)
+		if !ok {
+			pending++
+			continue
+		}
+		// 正常边在节点成功时生效，错误分支在节点失败时生效
+		var edges, errorEdges []*dto.WorkflowEdge
+		for _, edge := range bySource[sourceID] {
+			if isErrorEdge(edge) {
+				errorEdges = append(errorEdges, edge)
+			} else {
+				edges = append(edges, edge)
+			}
+		}
+
+		switch ns.Status {
+		case entity.ExecStatusCompleted:
+			if len(edges) > 0 && r.anyEdgeActive(sourceID, ns, edges) {
+				taken++
+			} else {
+				dead++
+			}
+		case entity.ExecStatusFailed:
+			if len(errorEdges) > 0 {
+				taken++
+			} else {
+				dead++
+			}
+		case entity.ExecStatusSkipped, entity.ExecStatusCancelled:
+			dead++
+		default:
+			pending++
+		}
+	}
+	return
+}
+
+// hasErrorRoute 节点是否配置了错误分支
+func (r *chainRun) hasErrorRoute(nodeID string) bool {
+	for _, edge := range r.outgoing[nodeID] {
+		if isErrorEdge(edge) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyEdgeActive 判断已完成的上游节点是否走向这些边
+// 条件、意图分类与参数提取节点按分支名选择；HTTP 节点先按状态码分支过滤；
+// 其它节点的边条件为表达式，基于节点完成时的变量求值，保证结果可重放
+func (r *chainRun) anyEdgeActive(sourceID string, ns *repository.NodeState, edges []*dto.WorkflowEdge) bool {
+	source := r.executor.parser.GetNodeByID(r.definition, sourceID)
+	if source == nil {
+		return true
+	}
+	if !isBranchNode(source.Type) {
+		variables := make(map[string]interface{}, len(ns.Input)+len(ns.Output)+1)
+		for k, v := range ns.Input {
+			variables[k] = v
+		}
+		for k, v := range ns.Output {
+			variables[k] = v
+		}
+		variables[loopBaseID(sourceID)] = ns.Output
+
+		var ports map[string]bool
+		if source.Type == dto.NodeTypeHTTP {
+			ports = selectStatusPorts(r.outgoing[sourceID], httpStatusCode(ns.Output))
+		}
+		for _, edge := range edges {
+			if ports != nil && isStatusPort(edge.SourcePort) && !ports[edge.SourcePort] {
+				continue
+			}
+			if edge.Condition == "" || evaluateCondition(edge.Condition, variables) {
+				return true
+			}
+		}
+		return false
+	}
+
+	selected := r.executor.selectNextNodeByCondition(r.definition, source, ns.Output)
+	if selected == nil {
+		return false
+	}
+	for _, edge := range edges {
+		if edge.Target == selected.ID {
+			return true
+		}
+	}
+	return false
+}
+
+type workflowDefinitionKey struct{}
+
+// withWorkflowDefinition 在 context 中携带工作流定义，供循环等需要访问子图的节点使用
+func withWorkflowDefinition(ctx context.Context, definition *dto.WorkflowDefinition) context.Context {
+	return context.WithValue(ctx, workflowDefinitionKey{}, definition)
+}
+
+// workflowDefinitionFromContext 获取 context 中的工作流定义
+func workflowDefinitionFromContext(ctx context.Context) *dto.WorkflowDefinition {
+	definition, _ := ctx.Value(workflowDefinitionKey{}).(*dto.WorkflowDefinition)
+	return definition
+}
+
+// nodeDisplayName 节点显示名称
+func nodeDisplayName(node *dto.WorkflowNode) string {
+	if node.Name != "" {
+		return node.Name
+	}
+	return node.Type
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+// newJoinTestRun builds a run where node "join" has three upstream nodes a, b and c
+func newJoinTestRun(joinData map[string]interface{}) (*chainRun, *dto.WorkflowNode) {
+	join := &dto.WorkflowNode{ID: "join", Type: dto.NodeTypeCode, Data: joinData}
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "a", Type: dto.NodeTypeCode},
+			{ID: "b", Type: dto.NodeTypeCode},
+			{ID: "c", Type: dto.NodeTypeCode},
+			join,
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "a", Target: "join"},
+			{Source: "b", Target: "join"},
+			{Source: "c", Target: "join"},
+		},
+	}
+	state := &repository.ChainState{
+		Status:     entity.ExecStatusRunning,
+		Variables:  make(map[string]interface{}),
+		NodeStates: make(map[string]*repository.NodeState),
+	}
+	executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+	return executor.newChainRun(context.Background(), state, definition), join
+}
+
+func TestChainRun_JoinDecision(t *testing.T) {
+	const (
+		done    = entity.ExecStatusCompleted
+		skipped = entity.ExecStatusSkipped
+		running = entity.ExecStatusRunning
+	)
+
+	tests := []struct {
+		name     string
+		data     map[string]interface{}
+		upstream map[string]entity.WorkflowExecStatus
+		expect   joinDecision
+	}{
+		{"all waits for pending", nil, map[string]entity.WorkflowExecStatus{"a": done, "b": running}, joinWait},
+		{"all fires when every branch ends", nil, map[string]entity.WorkflowExecStatus{"a": done, "b": done, "c": skipped}, joinFire},
+		{"all skips when every branch skipped", nil, map[string]entity.WorkflowExecStatus{"a": skipped, "b": skipped, "c": skipped}, joinSkip},
+		{"any fires on first completion", map[string]interface{}{"joinMode": "any"}, map[string]entity.WorkflowExecStatus{"a": done}, joinFire},
+		{"any waits while nothing completed", map[string]interface{}{"joinMode": "any"}, map[string]entity.WorkflowExecStatus{"a": skipped}, joinWait},
+		{"any skips when all skipped", map[string]interface{}{"joinMode": "any"}, map[string]entity.WorkflowExecStatus{"a": skipped, "b": skipped, "c": skipped}, joinSkip},
+		{"first_n waits below n", map[string]interface{}{"joinMode": "first_n", "joinCount": float64(2)}, map[string]entity.WorkflowExecStatus{"a": done}, joinWait},
+		{"first_n fires at n", map[string]interface{}{"joinMode": "first_n", "joinCount": float64(2)}, map[string]entity.WorkflowExecStatus{"a": done, "c": done}, joinFire},
+		{"first_n skips when n unreachable", map[string]interface{}{"joinMode": "first_n", "joinCount": float64(2)}, map[string]entity.WorkflowExecStatus{"a": skipped, "b": skipped}, joinSkip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run, join := newJoinTestRun(tt.data)
+			for id, status := range tt.upstream {
+				run.state.SetNodeState(&repository.NodeState{NodeID: id, Status: status})
+			}
+			if got := run.joinDecision(join); got != tt.expect {
+				t.Errorf("expected decision %d, got %d", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestChainRun_ConditionBranchIsDead(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "cond", Type: dto.NodeTypeCondition},
+			{ID: "yes", Type: dto.NodeTypeCode},
+			{ID: "no", Type: dto.NodeTypeCode},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "cond", Target: "yes", Condition: "yes"},
+			{Source: "cond", Target: "no", Condition: "no"},
+		},
+	}
+	state := &repository.ChainState{Status: entity.ExecStatusRunning}
+	executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+	run := executor.newChainRun(context.Background(), state, definition)
+
+	state.SetNodeState(&repository.NodeState{
+		NodeID: "cond",
+		Status: entity.ExecStatusCompleted,
+		Output: map[string]interface{}{"condition": "no"},
+	})
+
+	if got := run.joinDecision(definition.Nodes[1]); got != joinSkip {
+		t.Errorf("expected unselected branch to be skipped, got %d", got)
+	}
+	if got := run.joinDecision(definition.Nodes[2]); got != joinFire {
+		t.Errorf("expected selected branch to fire, got %d", got)
+	}
+}
+
+func TestChainRun_ErrorEdge(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "llm", Type: dto.NodeTypeLLM},
+			{ID: "next", Type: dto.NodeTypeCode},
+			{ID: "fallback", Type: dto.NodeTypeCode},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "llm", Target: "next"},
+			{Source: "llm", Target: "fallback", SourcePort: dto.EdgePortError},
+		},
+	}
+	newRun := func(status entity.WorkflowExecStatus) *chainRun {
+		state := &repository.ChainState{Status: entity.ExecStatusRunning}
+		state.SetNodeState(&repository.NodeState{NodeID: "llm", Status: status})
+		executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+		return executor.newChainRun(context.Background(), state, definition)
+	}
+
+	run := newRun(entity.ExecStatusCompleted)
+	if !run.hasErrorRoute("llm") {
+		t.Fatal("expected llm to have an error route")
+	}
+	if got := run.joinDecision(definition.Nodes[1]); got != joinFire {
+		t.Errorf("expected normal edge to fire on success, got %d", got)
+	}
+	if got := run.joinDecision(definition.Nodes[2]); got != joinSkip {
+		t.Errorf("expected error edge to be skipped on success, got %d", got)
+	}
+
+	run = newRun(entity.ExecStatusFailed)
+	if got := run.joinDecision(definition.Nodes[1]); got != joinSkip {
+		t.Errorf("expected normal edge to be skipped on failure, got %d", got)
+	}
+	if got := run.joinDecision(definition.Nodes[2]); got != joinFire {
+		t.Errorf("expected error edge to fire on failure, got %d", got)
+	}
+}
+
+func TestChainRun_EdgeConditionExpression(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "llm", Type: dto.NodeTypeLLM},
+			{ID: "high", Type: dto.NodeTypeCode},
+			{ID: "low", Type: dto.NodeTypeCode},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "llm", Target: "high", Condition: `${score} > 0.8`},
+			{Source: "llm", Target: "low", Condition: `${score} <= 0.8`},
+		},
+	}
+	state := &repository.ChainState{Status: entity.ExecStatusRunning}
+	state.SetNodeState(&repository.NodeState{
+		NodeID: "llm",
+		Status: entity.ExecStatusCompleted,
+		Output: map[string]interface{}{"score": 0.9},
+	})
+	executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+	run := executor.newChainRun(context.Background(), state, definition)
+
+	if got := run.joinDecision(definition.Nodes[1]); got != joinFire {
+		t.Errorf("expected matching edge to fire, got %d", got)
+	}
+	if got := run.joinDecision(definition.Nodes[2]); got != joinSkip {
+		t.Errorf("expected non-matching edge to be skipped, got %d", got)
+	}
+}
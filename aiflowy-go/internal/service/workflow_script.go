@@ -0,0 +1,502 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// 脚本运行限制
+const (
+	defaultScriptMaxSteps = 10_000_000
+	maxScriptMaxSteps     = 500_000_000
+	defaultScriptTimeout  = 5 * time.Second
+	maxScriptTimeout      = 60 * time.Second
+	maxScriptLogSize      = 64 << 10
+	maxScriptOutputSize   = 4 << 20
+
+	defaultScriptMemoryLimit = 64 << 20
+	maxScriptMemoryLimit     = 512 << 20
+	// 子进程结果包含返回值与日志
+	maxScriptResponseSize = 2 * maxScriptOutputSize
+)
+
+var (
+	errScriptTimeout = errors.New("脚本执行超时")
+	errScriptSteps   = errors.New("脚本执行步数超过上限")
+	errScriptMemory  = errors.New("脚本内存占用超过上限")
+)
+
+// scriptWorkerEnv 设置时进程作为脚本子进程启动，只执行一个脚本后退出
+const scriptWorkerEnv = "AIFLOWY_SCRIPT_WORKER"
+
+func init() {
+	if os.Getenv(scriptWorkerEnv) == "1" {
+		os.Exit(runScriptWorker())
+	}
+}
+
+// scriptFileOptions 允许 while、顶层控制语句、全局重新赋值与递归
+var scriptFileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// scriptSem 限制同时运行的脚本数
+var scriptSem = make(chan struct{}, runtime.NumCPU())
+
+// scriptLimits 脚本运行限制 (节点 data.maxSteps / data.scriptTimeout 毫秒 / data.memoryLimit MB)
+type scriptLimits struct {
+	MaxSteps    uint64
+	Timeout     time.Duration
+	MemoryLimit int64
+}
+
+// scriptRequest 发送给脚本子进程的脚本与输入
+type scriptRequest struct {
+	Code        string          `json:"code"`
+	Input       json.RawMessage `json:"input"`
+	MaxSteps    uint64          `json:"maxSteps"`
+	MemoryLimit int64           `json:"memoryLimit"`
+}
+
+// scriptResponse 脚本子进程返回的执行结果
+type scriptResponse struct {
+	Output        map[string]interface{} `json:"output,omitempty"`
+	Logs          string                 `json:"logs"`
+	Error         string                 `json:"error,omitempty"`
+	StepsExceeded bool                   `json:"stepsExceeded,omitempty"`
+}
+
+// ScriptResult 脚本执行结果
+type ScriptResult struct {
+	Output map[string]interface{}
+	Logs   string
+}
+
+// CheckScriptSyntax 检查 Starlark 脚本语法
+func CheckScriptSyntax(code string) error {
+	_, _, err := starlark.SourceProgramOptions(scriptFileOptions, "code.star", code, func(string) bool { return true })
+	return err
+}
+
+// RunScript 在沙箱中执行 Starlark 脚本
+// 脚本可定义 main(params) 函数返回字典，或在顶层为 result 赋值；工作流变量同时以 params 与同名全局变量提供。
+// 沙箱内只有 json、math 模块与 print，没有文件与网络访问。
+// 脚本在子进程中运行，子进程的内存受 limits.MemoryLimit 限制，超时或取消时结束子进程
+func RunScript(ctx context.Context, code string, variables map[string]interface{}, limits scriptLimits) (*ScriptResult, error) {
+	select {
+	case scriptSem <- struct{}{}:
+		defer func() { <-scriptSem }()
+	case <-ctx.Done():
+		return nil, context.Cause(ctx)
+	}
+
+	input, err := json.Marshal(variables)
+	if err != nil {
+		input = []byte("{}")
+	}
+	resp, err := runScriptProcess(ctx, &scriptRequest{
+		Code:        code,
+		Input:       input,
+		MaxSteps:    limits.MaxSteps,
+		MemoryLimit: limits.MemoryLimit,
+	}, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ScriptResult{Logs: resp.Logs}
+	if resp.StepsExceeded {
+		return result, fmt.Errorf("%w (%d)", errScriptSteps, limits.MaxSteps)
+	}
+	if resp.Error != "" {
+		return result, errors.New(resp.Error)
+	}
+	result.Output = resp.Output
+	return result, nil
+}
+
+// runScriptProcess 启动脚本子进程执行脚本，超时或 ctx 取消时结束子进程。
+// 请求经标准输入传入，结果经文件描述符 3 返回，标准错误只用于判断异常退出的原因
+func runScriptProcess(ctx context.Context, req *scriptRequest, limits scriptLimits) (*scriptResponse, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("启动脚本进程失败: %w", err)
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("转换脚本输入失败: %w", err)
+	}
+	respReader, respWriter, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("启动脚本进程失败: %w", err)
+	}
+	defer respReader.Close()
+
+	runCtx, cancel := context.WithTimeoutCause(ctx, limits.Timeout, errScriptTimeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, exe)
+	cmd.Env = append(os.Environ(), scriptWorkerEnv+"=1", "GOMAXPROCS=1", "GOTRACEBACK=none")
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = []*os.File{respWriter}
+	cmd.WaitDelay = time.Second
+	err = cmd.Start()
+	respWriter.Close()
+	if err != nil {
+		return nil, fmt.Errorf("启动脚本进程失败: %w", err)
+	}
+
+	// 先读完结果再等待退出，结果超过管道缓冲时子进程不会阻塞
+	data, readErr := io.ReadAll(io.LimitReader(respReader, maxScriptResponseSize+1))
+	waitErr := cmd.Wait()
+
+	if runCtx.Err() != nil {
+		cause := context.Cause(runCtx)
+		if errors.Is(cause, errScriptTimeout) {
+			return nil, fmt.Errorf("%w (%s)", errScriptTimeout, limits.Timeout)
+		}
+		return nil, cause
+	}
+	if waitErr != nil {
+		if strings.Contains(stderr.String(), "out of memory") {
+			return nil, fmt.Errorf("%w (%d MB)", errScriptMemory, limits.MemoryLimit>>20)
+		}
+		return nil, fmt.Errorf("脚本进程异常退出: %v %s", waitErr, strings.TrimSpace(stderr.String()))
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("读取脚本结果失败: %w", readErr)
+	}
+	if len(data) > maxScriptResponseSize {
+		return nil, fmt.Errorf("脚本返回值过大: %d 字节", len(data))
+	}
+	return decodeScriptResponse(data)
+}
+
+// decodeScriptResponse 解析子进程的结果，整数还原为 int64，其他数字为 float64
+func decodeScriptResponse(data []byte) (*scriptResponse, error) {
+	var resp scriptResponse
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("解析脚本结果失败: %w", err)
+	}
+	if resp.Output != nil {
+		resp.Output = restoreScriptNumbers(resp.Output).(map[string]interface{})
+	}
+	return &resp, nil
+}
+
+// restoreScriptNumbers 将 json.Number 转换为 int64 或 float64
+func restoreScriptNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	case []interface{}:
+		for i, item := range val {
+			val[i] = restoreScriptNumbers(item)
+		}
+		return val
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = restoreScriptNumbers(item)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// runScriptWorker 脚本子进程入口：读取请求，限制进程内存后执行脚本并写回结果
+func runScriptWorker() int {
+	var req scriptRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		fmt.Fprintf(os.Stderr, "读取脚本请求失败: %v\n", err)
+		return 1
+	}
+	if err := limitScriptMemory(req.MemoryLimit); err != nil {
+		fmt.Fprintf(os.Stderr, "限制脚本内存失败: %v\n", err)
+		return 1
+	}
+
+	out := os.NewFile(3, "script-result")
+	if err := json.NewEncoder(out).Encode(executeScript(&req)); err != nil {
+		fmt.Fprintf(os.Stderr, "写入脚本结果失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// executeScript 在当前进程执行脚本，只在脚本子进程中调用
+func executeScript(req *scriptRequest) *scriptResponse {
+	logs := &scriptLogBuffer{}
+	thread := &starlark.Thread{
+		Name:  "code",
+		Print: func(_ *starlark.Thread, msg string) { logs.WriteLine(msg) },
+	}
+	thread.SetMaxExecutionSteps(req.MaxSteps)
+	fail := func(err error) *scriptResponse {
+		return &scriptResponse{Logs: logs.String(), Error: err.Error()}
+	}
+
+	params, err := toStarlarkValue(decodeScriptInput(req.Input))
+	if err != nil {
+		return fail(fmt.Errorf("转换脚本输入失败: %w", err))
+	}
+	predeclared := starlark.StringDict{
+		"json":   starlarkjson.Module,
+		"math":   math.Module,
+		"params": params,
+	}
+	if dict, ok := params.(*starlark.Dict); ok {
+		for _, item := range dict.Items() {
+			name, _ := starlark.AsString(item[0])
+			if isScriptIdentifier(name) && predeclared[name] == nil {
+				predeclared[name] = item[1]
+			}
+		}
+	}
+
+	globals, err := starlark.ExecFileOptions(scriptFileOptions, thread, "code.star", req.Code, predeclared)
+	var value starlark.Value
+	if err == nil {
+		if fn, ok := globals["main"].(starlark.Callable); ok {
+			value, err = starlark.Call(thread, fn, starlark.Tuple{params}, nil)
+		} else if result, ok := globals["result"]; ok {
+			value = result
+		} else {
+			err = fmt.Errorf("脚本需定义 main(params) 函数或为 result 赋值")
+		}
+	}
+	if err != nil {
+		if thread.ExecutionSteps() >= req.MaxSteps {
+			return &scriptResponse{Logs: logs.String(), StepsExceeded: true}
+		}
+		return fail(scriptError(err))
+	}
+
+	output, err := fromStarlarkValue(value)
+	if err != nil {
+		return fail(fmt.Errorf("脚本返回值无效: %w", err))
+	}
+	result, ok := output.(map[string]interface{})
+	if !ok {
+		return fail(fmt.Errorf("脚本必须返回字典，实际为 %s", value.Type()))
+	}
+	if data, _ := json.Marshal(result); len(data) > maxScriptOutputSize {
+		return fail(fmt.Errorf("脚本返回值过大: %d 字节", len(data)))
+	}
+	return &scriptResponse{Output: result, Logs: logs.String()}
+}
+
+// scriptError 转换脚本执行错误，附带脚本调用栈
+func scriptError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return fmt.Errorf("脚本执行错误: %s", evalErr.Backtrace())
+	}
+	return fmt.Errorf("脚本执行错误: %w", err)
+}
+
+// parseScriptLimits 解析节点的脚本运行限制
+func parseScriptLimits(data map[string]interface{}) scriptLimits {
+	limits := scriptLimits{
+		MaxSteps:    defaultScriptMaxSteps,
+		Timeout:     defaultScriptTimeout,
+		MemoryLimit: defaultScriptMemoryLimit,
+	}
+	if n := getIntFromMap(data, "maxSteps"); n > 0 {
+		limits.MaxSteps = min(uint64(n), maxScriptMaxSteps)
+	}
+	if ms := getIntFromMap(data, "scriptTimeout"); ms > 0 {
+		limits.Timeout = min(time.Duration(ms)*time.Millisecond, maxScriptTimeout)
+	}
+	if mb := getIntFromMap(data, "memoryLimit"); mb > 0 {
+		limits.MemoryLimit = min(int64(mb)<<20, maxScriptMemoryLimit)
+	}
+	return limits
+}
+
+// scriptLogBuffer 收集 print 输出，超过上限后截断
+type scriptLogBuffer struct {
+	sb        strings.Builder
+	truncated bool
+}
+
+func (b *scriptLogBuffer) WriteLine(msg string) {
+	if b.truncated {
+		return
+	}
+	if b.sb.Len()+len(msg)+1 > maxScriptLogSize {
+		b.sb.WriteString("...(日志已截断)\n")
+		b.truncated = true
+		return
+	}
+	b.sb.WriteString(msg)
+	b.sb.WriteByte('\n')
+}
+
+func (b *scriptLogBuffer) String() string {
+	return b.sb.String()
+}
+
+// Starlark 关键字与保留字，不能作为变量名
+var scriptKeywords = map[string]bool{
+	"and": true, "break": true, "continue": true, "def": true, "elif": true, "else": true,
+	"for": true, "if": true, "in": true, "lambda": true, "load": true, "not": true,
+	"or": true, "pass": true, "return": true, "while": true,
+	"as": true, "assert": true, "async": true, "await": true, "class": true, "del": true,
+	"except": true, "finally": true, "from": true, "global": true, "import": true, "is": true,
+	"nonlocal": true, "raise": true, "try": true, "with": true, "yield": true,
+}
+
+// isScriptIdentifier 判断变量名能否作为脚本全局变量
+func isScriptIdentifier(name string) bool {
+	if name == "" || scriptKeywords[name] {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// decodeScriptInput 解析 JSON 编码的工作流变量，数字保留为 json.Number
+func decodeScriptInput(data []byte) interface{} {
+	var input interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&input); err != nil {
+		return map[string]interface{}{}
+	}
+	return input
+}
+
+// toStarlarkValue 将 JSON 值转换为 Starlark 值
+func toStarlarkValue(v interface{}) (starlark.Value, error) {
+	switch val := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(val), nil
+	case string:
+		return starlark.String(val), nil
+	case json.Number:
+		if i, err := val.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		if n, ok := new(big.Int).SetString(val.String(), 10); ok {
+			return starlark.MakeBigInt(n), nil
+		}
+		f, err := val.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.Float(f), nil
+	case []interface{}:
+		elems := make([]starlark.Value, len(val))
+		for i, item := range val {
+			elem, err := toStarlarkValue(item)
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return starlark.NewList(elems), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(val))
+		for _, k := range keys {
+			elem, err := toStarlarkValue(val[k])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(k), elem); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	default:
+		return nil, fmt.Errorf("不支持的类型 %T", v)
+	}
+}
+
+// fromStarlarkValue 将 Starlark 值转换为 JSON 值
+func fromStarlarkValue(v starlark.Value) (interface{}, error) {
+	switch val := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(val), nil
+	case starlark.String:
+		return string(val), nil
+	case starlark.Int:
+		if i, ok := val.Int64(); ok {
+			return i, nil
+		}
+		return val.String(), nil
+	case starlark.Float:
+		return float64(val), nil
+	case *starlark.List, starlark.Tuple, *starlark.Set:
+		iter := starlark.Iterate(val)
+		defer iter.Done()
+		items := make([]interface{}, 0)
+		var elem starlark.Value
+		for iter.Next(&elem) {
+			item, err := fromStarlarkValue(elem)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, val.Len())
+		for _, item := range val.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				key = item[0].String()
+			}
+			elem, err := fromStarlarkValue(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = elem
+		}
+		return m, nil
+	default:
+		return nil, fmt.Errorf("不支持的类型 %s", v.Type())
+	}
+}
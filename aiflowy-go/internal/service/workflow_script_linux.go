@@ -0,0 +1,55 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// scriptRuntimeReserve 脚本之外留给 Go 运行时的地址空间 (线程栈、GC 元数据等)
+const scriptRuntimeReserve = 64 << 20
+
+// limitScriptMemory 限制脚本子进程的地址空间为启动时已占用的部分加上脚本内存上限，
+// 超过后分配失败，Go 运行时以 out of memory 结束进程；同时设置软上限让 GC 提前回收
+func limitScriptMemory(limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	base, err := processVirtualMemory()
+	if err != nil {
+		return err
+	}
+	debug.SetMemoryLimit(limit)
+	max := uint64(base + limit + scriptRuntimeReserve)
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: max, Max: max})
+}
+
+// processVirtualMemory 读取当前进程已占用的地址空间 (字节)
+func processVirtualMemory() (int64, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "VmSize:")
+		if !ok {
+			continue
+		}
+		kb, err := strconv.ParseInt(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "kB")), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的 VmSize: %s", value)
+		}
+		return kb << 10, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("/proc/self/status 中没有 VmSize")
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package service
+
+import "runtime/debug"
+
+// limitScriptMemory 非 Linux 平台不能限制地址空间，只设置软上限让 GC 提前回收；
+// 部署环境为 Linux，硬上限见 workflow_script_linux.go
+func limitScriptMemory(limit int64) error {
+	if limit > 0 {
+		debug.SetMemoryLimit(limit)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunScript(t *testing.T) {
+	variables := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "amount": 2},
+			map[string]interface{}{"name": "b", "amount": 3.5},
+		},
+		"user-name": "bob",
+	}
+	limits := parseScriptLimits(nil)
+
+	code := `
+def main(params):
+    total = 0
+    for item in items:
+        total += item["amount"]
+    print("total", total)
+    return {"total": total, "names": [i["name"] for i in params["items"]], "user": params["user-name"]}
+`
+	result, err := RunScript(context.Background(), code, variables, limits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Output["total"] != 5.5 || result.Output["user"] != "bob" {
+		t.Errorf("unexpected output: %v", result.Output)
+	}
+	if result.Logs != "total 5.5\n" {
+		t.Errorf("unexpected logs: %q", result.Logs)
+	}
+
+	result, err = RunScript(context.Background(), `result = {"doc": json.decode('{"a": 1}'), "n": math.floor(2.7)}`, nil, limits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Output["n"] != int64(2) {
+		t.Errorf("unexpected output: %v", result.Output)
+	}
+}
+
+func TestRunScript_Limits(t *testing.T) {
+	steps := scriptLimits{MaxSteps: 10_000, Timeout: 5 * time.Second}
+	if _, err := RunScript(context.Background(), "while True:\n    pass\n", nil, steps); !errors.Is(err, errScriptSteps) {
+		t.Errorf("expected step limit error, got %v", err)
+	}
+
+	timeout := scriptLimits{MaxSteps: maxScriptMaxSteps, Timeout: 50 * time.Millisecond}
+	if _, err := RunScript(context.Background(), "while True:\n    pass\n", nil, timeout); !errors.Is(err, errScriptTimeout) {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	if _, err := RunScript(context.Background(), `load("os.star", "system")`, nil, parseScriptLimits(nil)); err == nil {
+		t.Error("expected load to be unavailable")
+	}
+	if _, err := RunScript(context.Background(), `result = [1, 2]`, nil, parseScriptLimits(nil)); err == nil || !strings.Contains(err.Error(), "字典") {
+		t.Errorf("expected non-dict result to be rejected, got %v", err)
+	}
+}
+
+func TestRunScript_MemoryLimit(t *testing.T) {
+	limits := parseScriptLimits(map[string]interface{}{"memoryLimit": 32, "scriptTimeout": 30000})
+	if limits.MemoryLimit != 32<<20 {
+		t.Fatalf("unexpected memory limit: %d", limits.MemoryLimit)
+	}
+
+	// a single step allocating far beyond the limit
+	if _, err := RunScript(context.Background(), `result = {"n": len("x" * (1 << 29))}`, nil, limits); !errors.Is(err, errScriptMemory) {
+		t.Errorf("expected memory limit error, got %v", err)
+	}
+	// memory growing over a few steps
+	doubling := "l = [0]\nfor i in range(40):\n    l = l + l\nresult = {\"n\": len(l)}\n"
+	if _, err := RunScript(context.Background(), doubling, nil, limits); !errors.Is(err, errScriptMemory) {
+		t.Errorf("expected memory limit error, got %v", err)
+	}
+	// scripts within the limit still run
+	result, err := RunScript(context.Background(), `result = {"n": len("x" * (1 << 20))}`, nil, limits)
+	if err != nil || result.Output["n"] != int64(1<<20) {
+		t.Errorf("unexpected result: %v %v", result, err)
+	}
+}
+
+func TestCheckScriptSyntax(t *testing.T) {
+	if err := CheckScriptSyntax("def main(params):\n    return {\"a\": undefined_name}\n"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := CheckScriptSyntax("def main(params)\n    return {}\n"); err == nil {
+		t.Error("expected syntax error")
+	}
+}
@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+// SQL 节点默认值
+const (
+	defaultSQLMaxRows = 1000
+	maxSQLRows        = 10000
+	defaultSQLTimeout = 30 * time.Second
+	maxSQLTimeout     = 5 * time.Minute
+)
+
+// 只读模式允许的语句
+var readOnlySQLKeywords = map[string]bool{
+	"SELECT":   true,
+	"WITH":     true,
+	"SHOW":     true,
+	"DESCRIBE": true,
+	"DESC":     true,
+	"EXPLAIN":  true,
+}
+
+// ========================== SQLNodeExecutor ==========================
+
+// SQLNodeExecutor SQL 节点执行器
+//
+//	{"datasource": "report", "sql": "SELECT * FROM orders WHERE user_id = ${userId} AND status IN (${statuses})",
+//	 "readOnly": true, "maxRows": 1000, "timeout": 30, "outputVariable": "rows"}
+//
+// 模板中的 ${var} 绑定为 SQL 参数 (数组展开为多个参数)，不会拼接到语句中；
+// datasource 必须引用 config.datasources 中的数据源 (不能访问主库)，默认只读并在只读事务中执行，
+// 数据源配置了 allow_write 时才能关闭只读
+type SQLNodeExecutor struct{}
+
+// NewSQLNodeExecutor 创建 SQL 节点执行器
+func NewSQLNodeExecutor() *SQLNodeExecutor {
+	return &SQLNodeExecutor{}
+}
+
+// Execute 执行 SQL 节点
+func (e *SQLNodeExecutor) Execute(ctx context.Context, state *repository.ChainState, node *dto.WorkflowNode) (map[string]interface{}, error) {
+	if node.Data == nil {
+		return nil, fmt.Errorf("SQL 节点缺少配置")
+	}
+
+	template := getStringFromMap(node.Data, "sql")
+	if strings.TrimSpace(template) == "" {
+		return nil, fmt.Errorf("SQL 节点未配置语句")
+	}
+
+	readOnly := true
+	if v, ok := node.Data["readOnly"].(bool); ok {
+		readOnly = v
+	}
+	maxRows := getIntFromMap(node.Data, "maxRows")
+	if maxRows <= 0 {
+		maxRows = defaultSQLMaxRows
+	}
+	if maxRows > maxSQLRows {
+		maxRows = maxSQLRows
+	}
+	timeout := defaultSQLTimeout
+	if seconds := getIntFromMap(node.Data, "timeout"); seconds > 0 {
+		timeout = time.Duration(min(seconds, int(maxSQLTimeout/time.Second))) * time.Second
+	}
+	outputVar := getStringFromMap(node.Data, "outputVariable")
+	if outputVar == "" {
+		outputVar = "rows"
+	}
+
+	// 绑定参数 (注释中的变量与引号不参与解析)
+	parts, paths, err := compileSQLTemplate(stripSQLComments(template))
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := bindSQLParams(parts, paths, state.SnapshotVariables())
+	if err != nil {
+		return nil, err
+	}
+
+	keyword, err := checkSQLStatement(query, readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	datasource := getStringFromMap(node.Data, "datasource")
+	var datasources map[string]config.DatasourceConfig
+	if cfg := config.Get(); cfg != nil {
+		datasources = cfg.Datasources
+	}
+	if err := checkSQLDatasource(datasources, datasource, readOnly); err != nil {
+		return nil, err
+	}
+
+	db, err := repository.GetDataSource(datasource)
+	if err != nil {
+		return nil, fmt.Errorf("获取数据源失败: %w", err)
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(queryCtx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return nil, fmt.Errorf("开启事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	var result map[string]interface{}
+	if readOnlySQLKeywords[keyword] {
+		result, err = querySQLRows(queryCtx, tx, query, args, maxRows, outputVar)
+	} else {
+		result, err = execSQLStatement(queryCtx, tx, query, args)
+	}
+	if err != nil {
+		if queryCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			return nil, fmt.Errorf("SQL 执行超时 (%s)", timeout)
+		}
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交事务失败: %w", err)
+	}
+	return result, nil
+}
+
+// checkSQLDatasource 检查节点引用的数据源：必须是已配置的数据源，写模式需要数据源允许写入
+func checkSQLDatasource(datasources map[string]config.DatasourceConfig, name string, readOnly bool) error {
+	if name == "" {
+		return fmt.Errorf("SQL 节点未配置数据源")
+	}
+	ds, ok := datasources[name]
+	if !ok {
+		return fmt.Errorf("数据源未配置: %s", name)
+	}
+	if !readOnly && !ds.AllowWrite {
+		return fmt.Errorf("数据源 %s 不允许写入", name)
+	}
+	return nil
+}
+
+// querySQLRows 执行查询，最多读取 maxRows 行
+func querySQLRows(ctx context.Context, tx *sql.Tx, query string, args []interface{}, maxRows int, outputVar string) (map[string]interface{}, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("执行 SQL 失败: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("读取列信息失败: %w", err)
+	}
+	columns := make([]string, len(columnTypes))
+	for i, ct := range columnTypes {
+		columns[i] = ct.Name()
+	}
+
+	records := make([]interface{}, 0)
+	truncated := false
+	for rows.Next() {
+		if len(records) >= maxRows {
+			truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("读取结果失败: %w", err)
+		}
+
+		record := make(map[string]interface{}, len(columns))
+		for i, name := range columns {
+			record[name] = convertSQLValue(columnTypes[i].DatabaseTypeName(), values[i])
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取结果失败: %w", err)
+	}
+
+	return map[string]interface{}{
+		outputVar:   records,
+		"rowCount":  len(records),
+		"columns":   columns,
+		"truncated": truncated,
+	}, nil
+}
+
+// execSQLStatement 执行写入语句 (仅非只读模式)
+func execSQLStatement(ctx context.Context, tx *sql.Tx, query string, args []interface{}) (map[string]interface{}, error) {
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("执行 SQL 失败: %w", err)
+	}
+	affected, _ := res.RowsAffected()
+	lastInsertID, _ := res.LastInsertId()
+	return map[string]interface{}{
+		"rowsAffected": affected,
+		"lastInsertId": lastInsertID,
+	}, nil
+}
+
+// compileSQLTemplate 按 ${var} 切分语句，返回语句片段与按顺序排列的变量路径 (len(parts) == len(paths)+1)
+// 变量只能作为值出现，位于字符串字面量中时报错
+func compileSQLTemplate(template string) ([]string, []string, error) {
+	var (
+		sb    strings.Builder
+		parts []string
+		paths []string
+		quote byte
+	)
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+
+		if quote != 0 {
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(template) {
+				i++
+				sb.WriteByte(template[i])
+				continue
+			}
+			if c == quote {
+				quote = 0
+			} else if c == '$' && i+1 < len(template) && template[i+1] == '{' {
+				return nil, nil, fmt.Errorf("SQL 模板变量不能位于字符串字面量中，请直接使用 ${变量} 作为参数")
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			sb.WriteByte(c)
+		case c == '?':
+			return nil, nil, fmt.Errorf("SQL 模板不支持 ? 占位符，请使用 ${变量} 引用参数")
+		case c == '$' && i+1 < len(template) && template[i+1] == '{':
+			end := strings.IndexByte(template[i+2:], '}')
+			if end < 0 {
+				return nil, nil, fmt.Errorf("SQL 模板变量未闭合: 位置 %d", i)
+			}
+			path := strings.TrimSpace(template[i+2 : i+2+end])
+			if path == "" {
+				return nil, nil, fmt.Errorf("SQL 模板变量为空: 位置 %d", i)
+			}
+			parts = append(parts, sb.String())
+			sb.Reset()
+			paths = append(paths, path)
+			i += end + 2
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	if quote != 0 {
+		return nil, nil, fmt.Errorf("SQL 语句中的引号未闭合")
+	}
+	parts = append(parts, sb.String())
+	return parts, paths, nil
+}
+
+// bindSQLParams 按顺序解析参数值生成语句，数组展开为 "?, ?, ?"，对象以 JSON 字符串传递
+func bindSQLParams(parts []string, paths []string, variables map[string]interface{}) (string, []interface{}, error) {
+	var (
+		sb   strings.Builder
+		args []interface{}
+	)
+	sb.WriteString(parts[0])
+	for i, path := range paths {
+		value, ok := lookupPath(variables, path)
+		if !ok {
+			return "", nil, fmt.Errorf("SQL 参数变量不存在: %s", path)
+		}
+
+		rv := reflect.ValueOf(value)
+		if value != nil && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+			if rv.Len() == 0 {
+				return "", nil, fmt.Errorf("SQL 参数 %s 为空数组", path)
+			}
+			for j := 0; j < rv.Len(); j++ {
+				if j > 0 {
+					sb.WriteString(", ")
+				}
+				sb.WriteByte('?')
+				args = append(args, sqlArg(rv.Index(j).Interface()))
+			}
+		} else {
+			sb.WriteByte('?')
+			args = append(args, sqlArg(value))
+		}
+		sb.WriteString(parts[i+1])
+	}
+	return sb.String(), args, nil
+}
+
+// sqlArg 将变量值转换为驱动可接受的参数
+func sqlArg(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int, int64, float64, []byte, time.Time:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// checkSQLStatement 检查语句：只允许单条语句，只读模式下只允许查询类语句，返回语句首个关键字
+func checkSQLStatement(query string, readOnly bool) (string, error) {
+	stripped := strings.TrimSpace(stripSQLComments(query))
+	stripped = strings.TrimSpace(strings.TrimSuffix(stripped, ";"))
+	if stripped == "" {
+		return "", fmt.Errorf("SQL 语句为空")
+	}
+	if strings.Contains(maskSQLLiterals(stripped), ";") {
+		return "", fmt.Errorf("SQL 节点只允许执行单条语句")
+	}
+
+	keyword := strings.ToUpper(strings.FieldsFunc(stripped, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '('
+	})[0])
+
+	if readOnly {
+		if !readOnlySQLKeywords[keyword] {
+			return "", fmt.Errorf("只读模式不允许执行 %s 语句", keyword)
+		}
+		upper := strings.ToUpper(maskSQLLiterals(stripped))
+		for _, forbidden := range []string{"INTO OUTFILE", "INTO DUMPFILE", "FOR UPDATE", "LOCK IN SHARE MODE"} {
+			if strings.Contains(upper, forbidden) {
+				return "", fmt.Errorf("只读模式不允许使用 %s", forbidden)
+			}
+		}
+	}
+	return keyword, nil
+}
+
+// stripSQLComments 去掉 SQL 注释 (-- / # / /* */)，保留字符串字面量
+func stripSQLComments(query string) string {
+	var (
+		sb    strings.Builder
+		quote byte
+	)
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		if quote != 0 {
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(query) {
+				i++
+				sb.WriteByte(query[i])
+			} else if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			sb.WriteByte(c)
+		case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "-- ")):
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			sb.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 3
+			}
+			sb.WriteByte(' ')
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// maskSQLLiterals 将字符串字面量内容替换为空格，便于检查语句结构
+func maskSQLLiterals(query string) string {
+	b := []byte(query)
+	var quote byte
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if quote != 0 {
+			if c == '\\' && i+1 < len(b) {
+				b[i], b[i+1] = ' ', ' '
+				i++
+			} else if c == quote {
+				quote = 0
+			} else {
+				b[i] = ' '
+			}
+			continue
+		}
+		if c == '\'' || c == '"' || c == '`' {
+			quote = c
+		}
+	}
+	return string(b)
+}
+
+// convertSQLValue 将驱动返回的原始值按列类型转换
+func convertSQLValue(dbType string, value interface{}) interface{} {
+	raw, ok := value.([]byte)
+	if !ok {
+		return value
+	}
+	s := string(raw)
+	switch strings.ToUpper(dbType) {
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "YEAR":
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+	case "UNSIGNED TINYINT", "UNSIGNED SMALLINT", "UNSIGNED MEDIUMINT", "UNSIGNED INT", "UNSIGNED BIGINT":
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n
+		}
+	case "FLOAT", "DOUBLE", "DECIMAL":
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f
+		}
+	case "JSON":
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err == nil {
+			return v
+		}
+	}
+	return s
+}
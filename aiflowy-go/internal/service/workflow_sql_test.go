@@ -0,0 +1,96 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/config"
+)
+
+func TestSQL_BindParams(t *testing.T) {
+	variables := map[string]interface{}{
+		"userId":   "42",
+		"statuses": []interface{}{"paid", "shipped"},
+		"filter":   map[string]interface{}{"minAmount": 10.5},
+	}
+
+	if _, _, err := compileSQLTemplate("SELECT * FROM t WHERE note <> '${note}'"); err == nil {
+		t.Error("expected error for variable inside string literal")
+	}
+
+	parts, paths, err := compileSQLTemplate(stripSQLComments(
+		"SELECT * FROM orders -- user's orders ${ignored}\n" +
+			"WHERE user_id = ${userId} AND status IN (${statuses}) AND amount > ${filter.minAmount} AND note <> '$1'",
+	))
+	if err != nil {
+		t.Fatalf("compile failed: %v", err)
+	}
+	query, args, err := bindSQLParams(parts, paths, variables)
+	if err != nil {
+		t.Fatalf("bind failed: %v", err)
+	}
+
+	expectQuery := "SELECT * FROM orders  WHERE user_id = ? AND status IN (?, ?) AND amount > ? AND note <> '$1'"
+	if query != expectQuery {
+		t.Errorf("unexpected query:\n%s\n%s", query, expectQuery)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"42", "paid", "shipped", 10.5}) {
+		t.Errorf("unexpected args: %v", args)
+	}
+
+	if _, _, err := bindSQLParams([]string{"SELECT ", ""}, []string{"missing"}, variables); err == nil {
+		t.Error("expected error for missing variable")
+	}
+	if _, _, err := compileSQLTemplate("SELECT * FROM t WHERE id = ?"); err == nil {
+		t.Error("expected error for raw placeholder")
+	}
+}
+
+func TestSQL_CheckStatement(t *testing.T) {
+	tests := []struct {
+		query    string
+		readOnly bool
+		ok       bool
+	}{
+		{"SELECT 1", true, true},
+		{"  with t as (select 1) select * from t;", true, true},
+		{"/* report */ SHOW TABLES", true, true},
+		{"SELECT ';' AS sep", true, true},
+		{"DELETE FROM users", true, false},
+		{"SELECT 1; DROP TABLE users", true, false},
+		{"SELECT * FROM users FOR UPDATE", true, false},
+		{"SELECT * FROM users INTO OUTFILE '/tmp/x'", true, false},
+		{"UPDATE users SET name = ?", false, true},
+		{"-- only a comment", true, false},
+	}
+
+	for _, tt := range tests {
+		_, err := checkSQLStatement(tt.query, tt.readOnly)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q (readOnly=%v): expected ok=%v, got err=%v", tt.query, tt.readOnly, tt.ok, err)
+		}
+	}
+}
+
+func TestSQL_CheckDatasource(t *testing.T) {
+	datasources := map[string]config.DatasourceConfig{
+		"report": {},
+		"orders": {AllowWrite: true},
+	}
+
+	if err := checkSQLDatasource(datasources, "", true); err == nil {
+		t.Error("expected error when no datasource is referenced, the main database must not be used")
+	}
+	if err := checkSQLDatasource(datasources, "main", true); err == nil {
+		t.Error("expected error for unknown datasource")
+	}
+	if err := checkSQLDatasource(datasources, "report", true); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := checkSQLDatasource(datasources, "report", false); err == nil {
+		t.Error("expected write mode to be rejected without allow_write")
+	}
+	if err := checkSQLDatasource(datasources, "orders", false); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/google/uuid"
+)
+
+// 子工作流最大嵌套层数
+const maxSubWorkflowDepth = 5
+
+type execStepKey struct{}
+
+// withExecStep 在 context 中携带当前节点的执行步骤，子工作流据此关联父步骤
+func withExecStep(ctx context.Context, step *entity.WorkflowExecStep) context.Context {
+	return context.WithValue(ctx, execStepKey{}, step)
+}
+
+// execStepFromContext 获取 context 中的执行步骤
+func execStepFromContext(ctx context.Context) *entity.WorkflowExecStep {
+	step, _ := ctx.Value(execStepKey{}).(*entity.WorkflowExecStep)
+	return step
+}
+
+type workflowCallStackKey struct{}
+
+// workflowCallStack 当前执行链上的工作流 ID (由外到内)
+func workflowCallStack(ctx context.Context, state *repository.ChainState) []int64 {
+	if stack, ok := ctx.Value(workflowCallStackKey{}).([]int64); ok {
+		return stack
+	}
+	return []int64{state.WorkflowID}
+}
+
+// executeSubWorkflow 同步执行子工作流直到结束
+// 子执行拥有独立的执行记录并关联父执行步骤，继承父节点的 context (取消与超时)
+func (e *ChainExecutor) executeSubWorkflow(ctx context.Context, parent *repository.ChainState, workflowID string, variables map[string]interface{}) (*repository.ChainState, error) {
+	wfID, err := strconv.ParseInt(workflowID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的工作流 ID: %s", workflowID)
+	}
+
+	// 检查循环调用与嵌套层数
+	stack := workflowCallStack(ctx, parent)
+	for i, id := range stack {
+		if id == wfID {
+			path := make([]string, 0, len(stack)-i+1)
+			for _, caller := range stack[i:] {
+				path = append(path, strconv.FormatInt(caller, 10))
+			}
+			path = append(path, workflowID)
+			return nil, fmt.Errorf("检测到子工作流循环调用: %s", strings.Join(path, " -> "))
+		}
+	}
+	if len(stack) > maxSubWorkflowDepth {
+		return nil, fmt.Errorf("子工作流嵌套层数超过上限 %d", maxSubWorkflowDepth)
+	}
+
+	dbCtx := context.WithoutCancel(ctx)
+
+	// 加载并解析工作流的发布版本，只能调用同一租户的工作流
+	parentWorkflow, err := e.workflowRepo.GetWorkflowByID(dbCtx, parent.WorkflowID)
+	if err != nil {
+		return nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	if parentWorkflow == nil {
+		return nil, fmt.Errorf("工作流不存在: %d", parent.WorkflowID)
+	}
+	workflow, version, err := e.loadRunnableWorkflow(dbCtx, workflowID, false, parentWorkflow.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	definition, err := e.parser.Parse(version.Content)
+	if err != nil {
+		return nil, fmt.Errorf("解析工作流定义失败: %w", err)
+	}
+	if err := e.parser.Validate(definition); err != nil {
+		return nil, fmt.Errorf("工作流定义无效: %w", err)
+	}
+	variables, err = initWorkflowVariables(e.parser, definition, variables)
+	if err != nil {
+		return nil, fmt.Errorf("子工作流 %s 的参数无效: %w", workflow.Title, err)
+	}
+
+	state := &repository.ChainState{
+		ExecuteID:  uuid.New().String(),
+		WorkflowID: workflow.ID,
+		Status:     entity.ExecStatusRunning,
+		Variables:  variables,
+		NodeStates: make(map[string]*repository.NodeState),
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+
+	// 创建子执行记录，执行人沿用父执行
+	inputJSON, _ := json.Marshal(variables)
+	execResult := &entity.WorkflowExecResult{
+		ExecKey:       state.ExecuteID,
+		WorkflowID:    workflow.ID,
+		Title:         workflow.Title,
+		Description:   workflow.Description,
+		Input:         string(inputJSON),
+		WorkflowJSON:  version.Content,
+		StartTime:     time.Now(),
+		Status:        entity.ExecStatusRunning,
+		ParentExecKey: parent.ExecuteID,
+
+		WorkflowVersion: version.Version,
+	}
+	if step := execStepFromContext(ctx); step != nil {
+		execResult.ParentStepID = step.ID
+	}
+	if parentRecord, _ := e.execRepo.GetExecResultByExecKey(dbCtx, parent.ExecuteID); parentRecord != nil {
+		execResult.CreatedKey = parentRecord.CreatedKey
+		execResult.CreatedBy = parentRecord.CreatedBy
+	}
+	if err := e.execRepo.CreateExecResult(dbCtx, execResult); err != nil {
+		return nil, fmt.Errorf("创建执行记录失败: %w", err)
+	}
+	state.RecordID = execResult.ID
+	e.states.Store(state.ExecuteID, state)
+	e.checkpoint(dbCtx, state)
+
+	runCtx, release := e.newRunContext(context.WithValue(ctx, workflowCallStackKey{}, append(stack[:len(stack):len(stack)], wfID)), state, definition)
+	e.executeWorkflow(runCtx, state, definition)
+	release()
+
+	switch state.GetStatus() {
+	case entity.ExecStatusCompleted:
+		return state, nil
+	case entity.ExecStatusSuspended:
+		// 子工作流不支持等待人工输入，直接结束子执行
+		cause := fmt.Errorf("子工作流 %s 暂停等待输入，子工作流中不支持人工确认节点", workflow.Title)
+		if state.Cancel(cause) {
+			e.recordCancelled(dbCtx, state, cause)
+		}
+		e.evictState(state)
+		return nil, cause
+	}
+
+	if ctx.Err() != nil {
+		// 父执行被取消，子执行随之结束
+		cause := context.Cause(ctx)
+		if state.Cancel(cause) {
+			e.recordCancelled(dbCtx, state, cause)
+		}
+		e.evictState(state)
+		return nil, cause
+	}
+	snapshot := state.Snapshot()
+	if snapshot.Error != nil {
+		return nil, fmt.Errorf("子工作流 %s 执行失败: %w", workflow.Title, snapshot.Error)
+	}
+	return nil, fmt.Errorf("子工作流 %s 未完成", workflow.Title)
+}
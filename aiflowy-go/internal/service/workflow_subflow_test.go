@@ -0,0 +1,37 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+)
+
+func TestSubWorkflow_CycleAndDepth(t *testing.T) {
+	executor := &ChainExecutor{parser: NewWorkflowDSLParser()}
+	parent := &repository.ChainState{ExecuteID: "parent", WorkflowID: 1}
+
+	// 1 -> 1
+	_, err := executor.executeSubWorkflow(context.Background(), parent, "1", nil)
+	if err == nil || !strings.Contains(err.Error(), "1 -> 1") {
+		t.Errorf("expected self recursion to be rejected, got %v", err)
+	}
+
+	// 1 -> 2 -> 3 -> 2
+	ctx := context.WithValue(context.Background(), workflowCallStackKey{}, []int64{1, 2, 3})
+	_, err = executor.executeSubWorkflow(ctx, parent, "2", nil)
+	if err == nil || !strings.Contains(err.Error(), "2 -> 3 -> 2") {
+		t.Errorf("expected indirect recursion to be rejected, got %v", err)
+	}
+
+	stack := make([]int64, maxSubWorkflowDepth+1)
+	for i := range stack {
+		stack[i] = int64(i + 10)
+	}
+	ctx = context.WithValue(context.Background(), workflowCallStackKey{}, stack)
+	_, err = executor.executeSubWorkflow(ctx, parent, "99", nil)
+	if err == nil || !strings.Contains(err.Error(), "嵌套层数") {
+		t.Errorf("expected depth limit to be enforced, got %v", err)
+	}
+}
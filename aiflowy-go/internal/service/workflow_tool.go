@@ -3,7 +3,9 @@ package service
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cloudwego/eino/schema"
 
@@ -11,8 +13,12 @@ import (
 	"github.com/aiflowy/aiflowy-go/internal/entity"
 	"github.com/aiflowy/aiflowy-go/internal/repository"
 	aitool "github.com/aiflowy/aiflowy-go/internal/service/tool"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
 )
 
+// 对话中调用工作流工具的最长等待时间，超时后取消执行
+const workflowToolTimeout = 5 * time.Minute
+
 // WorkflowToolService 工作流工具服务
 type WorkflowToolService struct {
 	repo *repository.WorkflowRepository
@@ -27,11 +33,13 @@ func NewWorkflowToolService() *WorkflowToolService {
 
 // ========================== WorkflowTool (Eino Tool 实现) ==========================
 
-// WorkflowTool 实现 Eino 的 Tool 接口
+// WorkflowTool 实现 Eino 的 Tool 接口，调用时同步执行工作流的发布版本
 type WorkflowTool struct {
 	workflow   *entity.Workflow
 	parameters []*dto.WorkflowParameter
 	service    *WorkflowToolService
+	executor   *ChainExecutor
+	timeout    time.Duration
 }
 
 // NewWorkflowTool 创建 WorkflowTool
@@ -39,6 +47,8 @@ func NewWorkflowTool(workflow *entity.Workflow) *WorkflowTool {
 	wt := &WorkflowTool{
 		workflow: workflow,
 		service:  NewWorkflowToolService(),
+		executor: GetChainExecutor(),
+		timeout:  workflowToolTimeout,
 	}
 
 	// 解析工作流参数
@@ -53,16 +63,19 @@ func NewWorkflowTool(workflow *entity.Workflow) *WorkflowTool {
 	return wt
 }
 
-// Name 返回工具名称
+// Name 返回工具名称，工具名只能包含字母、数字、下划线与连字符，未设置英文名时按 ID 生成
 func (t *WorkflowTool) Name() string {
 	if t.workflow.EnglishName != "" {
 		return t.workflow.EnglishName
 	}
-	return t.workflow.Title
+	return fmt.Sprintf("workflow_%d", t.workflow.ID)
 }
 
-// Description 返回工具描述
+// Description 返回工具描述，未设置描述时使用标题
 func (t *WorkflowTool) Description() string {
+	if t.workflow.Description == "" {
+		return t.workflow.Title
+	}
 	return t.workflow.Description
 }
 
@@ -97,60 +110,151 @@ func (t *WorkflowTool) Parameters() map[string]*schema.ParameterInfo {
 	return params
 }
 
-// Execute 执行工具 (工作流)
+// Execute 执行工具 (工作流)：等待执行结束并返回工作流的输出
+// 流式对话中执行事件以 workflow 域转发给用户；暂停等待人工确认时转发表单请求并返回暂停信息，
+// 用户提交表单后通过工作流的恢复接口继续执行
 func (t *WorkflowTool) Execute(ctx context.Context, args map[string]interface{}) (interface{}, error) {
-	// TODO: Stage 11 将实现工作流执行引擎
-	// 目前返回占位信息
-	return map[string]interface{}{
-		"status":  "pending",
-		"message": "工作流执行功能将在 Stage 11 实现",
-		"workflow": map[string]interface{}{
-			"id":    fmt.Sprintf("%d", t.workflow.ID),
-			"title": t.workflow.Title,
-		},
-		"input": args,
-	}, nil
-}
-
-// ========================== 加载 Bot 工作流工具 ==========================
+	tc, _ := ctx.Value(workflowToolKey{}).(*workflowToolContext)
+	userID := ""
+	if tc != nil {
+		userID = strconv.FormatInt(tc.userID, 10)
+	}
 
-// LoadBotWorkflowTools 加载 Bot 关联的工作流工具并注册到 Registry
-func (s *WorkflowToolService) LoadBotWorkflowTools(ctx context.Context, botID int64) ([]*schema.ToolInfo, error) {
-	// 获取 Bot 关联的工作流
-	workflows, err := s.repo.ListWorkflowsByBotID(ctx, botID)
+	sub, err := t.executor.ExecuteStream(ctx, strconv.FormatInt(t.workflow.ID, 10), false, args, userID, "")
 	if err != nil {
 		return nil, err
 	}
+	executeID := sub.ExecuteID
 
-	if len(workflows) == 0 {
-		return nil, nil
+	waitCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	if err := t.wait(waitCtx, sub, tc); err != nil {
+		// 对话不再等待，取消执行避免遗留后台运行
+		t.executor.Cancel(context.WithoutCancel(ctx), executeID, t.workflow.TenantID)
+		if ctx.Err() == nil && waitCtx.Err() != nil {
+			return nil, fmt.Errorf("工作流 %s 执行超时 (%s)", t.workflow.Title, t.timeout)
+		}
+		return nil, err
 	}
 
-	var toolInfos []*schema.ToolInfo
+	state, err := t.executor.loadState(ctx, executeID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, fmt.Errorf("执行记录不存在: %s", executeID)
+	}
+	return workflowToolResult(executeID, state.Snapshot())
+}
 
-	for _, workflow := range workflows {
-		// 创建 WorkflowTool
-		workflowTool := NewWorkflowTool(workflow)
-
-		// 转换为 Eino ToolInfo
-		toolInfo := &schema.ToolInfo{
-			Name: workflowTool.Name(),
-			Desc: workflowTool.Description(),
-			ParamsOneOf: schema.NewParamsOneOfByParams(
-				convertWorkflowParams(workflowTool.Parameters()),
-			),
+// wait 转发执行事件直到执行结束或暂停；订阅因消费过慢断开时重新附加
+func (t *WorkflowTool) wait(ctx context.Context, sub *WorkflowSubscription, tc *workflowToolContext) error {
+	for {
+		if err := relayWorkflowEvents(ctx, sub, tc); err != nil {
+			sub.Close()
+			return err
+		}
+		if !sub.Lagged() {
+			return nil
 		}
-		toolInfos = append(toolInfos, toolInfo)
 
-		// 注册到全局 Registry (用于执行)
-		registry := aitool.GetRegistry()
-		registry.Register(workflowTool)
+		next, err := t.executor.Subscribe(ctx, sub.ExecuteID, t.workflow.TenantID)
+		if err != nil {
+			return err
+		}
+		sub = next
 	}
+}
 
-	return toolInfos, nil
+// workflowToolResult 根据执行结束时的状态生成工具结果
+func workflowToolResult(executeID string, snapshot *repository.ChainState) (interface{}, error) {
+	switch snapshot.Status {
+	case entity.ExecStatusCompleted:
+		return snapshot.Result, nil
+	case entity.ExecStatusSuspended:
+		return map[string]interface{}{
+			"status":    "suspended",
+			"executeId": executeID,
+			"message":   "工作流正在等待用户填写表单，用户提交后将继续执行",
+		}, nil
+	default:
+		if snapshot.Error != nil {
+			return nil, fmt.Errorf("工作流执行失败: %w", snapshot.Error)
+		}
+		return nil, fmt.Errorf("工作流执行未完成: %s", executeID)
+	}
 }
 
-// convertWorkflowParams 转换参数格式
-func convertWorkflowParams(params map[string]*schema.ParameterInfo) map[string]*schema.ParameterInfo {
-	return params
+// ========================== 对话事件转发 ==========================
+
+type workflowToolKey struct{}
+
+// workflowToolContext 对话中调用工作流工具的执行人与事件转发目标
+type workflowToolContext struct {
+	userID  int64
+	builder *protocol.Builder
+	emit    StreamCallback
+}
+
+// withWorkflowToolContext 在 context 中携带对话的执行人与事件转发目标，emit 为 nil 时不转发事件 (非流式对话)
+func withWorkflowToolContext(ctx context.Context, userID int64, builder *protocol.Builder, emit StreamCallback) context.Context {
+	return context.WithValue(ctx, workflowToolKey{}, &workflowToolContext{userID: userID, builder: builder, emit: emit})
+}
+
+// relayWorkflowEvents 将执行的 workflow 与 interaction 域事件转发到对话，直到事件通道关闭
+// 节点的 LLM 增量输出与执行的 system.done 不转发，避免与对话自身的消息混淆
+func relayWorkflowEvents(ctx context.Context, sub *WorkflowSubscription, tc *workflowToolContext) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case env, ok := <-sub.Events():
+			if !ok {
+				return nil
+			}
+			if tc == nil || tc.emit == nil {
+				continue
+			}
+			if relayed := relayWorkflowEvent(tc.builder, sub.ExecuteID, env); relayed != nil {
+				// 推送失败说明对话已断开，由对话自身的推送处理
+				tc.emit(relayed)
+			}
+		}
+	}
+}
+
+// relayWorkflowEvent 将执行事件转换为对话事件并附加执行 ID (用于恢复执行)，不转发的事件返回 nil
+// 事件载荷由所有订阅者共享，修改前先复制
+func relayWorkflowEvent(b *protocol.Builder, executeID string, env *protocol.Envelope) *protocol.Envelope {
+	relayed := b.Relay(env)
+	switch payload := env.Payload.(type) {
+	case *protocol.WorkflowStatusPayload:
+		p := *payload
+		p.ExecuteID = executeID
+		relayed.Payload = &p
+	case *protocol.FormRequestPayload:
+		p := *payload
+		p.ExecuteID = executeID
+		relayed.Payload = &p
+	default:
+		return nil
+	}
+	return relayed
+}
+
+// ========================== 加载 Bot 工作流工具 ==========================
+
+// LoadBotWorkflowTools 加载 Bot 关联且已发布的工作流工具，按工作流 ID 排序
+func (s *WorkflowToolService) LoadBotWorkflowTools(ctx context.Context, botID int64) ([]aitool.Tool, error) {
+	// 只加载已发布的，参数取自发布版本
+	workflows, err := s.repo.ListPublishedWorkflowsByBotID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	tools := make([]aitool.Tool, 0, len(workflows))
+	for _, workflow := range workflows {
+		tools = append(tools, NewWorkflowTool(workflow))
+	}
+	return tools, nil
 }
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	"github.com/aiflowy/aiflowy-go/internal/repository"
+	"github.com/aiflowy/aiflowy-go/pkg/protocol"
+)
+
+func TestWorkflowTool_NameAndDescription(t *testing.T) {
+	tool := &WorkflowTool{workflow: &entity.Workflow{ID: 42, Title: "订单查询"}}
+	if tool.Name() != "workflow_42" || tool.Description() != "订单查询" {
+		t.Errorf("unexpected fallbacks: %q %q", tool.Name(), tool.Description())
+	}
+
+	tool.workflow.EnglishName = "query_order"
+	tool.workflow.Description = "Query an order by its number"
+	if tool.Name() != "query_order" || tool.Description() != "Query an order by its number" {
+		t.Errorf("unexpected name and description: %q %q", tool.Name(), tool.Description())
+	}
+}
+
+func TestRelayWorkflowEvents(t *testing.T) {
+	hub := newWorkflowEventHub()
+	sub := hub.subscribe("exec-1", func(b *protocol.Builder) ([]*protocol.Envelope, bool) {
+		return []*protocol.Envelope{b.WorkflowStatus("", protocol.WorkflowStateStart, "")}, false
+	})
+	hub.publish("exec-1", func(b *protocol.Builder) []*protocol.Envelope {
+		return []*protocol.Envelope{b.LLMMessageDelta("partial")}
+	})
+	hub.finish("exec-1", func(b *protocol.Builder) []*protocol.Envelope {
+		return []*protocol.Envelope{
+			b.WorkflowNodeStatus("confirm", "确认", protocol.WorkflowStateSuspend, "interaction", nil),
+			formRequestEvent(b, "confirm", "确认", []*repository.SuspendedParam{{Name: "approved", Type: "boolean"}}),
+			b.SystemDone(nil),
+		}
+	})
+
+	var relayed []*protocol.Envelope
+	tc := &workflowToolContext{
+		builder: protocol.NewBuilder("conv-1", "msg-1"),
+		emit: func(env *protocol.Envelope) error {
+			relayed = append(relayed, env)
+			return nil
+		},
+	}
+	if err := relayWorkflowEvents(context.Background(), sub, tc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the llm delta and system.done stay inside the workflow stream
+	if len(relayed) != 3 {
+		t.Fatalf("expected 3 relayed events, got %d", len(relayed))
+	}
+	for _, env := range relayed {
+		if env.ConversationID != "conv-1" || env.MessageID != "msg-1" {
+			t.Errorf("event not addressed to the chat: %+v", env)
+		}
+	}
+	form, ok := relayed[2].Payload.(*protocol.FormRequestPayload)
+	if !ok || relayed[2].Domain != protocol.DomainInteraction || form.ExecuteID != "exec-1" || form.FormID != "confirm" {
+		t.Errorf("unexpected form request: %+v", relayed[2])
+	}
+	status := relayed[1].Payload.(*protocol.WorkflowStatusPayload)
+	if status.ExecuteID != "exec-1" || status.State != protocol.WorkflowStateSuspend {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestRelayWorkflowEvents_Cancelled(t *testing.T) {
+	hub := newWorkflowEventHub()
+	sub := hub.subscribe("exec-1", func(b *protocol.Builder) ([]*protocol.Envelope, bool) {
+		return nil, false
+	})
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := relayWorkflowEvents(ctx, sub, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context error, got %v", err)
+	}
+}
+
+func TestWorkflowToolResult(t *testing.T) {
+	result, err := workflowToolResult("exec-1", &repository.ChainState{
+		Status: entity.ExecStatusCompleted,
+		Result: map[string]interface{}{"answer": 42},
+	})
+	if err != nil || result.(map[string]interface{})["answer"] != 42 {
+		t.Errorf("unexpected result: %v %v", result, err)
+	}
+
+	result, err = workflowToolResult("exec-1", &repository.ChainState{Status: entity.ExecStatusSuspended})
+	if err != nil || result.(map[string]interface{})["executeId"] != "exec-1" {
+		t.Errorf("unexpected suspended result: %v %v", result, err)
+	}
+
+	_, err = workflowToolResult("exec-1", &repository.ChainState{Status: entity.ExecStatusFailed, Error: errors.New("boom")})
+	if err == nil {
+		t.Error("expected failed execution to return an error")
+	}
+}
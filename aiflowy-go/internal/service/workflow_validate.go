@@ -0,0 +1,1067 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+// knownNodeTypes 执行引擎支持的节点类型
+var knownNodeTypes = map[string]bool{
+	dto.NodeTypeStart:        true,
+	dto.NodeTypeEnd:          true,
+	dto.NodeTypeLLM:          true,
+	dto.NodeTypeTool:         true,
+	dto.NodeTypeCondition:    true,
+	dto.NodeTypeHumanConfirm: true,
+	dto.NodeTypeWorkflow:     true,
+	dto.NodeTypeCode:         true,
+	dto.NodeTypePlugin:       true,
+	dto.NodeTypeDoc:          true,
+	dto.NodeTypeSQL:          true,
+	dto.NodeTypeHTTP:         true,
+	dto.NodeTypeKnowledge:    true,
+	dto.NodeTypeClassifier:   true,
+	dto.NodeTypeExtractor:    true,
+	dto.NodeTypeLoop:         true,
+}
+
+// variableRefPattern 匹配配置中的 ${path} 变量引用
+var variableRefPattern = regexp.MustCompile(`\$\{([^{}]*)\}`)
+
+// workflowValidator 工作流静态校验，收集全部问题而不是在第一个错误处返回
+type workflowValidator struct {
+	parser     *WorkflowDSLParser
+	definition *dto.WorkflowDefinition
+	nodes      map[string]*dto.WorkflowNode
+	incoming   map[string][]string
+	result     *dto.WorkflowValidationResult
+}
+
+// Check 完整校验工作流定义：结构、节点类型与配置、表达式与脚本语法、环路、可达性、变量引用与参数类型
+func (p *WorkflowDSLParser) Check(definition *dto.WorkflowDefinition) *dto.WorkflowValidationResult {
+	v := &workflowValidator{
+		parser:     p,
+		definition: definition,
+		nodes:      make(map[string]*dto.WorkflowNode),
+		incoming:   make(map[string][]string),
+		result:     &dto.WorkflowValidationResult{Valid: true, Issues: []*dto.WorkflowValidationIssue{}},
+	}
+	if definition == nil {
+		v.report(&dto.WorkflowValidationIssue{Level: dto.ValidationLevelError, Code: dto.ValidationInvalidStructure, Message: "工作流定义为空"})
+		return v.result
+	}
+
+	// 逐节点的检查不依赖图结构，结构有误时也能报告
+	structureOK := v.checkStructure()
+	v.checkNodes()
+	v.checkExpressions()
+	v.checkScripts()
+	if !structureOK {
+		return v.result
+	}
+
+	v.checkCycles()
+	v.checkReachability()
+	v.checkReferences()
+	return v.result
+}
+
+// CheckContent 解析并完整校验工作流 JSON
+func (p *WorkflowDSLParser) CheckContent(content string) *dto.WorkflowValidationResult {
+	definition, err := p.Parse(content)
+	if err != nil {
+		return &dto.WorkflowValidationResult{
+			Issues: []*dto.WorkflowValidationIssue{{
+				Level:   dto.ValidationLevelError,
+				Code:    dto.ValidationInvalidJSON,
+				Message: err.Error(),
+			}},
+		}
+	}
+	return p.Check(definition)
+}
+
+// ========================== 问题记录 ==========================
+
+func (v *workflowValidator) report(issue *dto.WorkflowValidationIssue) {
+	if issue.Level == dto.ValidationLevelError {
+		v.result.Valid = false
+	}
+	v.result.Issues = append(v.result.Issues, issue)
+}
+
+func (v *workflowValidator) nodeIssue(level, code string, node *dto.WorkflowNode, field, format string, args ...interface{}) {
+	v.report(&dto.WorkflowValidationIssue{
+		Level:   level,
+		Code:    code,
+		NodeID:  node.ID,
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *workflowValidator) edgeIssue(level, code string, edge *dto.WorkflowEdge, format string, args ...interface{}) {
+	v.report(&dto.WorkflowValidationIssue{
+		Level:   level,
+		Code:    code,
+		Source:  edge.Source,
+		Target:  edge.Target,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// ========================== 结构 ==========================
+
+// checkStructure 检查开始/结束节点、节点 ID、循环体归属与连线，结构完整时返回 true
+func (v *workflowValidator) checkStructure() bool {
+	ok := true
+	structureError := func(nodeID, format string, args ...interface{}) {
+		ok = false
+		v.report(&dto.WorkflowValidationIssue{
+			Level:   dto.ValidationLevelError,
+			Code:    dto.ValidationInvalidStructure,
+			NodeID:  nodeID,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if len(v.definition.Nodes) == 0 {
+		structureError("", "工作流没有节点")
+		return false
+	}
+	if v.parser.GetStartNode(v.definition) == nil {
+		structureError("", "工作流没有开始节点")
+	}
+	if len(v.parser.GetEndNodes(v.definition)) == 0 {
+		structureError("", "工作流没有结束节点")
+	}
+
+	for _, node := range v.definition.Nodes {
+		switch {
+		case node.ID == "":
+			structureError("", "节点 ID 不能为空")
+		case v.nodes[node.ID] != nil:
+			structureError(node.ID, "节点 ID 重复: %s", node.ID)
+		case strings.Contains(node.ID, "#"):
+			// "#" 用于标记循环迭代
+			structureError(node.ID, "节点 ID 不能包含 #: %s", node.ID)
+		default:
+			v.nodes[node.ID] = node
+		}
+	}
+
+	// 循环体节点必须属于循环节点
+	for _, node := range v.definition.Nodes {
+		if node.ParentID == "" {
+			continue
+		}
+		if parent := v.nodes[node.ParentID]; parent == nil || parent.Type != dto.NodeTypeLoop {
+			structureError(node.ID, "节点 %s 的所属循环节点不存在: %s", node.ID, node.ParentID)
+		}
+	}
+
+	for _, edge := range v.definition.Edges {
+		source, target := v.nodes[edge.Source], v.nodes[edge.Target]
+		switch {
+		case source == nil:
+			ok = false
+			v.edgeIssue(dto.ValidationLevelError, dto.ValidationDanglingEdge, edge, "边的源节点不存在: %s", edge.Source)
+		case target == nil:
+			ok = false
+			v.edgeIssue(dto.ValidationLevelError, dto.ValidationDanglingEdge, edge, "边的目标节点不存在: %s", edge.Target)
+		case source.ParentID != target.ParentID:
+			ok = false
+			v.edgeIssue(dto.ValidationLevelError, dto.ValidationDanglingEdge, edge, "边不能跨越循环体: %s -> %s", edge.Source, edge.Target)
+		default:
+			v.incoming[edge.Target] = append(v.incoming[edge.Target], edge.Source)
+		}
+	}
+
+	return ok
+}
+
+// ========================== 节点 ==========================
+
+// checkNodes 检查节点类型、必填配置与参数声明
+func (v *workflowValidator) checkNodes() {
+	topLevel := v.topLevelVariables()
+	for _, node := range v.definition.Nodes {
+		// 节点输出以节点 ID 保存在变量中，与开始参数、全局变量重名时变量优先
+		if _, ok := topLevel[node.ID]; ok {
+			v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationInvalidStructure, node, "id",
+				"节点 ID %s 与开始参数或全局变量重名，无法通过 ${%s.字段} 引用节点输出", node.ID, node.ID)
+		}
+		if !knownNodeTypes[node.Type] {
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationUnknownNodeType, node, "type",
+				"节点 %s 的类型未知: %s", nodeDisplayName(node), node.Type)
+			continue
+		}
+		v.checkNodeConfig(node)
+		v.checkParameters(node)
+	}
+}
+
+// topLevelVariables 执行开始时就有的变量 (变量名 -> 类型)：开始节点的参数与全局变量
+func (v *workflowValidator) topLevelVariables() map[string]string {
+	vars := make(map[string]string)
+	for name, value := range v.definition.Variables {
+		vars[name] = valueKind(value)
+	}
+	if start := v.parser.GetStartNode(v.definition); start != nil {
+		for _, param := range v.declaredParameters(start) {
+			if param.Name != "" {
+				vars[param.Name] = normalizeParamType(param.Type)
+			}
+		}
+	}
+	return vars
+}
+
+// checkNodeConfig 检查节点执行所需的配置
+func (v *workflowValidator) checkNodeConfig(node *dto.WorkflowNode) {
+	missing := func(field, what string) {
+		v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, field,
+			"节点 %s 未配置%s", nodeDisplayName(node), what)
+	}
+	data := node.Data
+
+	switch node.Type {
+	case dto.NodeTypeLLM:
+		if getStringFromMap(data, "modelId") == "" && getStringFromMap(data, "llmId") == "" {
+			missing("modelId", "模型")
+		}
+		if _, err := parseLLMOutputSchema(data); err != nil {
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "outputSchema",
+				"节点 %s 的结构化输出配置无效: %v", nodeDisplayName(node), err)
+		}
+	case dto.NodeTypeClassifier, dto.NodeTypeExtractor:
+		if getStringFromMap(data, "modelId") == "" && getStringFromMap(data, "llmId") == "" {
+			missing("modelId", "模型")
+		}
+		if isEmptyConfig(data["query"]) {
+			missing("query", "输入内容")
+		}
+		if node.Type == dto.NodeTypeClassifier {
+			if _, err := parseClassifierClasses(data); err != nil {
+				v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "classes",
+					"节点 %s 的类别配置无效: %v", nodeDisplayName(node), err)
+			}
+		} else if _, err := parseExtractorParameters(data); err != nil {
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "parameters",
+				"节点 %s 的参数配置无效: %v", nodeDisplayName(node), err)
+		}
+	case dto.NodeTypeTool:
+		if getStringFromMap(data, "toolName") == "" && getStringFromMap(data, "name") == "" {
+			missing("toolName", "工具名称")
+		}
+	case dto.NodeTypePlugin:
+		if getStringFromMap(data, "pluginToolId") == "" && getStringFromMap(data, "pluginId") == "" {
+			missing("pluginToolId", "插件工具")
+		}
+	case dto.NodeTypeWorkflow:
+		if getStringFromMap(data, "workflowId") == "" {
+			missing("workflowId", "子工作流")
+		}
+	case dto.NodeTypeSQL:
+		if strings.TrimSpace(getStringFromMap(data, "sql")) == "" {
+			missing("sql", " SQL 语句")
+		}
+		if getStringFromMap(data, "datasource") == "" {
+			missing("datasource", "数据源")
+		}
+	case dto.NodeTypeDoc:
+		if isEmptyConfig(data["file"]) {
+			missing("file", "文件")
+		}
+	case dto.NodeTypeKnowledge:
+		if isEmptyConfig(data["collectionIds"]) && isEmptyConfig(data["collectionId"]) {
+			missing("collectionIds", "知识库")
+		}
+		if isEmptyConfig(data["query"]) {
+			missing("query", "检索内容")
+		}
+	case dto.NodeTypeHTTP:
+		if strings.TrimSpace(getStringFromMap(data, "url")) == "" {
+			missing("url", " URL")
+		}
+		if method := strings.ToUpper(getStringFromMap(data, "method")); method != "" && !httpNodeMethods[method] {
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "method",
+				"节点 %s 的请求方法 %s 无效，可选值: %s", nodeDisplayName(node), method, strings.Join(sortedHTTPMethods(), ", "))
+		}
+		switch bodyType := getStringFromMap(data, "bodyType"); bodyType {
+		case "", httpBodyNone, httpBodyJSON, httpBodyForm, httpBodyRaw:
+		default:
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "bodyType",
+				"节点 %s 的请求体类型 %s 无效", nodeDisplayName(node), bodyType)
+		}
+		// 输出变量与提取字段不能覆盖状态码、响应头等固定输出
+		outputVar := getStringFromMap(data, "outputVariable")
+		if httpFixedOutputs[outputVar] {
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "outputVariable",
+				"节点 %s 的输出变量 %s 与固定输出重名", nodeDisplayName(node), outputVar)
+		}
+		if outputVar == "" {
+			outputVar = "body"
+		}
+		var names []string
+		for name := range httpExtractPaths(data["extract"]) {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if httpFixedOutputs[name] || name == outputVar {
+				v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "extract",
+					"节点 %s 的提取字段 %s 与固定输出或输出变量重名", nodeDisplayName(node), name)
+			}
+		}
+	case dto.NodeTypeCode:
+		switch getStringFromMap(data, "codeType") {
+		case "json", "template", "starlark", "python":
+			if strings.TrimSpace(getStringFromMap(data, "code")) == "" {
+				missing("code", "代码")
+			}
+		}
+	case dto.NodeTypeLoop:
+		config := parseLoopConfig(node)
+		switch config.Mode {
+		case dto.LoopModeArray:
+			if isEmptyConfig(config.Items) {
+				missing("items", "循环数组")
+			}
+		case dto.LoopModeWhile:
+			if strings.TrimSpace(config.Condition) == "" {
+				missing("condition", "循环条件")
+			}
+		default:
+			v.nodeIssue(dto.ValidationLevelError, dto.ValidationMissingConfig, node, "mode",
+				"循环节点 %s 的模式未知: %s", nodeDisplayName(node), config.Mode)
+		}
+	}
+}
+
+// checkParameters 检查参数声明的类型与默认值
+func (v *workflowValidator) checkParameters(node *dto.WorkflowNode) {
+	for _, param := range v.declaredParameters(node) {
+		if param.Type == "" {
+			continue
+		}
+		kind := normalizeParamType(param.Type)
+		if kind == "" {
+			v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationTypeMismatch, node, "parameters",
+				"节点 %s 的参数 %s 类型未知: %s", nodeDisplayName(node), param.Name, param.Type)
+			continue
+		}
+		if param.DefaultValue != nil && !valueMatchesType(kind, param.DefaultValue) {
+			v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationTypeMismatch, node, "parameters",
+				"节点 %s 的参数 %s 的默认值与类型 %s 不匹配", nodeDisplayName(node), param.Name, param.Type)
+		}
+	}
+}
+
+// declaredParameters 节点声明的参数：开始节点的输入参数与人工确认节点的确认参数
+func (v *workflowValidator) declaredParameters(node *dto.WorkflowNode) []*dto.WorkflowParameter {
+	switch node.Type {
+	case dto.NodeTypeStart:
+		return v.parser.extractNodeParameters(node)
+	case dto.NodeTypeHumanConfirm:
+		var params []*dto.WorkflowParameter
+		items, _ := node.Data["confirmParameters"].([]interface{})
+		for _, item := range items {
+			if paramMap, ok := item.(map[string]interface{}); ok {
+				params = append(params, &dto.WorkflowParameter{
+					Name: getStringFromMap(paramMap, "name"),
+					Type: getStringFromMap(paramMap, "type"),
+				})
+			}
+		}
+		return params
+	}
+	return node.Parameters
+}
+
+// ========================== 表达式与脚本 ==========================
+
+// checkExpressions 检查条件节点、循环节点与边条件的表达式语法
+func (v *workflowValidator) checkExpressions() {
+	for _, node := range v.definition.Nodes {
+		if node.Type == dto.NodeTypeLoop && node.Data != nil {
+			if condition := getStringFromMap(node.Data, "condition"); strings.TrimSpace(condition) != "" {
+				if _, err := CompileExpression(condition); err != nil {
+					v.nodeIssue(dto.ValidationLevelError, dto.ValidationInvalidExpression, node, "condition",
+						"循环节点 %s 的条件表达式错误: %v", node.ID, err)
+				}
+			}
+		}
+		if node.Type != dto.NodeTypeCondition || node.Data == nil {
+			continue
+		}
+		conditions, _ := node.Data["conditions"].([]interface{})
+		for _, cond := range conditions {
+			condMap, ok := cond.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			expression := getStringFromMap(condMap, "expression")
+			if strings.TrimSpace(expression) == "" {
+				continue
+			}
+			if _, err := CompileExpression(expression); err != nil {
+				v.nodeIssue(dto.ValidationLevelError, dto.ValidationInvalidExpression, node, "conditions",
+					"节点 %s 的条件 %s 表达式错误: %v", node.ID, getStringFromMap(condMap, "name"), err)
+			}
+		}
+	}
+
+	// 分支节点的边条件是分支名，其它节点的边条件是表达式
+	for _, edge := range v.definition.Edges {
+		if edge.Condition == "" {
+			continue
+		}
+		source := v.parser.GetNodeByID(v.definition, edge.Source)
+		if source == nil || isBranchNode(source.Type) {
+			continue
+		}
+		if _, err := CompileExpression(edge.Condition); err != nil {
+			v.edgeIssue(dto.ValidationLevelError, dto.ValidationInvalidExpression, edge,
+				"边 %s -> %s 的条件表达式错误: %v", edge.Source, edge.Target, err)
+		}
+	}
+}
+
+// checkScripts 检查代码节点脚本的语法
+func (v *workflowValidator) checkScripts() {
+	for _, node := range v.definition.Nodes {
+		if node.Type != dto.NodeTypeCode || node.Data == nil {
+			continue
+		}
+		switch getStringFromMap(node.Data, "codeType") {
+		case "starlark", "python":
+			if err := CheckScriptSyntax(getStringFromMap(node.Data, "code")); err != nil {
+				v.nodeIssue(dto.ValidationLevelError, dto.ValidationInvalidScript, node, "code",
+					"代码节点 %s 脚本语法错误: %v", node.ID, err)
+			}
+		}
+	}
+}
+
+// ========================== 图结构 ==========================
+
+// scopes 顶层流程与各循环体的节点 ID
+func (v *workflowValidator) scopes() map[string][]string {
+	scopes := make(map[string][]string)
+	for _, node := range v.definition.Nodes {
+		scopes[node.ParentID] = append(scopes[node.ParentID], node.ID)
+	}
+	return scopes
+}
+
+// checkCycles 检查环路：调度器按 DAG 执行，重复执行只能通过循环节点实现
+func (v *workflowValidator) checkCycles() {
+	outgoing := make(map[string][]string)
+	for target, sources := range v.incoming {
+		for _, source := range sources {
+			outgoing[source] = append(outgoing[source], target)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	color := make(map[string]int)
+	var path []string
+	var visit func(id string)
+	visit = func(id string) {
+		color[id] = visiting
+		path = append(path, id)
+		for _, next := range outgoing[id] {
+			switch color[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				start := 0
+				for i, p := range path {
+					if p == next {
+						start = i
+					}
+				}
+				cycle := append(append([]string{}, path[start:]...), next)
+				v.nodeIssue(dto.ValidationLevelError, dto.ValidationCycle, v.nodes[next], "",
+					"检测到环路: %s，重复执行请使用循环节点", strings.Join(cycle, " -> "))
+			}
+		}
+		path = path[:len(path)-1]
+		color[id] = done
+	}
+
+	for _, node := range v.definition.Nodes {
+		if color[node.ID] == unvisited {
+			visit(node.ID)
+		}
+	}
+}
+
+// checkReachability 检查顶层流程中无法从开始节点到达的节点 (循环体从没有入边的节点开始执行)
+func (v *workflowValidator) checkReachability() {
+	start := v.parser.GetStartNode(v.definition)
+	outgoing := make(map[string][]string)
+	for target, sources := range v.incoming {
+		for _, source := range sources {
+			outgoing[source] = append(outgoing[source], target)
+		}
+	}
+
+	reached := map[string]bool{start.ID: true}
+	queue := []string{start.ID}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range outgoing[id] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	for _, id := range v.scopes()[""] {
+		if !reached[id] {
+			node := v.nodes[id]
+			v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationUnreachable, node, "",
+				"节点 %s 无法从开始节点到达", nodeDisplayName(node))
+		}
+	}
+}
+
+// ========================== 变量引用 ==========================
+
+// variableScope 节点执行前可用的变量：vars 为开始参数、全局变量与循环变量 (变量名 -> 类型，类型为空表示未知)，
+// nodes 为上游节点按节点 ID 划分的输出，供 ${nodeId.field} 引用；
+// fields 为执行时可能平铺的节点输出字段 -> 产生它的节点 ID，供旧工作流的 ${field} 引用；
+// open 为 true 表示节点输出由运行结果决定，无法判断字段是否存在
+type variableScope struct {
+	vars   map[string]string
+	nodes  map[string]*variableScope
+	fields map[string][]string
+	open   bool
+}
+
+func newVariableScope() *variableScope {
+	return &variableScope{vars: make(map[string]string), nodes: make(map[string]*variableScope)}
+}
+
+// mergeScope 合并另一个作用域的变量与节点输出
+func (s *variableScope) mergeScope(other *variableScope) {
+	for name, kind := range other.vars {
+		if existing, ok := s.vars[name]; !ok || existing == "" {
+			s.vars[name] = kind
+		}
+	}
+	for id, outputs := range other.nodes {
+		s.nodes[id] = outputs
+	}
+	if s.fields == nil {
+		s.fields = other.fields
+	}
+}
+
+// addNode 加入节点的输出，只能通过 ${nodeId.field} 引用
+func (s *variableScope) addNode(id string, vars map[string]string, open bool) {
+	s.nodes[id] = &variableScope{vars: vars, open: open}
+}
+
+// lookup 查找引用路径的类型，resolved 为 false 表示引用的变量不存在
+// ${nodeId.field} 引用节点输出，节点输出由运行结果决定时无法判断字段是否存在；
+// 变量与节点 ID 重名时执行时保留变量；旧的 ${field} 引用只在字段由唯一的上游节点产生时可以解析
+func (s *variableScope) lookup(path string) (kind string, resolved bool) {
+	segments, err := parsePath(strings.TrimSpace(path))
+	if err != nil {
+		return "", true
+	}
+	if kind, ok := s.vars[segments[0].key]; ok {
+		if len(segments) > 1 {
+			kind = ""
+		}
+		return kind, true
+	}
+	if node, ok := s.nodes[segments[0].key]; ok {
+		if len(segments) < 2 {
+			return "object", true
+		}
+		if segments[1].isIndex || node.open {
+			return "", true
+		}
+		kind, ok := node.vars[segments[1].key]
+		if ok && len(segments) > 2 {
+			kind = ""
+		}
+		return kind, ok
+	}
+	if producer := s.legacyProducer(segments[0].key); producer != nil {
+		kind := producer.vars[segments[0].key]
+		if len(segments) > 1 {
+			kind = ""
+		}
+		return kind, true
+	}
+	return "", false
+}
+
+// legacyProducer 旧的 ${field} 引用解析到的上游节点输出，字段不是平铺输出或有歧义时返回 nil
+func (s *variableScope) legacyProducer(field string) *variableScope {
+	if producers := s.fields[field]; len(producers) == 1 {
+		return s.nodes[producers[0]]
+	}
+	return nil
+}
+
+// legacyReference 引用是否为旧的 ${field} 形式的节点输出引用
+func (s *variableScope) legacyReference(path string) bool {
+	root := referenceRoot(path)
+	if _, ok := s.vars[root]; ok {
+		return false
+	}
+	if _, ok := s.nodes[root]; ok {
+		return false
+	}
+	return s.legacyProducer(root) != nil
+}
+
+// unresolvedMessage 未解析引用的原因
+func (s *variableScope) unresolvedMessage(ref string) string {
+	if producers := s.fields[referenceRoot(ref)]; len(producers) > 1 {
+		return fmt.Sprintf("引用的变量 %s 由多个节点 (%s) 产生，请使用 ${节点ID.%s}", ref, strings.Join(producers, ", "), ref)
+	}
+	return fmt.Sprintf("引用的变量 %s 没有上游节点产生", ref)
+}
+
+// checkReferences 检查节点配置与边条件中的 ${var} 引用是否有上游节点产生，以及参数类型是否匹配
+func (v *workflowValidator) checkReferences() {
+	scopes := make(map[string]*variableScope)
+
+	for _, node := range v.definition.Nodes {
+		if !knownNodeTypes[node.Type] {
+			continue
+		}
+		scope := v.upstreamScope(node, scopes)
+		if node.Type == dto.NodeTypeLoop && parseLoopConfig(node).Mode == dto.LoopModeWhile {
+			// while 条件可以引用上一次迭代产生的变量
+			whileScope := newVariableScope()
+			whileScope.mergeScope(scope)
+			for _, id := range v.scopes()[node.ID] {
+				outputs, open := v.nodeOutputs(v.nodes[id])
+				whileScope.addNode(id, outputs, open)
+			}
+			scope = whileScope
+		}
+		v.checkNodeReferences(node, scope)
+	}
+
+	reported := make(map[string]bool)
+	for _, edge := range v.definition.Edges {
+		source := v.nodes[edge.Source]
+		if edge.Condition == "" || isBranchNode(source.Type) || !knownNodeTypes[source.Type] {
+			continue
+		}
+		scope := newVariableScope()
+		scope.mergeScope(v.upstreamScope(source, scopes))
+		outputs, open := v.nodeOutputs(source)
+		scope.addNode(source.ID, outputs, open)
+		for _, match := range variableRefPattern.FindAllStringSubmatch(edge.Condition, -1) {
+			ref := strings.TrimSpace(match[1])
+			if referenceRoot(ref) == "" || reported[edge.Source+"\x00"+ref] {
+				continue
+			}
+			reported[edge.Source+"\x00"+ref] = true
+			if _, ok := scope.lookup(ref); !ok {
+				v.edgeIssue(dto.ValidationLevelWarning, dto.ValidationUnresolvedReference, edge,
+					"边 %s -> %s 的条件%s", edge.Source, edge.Target, scope.unresolvedMessage(ref))
+			} else if scope.legacyReference(ref) {
+				v.edgeIssue(dto.ValidationLevelWarning, dto.ValidationLegacyReference, edge,
+					"边 %s -> %s 的条件使用旧的引用 ${%s}，请改为 ${%s.%s}",
+					edge.Source, edge.Target, ref, scope.fields[referenceRoot(ref)][0], ref)
+			}
+		}
+	}
+}
+
+// checkNodeReferences 检查单个节点配置中的变量引用
+func (v *workflowValidator) checkNodeReferences(node *dto.WorkflowNode, scope *variableScope) {
+	keys := make([]string, 0, len(node.Data))
+	for key := range node.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	reported := make(map[string]bool)
+	for _, key := range keys {
+		// 脚本直接读取变量，不使用 ${} 引用
+		if key == "code" && node.Type == dto.NodeTypeCode {
+			if codeType := getStringFromMap(node.Data, "codeType"); codeType == "starlark" || codeType == "python" {
+				continue
+			}
+		}
+
+		walkConfigStrings(node.Data[key], func(s string) {
+			for _, match := range variableRefPattern.FindAllStringSubmatch(s, -1) {
+				ref := strings.TrimSpace(match[1])
+				if referenceRoot(ref) == "" || reported[ref] {
+					continue
+				}
+				if _, ok := scope.lookup(ref); !ok {
+					reported[ref] = true
+					v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationUnresolvedReference, node, key,
+						"节点 %s %s", nodeDisplayName(node), scope.unresolvedMessage(ref))
+				} else if scope.legacyReference(ref) {
+					reported[ref] = true
+					v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationLegacyReference, node, key,
+						"节点 %s 使用旧的引用 ${%s}，请改为 ${%s.%s}",
+						nodeDisplayName(node), ref, scope.fields[referenceRoot(ref)][0], ref)
+				}
+			}
+		})
+		v.checkReferenceTypes(node, key, scope)
+	}
+}
+
+// checkReferenceTypes 检查引用变量的类型与使用处要求的类型是否一致：
+// 声明了 type 的 {"name", "type", "value"} 绑定、循环数组与文档节点的文件
+func (v *workflowValidator) checkReferenceTypes(node *dto.WorkflowNode, key string, scope *variableScope) {
+	check := func(value interface{}, want, name string) {
+		s, ok := value.(string)
+		if !ok {
+			return
+		}
+		ref := wholeReference(s)
+		if ref == "" {
+			return
+		}
+		got, ok := scope.lookup(ref)
+		if !ok || got == "" || typesCompatible(want, got) {
+			return
+		}
+		v.nodeIssue(dto.ValidationLevelWarning, dto.ValidationTypeMismatch, node, key,
+			"节点 %s 的%s 需要 %s 类型，引用的变量 %s 为 %s 类型", nodeDisplayName(node), name, want, ref, got)
+	}
+
+	switch {
+	case node.Type == dto.NodeTypeLoop && key == "items":
+		// 循环数组也可以是 JSON 数组字符串
+		s, _ := node.Data[key].(string)
+		if kind, _ := scope.lookup(wholeReference(s)); kind != "string" {
+			check(s, "array", "循环数组")
+		}
+		return
+	case node.Type == dto.NodeTypeDoc && key == "file":
+		check(node.Data[key], "file", "文件")
+		return
+	}
+
+	walkConfigMaps(node.Data[key], func(m map[string]interface{}) {
+		want := normalizeParamType(getStringFromMap(m, "type"))
+		if want == "" {
+			return
+		}
+		name := getStringFromMap(m, "name")
+		if name == "" {
+			name = "参数"
+		} else {
+			name = "参数 " + name
+		}
+		check(m["value"], want, name)
+	})
+}
+
+// upstreamScope 计算节点执行前可用的变量：开始参数、全局变量、所属循环提供的变量与所有上游节点的输出
+func (v *workflowValidator) upstreamScope(node *dto.WorkflowNode, cache map[string]*variableScope) *variableScope {
+	if scope, ok := cache[node.ID]; ok {
+		return scope
+	}
+	scope := newVariableScope()
+	scope.fields = v.outputFields(node)
+	cache[node.ID] = scope
+
+	for name, kind := range v.topLevelVariables() {
+		scope.vars[name] = kind
+	}
+
+	if loop := v.nodes[node.ParentID]; loop != nil {
+		scope.mergeScope(v.upstreamScope(loop, cache))
+		config := parseLoopConfig(loop)
+		scope.vars[config.ItemVariable] = ""
+		scope.vars[config.IndexVariable] = "integer"
+		if config.Mode == dto.LoopModeWhile {
+			// 上一次迭代产生的变量带入下一次迭代
+			for _, id := range v.scopes()[loop.ID] {
+				outputs, open := v.nodeOutputs(v.nodes[id])
+				scope.addNode(id, outputs, open)
+			}
+		}
+	}
+
+	visited := map[string]bool{node.ID: true}
+	queue := append([]string{}, v.incoming[node.ID]...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		outputs, open := v.nodeOutputs(v.nodes[id])
+		scope.addNode(id, outputs, open)
+		queue = append(queue, v.incoming[id]...)
+	}
+	return scope
+}
+
+// outputFields 节点执行时可能平铺到变量中的输出字段 -> 产生它的节点 ID：
+// 顶层流程与节点所属各层循环体中的节点都会写入同一作用域
+func (v *workflowValidator) outputFields(node *dto.WorkflowNode) map[string][]string {
+	fields := make(map[string][]string)
+	scopes := v.scopes()
+	for parent := node.ParentID; ; {
+		for _, id := range scopes[parent] {
+			outputs, _ := v.nodeOutputs(v.nodes[id])
+			for name := range outputs {
+				fields[name] = append(fields[name], id)
+			}
+		}
+		loop := v.nodes[parent]
+		if loop == nil {
+			break
+		}
+		parent = loop.ParentID
+	}
+	return fields
+}
+
+// nodeOutputs 节点产生的变量 (变量名 -> 类型)，open 为 true 表示输出由运行结果决定
+func (v *workflowValidator) nodeOutputs(node *dto.WorkflowNode) (map[string]string, bool) {
+	outputs := make(map[string]string)
+	outputVariable := func(fallback string) string {
+		if name := getStringFromMap(node.Data, "outputVariable"); name != "" {
+			return name
+		}
+		return fallback
+	}
+
+	// 错误分支的 error 输出
+	for _, edge := range v.definition.Edges {
+		if edge.Source == node.ID && edge.SourcePort == dto.EdgePortError {
+			outputs["error"] = "object"
+			break
+		}
+	}
+
+	switch node.Type {
+	case dto.NodeTypeStart, dto.NodeTypeHumanConfirm:
+		for _, param := range v.declaredParameters(node) {
+			if param.Name != "" {
+				outputs[param.Name] = normalizeParamType(param.Type)
+			}
+		}
+	case dto.NodeTypeLLM:
+		if config, err := parseLLMOutputSchema(node.Data); err == nil && config != nil {
+			outputs[outputVariable("llmOutput")] = "object"
+			names, properties := schemaProperties(config.Schema)
+			for _, name := range names {
+				property, _ := properties[name].(map[string]interface{})
+				outputs[name] = normalizeParamType(schemaType(property))
+			}
+			break
+		}
+		outputs[outputVariable("llmOutput")] = "string"
+	case dto.NodeTypeCondition:
+		outputs["condition"] = "string"
+	case dto.NodeTypeCode:
+		switch getStringFromMap(node.Data, "codeType") {
+		case "template":
+			outputs["output"] = "string"
+		case "json", "starlark", "python":
+			return outputs, true
+		}
+	case dto.NodeTypeWorkflow:
+		items, _ := node.Data["outputs"].([]interface{})
+		for _, item := range items {
+			if output, ok := item.(map[string]interface{}); ok {
+				if name := getStringFromMap(output, "name"); name != "" {
+					outputs[name] = ""
+				}
+			}
+		}
+		return outputs, len(outputs) == 0
+	case dto.NodeTypeLoop:
+		config := parseLoopConfig(node)
+		outputs[config.OutputVariable] = "array"
+		outputs["iterations"] = "integer"
+	case dto.NodeTypeSQL:
+		outputs[outputVariable("rows")] = "array"
+		outputs["rowCount"] = "integer"
+		outputs["columns"] = "array"
+		outputs["truncated"] = "boolean"
+		outputs["rowsAffected"] = "integer"
+		outputs["lastInsertId"] = "integer"
+	case dto.NodeTypeDoc:
+		outputs[outputVariable("content")] = "string"
+		outputs["fileType"] = "string"
+		outputs["length"] = "integer"
+		outputs["data"] = "object"
+		outputs["chunks"] = "array"
+		outputs["chunkCount"] = "integer"
+	case dto.NodeTypeHTTP:
+		outputs[outputVariable("body")] = ""
+		outputs["statusCode"] = "integer"
+		outputs["headers"] = "object"
+		for name := range httpExtractPaths(node.Data["extract"]) {
+			outputs[name] = ""
+		}
+	case dto.NodeTypeKnowledge:
+		outputs[outputVariable("chunks")] = "array"
+		outputs["context"] = "string"
+		outputs["count"] = "integer"
+	case dto.NodeTypeClassifier:
+		outputs[outputVariable("class")] = "string"
+		outputs["condition"] = "string"
+		outputs["reason"] = "string"
+	case dto.NodeTypeExtractor:
+		params, _ := parseExtractorParameters(node.Data)
+		for _, param := range params {
+			outputs[param.Name] = param.Type
+		}
+		outputs[outputVariable("params")] = "object"
+		outputs["missing"] = "array"
+		outputs["condition"] = "string"
+	case dto.NodeTypeTool, dto.NodeTypePlugin:
+		// 工具返回对象时直接作为输出
+		return outputs, true
+	}
+	return outputs, false
+}
+
+// ========================== 辅助函数 ==========================
+
+// referenceRoot 变量路径的第一段，如 llm.output[0] 的 llm
+func referenceRoot(path string) string {
+	segments, err := parsePath(strings.TrimSpace(path))
+	if err != nil {
+		return ""
+	}
+	return segments[0].key
+}
+
+// wholeReference 值恰好是单个 ${path} 引用时返回引用路径
+func wholeReference(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "${") || !strings.HasSuffix(s, "}") || strings.Count(s, "${") != 1 {
+		return ""
+	}
+	path := strings.TrimSpace(s[2 : len(s)-1])
+	if _, err := parsePath(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// valueKind 全局变量初始值对应的参数类型，无法判断时返回空
+func valueKind(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, int, int64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return ""
+}
+
+// walkConfigStrings 遍历配置值中的所有字符串
+func walkConfigStrings(value interface{}, visit func(string)) {
+	switch val := value.(type) {
+	case string:
+		visit(val)
+	case map[string]interface{}:
+		for _, item := range val {
+			walkConfigStrings(item, visit)
+		}
+	case []interface{}:
+		for _, item := range val {
+			walkConfigStrings(item, visit)
+		}
+	}
+}
+
+// walkConfigMaps 遍历配置值中的所有对象
+func walkConfigMaps(value interface{}, visit func(map[string]interface{})) {
+	switch val := value.(type) {
+	case map[string]interface{}:
+		visit(val)
+		for _, item := range val {
+			walkConfigMaps(item, visit)
+		}
+	case []interface{}:
+		for _, item := range val {
+			walkConfigMaps(item, visit)
+		}
+	}
+}
+
+// isEmptyConfig 配置值未设置
+func isEmptyConfig(value interface{}) bool {
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s) == ""
+	}
+	return isEmptyValue(value)
+}
+
+// normalizeParamType 规范化参数类型：string, number, integer, boolean, array, object, file，未知类型返回空
+func normalizeParamType(paramType string) string {
+	switch strings.ToLower(paramType) {
+	case "string", "text":
+		return "string"
+	case "number", "float", "double":
+		return "number"
+	case "integer", "int", "long":
+		return "integer"
+	case "boolean", "bool":
+		return "boolean"
+	case "array", "list":
+		return "array"
+	case "object", "map":
+		return "object"
+	case "file":
+		return "file"
+	}
+	return ""
+}
+
+// typesCompatible 引用变量的类型能否用于声明的类型
+func typesCompatible(want, got string) bool {
+	switch {
+	case want == got:
+		return true
+	case want == "number" && got == "integer":
+		return true
+	case want == "file":
+		return got == "string" || got == "object"
+	case got == "file":
+		return want == "string" || want == "object"
+	}
+	return false
+}
+
+// valueMatchesType 检查 JSON 值能否作为指定类型使用，字符串可以按类型转换
+func valueMatchesType(kind string, value interface{}) bool {
+	_, err := coerceParamValue(kind, value)
+	return err == nil
+}
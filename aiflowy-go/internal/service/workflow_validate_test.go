@@ -0,0 +1,255 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+func issueCodes(result *dto.WorkflowValidationResult) map[string][]string {
+	codes := make(map[string][]string)
+	for _, issue := range result.Issues {
+		codes[issue.Code] = append(codes[issue.Code], issue.NodeID)
+	}
+	return codes
+}
+
+func TestWorkflowCheck_Valid(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart, Parameters: []*dto.WorkflowParameter{
+				{Name: "list", Type: "array"},
+				{Name: "topic", Type: "string", DefaultValue: "news"},
+			}},
+			{ID: "loop", Type: dto.NodeTypeLoop, Data: map[string]interface{}{"items": "${list}"}},
+			{ID: "body", Type: dto.NodeTypeLLM, ParentID: "loop", Data: map[string]interface{}{
+				"modelId": "1", "userPrompt": "${topic}: ${item} (${index})",
+			}},
+			{ID: "end", Type: dto.NodeTypeEnd, Data: map[string]interface{}{
+				"outputs": []interface{}{map[string]interface{}{"name": "result", "value": "${loop.loopOutput}"}},
+			}},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "loop"},
+			{Source: "loop", Target: "end", Condition: "${loop.iterations} > 0"},
+		},
+	}
+
+	result := NewWorkflowDSLParser().Check(definition)
+	if !result.Valid || len(result.Issues) != 0 {
+		for _, issue := range result.Issues {
+			t.Errorf("unexpected issue: %s %s", issue.Code, issue.Message)
+		}
+	}
+}
+
+func TestWorkflowCheck_Issues(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart, Parameters: []*dto.WorkflowParameter{
+				{Name: "count", Type: "number", DefaultValue: "many"},
+				{Name: "name", Type: "string"},
+			}},
+			{ID: "llm", Type: dto.NodeTypeLLM, Data: map[string]interface{}{"userPrompt": "${name} ${missing}"}},
+			{ID: "a", Type: dto.NodeTypeCode},
+			{ID: "b", Type: dto.NodeTypeCode},
+			{ID: "loop", Type: dto.NodeTypeLoop, Data: map[string]interface{}{"items": "${count}"}},
+			{ID: "body", Type: dto.NodeTypeCode, ParentID: "loop"},
+			{ID: "orphan", Type: "mystery"},
+			{ID: "end", Type: dto.NodeTypeEnd},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "llm"},
+			{Source: "llm", Target: "a"},
+			{Source: "a", Target: "b"},
+			{Source: "b", Target: "a"},
+			{Source: "llm", Target: "loop"},
+			{Source: "loop", Target: "end"},
+		},
+	}
+
+	parser := NewWorkflowDSLParser()
+	result := parser.Check(definition)
+	if result.Valid {
+		t.Fatal("expected workflow to be invalid")
+	}
+
+	codes := issueCodes(result)
+	expected := map[string][]string{
+		dto.ValidationMissingConfig:       {"llm"},
+		dto.ValidationUnknownNodeType:     {"orphan"},
+		dto.ValidationCycle:               {"a"},
+		dto.ValidationUnreachable:         {"orphan"},
+		dto.ValidationUnresolvedReference: {"llm"},
+		dto.ValidationTypeMismatch:        {"start", "loop"},
+	}
+	for code, nodes := range expected {
+		if len(codes[code]) != len(nodes) {
+			t.Errorf("expected %s issues on %v, got %v", code, nodes, codes[code])
+			continue
+		}
+		for i, node := range nodes {
+			if codes[code][i] != node {
+				t.Errorf("expected %s issue on %s, got %s", code, node, codes[code][i])
+			}
+		}
+	}
+
+	// Validate reports the first error-level issue
+	if err := parser.Validate(definition); err == nil {
+		t.Error("expected Validate to fail")
+	}
+}
+
+func TestWorkflowCheck_Structure(t *testing.T) {
+	result := NewWorkflowDSLParser().CheckContent(`{"nodes":[{"id":"start","type":"start"}],"edges":[{"source":"start","target":"x"}]}`)
+	codes := issueCodes(result)
+	if len(codes[dto.ValidationInvalidStructure]) != 1 || len(codes[dto.ValidationDanglingEdge]) != 1 {
+		t.Errorf("unexpected issues: %v", codes)
+	}
+
+	result = NewWorkflowDSLParser().CheckContent(`{`)
+	if result.Valid || len(issueCodes(result)[dto.ValidationInvalidJSON]) != 1 {
+		t.Errorf("expected invalid json issue, got %v", issueCodes(result))
+	}
+}
+
+func TestWorkflowCheck_NodeReferences(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Variables: map[string]interface{}{"limit": 10.0},
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart},
+			{ID: "query", Type: dto.NodeTypeSQL, Data: map[string]interface{}{"datasource": "report", "sql": "select 1 limit ${limit}"}},
+			{ID: "llm", Type: dto.NodeTypeLLM, Data: map[string]interface{}{
+				"modelId": "1", "userPrompt": "${query.rows[0]} ${query.rowCount} ${query.missing}",
+			}},
+			{ID: "loop", Type: dto.NodeTypeLoop, Data: map[string]interface{}{"items": "${query.rowCount}"}},
+			{ID: "end", Type: dto.NodeTypeEnd},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "query"},
+			{Source: "query", Target: "llm"},
+			{Source: "llm", Target: "loop"},
+			{Source: "loop", Target: "end", Condition: "${llm.llmOutput} != ''"},
+		},
+	}
+
+	codes := issueCodes(NewWorkflowDSLParser().Check(definition))
+	if nodes := codes[dto.ValidationUnresolvedReference]; len(nodes) != 1 || nodes[0] != "llm" {
+		t.Errorf("expected unresolved reference on llm, got %v", nodes)
+	}
+	if nodes := codes[dto.ValidationTypeMismatch]; len(nodes) != 1 || nodes[0] != "loop" {
+		t.Errorf("expected type mismatch on loop, got %v", nodes)
+	}
+}
+
+func TestWorkflowCheck_BranchNodes(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart, Parameters: []*dto.WorkflowParameter{{Name: "message", Type: "string"}}},
+			{ID: "intent", Type: dto.NodeTypeClassifier, Data: map[string]interface{}{
+				"modelId": "1", "query": "${message}",
+				"classes": []interface{}{map[string]interface{}{"name": "book"}, map[string]interface{}{"name": "cancel"}},
+			}},
+			{ID: "extract", Type: dto.NodeTypeExtractor, Data: map[string]interface{}{
+				"modelId": "1", "query": "${message}",
+				"parameters": []interface{}{map[string]interface{}{"name": "city", "required": true}},
+			}},
+			{ID: "dup", Type: dto.NodeTypeClassifier, Data: map[string]interface{}{
+				"modelId": "1", "query": "${message}",
+				"classes": []interface{}{map[string]interface{}{"name": "a"}, map[string]interface{}{"name": "a"}},
+			}},
+			{ID: "end", Type: dto.NodeTypeEnd, Data: map[string]interface{}{
+				"outputs": []interface{}{map[string]interface{}{"name": "city", "value": "${extract.city}"}},
+			}},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "intent"},
+			{Source: "intent", Target: "extract", Condition: "book"},
+			{Source: "intent", Target: "dup", SourcePort: "cancel"},
+			{Source: "extract", Target: "end", Condition: dto.ExtractorBranchComplete},
+			{Source: "dup", Target: "end"},
+		},
+	}
+
+	codes := issueCodes(NewWorkflowDSLParser().Check(definition))
+	if len(codes[dto.ValidationInvalidExpression]) != 0 || len(codes[dto.ValidationUnresolvedReference]) != 0 {
+		t.Errorf("branch names must not be checked as expressions: %v", codes)
+	}
+	if nodes := codes[dto.ValidationMissingConfig]; len(nodes) != 1 || nodes[0] != "dup" {
+		t.Errorf("expected invalid classes on dup, got %v", nodes)
+	}
+}
+
+func TestWorkflowCheck_ScopedOutputs(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Variables: map[string]interface{}{"lang": "zh"},
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart, Parameters: []*dto.WorkflowParameter{{Name: "query", Type: "string"}}},
+			{ID: "query", Type: dto.NodeTypeLLM, Data: map[string]interface{}{"modelId": "1", "userPrompt": "${query}"}},
+			{ID: "lang", Type: dto.NodeTypeLLM, Data: map[string]interface{}{"modelId": "1", "userPrompt": "${lang}"}},
+			{ID: "end", Type: dto.NodeTypeEnd, Data: map[string]interface{}{
+				"outputs": []interface{}{
+					map[string]interface{}{"name": "answer", "value": "${llmOutput}"},
+					map[string]interface{}{"name": "error", "value": "${query.error.message}"},
+				},
+			}},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "query"},
+			{Source: "query", Target: "lang"},
+			{Source: "query", Target: "end", SourcePort: dto.EdgePortError},
+			{Source: "lang", Target: "end"},
+		},
+	}
+
+	result := NewWorkflowDSLParser().Check(definition)
+	codes := issueCodes(result)
+	// node IDs that shadow a start parameter or a global variable are reported but still run
+	if nodes := codes[dto.ValidationInvalidStructure]; len(nodes) != 2 {
+		t.Errorf("expected both clashing node IDs to be reported, got %v", nodes)
+	}
+	if !result.Valid {
+		t.Errorf("expected clashing node IDs to be warnings: %+v", result.Issues)
+	}
+	// ${llmOutput} is produced by both LLM nodes and can't be resolved
+	if nodes := codes[dto.ValidationUnresolvedReference]; len(nodes) != 1 || nodes[0] != "end" {
+		t.Errorf("expected only the ambiguous ${llmOutput} to be unresolved, got %v", nodes)
+	}
+}
+
+func TestWorkflowCheck_LegacyReferences(t *testing.T) {
+	definition := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: dto.NodeTypeStart, Parameters: []*dto.WorkflowParameter{{Name: "query", Type: "string"}}},
+			{ID: "llm", Type: dto.NodeTypeLLM, Data: map[string]interface{}{"modelId": "1", "userPrompt": "${query}"}},
+			{ID: "end", Type: dto.NodeTypeEnd, Data: map[string]interface{}{
+				"outputs": []interface{}{
+					map[string]interface{}{"name": "answer", "value": "${llmOutput}"},
+					map[string]interface{}{"name": "missing", "value": "${summary}"},
+				},
+			}},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "llm"},
+			{Source: "llm", Target: "end"},
+		},
+	}
+
+	var legacy, unresolved []string
+	for _, issue := range NewWorkflowDSLParser().Check(definition).Issues {
+		switch issue.Code {
+		case dto.ValidationLegacyReference:
+			legacy = append(legacy, issue.NodeID+issue.Source)
+		case dto.ValidationUnresolvedReference:
+			unresolved = append(unresolved, issue.NodeID+issue.Source)
+		}
+	}
+	// a flat name produced by a single upstream node still resolves, with a deprecation warning
+	if len(legacy) != 1 || legacy[0] != "end" {
+		t.Errorf("expected the flat ${llmOutput} on end to be reported as legacy, got %v", legacy)
+	}
+	if len(unresolved) != 1 || unresolved[0] != "end" {
+		t.Errorf("expected only ${summary} to be unresolved, got %v", unresolved)
+	}
+}
@@ -0,0 +1,330 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+	"github.com/aiflowy/aiflowy-go/internal/entity"
+	apierrors "github.com/aiflowy/aiflowy-go/internal/errors"
+)
+
+// ========================== 工作流版本 ==========================
+//
+// tb_workflow.content 为设计器编辑的草稿；发布时将草稿保存为不可修改的版本，
+// 执行、子工作流与 Bot 工具调用均使用发布版本，回滚只切换发布版本，不修改草稿
+
+// ListVersions 获取工作流的版本列表
+func (s *WorkflowService) ListVersions(ctx context.Context, id string) ([]*entity.WorkflowVersion, error) {
+	workflow, err := s.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := s.repo.ListWorkflowVersions(ctx, workflow.ID)
+	if err != nil {
+		return nil, apierrors.InternalError("获取版本列表失败")
+	}
+	for _, version := range versions {
+		version.Published = version.ID == workflow.PublishedVersionID
+	}
+	return versions, nil
+}
+
+// GetVersion 获取指定版本的内容，版本号 0 返回草稿
+func (s *WorkflowService) GetVersion(ctx context.Context, id string, version int) (*entity.WorkflowVersion, error) {
+	workflow, err := s.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadVersion(ctx, workflow, version)
+}
+
+// loadVersion 加载版本，版本号 0 表示草稿
+func (s *WorkflowService) loadVersion(ctx context.Context, workflow *entity.Workflow, version int) (*entity.WorkflowVersion, error) {
+	if version == 0 {
+		return &entity.WorkflowVersion{
+			WorkflowID: workflow.ID,
+			Content:    workflow.Content,
+			Created:    workflow.Modified,
+			CreatedBy:  workflow.ModifiedBy,
+		}, nil
+	}
+
+	v, err := s.repo.GetWorkflowVersion(ctx, workflow.ID, version)
+	if err != nil {
+		return nil, apierrors.InternalError("获取工作流版本失败")
+	}
+	if v == nil {
+		return nil, apierrors.NotFound(fmt.Sprintf("版本不存在: %d", version))
+	}
+	v.Published = v.ID == workflow.PublishedVersionID
+	return v, nil
+}
+
+// PublishWorkflow 将当前草稿发布为新版本
+func (s *WorkflowService) PublishWorkflow(ctx context.Context, req *dto.WorkflowPublishRequest, userID int64) (*entity.WorkflowVersion, error) {
+	workflow, err := s.GetWorkflow(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if workflow.Content == "" {
+		return nil, apierrors.BadRequest("工作流内容为空，无法发布")
+	}
+
+	// 发布前完整校验，避免发布无法执行的版本；未解析的变量引用执行时会得到空值，同样拒绝发布
+	parser := NewWorkflowDSLParser()
+	definition, err := parser.Parse(workflow.Content)
+	if err != nil {
+		return nil, apierrors.BadRequest(err.Error())
+	}
+	for _, issue := range parser.Check(definition).Issues {
+		if issue.Level == dto.ValidationLevelError || issue.Code == dto.ValidationUnresolvedReference {
+			return nil, apierrors.BadRequest("工作流定义无效: " + issue.Message)
+		}
+	}
+
+	if workflow.PublishedVersionID != 0 {
+		published, err := s.repo.GetWorkflowVersionByID(ctx, workflow.PublishedVersionID)
+		if err != nil {
+			return nil, apierrors.InternalError("获取发布版本失败")
+		}
+		if published != nil && published.Content == workflow.Content {
+			return nil, apierrors.BadRequest(fmt.Sprintf("草稿与当前发布版本 v%d 一致，无需发布", published.Version))
+		}
+	}
+
+	version := &entity.WorkflowVersion{
+		WorkflowID: workflow.ID,
+		Content:    workflow.Content,
+		Comment:    req.Comment,
+		Created:    time.Now(),
+		CreatedBy:  userID,
+		Published:  true,
+	}
+	if err := s.repo.PublishWorkflowVersion(ctx, version); err != nil {
+		return nil, apierrors.InternalError("发布工作流失败")
+	}
+	return version, nil
+}
+
+// RollbackWorkflow 将发布版本切换为指定的历史版本
+func (s *WorkflowService) RollbackWorkflow(ctx context.Context, req *dto.WorkflowRollbackRequest) (*entity.WorkflowVersion, error) {
+	workflow, err := s.GetWorkflow(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version <= 0 {
+		return nil, apierrors.BadRequest("无效的版本号")
+	}
+
+	version, err := s.loadVersion(ctx, workflow, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if version.Published {
+		return version, nil
+	}
+
+	if err := s.repo.SetPublishedVersion(ctx, workflow.ID, version.ID); err != nil {
+		return nil, apierrors.InternalError("回滚工作流失败")
+	}
+	version.Published = true
+	return version, nil
+}
+
+// DiffVersions 按节点比较两个版本，版本号 0 表示草稿
+func (s *WorkflowService) DiffVersions(ctx context.Context, id string, from, to int) (*dto.WorkflowVersionDiff, error) {
+	workflow, err := s.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := NewWorkflowDSLParser()
+	definitions := make([]*dto.WorkflowDefinition, 2)
+	for i, number := range []int{from, to} {
+		version, err := s.loadVersion(ctx, workflow, number)
+		if err != nil {
+			return nil, err
+		}
+		definition := &dto.WorkflowDefinition{}
+		if version.Content != "" {
+			definition, err = parser.Parse(version.Content)
+			if err != nil {
+				return nil, apierrors.BadRequest(fmt.Sprintf("版本 %d 解析失败: %v", number, err))
+			}
+		}
+		definitions[i] = definition
+	}
+
+	diff := diffWorkflowDefinitions(definitions[0], definitions[1])
+	diff.From = from
+	diff.To = to
+	return diff, nil
+}
+
+// diffWorkflowDefinitions 比较两个工作流定义：节点按 ID 对应，连线按 source、sourcePort、target 对应
+// 只移动画布位置不视为修改
+func diffWorkflowDefinitions(from, to *dto.WorkflowDefinition) *dto.WorkflowVersionDiff {
+	diff := &dto.WorkflowVersionDiff{
+		Nodes: []*dto.WorkflowNodeDiff{},
+		Edges: []*dto.WorkflowEdgeDiff{},
+	}
+
+	if from.Timeout != to.Timeout {
+		diff.Settings = append(diff.Settings, "timeout")
+	}
+	if !jsonEqual(from.Variables, to.Variables) {
+		diff.Settings = append(diff.Settings, "variables")
+	}
+
+	// 节点
+	oldNodes := make(map[string]*dto.WorkflowNode, len(from.Nodes))
+	for _, node := range from.Nodes {
+		oldNodes[node.ID] = node
+	}
+	newNodes := make(map[string]bool, len(to.Nodes))
+	for _, node := range to.Nodes {
+		newNodes[node.ID] = true
+		old, ok := oldNodes[node.ID]
+		if !ok {
+			diff.Nodes = append(diff.Nodes, &dto.WorkflowNodeDiff{
+				NodeID: node.ID, Name: node.Name, Type: node.Type, Change: dto.DiffAdded, After: node,
+			})
+			continue
+		}
+		if fields := diffNodeFields(old, node); len(fields) > 0 {
+			diff.Nodes = append(diff.Nodes, &dto.WorkflowNodeDiff{
+				NodeID: node.ID, Name: node.Name, Type: node.Type, Change: dto.DiffModified,
+				Fields: fields, Before: old, After: node,
+			})
+		}
+	}
+	for _, node := range from.Nodes {
+		if !newNodes[node.ID] {
+			diff.Nodes = append(diff.Nodes, &dto.WorkflowNodeDiff{
+				NodeID: node.ID, Name: node.Name, Type: node.Type, Change: dto.DiffRemoved, Before: node,
+			})
+		}
+	}
+
+	// 连线
+	edgeKey := func(edge *dto.WorkflowEdge) string {
+		return edge.Source + "\x00" + edge.SourcePort + "\x00" + edge.Target
+	}
+	oldEdges := make(map[string]*dto.WorkflowEdge, len(from.Edges))
+	for _, edge := range from.Edges {
+		oldEdges[edgeKey(edge)] = edge
+	}
+	newEdges := make(map[string]bool, len(to.Edges))
+	for _, edge := range to.Edges {
+		key := edgeKey(edge)
+		newEdges[key] = true
+		old, ok := oldEdges[key]
+		switch {
+		case !ok:
+			diff.Edges = append(diff.Edges, &dto.WorkflowEdgeDiff{Change: dto.DiffAdded, After: edge})
+		case old.Condition != edge.Condition || old.TargetPort != edge.TargetPort:
+			diff.Edges = append(diff.Edges, &dto.WorkflowEdgeDiff{Change: dto.DiffModified, Before: old, After: edge})
+		}
+	}
+	for _, edge := range from.Edges {
+		if !newEdges[edgeKey(edge)] {
+			diff.Edges = append(diff.Edges, &dto.WorkflowEdgeDiff{Change: dto.DiffRemoved, Before: edge})
+		}
+	}
+
+	return diff
+}
+
+// diffNodeFields 比较同一节点的两个版本，返回修改的字段 (data 按键比较)
+func diffNodeFields(old, node *dto.WorkflowNode) []string {
+	var fields []string
+	if old.Name != node.Name {
+		fields = append(fields, "name")
+	}
+	if old.Type != node.Type {
+		fields = append(fields, "type")
+	}
+	if old.ParentID != node.ParentID {
+		fields = append(fields, "parentId")
+	}
+	if !jsonEqual(old.Parameters, node.Parameters) {
+		fields = append(fields, "parameters")
+	}
+
+	var dataFields []string
+	for key, value := range node.Data {
+		if oldValue, ok := old.Data[key]; !ok || !jsonEqual(oldValue, value) {
+			dataFields = append(dataFields, "data."+key)
+		}
+	}
+	for key := range old.Data {
+		if _, ok := node.Data[key]; !ok {
+			dataFields = append(dataFields, "data."+key)
+		}
+	}
+	sort.Strings(dataFields)
+	return append(fields, dataFields...)
+}
+
+// jsonEqual 比较两个由 JSON 解析得到的值，nil 与空集合视为相同
+func jsonEqual(a, b interface{}) bool {
+	if isEmptyValue(a) && isEmptyValue(b) {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice:
+		return rv.Len() == 0
+	case reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// ========================== 执行使用的版本 ==========================
+
+// loadRunnableWorkflow 加载执行使用的工作流内容：默认为发布版本，draft 为 true 时为草稿 (版本号 0)。
+// tenantID 不为 0 时其他租户的工作流视为不存在
+func (e *ChainExecutor) loadRunnableWorkflow(ctx context.Context, workflowID string, draft bool, tenantID int64) (*entity.Workflow, *entity.WorkflowVersion, error) {
+	wfID, err := strconv.ParseInt(workflowID, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的工作流 ID: %s", workflowID)
+	}
+
+	workflow, err := e.workflowRepo.GetWorkflowByID(ctx, wfID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载工作流失败: %w", err)
+	}
+	if workflow == nil || (tenantID != 0 && workflow.TenantID != tenantID) {
+		return nil, nil, fmt.Errorf("工作流不存在: %s", workflowID)
+	}
+
+	if draft {
+		return workflow, &entity.WorkflowVersion{WorkflowID: workflow.ID, Content: workflow.Content}, nil
+	}
+
+	if workflow.PublishedVersionID == 0 {
+		return nil, nil, fmt.Errorf("工作流 %s 尚未发布", workflow.Title)
+	}
+	version, err := e.workflowRepo.GetWorkflowVersionByID(ctx, workflow.PublishedVersionID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载工作流版本失败: %w", err)
+	}
+	if version == nil {
+		return nil, nil, fmt.Errorf("工作流 %s 的发布版本不存在", workflow.Title)
+	}
+	return workflow, version, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aiflowy/aiflowy-go/internal/dto"
+)
+
+func TestDiffWorkflowDefinitions(t *testing.T) {
+	from := &dto.WorkflowDefinition{
+		Nodes: []*dto.WorkflowNode{
+			{ID: "start", Type: "start", Name: "开始", Position: &dto.NodePosition{X: 0, Y: 0}},
+			{ID: "llm", Type: "llm", Name: "大模型", Data: map[string]interface{}{"prompt": "hi", "temperature": 0.5}},
+			{ID: "old", Type: "code", Name: "脚本"},
+			{ID: "end", Type: "end", Name: "结束"},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{Source: "start", Target: "llm"},
+			{Source: "llm", Target: "old"},
+			{Source: "old", Target: "end", Condition: "a > 1"},
+		},
+	}
+	to := &dto.WorkflowDefinition{
+		Timeout: 30,
+		Nodes: []*dto.WorkflowNode{
+			// moved on the canvas only
+			{ID: "start", Type: "start", Name: "开始", Position: &dto.NodePosition{X: 100, Y: 50}},
+			{ID: "llm", Type: "llm", Name: "大模型", Data: map[string]interface{}{"prompt": "hello", "temperature": 0.5, "model": "m"}},
+			{ID: "end", Type: "end", Name: "结束", Data: map[string]interface{}{}},
+			{ID: "new", Type: "http", Name: "请求"},
+		},
+		Edges: []*dto.WorkflowEdge{
+			{ID: "renamed", Source: "start", Target: "llm"},
+			{Source: "llm", Target: "new"},
+			{Source: "new", Target: "end"},
+		},
+	}
+
+	diff := diffWorkflowDefinitions(from, to)
+
+	if !reflect.DeepEqual(diff.Settings, []string{"timeout"}) {
+		t.Errorf("unexpected settings diff: %v", diff.Settings)
+	}
+
+	changes := make(map[string]*dto.WorkflowNodeDiff)
+	for _, node := range diff.Nodes {
+		changes[node.NodeID] = node
+	}
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 node changes, got %d", len(diff.Nodes))
+	}
+	if changes["new"] == nil || changes["new"].Change != dto.DiffAdded {
+		t.Errorf("expected node new to be added")
+	}
+	if changes["old"] == nil || changes["old"].Change != dto.DiffRemoved {
+		t.Errorf("expected node old to be removed")
+	}
+	llm := changes["llm"]
+	if llm == nil || llm.Change != dto.DiffModified {
+		t.Fatalf("expected node llm to be modified")
+	}
+	if !reflect.DeepEqual(llm.Fields, []string{"data.model", "data.prompt"}) {
+		t.Errorf("unexpected llm fields: %v", llm.Fields)
+	}
+
+	counts := make(map[string]int)
+	for _, edge := range diff.Edges {
+		counts[edge.Change]++
+	}
+	if counts[dto.DiffAdded] != 2 || counts[dto.DiffRemoved] != 2 || counts[dto.DiffModified] != 0 {
+		t.Errorf("unexpected edge changes: %v", counts)
+	}
+}
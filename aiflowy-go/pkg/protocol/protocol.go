@@ -48,6 +48,17 @@ const (
 	TypeWorkflowStatus = "status"
 )
 
+// Workflow status states
+const (
+	WorkflowStateStart   = "start"
+	WorkflowStateSuspend = "suspend"
+	WorkflowStateResume  = "resume"
+	WorkflowStateEnd     = "end"
+	WorkflowStateError   = "error"
+	WorkflowStateSkip    = "skip"
+	WorkflowStateCancel  = "cancel"
+)
+
 // Interaction domain types
 const (
 	TypeFormRequest = "form_request"
@@ -126,10 +137,27 @@ type StatusPayload struct {
 // Workflow Payloads
 
 // WorkflowStatusPayload for workflow.status
+// An empty NodeID means the status of the whole workflow execution.
+// ExecuteID is set when the event is relayed into a chat stream
 type WorkflowStatusPayload struct {
-	NodeID string `json:"node_id,omitempty"`
-	State  string `json:"state"`  // start, suspend, resume, end
-	Reason string `json:"reason,omitempty"`
+	ExecuteID string      `json:"execute_id,omitempty"`
+	NodeID    string      `json:"node_id,omitempty"`
+	NodeName  string      `json:"node_name,omitempty"`
+	State     string      `json:"state"` // start, suspend, resume, end, error, skip, cancel
+	Reason    string      `json:"reason,omitempty"`
+	Output    interface{} `json:"output,omitempty"`
+}
+
+// Interaction Payloads
+
+// FormRequestPayload for interaction.form_request
+// ExecuteID is set when the form belongs to a workflow relayed into a chat stream
+type FormRequestPayload struct {
+	ExecuteID   string                 `json:"execute_id,omitempty"`
+	FormID      string                 `json:"form_id"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
 }
 
 // Builder provides convenient methods to build envelopes
@@ -247,6 +275,36 @@ func (b *Builder) WorkflowStatus(nodeID, state, reason string) *Envelope {
 	})
 }
 
+// WorkflowNodeStatus creates a workflow.status envelope carrying node name and output
+func (b *Builder) WorkflowNodeStatus(nodeID, nodeName, state, reason string, output interface{}) *Envelope {
+	return b.newEnvelope(DomainWorkflow, TypeWorkflowStatus, &WorkflowStatusPayload{
+		NodeID:   nodeID,
+		NodeName: nodeName,
+		State:    state,
+		Reason:   reason,
+		Output:   output,
+	})
+}
+
+// FormRequest creates an interaction.form_request envelope
+func (b *Builder) FormRequest(formID, title, description string, schema map[string]interface{}) *Envelope {
+	return b.newEnvelope(DomainInteraction, TypeFormRequest, &FormRequestPayload{
+		FormID:      formID,
+		Title:       title,
+		Description: description,
+		Schema:      schema,
+	})
+}
+
+// Relay re-addresses an envelope built by another builder (e.g. a workflow
+// execution) to this builder's conversation and message. The payload is shared
+func (b *Builder) Relay(env *Envelope) *Envelope {
+	relayed := *env
+	relayed.ConversationID = b.conversationID
+	relayed.MessageID = b.messageID
+	return &relayed
+}
+
 // ToJSON converts an envelope to JSON string
 func (e *Envelope) ToJSON() (string, error) {
 	data, err := json.Marshal(e)
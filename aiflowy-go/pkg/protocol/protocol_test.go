@@ -337,6 +337,59 @@ func TestWorkflowStatus(t *testing.T) {
 	}
 }
 
+func TestWorkflowNodeStatus(t *testing.T) {
+	b := NewBuilder("exec-1", "")
+	output := map[string]interface{}{"answer": "ok"}
+
+	env := b.WorkflowNodeStatus("node-1", "LLM", WorkflowStateEnd, "", output)
+
+	payload, ok := env.Payload.(*WorkflowStatusPayload)
+	if !ok {
+		t.Fatalf("expected WorkflowStatusPayload type")
+	}
+	if payload.NodeName != "LLM" || payload.State != WorkflowStateEnd {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+
+	jsonStr, err := env.ToJSON()
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if !strings.Contains(jsonStr, `"output":{"answer":"ok"}`) {
+		t.Errorf("expected output in JSON, got %s", jsonStr)
+	}
+}
+
+func TestFormRequest(t *testing.T) {
+	b := NewBuilder("exec-1", "")
+	schema := map[string]interface{}{"type": "object"}
+
+	env := b.FormRequest("node-1", "确认", "", schema)
+
+	if env.Domain != DomainInteraction || env.Type != TypeFormRequest {
+		t.Errorf("unexpected envelope: %s.%s", env.Domain, env.Type)
+	}
+	payload, ok := env.Payload.(*FormRequestPayload)
+	if !ok {
+		t.Fatalf("expected FormRequestPayload type")
+	}
+	if payload.FormID != "node-1" || payload.Schema["type"] != "object" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestRelay(t *testing.T) {
+	env := NewBuilder("exec-1", "").WorkflowStatus("node-1", WorkflowStateEnd, "")
+	relayed := NewBuilder("conv-1", "msg-1").Relay(env)
+
+	if relayed.ConversationID != "conv-1" || relayed.MessageID != "msg-1" {
+		t.Errorf("unexpected relayed envelope: %+v", relayed)
+	}
+	if env.ConversationID != "exec-1" || relayed.Payload != env.Payload {
+		t.Errorf("relay must copy the envelope and keep the payload: %+v", env)
+	}
+}
+
 func TestEnvelopeToJSON(t *testing.T) {
 	b := NewBuilder("conv-1", "msg-1")
 	env := b.LLMMessageDelta("Hello")
@@ -77,6 +77,8 @@ CREATE TABLE `tb_bot_conversation`
     `created_by`  bigint UNSIGNED NULL DEFAULT NULL,
     `modified`    datetime NULL DEFAULT NULL,
     `modified_by` bigint UNSIGNED NULL DEFAULT NULL,
+    `summary`     text CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci NULL COMMENT '早期对话的滚动摘要',
+    `summary_message_id` bigint UNSIGNED NULL DEFAULT NULL COMMENT '摘要已覆盖到的消息ID',
     PRIMARY KEY (`id`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_general_ci COMMENT = 'bot对话' ROW_FORMAT = DYNAMIC;
 
@@ -325,6 +327,9 @@ CREATE TABLE `tb_model`
     `support_video`          tinyint(1) NULL DEFAULT NULL COMMENT '是否支持视频',
     `support_audio`          tinyint(1) NULL DEFAULT NULL COMMENT '是否支持音频',
     `support_free`           tinyint(1) NULL DEFAULT NULL COMMENT '是否免费',
+    `input_price`            decimal(12, 4) NULL DEFAULT NULL COMMENT '输入价格 (每百万 tokens)',
+    `output_price`           decimal(12, 4) NULL DEFAULT NULL COMMENT '输出价格 (每百万 tokens)',
+    `context_length`         int NULL DEFAULT NULL COMMENT '上下文长度 (tokens)',
     PRIMARY KEY (`id`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '大模型管理' ROW_FORMAT = DYNAMIC;
 
@@ -991,6 +996,32 @@ CREATE TABLE `tb_sys_role_menu`
     PRIMARY KEY (`id`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '角色-菜单表' ROW_FORMAT = DYNAMIC;
 
+-- ----------------------------
+-- Table structure for tb_token_usage
+-- ----------------------------
+DROP TABLE IF EXISTS `tb_token_usage`;
+CREATE TABLE `tb_token_usage`
+(
+    `id`                bigint UNSIGNED NOT NULL COMMENT '主键',
+    `tenant_id`         bigint UNSIGNED NOT NULL COMMENT '租户ID',
+    `account_id`        bigint UNSIGNED NOT NULL COMMENT '用户ID',
+    `bot_id`            bigint UNSIGNED NOT NULL COMMENT 'botId',
+    `model_id`          bigint UNSIGNED NOT NULL COMMENT '模型ID',
+    `model_name`        varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '大模型名称',
+    `conversation_id`   bigint UNSIGNED NULL DEFAULT NULL COMMENT '会话ID',
+    `message_id`        bigint UNSIGNED NULL DEFAULT NULL COMMENT '消息ID',
+    `prompt_tokens`     int                                                          NOT NULL DEFAULT 0 COMMENT '输入 tokens',
+    `completion_tokens` int                                                          NOT NULL DEFAULT 0 COMMENT '输出 tokens',
+    `total_tokens`      int                                                          NOT NULL DEFAULT 0 COMMENT '总 tokens',
+    `cost`              decimal(18, 6)                                               NOT NULL DEFAULT 0 COMMENT '费用',
+    `created`           datetime                                                     NOT NULL COMMENT '创建时间',
+    PRIMARY KEY (`id`) USING BTREE,
+    INDEX               `idx_tenant_created`(`tenant_id`, `created`) USING BTREE,
+    INDEX               `idx_account_created`(`account_id`, `created`) USING BTREE,
+    INDEX               `idx_bot_created`(`bot_id`, `created`) USING BTREE,
+    INDEX               `idx_model_created`(`model_id`, `created`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '大模型 token 用量' ROW_FORMAT = DYNAMIC;
+
 -- ----------------------------
 -- Table structure for tb_workflow
 -- ----------------------------
@@ -1012,6 +1043,7 @@ CREATE TABLE `tb_workflow`
     `english_name` varchar(256) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '英文名称',
     `status`       int                                                           NOT NULL DEFAULT 0 COMMENT '数据状态',
     `category_id`  bigint UNSIGNED NULL DEFAULT NULL COMMENT '分类ID',
+    `published_version_id` bigint UNSIGNED NULL DEFAULT NULL COMMENT '当前发布版本ID',
     PRIMARY KEY (`id`) USING BTREE,
     UNIQUE INDEX `tb_ai_workflow_alias_uindex`(`alias`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '工作流' ROW_FORMAT = DYNAMIC;
@@ -1054,8 +1086,14 @@ CREATE TABLE `tb_workflow_exec_result`
     `created_key`   varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '执行人标识[有可能是用户|外部|定时任务等情况]',
     `created_by`    varchar(50) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '执行人',
     `error_info`    text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '错误信息',
+    `chain_state`   longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '执行状态快照(变量、节点状态、暂停信息)',
+    `parent_exec_key` varchar(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '子工作流的父执行标识',
+    `parent_step_id` bigint UNSIGNED NULL DEFAULT NULL COMMENT '子工作流的父执行步骤ID',
+    `workflow_version` int                                                        NOT NULL DEFAULT 0 COMMENT '执行的工作流版本号(0为草稿)',
     PRIMARY KEY (`id`) USING BTREE,
-    UNIQUE INDEX `uni_exec_key`(`exec_key`) USING BTREE
+    UNIQUE INDEX `uni_exec_key`(`exec_key`) USING BTREE,
+    INDEX           `idx_status`(`status`) USING BTREE,
+    INDEX           `idx_parent_exec_key`(`parent_exec_key`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '工作流执行记录' ROW_FORMAT = DYNAMIC;
 
 -- ----------------------------
@@ -1077,10 +1115,29 @@ CREATE TABLE `tb_workflow_exec_step`
     `tokens`     bigint UNSIGNED NULL DEFAULT NULL COMMENT '消耗总token',
     `status`     int                                                           NOT NULL DEFAULT 0 COMMENT '数据状态',
     `error_info` text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '错误信息',
+    `attempt`    int                                                           NOT NULL DEFAULT 1 COMMENT '第几次尝试',
+    `logs`       text CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL COMMENT '节点运行日志',
     PRIMARY KEY (`id`) USING BTREE,
-    UNIQUE INDEX `uni_exec`(`exec_key`) USING BTREE,
+    INDEX        `idx_exec_key`(`exec_key`) USING BTREE,
     INDEX        `idx_record_id`(`record_id`) USING BTREE
 ) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '执行记录步骤' ROW_FORMAT = DYNAMIC;
 
+-- ----------------------------
+-- Table structure for tb_workflow_version
+-- ----------------------------
+DROP TABLE IF EXISTS `tb_workflow_version`;
+CREATE TABLE `tb_workflow_version`
+(
+    `id`          bigint UNSIGNED NOT NULL COMMENT '主键',
+    `workflow_id` bigint UNSIGNED NOT NULL COMMENT '工作流ID',
+    `version`     int                                                           NOT NULL COMMENT '版本号',
+    `content`     longtext CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL COMMENT '发布时的工作流 JSON 内容',
+    `comment`     varchar(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NULL DEFAULT NULL COMMENT '发布说明',
+    `created`     datetime                                                      NOT NULL COMMENT '发布时间',
+    `created_by`  bigint UNSIGNED NOT NULL COMMENT '发布人',
+    PRIMARY KEY (`id`) USING BTREE,
+    UNIQUE INDEX `uni_workflow_version`(`workflow_id`, `version`) USING BTREE
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_0900_ai_ci COMMENT = '工作流发布版本' ROW_FORMAT = DYNAMIC;
+
 SET
 FOREIGN_KEY_CHECKS = 1;